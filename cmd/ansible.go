@@ -0,0 +1,426 @@
+/*
+Copyright © 2024 Isaac Fei
+*/
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/Isaac-Fate/myst/internal/ansiblevault"
+	"github.com/Isaac-Fate/myst/internal/models"
+	"github.com/Isaac-Fate/myst/internal/session"
+	"github.com/spf13/cobra"
+)
+
+// The name Ansible runs the vault password client by. Ansible only passes
+// --vault-id to scripts whose names end with "-client".
+const ansibleVaultClientBinary string = "myst-vault-client"
+
+// Ansible exits with this code from a vault password client when it has no
+// password for the vault ID.
+const ansibleUnknownVaultIDCode int = 2
+
+var ansibleVaultClientCmd = &cobra.Command{
+	Use:   "ansible-vault-client",
+	Short: "Act as an Ansible vault password client",
+	Long: `Print the password of an Ansible vault ID, held by the secret with the
+key ansible/<vault-id>, for ansible and ansible-vault.
+
+Ansible passes --vault-id only to scripts whose names end with "-client",
+so link myst as myst-vault-client somewhere on your PATH:
+
+  ln -s "$(command -v myst)" ~/.local/bin/myst-vault-client
+  ansible-playbook site.yml --vault-id prod@myst-vault-client
+
+Without $MYST_SERVER, the passphrase is asked for on the terminal; to avoid
+that, keep 'myst serve' running and set $MYST_SERVER and $MYST_TOKEN or
+$MYST_TOKEN_FILE.`,
+	Args:          cobra.NoArgs,
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	Run: func(cmd *cobra.Command, args []string) {
+		id, _ := cmd.Flags().GetString("vault-id")
+
+		// Keep errors out of the output Ansible reads
+		runHelper(os.Stderr, func() error {
+			s, err := openSession(cmd, "ansible-vault-client")
+			if err != nil {
+				return err
+			}
+			defer s.Close()
+
+			password, err := ansibleVaultPassword(context.Background(), s, id)
+			if errors.Is(err, models.ErrSecretNotFound) {
+				// Tell Ansible the vault ID is unknown, rather than that
+				// the client failed
+				return &helperExitError{err: err, code: ansibleUnknownVaultIDCode}
+			}
+			if err != nil {
+				return err
+			}
+
+			_, err = fmt.Fprintln(cmd.OutOrStdout(), password)
+			return err
+		})
+	},
+}
+
+var ansibleCmd = &cobra.Command{
+	Use:   "ansible",
+	Short: "Read and write ansible-vault files",
+	Long: `Read and write files encrypted by ansible-vault, with the passwords of
+vault IDs held by the secrets with the keys ansible/<vault-id>.
+
+Files encrypted for a vault ID are decrypted with its password, and others
+with the password of --vault-id, "default" if not given.`,
+}
+
+var ansibleViewCmd = &cobra.Command{
+	Use:   "view <file...>",
+	Short: "Print the decrypted contents of ansible-vault files",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return withAnsiblePasswords(cmd, "ansible view", func(passwords *ansiblePasswords) error {
+			for _, path := range args {
+				plaintext, err := decryptAnsibleFile(passwords, path)
+				if err != nil {
+					return err
+				}
+
+				if _, err := cmd.OutOrStdout().Write(plaintext); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+	},
+}
+
+var ansibleDecryptCmd = &cobra.Command{
+	Use:   "decrypt <file...>",
+	Short: "Decrypt ansible-vault files in place",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		output, _ := cmd.Flags().GetString("output")
+		if output != "" && len(args) > 1 {
+			return errors.New("--output can only be used with a single file")
+		}
+
+		return withAnsiblePasswords(cmd, "ansible decrypt", func(passwords *ansiblePasswords) error {
+			for _, path := range args {
+				plaintext, err := decryptAnsibleFile(passwords, path)
+				if err != nil {
+					return err
+				}
+
+				if err := writeAnsibleOutput(cmd, path, output, plaintext); err != nil {
+					return err
+				}
+			}
+
+			if output != "-" {
+				fmt.Fprintf(os.Stderr, "✅ Decrypted %d files\n", len(args))
+			}
+			return nil
+		})
+	},
+}
+
+var ansibleEncryptCmd = &cobra.Command{
+	Use:   "encrypt <file...>",
+	Short: "Encrypt files in place for ansible-vault",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, _ := cmd.Flags().GetString("vault-id")
+		output, _ := cmd.Flags().GetString("output")
+		if output != "" && len(args) > 1 {
+			return errors.New("--output can only be used with a single file")
+		}
+
+		return withAnsiblePasswords(cmd, "ansible encrypt", func(passwords *ansiblePasswords) error {
+			for _, path := range args {
+				plaintext, err := os.ReadFile(path)
+				if err != nil {
+					return fmt.Errorf("failed to read %s: %w", path, err)
+				}
+
+				if ansiblevault.IsEncrypted(plaintext) {
+					return fmt.Errorf("%s is already encrypted", path)
+				}
+
+				data, err := encryptAnsible(passwords, plaintext, id)
+				if err != nil {
+					return err
+				}
+
+				if err := writeAnsibleOutput(cmd, path, output, data); err != nil {
+					return err
+				}
+			}
+
+			if output != "-" {
+				fmt.Fprintf(os.Stderr, "✅ Encrypted %d files\n", len(args))
+			}
+			return nil
+		})
+	},
+}
+
+var ansibleEditCmd = &cobra.Command{
+	Use:   "edit <file>",
+	Short: "Edit an ansible-vault file in $EDITOR",
+	Long: `Decrypt an ansible-vault file into a temporary file only you can read,
+open it in $EDITOR, and encrypt the file again for the same vault ID if it
+was changed.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := args[0]
+
+		return withAnsiblePasswords(cmd, "ansible edit", func(passwords *ansiblePasswords) error {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", path, err)
+			}
+
+			header, err := ansiblevault.ParseHeader(data)
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+
+			plaintext, err := decryptAnsibleFile(passwords, path)
+			if err != nil {
+				return err
+			}
+
+			edited, err := editInEditor(filepath.Base(path), plaintext)
+			if err != nil {
+				return err
+			}
+
+			if bytes.Equal(edited, plaintext) {
+				fmt.Fprintln(os.Stderr, "No changes made")
+				return nil
+			}
+
+			// Keep the header the file had
+			password, err := passwords.get(header.ID)
+			if err != nil {
+				return err
+			}
+
+			data, err = ansiblevault.Encrypt(edited, password, header.ID)
+			if err != nil {
+				return err
+			}
+
+			if err := writeAnsibleOutput(cmd, path, "", data); err != nil {
+				return err
+			}
+
+			fmt.Fprintf(os.Stderr, "✅ Saved %s\n", path)
+			return nil
+		})
+	},
+}
+
+// Reports whether the program was run as the Ansible vault password client.
+func runAsAnsibleVaultClient() bool {
+	name := strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe")
+	return name == ansibleVaultClientBinary
+}
+
+// Returns the key of the secret holding the password of a vault ID.
+func ansibleVaultKey(id string) string {
+	if id == "" {
+		id = ansiblevault.DefaultID
+	}
+
+	return "ansible/" + id
+}
+
+// Reads the password of a vault ID. Line breaks around it are dropped, as
+// Ansible does with the output of password clients.
+func ansibleVaultPassword(ctx context.Context, s session.Session, id string) (string, error) {
+	key := ansibleVaultKey(id)
+
+	_, value, err := s.Get(ctx, key)
+	if errors.Is(err, models.ErrSecretNotFound) {
+		return "", fmt.Errorf("no password for vault ID '%s', add it as the secret '%s': %w", id, key, err)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Trim(value, "\r\n"), nil
+}
+
+// The passwords of vault IDs read so far, so that each is read once.
+type ansiblePasswords struct {
+	ctx       context.Context
+	session   session.Session
+	defaultID string
+	passwords map[string]string
+}
+
+// Returns the password of the vault ID, or of the default one if empty.
+func (p *ansiblePasswords) get(id string) (string, error) {
+	if id == "" {
+		id = p.defaultID
+	}
+
+	if password, ok := p.passwords[id]; ok {
+		return password, nil
+	}
+
+	password, err := ansibleVaultPassword(p.ctx, p.session, id)
+	if err != nil {
+		return "", err
+	}
+
+	p.passwords[id] = password
+	return password, nil
+}
+
+// Opens a session and runs fn with the passwords read through it.
+func withAnsiblePasswords(cmd *cobra.Command, details string, fn func(*ansiblePasswords) error) error {
+	id, _ := cmd.Flags().GetString("vault-id")
+
+	s, err := openSession(cmd, details)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	return fn(&ansiblePasswords{
+		ctx:       context.Background(),
+		session:   s,
+		defaultID: id,
+		passwords: map[string]string{},
+	})
+}
+
+// Decrypts a file with the password of the vault ID in its header.
+func decryptAnsibleFile(passwords *ansiblePasswords, path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	header, err := ansiblevault.ParseHeader(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	password, err := passwords.get(header.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := ansiblevault.Decrypt(data, password)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return plaintext, nil
+}
+
+// Encrypts the plaintext for the vault ID, or the default one if empty.
+func encryptAnsible(passwords *ansiblePasswords, plaintext []byte, id string) ([]byte, error) {
+	if id == "" {
+		id = passwords.defaultID
+	}
+
+	password, err := passwords.get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return ansiblevault.Encrypt(plaintext, password, id)
+}
+
+// Writes the data over the file at path keeping its permissions, or to the
+// output if given, where "-" is stdout.
+func writeAnsibleOutput(cmd *cobra.Command, path string, output string, data []byte) error {
+	if output == "-" {
+		_, err := cmd.OutOrStdout().Write(data)
+		return err
+	}
+
+	mode := os.FileMode(0600)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	if output == "" {
+		output = path
+	}
+
+	if err := os.WriteFile(output, data, mode); err != nil {
+		return fmt.Errorf("failed to write %s: %w", output, err)
+	}
+
+	return nil
+}
+
+// Opens the content in $EDITOR in a temporary file, returning the content
+// it was saved with. The file is removed afterwards.
+func editInEditor(name string, content []byte) ([]byte, error) {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	dir, err := os.MkdirTemp("", "myst-edit-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, content, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write temporary file: %w", err)
+	}
+
+	// Run through the shell, as editors are often given with arguments
+	editorCmd := exec.Command("sh", "-c", editor+` "$1"`, "sh", path)
+	editorCmd.Stdin = os.Stdin
+	editorCmd.Stdout = os.Stdout
+	editorCmd.Stderr = os.Stderr
+	if err := editorCmd.Run(); err != nil {
+		return nil, fmt.Errorf("editor failed: %w", err)
+	}
+
+	edited, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read temporary file: %w", err)
+	}
+
+	return edited, nil
+}
+
+func init() {
+	ansibleVaultClientCmd.Flags().String("vault-id", ansiblevault.DefaultID, "vault ID to print the password of")
+	addSessionFlags(ansibleVaultClientCmd)
+
+	ansibleCmd.PersistentFlags().String("vault-id", ansiblevault.DefaultID, "vault ID whose password to use")
+	ansibleDecryptCmd.Flags().String("output", "", "file to write to instead, or - for stdout")
+	ansibleEncryptCmd.Flags().String("output", "", "file to write to instead, or - for stdout")
+
+	for _, cmd := range []*cobra.Command{ansibleViewCmd, ansibleDecryptCmd, ansibleEncryptCmd, ansibleEditCmd} {
+		addSessionFlags(cmd)
+	}
+
+	ansibleCmd.AddCommand(ansibleViewCmd, ansibleDecryptCmd, ansibleEncryptCmd, ansibleEditCmd)
+	rootCmd.AddCommand(ansibleVaultClientCmd, ansibleCmd)
+}
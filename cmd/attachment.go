@@ -0,0 +1,283 @@
+/*
+Copyright © 2024 Isaac Fei
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/Isaac-Fate/myst/internal/attachment"
+	"github.com/Isaac-Fate/myst/internal/audit"
+	"github.com/Isaac-Fate/myst/internal/models"
+	"github.com/Isaac-Fate/myst/internal/utils"
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/cobra"
+)
+
+var attachCmd = &cobra.Command{
+	Use:   "attach <key> <file>",
+	Short: "Keep an encrypted copy of a file with a secret",
+	Long: `Keep an encrypted copy of a file, such as a certificate, a keystore or a
+kubeconfig, with the secret with the key. The file is encrypted as it is
+read, so large files are never held in memory as a whole.
+
+Attachments are named after the file unless given --name, and are at most
+64 MiB unless max_attachment_size is set in the config, such as to
+"256MiB". They are synced with their secrets.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		key, path := args[0], args[1]
+		name, _ := cmd.Flags().GetString("name")
+		replace, _ := cmd.Flags().GetBool("replace")
+
+		if name == "" {
+			name = filepath.Base(path)
+		}
+		if err := attachment.ValidateName(name); err != nil {
+			return err
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open the file: %w", err)
+		}
+		defer file.Close()
+
+		info, err := file.Stat()
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return fmt.Errorf("%s is not a regular file", path)
+		}
+
+		if err := unlock(); err != nil {
+			return err
+		}
+		defer appContext.SecretManager.Close()
+
+		limit, err := appContext.Config.AttachmentSizeLimit()
+		if err != nil {
+			return err
+		}
+
+		// Fail early on files known to be too large; the limit is enforced
+		// while reading anyway
+		if info.Size() > limit {
+			return fmt.Errorf("%w: %s is %s, the limit is %s", attachment.ErrTooLarge, path, utils.FormatSize(info.Size()), utils.FormatSize(limit))
+		}
+
+		secret, err := findSecretByKey(key)
+		if err != nil {
+			return err
+		}
+
+		existing, err := secret.Attachment(name)
+		if err == nil && !replace {
+			return fmt.Errorf("%w: '%s', pass --replace to replace it", models.ErrDuplicateAttachment, name)
+		}
+
+		store := appContext.SecretManager.Attachments()
+
+		added, err := store.Add(appContext.Passphrase, name, file, limit)
+		if err != nil {
+			return err
+		}
+
+		var replaced []models.Attachment
+		if existing != nil {
+			replaced = append(replaced, *existing)
+			secret.Attachments = slices.DeleteFunc(secret.Attachments, func(a models.Attachment) bool {
+				return a.Name == name
+			})
+		}
+		secret.Attachments = append(secret.Attachments, *added)
+
+		if err := appContext.SecretManager.UpdateSecret(secret); err != nil {
+			store.Remove(*added)
+			return fmt.Errorf("failed to update secret: %w", err)
+		}
+
+		if err := store.Remove(replaced...); err != nil {
+			return fmt.Errorf("failed to delete the replaced attachment: %w", err)
+		}
+
+		fmt.Printf("📎 Attached %s (%s) to '%s'\n", added.Name, utils.FormatSize(added.Size), secret.Key)
+		return nil
+	},
+}
+
+var attachmentCmd = &cobra.Command{
+	Use:   "attachment",
+	Short: "List, save and remove the files attached to secrets",
+}
+
+var attachmentListCmd = &cobra.Command{
+	Use:   "list <key>",
+	Short: "List the files attached to a secret",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := unlock(); err != nil {
+			return err
+		}
+		defer appContext.SecretManager.Close()
+
+		secret, err := findSecretByKey(args[0])
+		if err != nil {
+			return err
+		}
+
+		if len(secret.Attachments) == 0 {
+			fmt.Printf("No files attached to '%s'\n", secret.Key)
+			return nil
+		}
+
+		for _, a := range secret.Attachments {
+			fmt.Printf("📎 %s  %s, added %s\n", a.Name, utils.FormatSize(a.Size), a.AddedAt.Format("2006-01-02"))
+		}
+
+		return nil
+	},
+}
+
+var attachmentGetCmd = &cobra.Command{
+	Use:   "get <key> <name>",
+	Short: "Save a file attached to a secret",
+	Long: `Decrypt a file attached to a secret into a file only you can read, named
+after the attachment in the current directory unless given --output. With
+--output -, the file is written to stdout and the passphrase is asked for on
+the terminal.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		output, _ := cmd.Flags().GetString("output")
+		force, _ := cmd.Flags().GetBool("force")
+
+		if output == "" {
+			output = args[1]
+		}
+
+		if output != "-" && !force {
+			if _, err := os.Stat(output); err == nil {
+				return fmt.Errorf("%s already exists, pass --force to replace it", output)
+			}
+		}
+
+		// Keep the prompt out of the contents written to stdout
+		unlockVault := unlock
+		if output == "-" {
+			unlockVault = unlockOnTerminal
+		}
+
+		if err := unlockVault(); err != nil {
+			return err
+		}
+		defer appContext.SecretManager.Close()
+
+		secret, err := findSecretByKey(args[0])
+		if err != nil {
+			return err
+		}
+
+		a, err := secret.Attachment(args[1])
+		if errors.Is(err, models.ErrAttachmentNotFound) {
+			return fmt.Errorf("'%s' has no attachment named '%s'", secret.Key, args[1])
+		}
+
+		// Record the export before the contents leave the vault
+		if err := appContext.SecretManager.RecordAccess(audit.ActionExport, secret, "attachment "+a.Name); err != nil {
+			return err
+		}
+
+		store := appContext.SecretManager.Attachments()
+
+		if output == "-" {
+			_, err := store.Open(appContext.Passphrase, a, os.Stdout)
+			return err
+		}
+
+		if err := store.Save(appContext.Passphrase, a, output); err != nil {
+			return err
+		}
+
+		fmt.Printf("✅ Saved %s to %s\n", a.Name, output)
+		return nil
+	},
+}
+
+var attachmentRemoveCmd = &cobra.Command{
+	Use:   "remove <key> <name...>",
+	Short: "Delete files attached to a secret",
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		yes, _ := cmd.Flags().GetBool("yes")
+
+		if err := unlock(); err != nil {
+			return err
+		}
+		defer appContext.SecretManager.Close()
+
+		secret, err := findSecretByKey(args[0])
+		if err != nil {
+			return err
+		}
+
+		var removed []models.Attachment
+		for _, name := range args[1:] {
+			a, err := secret.Attachment(name)
+			if err != nil {
+				return fmt.Errorf("'%s' has no attachment named '%s'", secret.Key, name)
+			}
+			removed = append(removed, *a)
+		}
+
+		if !yes {
+			names := make([]string, len(removed))
+			for i, a := range removed {
+				names[i] = a.Name
+			}
+
+			// Attachments do not go to the trash
+			confirmPrompt := promptui.Prompt{
+				Label:     fmt.Sprintf("Delete %s for good", strings.Join(names, ", ")),
+				IsConfirm: true,
+			}
+
+			if _, err := confirmPrompt.Run(); err != nil {
+				return nil // User cancelled
+			}
+		}
+
+		secret.Attachments = slices.DeleteFunc(secret.Attachments, func(a models.Attachment) bool {
+			return slices.ContainsFunc(removed, func(r models.Attachment) bool { return r.ID == a.ID })
+		})
+
+		if err := appContext.SecretManager.UpdateSecret(secret); err != nil {
+			return fmt.Errorf("failed to update secret: %w", err)
+		}
+
+		if err := appContext.SecretManager.Attachments().Remove(removed...); err != nil {
+			return fmt.Errorf("failed to delete the attachments: %w", err)
+		}
+
+		fmt.Printf("✅ Deleted %d attachments of '%s'\n", len(removed), secret.Key)
+		return nil
+	},
+}
+
+func init() {
+	attachCmd.Flags().String("name", "", "name of the attachment, the file name by default")
+	attachCmd.Flags().Bool("replace", false, "replace an attachment with the same name")
+
+	attachmentGetCmd.Flags().StringP("output", "o", "", "file to save to, or - for stdout")
+	attachmentGetCmd.Flags().BoolP("force", "f", false, "replace an existing file")
+
+	attachmentRemoveCmd.Flags().BoolP("yes", "y", false, "do not ask for confirmation")
+
+	attachmentCmd.AddCommand(attachmentListCmd, attachmentGetCmd, attachmentRemoveCmd)
+	rootCmd.AddCommand(attachCmd, attachmentCmd)
+}
@@ -0,0 +1,172 @@
+/*
+Copyright © 2024 Isaac Fei
+*/
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/Isaac-Fate/myst/internal/audit"
+	"github.com/Isaac-Fate/myst/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Show the audit log of changes to and accesses of secrets",
+	Long: `Show the audit log of changes to and accesses of secrets.
+
+Every add, update, remove, restore, purge, reveal, copy, export and sign
+is recorded with its time, the secret and who did it. Events are chained
+with a key only your master passphrase unlocks, so editing, deleting or
+inserting one is detected by 'myst audit verify'. Deleting the most recent
+events is not.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		actions, _ := cmd.Flags().GetStringSlice("action")
+		key, _ := cmd.Flags().GetString("secret")
+		sinceFlag, _ := cmd.Flags().GetString("since")
+		limit, _ := cmd.Flags().GetInt("limit")
+
+		var since time.Time
+		if sinceFlag != "" {
+			var err error
+			since, err = parseSince(sinceFlag)
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := unlock(); err != nil {
+			return err
+		}
+		defer appContext.SecretManager.Close()
+
+		// Map secret IDs to keys, since the log only holds IDs
+		secrets, err := appContext.SecretManager.ListSecrets()
+		if err != nil {
+			return err
+		}
+
+		trash, err := appContext.SecretManager.ListTrash()
+		if err != nil {
+			return err
+		}
+		secrets = append(secrets, trash...)
+
+		keys := make(map[string]string, len(secrets))
+		secretId := key
+		for _, secret := range secrets {
+			keys[secret.ID.String()] = secret.Key
+			if key != "" && secret.Key == key {
+				secretId = secret.ID.String()
+			}
+		}
+
+		auditLog, err := openAuditLog()
+		if err != nil {
+			return err
+		}
+
+		events, err := auditLog.Events()
+		if err != nil {
+			return err
+		}
+
+		// Filter the events
+		var matched []audit.Event
+		for _, event := range events {
+			if len(actions) > 0 && !slices.Contains(actions, string(event.Action)) {
+				continue
+			}
+			if secretId != "" && event.SecretId != secretId {
+				continue
+			}
+			if event.Time.Before(since) {
+				continue
+			}
+
+			matched = append(matched, event)
+		}
+
+		// Keep the most recent events
+		if limit > 0 && len(matched) > limit {
+			matched = matched[len(matched)-limit:]
+		}
+
+		if len(matched) == 0 {
+			fmt.Println("No events found")
+			return nil
+		}
+
+		for _, event := range matched {
+			// Purged secrets are shown by their ID
+			secret := keys[event.SecretId]
+			if secret == "" {
+				secret = event.SecretId
+			}
+
+			line := fmt.Sprintf("%5d  %s  %-7s %s", event.Sequence, event.Time.Local().Format("2006-01-02 15:04:05"), event.Action, event.Actor)
+			if secret != "" {
+				line += "  🔑 " + secret
+			}
+			if event.Details != "" {
+				line += "  (" + event.Details + ")"
+			}
+
+			fmt.Println(line)
+		}
+
+		return nil
+	},
+}
+
+var auditVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check that no event has been modified or removed",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := unlockConfig(); err != nil {
+			return err
+		}
+
+		auditLog, err := openAuditLog()
+		if err != nil {
+			return err
+		}
+
+		count, err := auditLog.Verify()
+		if err != nil {
+			return fmt.Errorf("❌ %w (the first %d events are intact)", err, count)
+		}
+
+		fmt.Printf("✅ Audit log intact, %d events verified\n", count)
+		return nil
+	},
+}
+
+func init() {
+	auditCmd.Flags().StringSlice("action", nil, "only show these actions (add, update, remove, restore, purge, import, reveal, copy, export, sign, rekey)")
+	auditCmd.Flags().String("secret", "", "only show events of the secret with this key or ID")
+	auditCmd.Flags().String("since", "", "only show events after a date (2006-01-02) or within a duration (24h, 7d)")
+	auditCmd.Flags().Int("limit", 0, "only show the most recent events")
+
+	auditCmd.AddCommand(auditVerifyCmd)
+	rootCmd.AddCommand(auditCmd)
+}
+
+// Parses a date, or a duration before now such as "24h" or "7d".
+func parseSince(value string) (time.Time, error) {
+	if date, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return date, nil
+	}
+
+	duration, err := utils.ParseDuration(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date or duration '%s'", value)
+	}
+
+	return time.Now().Add(-duration), nil
+}
@@ -0,0 +1,75 @@
+/*
+Copyright © 2024 Isaac Fei
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Isaac-Fate/myst/internal/awscred"
+	"github.com/Isaac-Fate/myst/internal/models"
+	"github.com/spf13/cobra"
+)
+
+var awsCredentialProcessCmd = &cobra.Command{
+	Use:   "aws-credential-process <key>",
+	Short: "Print AWS credentials for the SDKs' credential_process",
+	Long: `Print the AWS credentials held by a secret in the JSON format the AWS
+SDKs and CLI expect from a credential_process, so that access keys never
+touch ~/.aws/credentials:
+
+  # ~/.aws/config
+  [profile prod-admin]
+  credential_process = myst aws-credential-process prod-admin
+
+The secret holds the access key ID as its username and the secret access
+key as its value, and its expiry date, if any, is the expiration. It may
+instead hold JSON with AccessKeyId, SecretAccessKey, SessionToken and
+Expiration, such as the output of 'aws sts get-session-token'.
+
+Without $MYST_SERVER, the passphrase is asked for on the terminal; to avoid
+that, keep 'myst serve' running and set $MYST_SERVER and $MYST_TOKEN or
+$MYST_TOKEN_FILE.`,
+	Args:          cobra.ExactArgs(1),
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Keep errors out of the output the SDKs read
+		runHelper(os.Stderr, func() error {
+			s, err := openSession(cmd, "aws-credential-process")
+			if err != nil {
+				return err
+			}
+			defer s.Close()
+
+			secret, value, err := s.Get(context.Background(), args[0])
+			if errors.Is(err, models.ErrSecretNotFound) {
+				return fmt.Errorf("secret with key '%s' not found", args[0])
+			}
+			if err != nil {
+				return err
+			}
+
+			credentials, err := awscred.FromSecret(secret, value)
+			if err != nil {
+				return err
+			}
+
+			if err := credentials.Check(time.Now()); err != nil {
+				return fmt.Errorf("secret '%s': %w", args[0], err)
+			}
+
+			return credentials.Write(cmd.OutOrStdout())
+		})
+	},
+}
+
+func init() {
+	addSessionFlags(awsCredentialProcessCmd)
+
+	rootCmd.AddCommand(awsCredentialProcessCmd)
+}
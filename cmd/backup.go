@@ -0,0 +1,95 @@
+/*
+Copyright © 2024 Isaac Fei
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Back up the secrets and their attachments to a file, or restore them",
+}
+
+var backupCreateCmd = &cobra.Command{
+	Use:   "create <file>",
+	Short: "Back up the secrets and their attachments to a file",
+	Long: `Back up the secrets and the files attached to them to a single file only
+you can read. The secrets are encrypted with your master passphrase, and the
+attachments are copied as they are kept in the vault, already encrypted.
+Secrets in the trash are left out.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		force, _ := cmd.Flags().GetBool("force")
+
+		if !force {
+			if _, err := os.Stat(args[0]); err == nil {
+				return fmt.Errorf("%s already exists, pass --force to replace it", args[0])
+			}
+		}
+
+		if err := unlock(); err != nil {
+			return err
+		}
+		defer appContext.SecretManager.Close()
+
+		file, err := os.OpenFile(args[0], os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+		if err != nil {
+			return err
+		}
+
+		count, err := appContext.SecretManager.Backup(file, appContext.Passphrase)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			// Leave no partial backup behind
+			os.Remove(args[0])
+			return fmt.Errorf("failed to back up the vault: %w", err)
+		}
+
+		fmt.Printf("✅ Backed up %d secrets to %s\n", count, args[0])
+		return nil
+	},
+}
+
+var backupRestoreCmd = &cobra.Command{
+	Use:   "restore <file>",
+	Short: "Restore the secrets missing from the vault from a backup",
+	Long: `Restore the secrets of a backup that are not in the vault, along with the
+files attached to them. Secrets already in the vault are left as they are.
+
+The backup must have been made with the current master passphrase.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		if err := unlock(); err != nil {
+			return err
+		}
+		defer appContext.SecretManager.Close()
+
+		count, err := appContext.SecretManager.RestoreBackup(file, appContext.Passphrase)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("✅ Restored %d secrets\n", count)
+		return nil
+	},
+}
+
+func init() {
+	backupCreateCmd.Flags().BoolP("force", "f", false, "replace an existing file")
+
+	backupCmd.AddCommand(backupCreateCmd, backupRestoreCmd)
+	rootCmd.AddCommand(backupCmd)
+}
@@ -0,0 +1,101 @@
+/*
+Copyright © 2024 Isaac Fei
+*/
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/Isaac-Fate/myst/internal/breach"
+	"github.com/Isaac-Fate/myst/internal/config"
+	mycrypto "github.com/Isaac-Fate/myst/internal/crypto"
+	"github.com/Isaac-Fate/myst/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var breachCheckCmd = &cobra.Command{
+	Use:   "breach-check",
+	Short: "Check values against a local copy of the Pwned Passwords list",
+	Long: `Check values against a local copy of the Pwned Passwords list.
+
+The list is either the single file of SHA-1 hashes ordered by hash, or a
+directory of range files named after the first 5 characters of the hashes,
+as downloaded by the official downloader. Values are hashed in memory and
+looked up in the list on disk, without any network access.
+
+With --remember, the list is saved in the configuration and values are
+also checked as they are entered in 'add' and 'update'.
+
+  myst breach-check --hibp-file ~/pwned/pwnedpasswords.txt --remember`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, _ := cmd.Flags().GetString("hibp-file")
+		remember, _ := cmd.Flags().GetBool("remember")
+
+		if err := unlock(); err != nil {
+			return err
+		}
+		defer appContext.SecretManager.Close()
+
+		if path == "" {
+			path = appContext.Config.HIBPFile
+		}
+		if path == "" {
+			return fmt.Errorf("no Pwned Passwords list given, use --hibp-file")
+		}
+
+		path, err := utils.ResolvePath(path)
+		if err != nil {
+			return err
+		}
+
+		list, err := breach.Open(path)
+		if err != nil {
+			return err
+		}
+
+		if remember && path != appContext.Config.HIBPFile {
+			appContext.Config.HIBPFile = path
+			if err := config.Save(&appContext.Config); err != nil {
+				return err
+			}
+		}
+
+		secrets, err := appContext.SecretManager.ListSecrets()
+		if err != nil {
+			return err
+		}
+
+		breached := 0
+		for _, secret := range secrets {
+			value, err := mycrypto.Decrypt(appContext.Passphrase, secret.EncryptedValue)
+			if err != nil {
+				return fmt.Errorf("failed to decrypt secret '%s': %w", secret.Key, err)
+			}
+
+			count, err := list.Count(value)
+			if err != nil {
+				return fmt.Errorf("failed to check secret '%s': %w", secret.Key, err)
+			}
+
+			if count > 0 {
+				fmt.Printf("🚨 %s  seen %d times in breaches\n", secret.Key, count)
+				breached++
+			}
+		}
+
+		if breached == 0 {
+			fmt.Printf("✅ None of %d secrets is in the list\n", len(secrets))
+			return nil
+		}
+
+		return fmt.Errorf("%d of %d secrets are in the list, change them", breached, len(secrets))
+	},
+}
+
+func init() {
+	breachCheckCmd.Flags().String("hibp-file", "", "the Pwned Passwords hash file or range directory (default: the remembered one)")
+	breachCheckCmd.Flags().Bool("remember", false, "save the list in the configuration and check new values against it")
+
+	rootCmd.AddCommand(breachCheckCmd)
+}
@@ -0,0 +1,359 @@
+/*
+Copyright © 2024 Isaac Fei
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/Isaac-Fate/myst/cmd/handlers"
+	"github.com/Isaac-Fate/myst/internal/manager"
+	"github.com/Isaac-Fate/myst/internal/models"
+	"github.com/spf13/cobra"
+)
+
+// Commands acting on several secrets at once. The secrets are given by key,
+// by a search query, or both, and are picked interactively if neither is
+// given. The affected secrets are listed and the user confirms by typing how
+// many there are, unless --yes is given. All changes are made in a single
+// transaction.
+
+var rmCmd = &cobra.Command{
+	Use:     "rm [key...]",
+	Aliases: []string{"remove"},
+	Short:   "Remove secrets by key or query",
+	Long: `Remove secrets by key or query.
+
+Removed secrets are moved to the trash, from which 'myst restore' brings
+them back until they are purged.
+
+  myst rm old-token
+  myst rm --query 'tag:old' --yes`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := unlock(); err != nil {
+			return err
+		}
+		defer appContext.SecretManager.Close()
+
+		secrets, err := selectSecrets(cmd, args, "Select the secrets to remove")
+		if err != nil || len(secrets) == 0 {
+			return err
+		}
+
+		if confirmed, err := confirmBulk(cmd, "remove", secrets); err != nil || !confirmed {
+			return err
+		}
+
+		if err := appContext.SecretManager.RemoveSecrets(secrets); err != nil {
+			return fmt.Errorf("failed to remove secrets: %w", err)
+		}
+
+		fmt.Printf("✅ Moved %d secrets to the trash, 'myst trash' lists them\n", len(secrets))
+		return nil
+	},
+}
+
+var tagCmd = &cobra.Command{
+	Use:   "tag",
+	Short: "Add, remove and list tags of secrets",
+	Long: `Add, remove and list tags of secrets.
+
+Tags label secrets for finding and acting on them together, e.g. with
+'myst find tag:prod' or 'myst rm --query tag:old'.`,
+}
+
+var tagAddCmd = &cobra.Command{
+	Use:   "add <tag> [key...]",
+	Short: "Tag secrets by key or query",
+	Long: `Tag secrets by key or query.
+
+  myst tag add prod --query website:aws`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runTagCommand(cmd, args[0], args[1:], true)
+	},
+}
+
+var tagRemoveCmd = &cobra.Command{
+	Use:   "remove <tag> [key...]",
+	Short: "Untag secrets by key or query",
+	Long: `Untag secrets by key or query.
+
+  myst tag remove prod --query 'tag:prod folder:old'`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runTagCommand(cmd, args[0], args[1:], false)
+	},
+}
+
+var tagListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the tags with the number of secrets having each",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := unlock(); err != nil {
+			return err
+		}
+		defer appContext.SecretManager.Close()
+
+		secrets, err := appContext.SecretManager.ListSecrets()
+		if err != nil {
+			return err
+		}
+
+		counts := make(map[string]int)
+		for _, secret := range secrets {
+			for _, tag := range secret.Tags {
+				counts[tag]++
+			}
+		}
+
+		if len(counts) == 0 {
+			fmt.Println("No tags yet")
+			return nil
+		}
+
+		tags := make([]string, 0, len(counts))
+		for tag := range counts {
+			tags = append(tags, tag)
+		}
+		sort.Strings(tags)
+
+		for _, tag := range tags {
+			fmt.Printf("🏷️  %s (%d)\n", tag, counts[tag])
+		}
+
+		return nil
+	},
+}
+
+var mvCmd = &cobra.Command{
+	Use:   "mv [key...] --to <folder>",
+	Short: "Move secrets by key or query to a folder",
+	Long: `Move secrets by key or query to a folder.
+
+  myst mv --query 'key:acme-*' --to clients/acme
+  myst mv old-token --to ""     # out of any folder`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		folder, _ := cmd.Flags().GetString("to")
+
+		if err := unlock(); err != nil {
+			return err
+		}
+		defer appContext.SecretManager.Close()
+
+		secrets, err := selectSecrets(cmd, args, "Select the secrets to move")
+		if err != nil || len(secrets) == 0 {
+			return err
+		}
+
+		// Only change secrets that are elsewhere
+		secrets = slices.DeleteFunc(secrets, func(secret models.Secret) bool {
+			return secret.Folder == folder
+		})
+		if len(secrets) == 0 {
+			fmt.Println("Nothing to move")
+			return nil
+		}
+
+		if confirmed, err := confirmBulk(cmd, "move", secrets); err != nil || !confirmed {
+			return err
+		}
+
+		for i := range secrets {
+			secrets[i].Folder = folder
+		}
+
+		if err := appContext.SecretManager.UpdateSecrets(secrets); err != nil {
+			return fmt.Errorf("failed to move secrets: %w", err)
+		}
+
+		fmt.Printf("✅ Moved %d secrets\n", len(secrets))
+		return nil
+	},
+}
+
+var reencryptCmd = &cobra.Command{
+	Use:   "reencrypt [key...]",
+	Short: "Encrypt the values of secrets again",
+	Long: `Encrypt the values of secrets again, with a fresh salt and nonce.
+
+The passphrase stays the same. Use it after upgrading myst so that old
+values use the current encryption settings.
+
+  myst reencrypt --query 'folder:work' --yes`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := unlock(); err != nil {
+			return err
+		}
+		defer appContext.SecretManager.Close()
+
+		secrets, err := selectSecrets(cmd, args, "Select the secrets to re-encrypt")
+		if err != nil || len(secrets) == 0 {
+			return err
+		}
+
+		if confirmed, err := confirmBulk(cmd, "re-encrypt", secrets); err != nil || !confirmed {
+			return err
+		}
+
+		if err := appContext.SecretManager.ReencryptSecrets(secrets, appContext.Passphrase); err != nil {
+			return fmt.Errorf("failed to re-encrypt secrets: %w", err)
+		}
+
+		fmt.Printf("✅ Re-encrypted %d secrets\n", len(secrets))
+		return nil
+	},
+}
+
+// Adds or removes a tag on the selected secrets.
+func runTagCommand(cmd *cobra.Command, tag string, keys []string, add bool) error {
+	if err := models.ValidateTag(tag); err != nil {
+		return err
+	}
+
+	if err := unlock(); err != nil {
+		return err
+	}
+	defer appContext.SecretManager.Close()
+
+	label := fmt.Sprintf("Select the secrets to tag '%s'", tag)
+	if !add {
+		label = fmt.Sprintf("Select the secrets to untag '%s'", tag)
+	}
+
+	secrets, err := selectSecrets(cmd, keys, label)
+	if err != nil || len(secrets) == 0 {
+		return err
+	}
+
+	// Only change secrets whose tags change
+	secrets = slices.DeleteFunc(secrets, func(secret models.Secret) bool {
+		return secret.HasTag(tag) == add
+	})
+	if len(secrets) == 0 {
+		fmt.Println("Nothing to change")
+		return nil
+	}
+
+	action := "tag"
+	if !add {
+		action = "untag"
+	}
+
+	if confirmed, err := confirmBulk(cmd, action, secrets); err != nil || !confirmed {
+		return err
+	}
+
+	for i := range secrets {
+		if add {
+			secrets[i].AddTag(tag)
+		} else {
+			secrets[i].RemoveTag(tag)
+		}
+	}
+
+	if err := appContext.SecretManager.UpdateSecrets(secrets); err != nil {
+		return fmt.Errorf("failed to %s secrets: %w", action, err)
+	}
+
+	fmt.Printf("✅ %sged %d secrets '%s'\n", strings.ToUpper(action[:1])+action[1:], len(secrets), tag)
+	return nil
+}
+
+// Adds the flags selecting the secrets a bulk command acts on.
+func addSelectionFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP("query", "q", "", "act on the secrets matching the search query")
+	cmd.Flags().BoolP("yes", "y", false, "do not ask for confirmation")
+}
+
+// Returns the secrets with the keys and the secrets matching the query, each
+// once. If neither is given, the user picks the secrets.
+//
+// It returns no secrets and no error if the user picks nothing.
+func selectSecrets(cmd *cobra.Command, keys []string, label string) ([]models.Secret, error) {
+	query, _ := cmd.Flags().GetString("query")
+
+	if len(keys) == 0 && query == "" {
+		secrets, err := appContext.SecretManager.ListSecrets()
+		if err != nil {
+			return nil, err
+		}
+
+		picked, err := handlers.PickSecrets(label, secrets)
+		if errors.Is(err, handlers.ErrNothingPicked) {
+			return nil, nil
+		}
+
+		return picked, err
+	}
+
+	var secrets []models.Secret
+	seen := make(map[string]bool)
+
+	for _, key := range keys {
+		secret, err := handlers.LookupSecret(&appContext, key, manager.MatchExactKey)
+		if err != nil {
+			return nil, err
+		}
+
+		if !seen[secret.ID.String()] {
+			seen[secret.ID.String()] = true
+			secrets = append(secrets, *secret)
+		}
+	}
+
+	if query != "" {
+		matches, err := appContext.SecretManager.FindSecrets(query)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(matches) == 0 {
+			fmt.Printf("No secrets match '%s'\n", query)
+		}
+
+		for _, secret := range matches {
+			if !seen[secret.ID.String()] {
+				seen[secret.ID.String()] = true
+				secrets = append(secrets, secret)
+			}
+		}
+	}
+
+	return secrets, nil
+}
+
+// Lists the secrets an action affects and asks for confirmation, unless
+// --yes was given.
+func confirmBulk(cmd *cobra.Command, action string, secrets []models.Secret) (bool, error) {
+	fmt.Printf("Going to %s %d secrets:\n", action, len(secrets))
+	handlers.PreviewSecrets(secrets)
+
+	if yes, _ := cmd.Flags().GetBool("yes"); yes {
+		return true, nil
+	}
+
+	confirmed, err := handlers.ConfirmCount(action, len(secrets))
+	if err == nil && !confirmed {
+		fmt.Println("Cancelled, nothing was changed")
+	}
+
+	return confirmed, err
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{rmCmd, tagAddCmd, tagRemoveCmd, mvCmd, reencryptCmd} {
+		addSelectionFlags(cmd)
+	}
+
+	mvCmd.Flags().String("to", "", "the folder to move the secrets to, or \"\" for none")
+	mvCmd.MarkFlagRequired("to")
+
+	tagCmd.AddCommand(tagAddCmd, tagRemoveCmd, tagListCmd)
+	rootCmd.AddCommand(rmCmd, tagCmd, mvCmd, reencryptCmd)
+}
@@ -0,0 +1,65 @@
+/*
+Copyright © 2024 Isaac Fei
+*/
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Isaac-Fate/myst/internal/dockercred"
+	"github.com/spf13/cobra"
+)
+
+// The name docker runs the credential helper by, for "credsStore": "myst".
+const dockerCredentialBinary string = "docker-credential-myst"
+
+var dockerCredentialCmd = &cobra.Command{
+	Use:   "docker-credential <store|get|erase|list>",
+	Short: "Act as a docker credential helper",
+	Long: `Act as a docker credential helper, keeping registry passwords in the
+vault instead of in ~/.docker/config.json.
+
+Docker runs the helper as docker-credential-myst, so link it under that
+name somewhere on your PATH and set "credsStore" in ~/.docker/config.json:
+
+  ln -s "$(command -v myst)" ~/.local/bin/docker-credential-myst
+  # ~/.docker/config.json
+  { "credsStore": "myst" }
+
+Credentials are kept in the 'docker' folder, tagged 'docker-credential',
+and are left out of the interactive list. Without $MYST_SERVER, the
+passphrase is asked for on the terminal every time; to avoid that, keep
+'myst serve' running and set $MYST_SERVER and $MYST_TOKEN or
+$MYST_TOKEN_FILE.`,
+	Args:          cobra.ExactArgs(1),
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Docker reads errors from stdout, and recognizes the one for
+		// missing credentials by its message
+		runHelper(os.Stdout, func() error {
+			s, err := openSession(cmd, "docker-credential")
+			if err != nil {
+				return err
+			}
+			defer s.Close()
+
+			return dockercred.Run(context.Background(), args[0], cmd.InOrStdin(), cmd.OutOrStdout(), s)
+		})
+	},
+}
+
+// Reports whether the program was run as the docker credential helper.
+func runAsDockerCredential() bool {
+	name := strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe")
+	return name == dockerCredentialBinary
+}
+
+func init() {
+	addSessionFlags(dockerCredentialCmd)
+
+	rootCmd.AddCommand(dockerCredentialCmd)
+}
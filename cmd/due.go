@@ -0,0 +1,129 @@
+/*
+Copyright © 2024 Isaac Fei
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/Isaac-Fate/myst/cmd/handlers"
+	"github.com/Isaac-Fate/myst/internal/config"
+	"github.com/Isaac-Fate/myst/internal/models"
+	"github.com/Isaac-Fate/myst/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+// A secret needing attention, as reported by 'myst due --output json'.
+type dueSecret struct {
+	Key     string           `json:"key"`
+	Folder  string           `json:"folder,omitempty"`
+	Reason  models.DueReason `json:"reason"`
+	DueAt   time.Time        `json:"due_at"`
+	Overdue bool             `json:"overdue"`
+}
+
+var dueCmd = &cobra.Command{
+	Use:   "due",
+	Short: "List secrets that are expired or due for rotation",
+	Long: `List secrets that are expired or due for rotation, or will be soon.
+
+Secrets expire on the date set when adding or updating them, and are due
+for rotation once the rotation period has passed since their value last changed.
+With the SQLite backend, only metadata is read, so no passphrase is asked
+for and the report can run from cron:
+
+  myst due --within 30d
+  myst due --output json | jq -r '.[] | select(.overdue) | .key'`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		withinFlag, _ := cmd.Flags().GetString("within")
+		output, _ := cmd.Flags().GetString("output")
+
+		within, err := utils.ParseDuration(withinFlag)
+		if err != nil {
+			return err
+		}
+
+		if output != "text" && output != "json" {
+			return fmt.Errorf("unknown output '%s', expected text or json", output)
+		}
+
+		if err := unlockMetadata(); err != nil {
+			return err
+		}
+		defer appContext.SecretManager.Close()
+
+		secrets, err := appContext.SecretManager.ListSecrets()
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		due := []dueSecret{}
+		for i := range secrets {
+			for _, deadline := range secrets[i].DueWithin(now, within) {
+				due = append(due, dueSecret{
+					Key:     secrets[i].Key,
+					Folder:  secrets[i].Folder,
+					Reason:  deadline.Reason,
+					DueAt:   deadline.At,
+					Overdue: deadline.Passed(now),
+				})
+			}
+		}
+
+		slices.SortStableFunc(due, func(a, b dueSecret) int {
+			return a.DueAt.Compare(b.DueAt)
+		})
+
+		if output == "json" {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(due)
+		}
+
+		if len(due) == 0 {
+			fmt.Printf("✅ Nothing is due within %s\n", utils.FormatDuration(within))
+			return nil
+		}
+
+		for _, secret := range due {
+			icon := "⏰"
+			if secret.Overdue {
+				icon = "⚠️ "
+			}
+
+			description := handlers.DescribeDeadline(models.Deadline{Reason: secret.Reason, At: secret.DueAt}, now)
+			fmt.Printf("%s %s  %s (%s)\n", icon, secret.Key, description, secret.DueAt.Local().Format("2006-01-02"))
+		}
+
+		return nil
+	},
+}
+
+// Opens the secret manager to read metadata only. The passphrase is asked
+// for only if the backend keeps metadata encrypted too, like the vault file.
+func unlockMetadata() error {
+	if err := initializeConfig(); err != nil {
+		return err
+	}
+
+	if appContext.Config.StorageBackend() != config.SQLiteBackend {
+		if err := loadPassphrase(); err != nil {
+			return err
+		}
+	}
+
+	return initializeSecretManager()
+}
+
+func init() {
+	dueCmd.Flags().String("within", "14d", "also list deadlines up to this far ahead (24h, 14d)")
+	dueCmd.Flags().StringP("output", "o", "text", "output format: text or json")
+
+	rootCmd.AddCommand(dueCmd)
+}
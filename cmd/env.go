@@ -0,0 +1,318 @@
+/*
+Copyright © 2024 Isaac Fei
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Isaac-Fate/myst/internal/models"
+	"github.com/Isaac-Fate/myst/internal/projectenv"
+	"github.com/Isaac-Fate/myst/internal/session"
+	"github.com/Isaac-Fate/myst/pkg/vault"
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/cobra"
+)
+
+var envCmd = &cobra.Command{
+	Use:   "env",
+	Short: "Print the environment variables of the project",
+	Long: `Print the environment variables declared in the .myst.yml of the current
+directory, or of the closest parent that has one, as commands for the shell
+or as JSON:
+
+  # .myst.yml
+  env:
+    DATABASE_URL: work/db-url
+    GITHUB_TOKEN:
+      key: github-token
+      vault: ~/other-vault
+
+  eval "$(myst env)"
+  myst env --format fish | source
+
+With --check, the secrets are only looked up, and those missing reported.
+Their values are not read, so no access is recorded in the audit log.
+
+To load the variables when entering the project with direnv, add this to
+~/.config/direnv/direnvrc:
+
+  use_myst() {
+    watch_file .myst.yml
+    eval "$(myst env --format bash)"
+  }
+
+and 'use myst' to the .envrc of the project. Without $MYST_SERVER, the
+passphrase is asked for on the terminal; to avoid that, keep 'myst serve'
+running and set $MYST_SERVER and $MYST_TOKEN or $MYST_TOKEN_FILE.`,
+	Args:          cobra.NoArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		format, _ := cmd.Flags().GetString("format")
+		file, _ := cmd.Flags().GetString("file")
+		check, _ := cmd.Flags().GetBool("check")
+
+		// Keep errors out of the output the shell evaluates
+		runHelper(os.Stderr, func() error {
+			manifest, err := loadProjectEnv(file)
+			if err != nil {
+				return err
+			}
+
+			sources := &envSources{
+				cmd:    cmd,
+				vaults: map[string]*vault.Vault{},
+				check:  check,
+				keys:   map[string]map[string]bool{},
+			}
+			defer sources.Close()
+
+			values, missing, err := manifest.Resolve(context.Background(), sources.lookup)
+			if err != nil {
+				return err
+			}
+
+			if check {
+				return reportProjectEnv(manifest, missing)
+			}
+
+			if len(missing) > 0 {
+				return fmt.Errorf("secrets not found for %s", describeMissing(missing))
+			}
+
+			return projectenv.Write(cmd.OutOrStdout(), format, values)
+		})
+	},
+}
+
+// Loads the .myst.yml at path, or the one found from the working directory.
+func loadProjectEnv(path string) (*projectenv.Manifest, error) {
+	if path != "" {
+		return projectenv.Load(path)
+	}
+
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+
+	return projectenv.Find(dir)
+}
+
+// Prints whether the secret of each variable was found.
+func reportProjectEnv(manifest *projectenv.Manifest, missing []projectenv.Variable) error {
+	fmt.Printf("📄 %s\n", manifest.Path)
+
+	for _, variable := range manifest.Variables {
+		status := "✅"
+		for _, m := range missing {
+			if m.Name == variable.Name {
+				status = "❌"
+			}
+		}
+
+		line := fmt.Sprintf("%s %s: %s", status, variable.Name, variable.Key)
+		if variable.Vault != "" {
+			line += " in " + variable.Vault
+		}
+		fmt.Println(line)
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("%d of %d secrets not found", len(missing), len(manifest.Variables))
+	}
+
+	return nil
+}
+
+func describeMissing(missing []projectenv.Variable) string {
+	descriptions := make([]string, len(missing))
+	for i, variable := range missing {
+		descriptions[i] = fmt.Sprintf("%s ('%s')", variable.Name, variable.Key)
+	}
+
+	return strings.Join(descriptions, ", ")
+}
+
+// The vaults values are looked up in, opened when first needed.
+type envSources struct {
+	cmd     *cobra.Command
+	session session.Session
+	vaults  map[string]*vault.Vault
+
+	// Only tells whether the secrets exist, without reading their values or
+	// recording accesses
+	check bool
+
+	// The keys of the secrets in each vault, "" for the session, listed when
+	// first checked
+	keys map[string]map[string]bool
+}
+
+func (sources *envSources) lookup(ctx context.Context, path string, key string) (string, bool, error) {
+	if sources.check {
+		keys, err := sources.listKeys(ctx, path)
+		if err != nil {
+			return "", false, err
+		}
+
+		return "", keys[key], nil
+	}
+
+	if path == "" {
+		s, err := sources.openSession()
+		if err != nil {
+			return "", false, err
+		}
+
+		_, value, err := s.Get(ctx, key)
+		if errors.Is(err, models.ErrSecretNotFound) {
+			return "", false, nil
+		}
+		if err != nil {
+			return "", false, err
+		}
+
+		return value, true, nil
+	}
+
+	v, err := sources.openVault(ctx, path)
+	if err != nil {
+		return "", false, err
+	}
+
+	secret, err := v.Get(ctx, key)
+	if errors.Is(err, vault.ErrNotFound) || errors.Is(err, vault.ErrInTrash) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	return secret.Value, true, nil
+}
+
+// Lists the keys of the secrets in the vault at path, or of the session if
+// path is empty.
+func (sources *envSources) listKeys(ctx context.Context, path string) (map[string]bool, error) {
+	if keys, ok := sources.keys[path]; ok {
+		return keys, nil
+	}
+
+	keys := make(map[string]bool)
+
+	if path == "" {
+		s, err := sources.openSession()
+		if err != nil {
+			return nil, err
+		}
+
+		secrets, err := s.Find(ctx, "")
+		if err != nil {
+			return nil, err
+		}
+
+		for _, secret := range secrets {
+			keys[secret.Key] = true
+		}
+	} else {
+		v, err := sources.openVault(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+
+		secrets, err := v.Search(ctx, "")
+		if err != nil {
+			return nil, err
+		}
+
+		for _, secret := range secrets {
+			keys[secret.Key] = true
+		}
+	}
+
+	sources.keys[path] = keys
+	return keys, nil
+}
+
+func (sources *envSources) openSession() (session.Session, error) {
+	if sources.session == nil {
+		s, err := openSession(sources.cmd, "env")
+		if err != nil {
+			return nil, err
+		}
+		sources.session = s
+	}
+
+	return sources.session, nil
+}
+
+func (sources *envSources) openVault(ctx context.Context, path string) (*vault.Vault, error) {
+	if v, ok := sources.vaults[path]; ok {
+		return v, nil
+	}
+
+	v, err := vault.OpenContext(ctx, path, vault.UnlockerFunc(func(ctx context.Context) (string, error) {
+		return promptVaultPassphrase(path)
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the vault %s: %w", path, err)
+	}
+	sources.vaults[path] = v
+
+	return v, nil
+}
+
+func (sources *envSources) Close() {
+	if sources.session != nil {
+		sources.session.Close()
+	}
+	for _, v := range sources.vaults {
+		v.Close()
+	}
+}
+
+// Asks for the passphrase of another vault on the terminal.
+func promptVaultPassphrase(path string) (string, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return "", fmt.Errorf("failed to open the terminal to ask for the passphrase: %w", err)
+	}
+	defer tty.Close()
+
+	passphrasePrompt := promptui.Prompt{
+		Label:  fmt.Sprintf("🔑 Enter the passphrase of %s", path),
+		Mask:   '*',
+		Stdin:  tty,
+		Stdout: tty,
+	}
+
+	return passphrasePrompt.Run()
+}
+
+// Picks the format for the shell the user runs.
+func defaultEnvFormat() string {
+	switch filepath.Base(os.Getenv("SHELL")) {
+	case "fish":
+		return projectenv.FormatFish
+	case "zsh":
+		return projectenv.FormatZsh
+	default:
+		return projectenv.FormatBash
+	}
+}
+
+func init() {
+	envCmd.Flags().String("format", defaultEnvFormat(), "output format: "+strings.Join(projectenv.Formats, ", "))
+	envCmd.Flags().String("file", "", "the .myst.yml to use, instead of finding it")
+	envCmd.Flags().Bool("check", false, "only report the secrets that are missing")
+	addSessionFlags(envCmd)
+
+	rootCmd.AddCommand(envCmd)
+}
@@ -0,0 +1,81 @@
+/*
+Copyright © 2024 Isaac Fei
+*/
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Isaac-Fate/myst/cmd/handlers"
+	"github.com/Isaac-Fate/myst/internal/search"
+	"github.com/spf13/cobra"
+)
+
+var findCmd = &cobra.Command{
+	Use:   "find <query>",
+	Short: "Search secrets and print the best matches first",
+	Long: `Search secrets and print the best matches first.
+
+The query supports fields, prefixes, typos and boolean operators, such as
+'key:git* website:github.com -notes:old'. Values are never printed, use the
+interactive 'find' to reveal them.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		limitFlag, _ := cmd.Flags().GetString("limit")
+		offset, _ := cmd.Flags().GetInt("offset")
+
+		limit := search.NoLimit
+		if limitFlag != "all" {
+			var err error
+			limit, err = strconv.Atoi(limitFlag)
+			if err != nil || limit <= 0 {
+				return fmt.Errorf("invalid limit '%s', expected a positive number or 'all'", limitFlag)
+			}
+		}
+
+		if offset < 0 {
+			return fmt.Errorf("invalid offset %d", offset)
+		}
+
+		if err := unlock(); err != nil {
+			return err
+		}
+		defer appContext.SecretManager.Close()
+
+		result, err := appContext.SecretManager.SearchSecrets(strings.Join(args, " "), search.Options{
+			Limit:     limit,
+			Offset:    offset,
+			Highlight: &handlers.TerminalHighlight,
+		})
+		if err != nil {
+			return err
+		}
+
+		if result.Total == 0 {
+			fmt.Println("No secrets found")
+			return nil
+		}
+
+		if len(result.Hits) == 0 {
+			fmt.Printf("Found %d secrets, none after offset %d\n", result.Total, offset)
+			return nil
+		}
+
+		fmt.Printf("Found %d secrets, showing %d-%d:\n", result.Total, offset+1, offset+len(result.Hits))
+		for _, hit := range result.Hits {
+			fmt.Println()
+			handlers.PrintSearchHit(hit)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	findCmd.Flags().String("limit", "20", "maximum number of secrets to show, or 'all'")
+	findCmd.Flags().Int("offset", 0, "number of secrets to skip")
+
+	rootCmd.AddCommand(findCmd)
+}
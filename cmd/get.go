@@ -0,0 +1,114 @@
+/*
+Copyright © 2024 Isaac Fei
+*/
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Isaac-Fate/myst/cmd/handlers"
+	"github.com/Isaac-Fate/myst/internal/audit"
+	mycrypto "github.com/Isaac-Fate/myst/internal/crypto"
+	"github.com/Isaac-Fate/myst/internal/manager"
+	"github.com/Isaac-Fate/myst/internal/models"
+	"github.com/atotto/clipboard"
+	"github.com/spf13/cobra"
+)
+
+// The fields 'myst get' can print.
+var getFields = []string{"value", "username", "website", "notes", "folder"}
+
+var getCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Print or copy a field of the secret with the exact key",
+	Long: `Print or copy a field of the secret with the exact key.
+
+The value is printed by default, followed by a newline, so the output can be
+used in scripts, e.g. TOKEN=$(myst get github-token). Unlike 'find', the key
+is not searched for: it must match exactly, or ignoring case with
+--ignore-case. If no secret has the key, similar keys are suggested.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		field, _ := cmd.Flags().GetString("field")
+		copyToClipboard, _ := cmd.Flags().GetBool("copy")
+		ignoreCase, _ := cmd.Flags().GetBool("ignore-case")
+
+		field = strings.ToLower(field)
+		if !slices.Contains(getFields, field) {
+			return fmt.Errorf("unknown field '%s', expected one of %s", field, strings.Join(getFields, ", "))
+		}
+
+		match := manager.MatchExactKey
+		if ignoreCase {
+			match = manager.MatchKeyIgnoringCase
+		}
+
+		if err := unlock(); err != nil {
+			return err
+		}
+		defer appContext.SecretManager.Close()
+
+		secret, err := handlers.LookupSecret(&appContext, args[0], match)
+		if err != nil {
+			return err
+		}
+
+		text, err := secretField(secret, field, copyToClipboard)
+		if err != nil {
+			return err
+		}
+
+		if !copyToClipboard {
+			fmt.Println(text)
+			return nil
+		}
+
+		if err := clipboard.WriteAll(text); err != nil {
+			return fmt.Errorf("failed to copy to clipboard: %w", err)
+		}
+
+		fmt.Printf("✅ %s of '%s' copied to clipboard\n", strings.ToUpper(field[:1])+field[1:], secret.Key)
+		return nil
+	},
+}
+
+// Returns a field of the secret, decrypting and recording access to the value.
+func secretField(secret *models.Secret, field string, copyToClipboard bool) (string, error) {
+	switch field {
+	case "username":
+		return secret.Username, nil
+	case "website":
+		return secret.Website, nil
+	case "notes":
+		return secret.Notes, nil
+	case "folder":
+		return secret.Folder, nil
+	}
+
+	// Record the access before handing out the value
+	action := audit.ActionReveal
+	if copyToClipboard {
+		action = audit.ActionCopy
+	}
+
+	if err := appContext.SecretManager.RecordAccess(action, secret, ""); err != nil {
+		return "", err
+	}
+
+	value, err := mycrypto.Decrypt(appContext.Passphrase, secret.EncryptedValue)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt secret value: %w", err)
+	}
+
+	return value, nil
+}
+
+func init() {
+	getCmd.Flags().String("field", "value", "field to print: "+strings.Join(getFields, ", "))
+	getCmd.Flags().Bool("copy", false, "copy the field to the clipboard instead of printing it")
+	getCmd.Flags().BoolP("ignore-case", "i", false, "match the key ignoring case, if only one secret matches")
+
+	rootCmd.AddCommand(getCmd)
+}
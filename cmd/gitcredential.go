@@ -0,0 +1,55 @@
+/*
+Copyright © 2024 Isaac Fei
+*/
+package cmd
+
+import (
+	"context"
+	"os"
+
+	"github.com/Isaac-Fate/myst/internal/gitcred"
+	"github.com/spf13/cobra"
+)
+
+var gitCredentialCmd = &cobra.Command{
+	Use:   "git-credential <get|store|erase>",
+	Short: "Act as a git credential helper",
+	Long: `Act as a git credential helper, giving git the passwords and tokens of
+secrets whose website matches the host it asks about.
+
+  git config --global credential.helper '!myst git-credential'
+
+Without --server, the passphrase is asked for on the terminal every time.
+To avoid that, keep 'myst serve' running, and save a token created with
+'myst token create git' in a file only you can read:
+
+  myst serve --listen unix:$HOME/.myst.sock
+  git config --global credential.helper \
+    '!myst git-credential --server unix:$HOME/.myst.sock --token-file $HOME/.myst-git-token'
+
+Credentials git stores are added to the 'git' folder, keyed by username
+and host. Credentials git rejects are moved to the trash if they are in the
+'git' folder; other secrets are never replaced or removed. Websites without
+a protocol are only given out over https.`,
+	Args:          cobra.ExactArgs(1),
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	Run: func(cmd *cobra.Command, args []string) {
+		// Keep errors out of the output git reads
+		runHelper(os.Stderr, func() error {
+			s, err := openSession(cmd, "git-credential")
+			if err != nil {
+				return err
+			}
+			defer s.Close()
+
+			return gitcred.Run(context.Background(), args[0], cmd.InOrStdin(), cmd.OutOrStdout(), s)
+		})
+	},
+}
+
+func init() {
+	addSessionFlags(gitCredentialCmd)
+
+	rootCmd.AddCommand(gitCredentialCmd)
+}
@@ -1,10 +1,12 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/Isaac-Fate/myst/cmd/context"
 	mycrypto "github.com/Isaac-Fate/myst/internal/crypto"
+	"github.com/Isaac-Fate/myst/internal/manager"
 	"github.com/Isaac-Fate/myst/internal/models"
 	"github.com/google/uuid"
 	"github.com/manifoldco/promptui"
@@ -20,14 +22,21 @@ func AddSecret(appContext *context.AppContext) error {
 			}
 
 			// Check if secret with this key already exists
-			secrets, err := appContext.SecretManager.FindSecrets(input)
-			if err != nil {
+			_, err := appContext.SecretManager.GetSecretByKey(input, manager.MatchExactKey)
+			if err == nil {
+				return fmt.Errorf("secret with key '%s' already exists", input)
+			}
+			if !errors.Is(err, models.ErrSecretNotFound) {
 				return fmt.Errorf("failed to check existing secrets: %w", err)
 			}
-			for _, s := range secrets {
-				if s.Key == input {
-					return fmt.Errorf("secret with key '%s' already exists", input)
-				}
+
+			// Keys of secrets in the trash stay taken until they are purged
+			_, err = appContext.SecretManager.GetTrashedSecretByKey(input)
+			if err == nil {
+				return fmt.Errorf("secret with key '%s' is in the trash, restore it with 'myst restore %s' or purge it", input, input)
+			}
+			if !errors.Is(err, models.ErrSecretNotFound) {
+				return fmt.Errorf("failed to check existing secrets: %w", err)
 			}
 			return nil
 		},
@@ -38,6 +47,11 @@ func AddSecret(appContext *context.AppContext) error {
 		return err
 	}
 
+	// Point out likely duplicates under another key
+	if similarKeys := SimilarKeys(appContext, secretKey); len(similarKeys) > 0 {
+		fmt.Printf("💡 Similar secrets already exist: %s\n", joinKeys(similarKeys, "and"))
+	}
+
 	// Prompt for secret value
 	prompt = promptui.Prompt{
 		Label: "Enter the secret value",
@@ -55,12 +69,27 @@ func AddSecret(appContext *context.AppContext) error {
 		return err
 	}
 
+	if !ConfirmUnbreached(appContext, value) {
+		fmt.Println("Cancelled, nothing was added")
+		return nil
+	}
+
 	// Encrypt the secret value
 	encryptedValue, err := mycrypto.Encrypt(appContext.Passphrase, value)
 	if err != nil {
 		return err
 	}
 
+	// Prompt for username
+	prompt = promptui.Prompt{
+		Label: "Enter the username (optional)",
+	}
+
+	username, err := prompt.Run()
+	if err != nil {
+		return err
+	}
+
 	// Prompt for website
 	prompt = promptui.Prompt{
 		Label: "Enter the website (optional)",
@@ -81,13 +110,76 @@ func AddSecret(appContext *context.AppContext) error {
 		return err
 	}
 
+	// Prompt for folder
+	prompt = promptui.Prompt{
+		Label: "Enter the folder (optional)",
+	}
+
+	folder, err := prompt.Run()
+	if err != nil {
+		return err
+	}
+
+	// Prompt for tags
+	prompt = promptui.Prompt{
+		Label: "Enter tags, separated by commas (optional)",
+		Validate: func(input string) error {
+			_, err := models.ParseTags(input)
+			return err
+		},
+	}
+
+	input, err := prompt.Run()
+	if err != nil {
+		return err
+	}
+
+	tags, _ := models.ParseTags(input)
+
+	// Prompt for the expiry date
+	prompt = promptui.Prompt{
+		Label: "Enter the expiry date, YYYY-MM-DD (optional)",
+		Validate: func(input string) error {
+			_, err := parseExpiry(input)
+			return err
+		},
+	}
+
+	input, err = prompt.Run()
+	if err != nil {
+		return err
+	}
+
+	expiresAt, _ := parseExpiry(input)
+
+	// Prompt for the rotation period
+	prompt = promptui.Prompt{
+		Label: "Rotate the value every, e.g. 90d (optional)",
+		Validate: func(input string) error {
+			_, err := parseRotation(input)
+			return err
+		},
+	}
+
+	input, err = prompt.Run()
+	if err != nil {
+		return err
+	}
+
+	rotateEvery, _ := parseRotation(input)
+
 	// Create the secret
 	secret := models.Secret{
 		ID:             uuid.New(),
 		Key:            secretKey,
 		EncryptedValue: encryptedValue,
+		Username:       username,
 		Website:        website,
 		Notes:          notes,
+		Folder:         folder,
+		Tags:           tags,
+		ExpiresAt:      expiresAt,
+		RotateEvery:    rotateEvery,
 	}
 
 	// Add the secret
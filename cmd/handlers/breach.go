@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/Isaac-Fate/myst/cmd/context"
+	"github.com/Isaac-Fate/myst/internal/breach"
+	"github.com/manifoldco/promptui"
+)
+
+// Checks a new value against the Pwned Passwords list in the configuration,
+// if there is one, and asks whether to keep a breached value.
+//
+// The check is a courtesy, so a list that cannot be read only prints a
+// warning.
+func ConfirmUnbreached(appContext *context.AppContext, value string) bool {
+	if appContext.Config.HIBPFile == "" {
+		return true
+	}
+
+	count, err := breachCount(appContext.Config.HIBPFile, value)
+	if err != nil {
+		fmt.Printf("⚠️  Could not check the value against the Pwned Passwords list: %v\n", err)
+		return true
+	}
+
+	if count == 0 {
+		return true
+	}
+
+	fmt.Printf("🚨 This value has been seen %d times in breaches\n", count)
+
+	prompt := promptui.Prompt{
+		Label:     "Use it anyway",
+		IsConfirm: true,
+	}
+
+	result, err := prompt.Run()
+	return err == nil && result == "y"
+}
+
+// Returns how many times the value appears in the Pwned Passwords list.
+func breachCount(path string, value string) (int, error) {
+	list, err := breach.Open(path)
+	if err != nil {
+		return 0, err
+	}
+
+	return list.Count(value)
+}
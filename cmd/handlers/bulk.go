@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/Isaac-Fate/myst/internal/models"
+	"github.com/manifoldco/promptui"
+)
+
+// Number of secrets listed in a preview before the rest are only counted.
+const previewSize int = 20
+
+// Prints the secrets an operation is about to change.
+func PreviewSecrets(secrets []models.Secret) {
+	for i, secret := range secrets {
+		if i == previewSize {
+			fmt.Printf("  ... and %d more\n", len(secrets)-previewSize)
+			break
+		}
+
+		if secret.Folder != "" {
+			fmt.Printf("  • %s [%s]\n", secret.Key, secret.Folder)
+		} else {
+			fmt.Printf("  • %s\n", secret.Key)
+		}
+	}
+}
+
+// Asks the user to confirm an action on several secrets by typing how many
+// there are, so that a query matching more than expected is noticed.
+//
+// It reports false if the user types anything else or cancels.
+func ConfirmCount(action string, count int) (bool, error) {
+	prompt := promptui.Prompt{
+		Label: fmt.Sprintf("Type %d to %s %d secrets", count, action, count),
+	}
+
+	input, err := prompt.Run()
+	if errors.Is(err, promptui.ErrInterrupt) || errors.Is(err, promptui.ErrEOF) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return input == strconv.Itoa(count), nil
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Isaac-Fate/myst/cmd/context"
+	"github.com/Isaac-Fate/myst/internal/models"
+	"github.com/Isaac-Fate/myst/internal/utils"
+)
+
+// How far ahead deadlines are pointed out by default.
+const DueSoon time.Duration = 14 * 24 * time.Hour
+
+// The layout of expiry dates typed by the user.
+const dateLayout string = "2006-01-02"
+
+// Describes a deadline relative to now, such as "expires in 3 days" or
+// "rotation was due 2 days ago".
+func DescribeDeadline(deadline models.Deadline, now time.Time) string {
+	when := utils.FormatRelative(deadline.At, now)
+
+	switch {
+	case deadline.Reason == models.DueExpiry && deadline.Passed(now):
+		return "expired " + when
+	case deadline.Reason == models.DueExpiry:
+		return "expires " + when
+	case deadline.Passed(now):
+		return "rotation was due " + when
+	default:
+		return "rotation due " + when
+	}
+}
+
+// Warns about secrets that are expired or due for rotation.
+//
+// The warning is a courtesy, so failing to list the secrets is not an error.
+func WarnDueSecrets(appContext *context.AppContext) {
+	secrets, err := appContext.SecretManager.ListSecrets()
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	count := 0
+	for i := range secrets {
+		if len(secrets[i].DueWithin(now, 0)) > 0 {
+			count++
+		}
+	}
+
+	switch count {
+	case 0:
+	case 1:
+		fmt.Fprintln(os.Stderr, "⚠️  1 secret is expired or due for rotation, run 'myst due' for details")
+	default:
+		fmt.Fprintf(os.Stderr, "⚠️  %d secrets are expired or due for rotation, run 'myst due' for details\n", count)
+	}
+}
+
+// Prints the dates of a secret, and its deadlines within DueSoon.
+func printDates(secret *models.Secret, now time.Time) {
+	fmt.Printf("    🕒 Created %s, updated %s\n", secret.CreatedAt.Local().Format(dateLayout), utils.FormatRelative(secret.UpdatedAt, now))
+
+	if secret.RotateEvery > 0 {
+		fmt.Printf("    🔄 Rotate every %s, value changed %s\n", utils.FormatDuration(secret.RotateEvery), utils.FormatRelative(secret.ValueChangedAt(), now))
+	}
+
+	for _, deadline := range secret.DueWithin(now, DueSoon) {
+		fmt.Printf("    ⚠️  %s\n", capitalize(DescribeDeadline(deadline, now)))
+	}
+}
+
+// Parses an expiry date typed by the user, where an empty input means none.
+func parseExpiry(input string) (*time.Time, error) {
+	if input == "" {
+		return nil, nil
+	}
+
+	date, err := time.ParseInLocation(dateLayout, input, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date '%s', expected YYYY-MM-DD", input)
+	}
+
+	return &date, nil
+}
+
+// Parses a rotation period typed by the user, where an empty input means none.
+func parseRotation(input string) (time.Duration, error) {
+	if input == "" {
+		return 0, nil
+	}
+
+	period, err := utils.ParseDuration(input)
+	if err != nil || period <= 0 {
+		return 0, fmt.Errorf("invalid period '%s', expected e.g. 90d", input)
+	}
+
+	return period, nil
+}
+
+// Formats an expiry date for editing, or an empty string if there is none.
+func formatExpiry(expiresAt *time.Time) string {
+	if expiresAt == nil {
+		return ""
+	}
+
+	return expiresAt.Local().Format(dateLayout)
+}
+
+// Formats a rotation period for editing, or an empty string if there is none.
+func formatRotation(period time.Duration) string {
+	if period <= 0 {
+		return ""
+	}
+
+	return utils.FormatDuration(period)
+}
+
+// Capitalizes the first letter of a description.
+func capitalize(text string) string {
+	if text == "" {
+		return text
+	}
+
+	return strings.ToUpper(text[:1]) + text[1:]
+}
@@ -1,18 +1,22 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/Isaac-Fate/myst/cmd/context"
-	mycrypto "github.com/Isaac-Fate/myst/internal/crypto"
-	"github.com/atotto/clipboard"
+	"github.com/Isaac-Fate/myst/internal/models"
+	"github.com/Isaac-Fate/myst/internal/search"
 	"github.com/manifoldco/promptui"
 )
 
+// Number of best matches displayed before picking one.
+const findPreviewSize int = 10
+
 func FindSecrets(appContext *context.AppContext) error {
 	// Prompt for search term
 	prompt := promptui.Prompt{
-		Label: "Enter search term",
+		Label: "Enter search term (e.g. key:git* -notes:old)",
 		Validate: func(input string) error {
 			if len(input) == 0 {
 				return fmt.Errorf("search term cannot be empty")
@@ -26,64 +30,46 @@ func FindSecrets(appContext *context.AppContext) error {
 		return err
 	}
 
-	// Search for secrets
-	secrets, err := appContext.SecretManager.FindSecrets(term)
+	// Search for secrets, best matches first
+	result, err := appContext.SecretManager.SearchSecrets(term, search.Options{
+		Limit:     search.NoLimit,
+		Highlight: &TerminalHighlight,
+	})
 	if err != nil {
 		return fmt.Errorf("failed to search secrets: %w", err)
 	}
 
-	if len(secrets) == 0 {
+	if result.Total == 0 {
 		fmt.Println("No secrets found")
 		return nil
 	}
 
-	// Display found secrets
-	fmt.Printf("Found %d secrets:\n", len(secrets))
-	for _, secret := range secrets {
-		// Create a template for displaying secret details
-		fmt.Printf("\n🔑 Key: %s\n", secret.Key)
-		if secret.Website != "" {
-			fmt.Printf("🌐 Website: %s\n", secret.Website)
-		}
-		if secret.Notes != "" {
-			fmt.Printf("📝 Notes: %s\n", secret.Notes)
-		}
-
-		// Create a selection prompt for value actions
-		actionPrompt := promptui.Select{
-			Label: "Choose action for secret value",
-			Items: []string{
-				"Skip",
-				"Display in terminal",
-				"Copy to clipboard",
-			},
-		}
+	// Display the best matches
+	fmt.Printf("Found %d secrets:\n", result.Total)
 
-		idx, _, err := actionPrompt.Run()
-		if err != nil {
-			return err
-		}
+	secrets := make([]models.Secret, len(result.Hits))
+	for i, hit := range result.Hits {
+		secrets[i] = hit.Secret
 
-		if idx == 0 { // Skip
-			continue
+		if i < findPreviewSize {
+			fmt.Println()
+			PrintSearchHit(hit)
 		}
+	}
 
-		// Decrypt the secret value
-		decryptedValue, err := mycrypto.Decrypt(appContext.Passphrase, secret.EncryptedValue)
-		if err != nil {
-			return fmt.Errorf("failed to decrypt secret value: %w", err)
-		}
+	if len(secrets) > findPreviewSize {
+		fmt.Printf("\n… and %d more\n", len(secrets)-findPreviewSize)
+	}
 
-		switch idx {
-		case 1: // Display in terminal
-			fmt.Printf("🔒 Value: %s\n", decryptedValue)
-		case 2: // Copy to clipboard
-			if err := clipboard.WriteAll(decryptedValue); err != nil {
-				return fmt.Errorf("failed to copy to clipboard: %w", err)
-			}
-			fmt.Printf("✅ Value copied to clipboard\n")
-		}
+	// Let user pick a secret to view/copy among the matches
+	fmt.Println()
+	selectedSecret, err := PickSecret("Select a secret to view or copy", secrets)
+	if errors.Is(err, ErrNothingPicked) {
+		return nil
+	}
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return offerValueActions(appContext, selectedSecret)
 }
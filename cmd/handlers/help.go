@@ -12,30 +12,100 @@ func ShowHelp(appContext *context.AppContext) error {
 
 Available Commands:
   add     Add a new secret
-          - Prompts for key, value, website (optional), and notes (optional)
+          - Prompts for key, value, username, website, notes, folder and
+            tags (all optional except key and value)
           - Values are encrypted using your master passphrase
 
   find    Search for secrets
-          - Search by key, website, or notes
+          - Search by key, username, website, notes, folder or tag
+          - Narrow down with key:git*, website:github.com, -notes:old,
+            OR, NOT, parentheses and typo~
           - View decrypted values for found secrets
 
   list    List all secrets
-          - Shows all stored secrets
+          - Shows all stored secrets and when they were updated
+          - Warns about expired secrets and due rotations
           - Option to view decrypted values
 
-  remove  Remove a secret
-          - Remove by key
-          - Confirms before deletion
+  update  Update a secret's value, username, website, notes, folder, tags,
+          expiry date or rotation period
+
+  remove  Move a secret to the trash
+          - Enter the exact key, or pick several secrets from a list
+          - Confirms before removal
 
   help    Show this help message
 
   quit    Exit the application
 
+Bulk operations (run from your shell):
+  myst tag add <tag> --query <q>   Tag the secrets matching a query
+  myst mv --query <q> --to <dir>   Move the secrets to a folder
+  myst rm --query <q>              Remove the secrets, after a preview
+  myst reencrypt --query <q>       Encrypt the values again
+
+Reminders (run from your shell):
+  myst due                         List secrets expired or due for rotation
+  myst due --output json           The same, for cron jobs
+  myst health                      Report weak, reused and old values
+  myst breach-check                Look values up in a Pwned Passwords copy
+
+Attachments (run from your shell):
+  myst attach <key> <file>         Keep an encrypted file with a secret
+  myst attachment list <key>       List the files attached to a secret
+  myst attachment get <key> <n>    Save an attached file, -o for the path
+
+Trash (run from your shell):
+  myst trash                       List the removed secrets
+  myst restore <key>               Bring a removed secret back
+  myst trash purge                 Delete removed secrets for good
+
+Scripting (run from your shell):
+  myst get <key>                   Print the value of the secret with the key
+  myst get <key> --field username  Print another field
+  myst get <key> --copy            Copy the value to the clipboard
+  eval "$(myst env)"               Export the variables in .myst.yml
+  myst env --check                 Report the secrets .myst.yml misses
+
+REST API (run from your shell):
+  myst token create <name>         Create a token, optionally scoped
+  myst serve                       Serve the API on 127.0.0.1:7755
+
+SSH keys (run from your shell):
+  myst ssh-key generate <key>      Generate an SSH key in the vault
+  myst ssh-key import <key> <f>    Import a key file from ~/.ssh
+  myst ssh-agent                   Serve the SSH keys to ssh
+
+Deployments (run from your shell):
+  myst k8s secret <n> --from <k>   Render a Kubernetes Secret for kubectl
+  myst k8s secret ... --seal-cert  Render a SealedSecret instead
+  myst docker secret <key>         Print a value for 'docker secret create'
+  myst ansible view <file>         Decrypt an ansible-vault file to stdout
+  myst ansible edit <file>         Edit an ansible-vault file in $EDITOR
+
+Credential helpers (run by other programs):
+  myst git-credential <action>     Give git the passwords of matching secrets
+  docker-credential-myst <action>  Keep docker registry passwords in myst
+  myst aws-credential-process <k>  Give the AWS SDKs the keys in a secret
+  myst-vault-client --vault-id <i> Give Ansible the password of a vault ID
+
+Full-screen UI (run from your shell):
+  myst tui                         Search, reveal, copy and edit secrets
+
+Sharing (run from your shell):
+  myst identity create             Create your key pair for receiving secrets
+  myst recipient add <name> <key>  Add a teammate's public key
+  myst share <key> --to <name>     Encrypt a secret for a teammate
+  myst import <bundle>             Import a bundle shared with you
+
 Tips:
   - You can type commands or use arrow keys to select
+  - When picking a secret, type to filter by key, username, website, notes,
+    folder or tags; "gh tok" finds "github-token"
   - Use Ctrl+C to cancel any operation
   - Secret values are always encrypted before storage
-  - Keep your master passphrase safe - it cannot be recovered!
+  - Keep your master passphrase safe - it cannot be recovered unless you
+    split a recovery key among trusted people with 'myst recovery split'!
 `
 	fmt.Println(helpText)
 	return nil
@@ -1,11 +1,15 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/Isaac-Fate/myst/cmd/context"
-	mycrypto "github.com/Isaac-Fate/myst/internal/crypto"
-	"github.com/atotto/clipboard"
+	"github.com/Isaac-Fate/myst/internal/dockercred"
+	"github.com/Isaac-Fate/myst/internal/models"
 	"github.com/manifoldco/promptui"
 )
 
@@ -15,21 +19,57 @@ func ListSecrets(appContext *context.AppContext) error {
 		return fmt.Errorf("failed to list secrets: %w", err)
 	}
 
+	// Leave out the credentials kept for docker, which are many and of little
+	// interest here
+	hidden := 0
+	secrets = slices.DeleteFunc(secrets, func(secret models.Secret) bool {
+		if dockercred.IsCredential(&secret) {
+			hidden++
+			return true
+		}
+		return false
+	})
+
+	if hidden > 0 {
+		fmt.Printf("(%d docker credentials hidden, see 'myst docker-credential list')\n", hidden)
+	}
+
 	if len(secrets) == 0 {
 		fmt.Println("No secrets found")
 		return nil
 	}
 
 	// Display all secrets
+	now := time.Now()
 	fmt.Printf("Found %d secrets:\n", len(secrets))
 	for i, secret := range secrets {
 		fmt.Printf("\n[%d] 🔑 %s\n", i+1, secret.Key)
+		if secret.Kind == models.KindSSHKey {
+			fmt.Println("    🗝️  SSH key")
+		}
+		if secret.Username != "" {
+			fmt.Printf("    👤 Username: %s\n", secret.Username)
+		}
 		if secret.Website != "" {
 			fmt.Printf("    🌐 Website: %s\n", secret.Website)
 		}
 		if secret.Notes != "" {
 			fmt.Printf("    📝 Notes: %s\n", secret.Notes)
 		}
+		if secret.Folder != "" {
+			fmt.Printf("    📁 Folder: %s\n", secret.Folder)
+		}
+		if len(secret.Tags) > 0 {
+			fmt.Printf("    🏷️  Tags: %s\n", strings.Join(secret.Tags, ", "))
+		}
+		if len(secret.Attachments) > 0 {
+			names := make([]string, len(secret.Attachments))
+			for i, attachment := range secret.Attachments {
+				names[i] = attachment.Name
+			}
+			fmt.Printf("    📎 Attachments: %s\n", strings.Join(names, ", "))
+		}
+		printDates(&secret, now)
 	}
 
 	// Ask if user wants to view/copy any secret values
@@ -38,62 +78,18 @@ func ListSecrets(appContext *context.AppContext) error {
 		IsConfirm: true,
 	}
 
-	if result, err := viewPrompt.Run(); err == nil && result == "y" {
-		// Create items for selection
-		items := make([]string, len(secrets))
-		for i, s := range secrets {
-			items[i] = fmt.Sprintf("[%d] %s (%s)", i+1, s.Key, s.Notes)
-		}
-
-		// Let user select which secret to view/copy
-		selectPrompt := promptui.Select{
-			Label: "Select a secret",
-			Items: items,
-			Size:  10, // Show 10 items at a time if list is long
-		}
-
-		idx, _, err := selectPrompt.Run()
-		if err != nil {
-			return err
-		}
-
-		selectedSecret := secrets[idx]
-
-		// Ask what to do with the selected secret
-		actionPrompt := promptui.Select{
-			Label: "Choose action",
-			Items: []string{
-				"Skip",
-				"Display in terminal",
-				"Copy to clipboard",
-			},
-		}
-
-		actionIdx, _, err := actionPrompt.Run()
-		if err != nil {
-			return err
-		}
-
-		if actionIdx == 0 { // Skip
-			return nil
-		}
-
-		// Decrypt the secret value
-		decryptedValue, err := mycrypto.Decrypt(appContext.Passphrase, selectedSecret.EncryptedValue)
-		if err != nil {
-			return fmt.Errorf("failed to decrypt secret value: %w", err)
-		}
+	if result, err := viewPrompt.Run(); err != nil || result != "y" {
+		return nil
+	}
 
-		switch actionIdx {
-		case 1: // Display in terminal
-			fmt.Printf("\n🔒 Value for '%s': %s\n", selectedSecret.Key, decryptedValue)
-		case 2: // Copy to clipboard
-			if err := clipboard.WriteAll(decryptedValue); err != nil {
-				return fmt.Errorf("failed to copy to clipboard: %w", err)
-			}
-			fmt.Printf("\n✅ Value for '%s' copied to clipboard\n", selectedSecret.Key)
-		}
+	// Let user pick which secret to view/copy
+	selectedSecret, err := PickSecret("Select a secret", secrets)
+	if errors.Is(err, ErrNothingPicked) {
+		return nil
+	}
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return offerValueActions(appContext, selectedSecret)
 }
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Isaac-Fate/myst/cmd/context"
+	"github.com/Isaac-Fate/myst/internal/manager"
+	"github.com/Isaac-Fate/myst/internal/models"
+)
+
+// Number of similar keys suggested when a key is not found.
+const suggestionCount int = 3
+
+// Gets the secret with the key.
+//
+// If there is none, the error suggests secrets with similar keys.
+func LookupSecret(appContext *context.AppContext, key string, match manager.KeyMatch) (*models.Secret, error) {
+	secret, err := appContext.SecretManager.GetSecretByKey(key, match)
+
+	switch {
+	case errors.Is(err, models.ErrSecretNotFound):
+		if suggestions := SimilarKeys(appContext, key); len(suggestions) > 0 {
+			return nil, fmt.Errorf("secret with key '%s' not found, did you mean %s?", key, joinKeys(suggestions, "or"))
+		}
+		return nil, fmt.Errorf("secret with key '%s' not found", key)
+
+	case errors.Is(err, models.ErrAmbiguousKey):
+		return nil, fmt.Errorf("several secrets have the key '%s' in a different case, use the exact key", key)
+
+	case err != nil:
+		return nil, fmt.Errorf("failed to get secret: %w", err)
+	}
+
+	return secret, nil
+}
+
+// Returns the keys of the secrets most similar to the key, other than the key
+// itself.
+//
+// Suggestions are a courtesy, so failing to find any is not an error.
+func SimilarKeys(appContext *context.AppContext, key string) []string {
+	secrets, err := appContext.SecretManager.SuggestSecrets(key, suggestionCount+1)
+	if err != nil {
+		return nil
+	}
+
+	var keys []string
+	for _, secret := range secrets {
+		if secret.Key != key && len(keys) < suggestionCount {
+			keys = append(keys, secret.Key)
+		}
+	}
+
+	return keys
+}
+
+// Quotes the keys and joins them like 'a', 'b' or 'c'.
+func joinKeys(keys []string, conjunction string) string {
+	quoted := make([]string, len(keys))
+	for i, key := range keys {
+		quoted[i] = "'" + key + "'"
+	}
+
+	if len(quoted) == 1 {
+		return quoted[0]
+	}
+
+	return strings.Join(quoted[:len(quoted)-1], ", ") + " " + conjunction + " " + quoted[len(quoted)-1]
+}
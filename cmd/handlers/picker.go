@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"cmp"
+	"errors"
+	"maps"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/Isaac-Fate/myst/internal/fuzzy"
+	"github.com/Isaac-Fate/myst/internal/models"
+	"github.com/manifoldco/promptui"
+)
+
+// Number of secrets the picker shows at a time.
+const pickerSize int = 10
+
+// Returned when the user leaves the picker without picking a secret.
+var ErrNothingPicked = errors.New("no secret picked")
+
+var pickerTemplates = &promptui.SelectTemplates{
+	Label:    "{{ . }}",
+	Active:   "▸ {{ .Key | cyan }}{{ if .Folder }} {{ printf \"[%s]\" .Folder | faint }}{{ end }}",
+	Inactive: "  {{ .Key }}{{ if .Folder }} {{ printf \"[%s]\" .Folder | faint }}{{ end }}",
+	Selected: "✔ {{ .Key | green }}",
+	Details: `
+{{ "🔑 Key:" | faint }}      {{ .Key }}
+{{ "👤 Username:" | faint }} {{ .Username }}
+{{ "🌐 Website:" | faint }}  {{ .Website }}
+{{ "📝 Notes:" | faint }}    {{ .Notes }}
+{{ "📁 Folder:" | faint }}   {{ .Folder }}
+{{ "🏷️ Tags:" | faint }}     {{ join .Tags ", " }}
+{{ "🕒 Updated:" | faint }}  {{ .UpdatedAt.Format "2006-01-02 15:04" }}`,
+	FuncMap: pickerFuncs,
+}
+
+// A row of a picker: a secret, or the row that finishes picking.
+type pickerRow struct {
+	*models.Secret
+	Picked bool
+	Done   bool
+	Count  int
+
+	// Position of the secret in the secrets given to the picker
+	index int
+}
+
+var multiPickerTemplates = &promptui.SelectTemplates{
+	Label:    "{{ . }}",
+	Active:   "▸ {{ if .Done }}{{ printf \"✔ Done (%d picked)\" .Count | green }}{{ else }}{{ if .Picked }}[x]{{ else }}[ ]{{ end }} {{ .Key | cyan }}{{ if .Folder }} {{ printf \"[%s]\" .Folder | faint }}{{ end }}{{ end }}",
+	Inactive: "  {{ if .Done }}{{ printf \"✔ Done (%d picked)\" .Count }}{{ else }}{{ if .Picked }}[x]{{ else }}[ ]{{ end }} {{ .Key }}{{ if .Folder }} {{ printf \"[%s]\" .Folder | faint }}{{ end }}{{ end }}",
+	Details: `{{ if not .Done }}
+{{ "🔑 Key:" | faint }}      {{ .Key }}
+{{ "👤 Username:" | faint }} {{ .Username }}
+{{ "🌐 Website:" | faint }}  {{ .Website }}
+{{ "📁 Folder:" | faint }}   {{ .Folder }}
+{{ "🏷️ Tags:" | faint }}     {{ join .Tags ", " }}{{ end }}`,
+	FuncMap: pickerFuncs,
+}
+
+var pickerFuncs = func() template.FuncMap {
+	funcs := template.FuncMap{"join": strings.Join}
+	maps.Copy(funcs, promptui.FuncMap)
+	return funcs
+}()
+
+// Scores the secret against what the user typed in a picker, reporting
+// whether it matches.
+func scorePickerInput(input string, secret *models.Secret) (int, bool) {
+	return fuzzy.MatchFields(input, secret.Key, secret.Username, secret.Website, secret.Notes, secret.Folder, strings.Join(secret.Tags, " "))
+}
+
+// Returns a searcher that shows the matching rows best first.
+//
+// promptui filters the rows but keeps them in their order, so when a search
+// starts the rows after the first fixed ones are rearranged in place: the
+// matches by descending score, then the others. The searcher then keeps the
+// leading rows up to the last match.
+func rankingSearcher(rows []*pickerRow, fixed int) func(input string, index int) bool {
+	originals := make([]pickerRow, len(rows))
+	for i, row := range rows {
+		originals[i] = *row
+	}
+
+	type ranked struct {
+		row   pickerRow
+		score int
+	}
+
+	matches := 0
+
+	return func(input string, index int) bool {
+		// promptui asks for every row in order, starting from the first
+		if index == 0 {
+			var hits, misses []ranked
+			for _, row := range originals[fixed:] {
+				if score, ok := scorePickerInput(input, row.Secret); ok {
+					hits = append(hits, ranked{row, score})
+				} else {
+					misses = append(misses, ranked{row, 0})
+				}
+			}
+
+			slices.SortStableFunc(hits, func(a, b ranked) int {
+				return cmp.Compare(b.score, a.score)
+			})
+
+			for i, r := range append(hits, misses...) {
+				*rows[fixed+i] = r.row
+			}
+
+			matches = len(hits)
+		}
+
+		return index < fixed+matches
+	}
+}
+
+// Lets the user pick one of the secrets, filtering them as they type.
+//
+// Typing narrows the list down to the secrets whose key, username, website,
+// notes, folder or tags fuzzily match every typed word, so "gh tok" finds
+// "github-token", with the best matches first. The metadata of the
+// highlighted secret is shown below the list.
+//
+// It returns ErrNothingPicked if the user cancels.
+func PickSecret(label string, secrets []models.Secret) (*models.Secret, error) {
+	if len(secrets) == 0 {
+		return nil, ErrNothingPicked
+	}
+
+	rows := make([]*pickerRow, len(secrets))
+	for i := range secrets {
+		rows[i] = &pickerRow{Secret: &secrets[i], index: i}
+	}
+
+	picker := promptui.Select{
+		Label:             label + " (type to filter)",
+		Items:             rows,
+		Templates:         pickerTemplates,
+		Size:              pickerSize,
+		Searcher:          rankingSearcher(rows, 0),
+		StartInSearchMode: true,
+	}
+
+	i, _, err := picker.Run()
+	if err != nil {
+		if errors.Is(err, promptui.ErrInterrupt) || errors.Is(err, promptui.ErrEOF) {
+			return nil, ErrNothingPicked
+		}
+		return nil, err
+	}
+
+	return rows[i].Secret, nil
+}
+
+// Lets the user pick any number of the secrets, filtering them as they type.
+//
+// Choosing a secret toggles whether it is picked, and choosing the first row
+// finishes. The secrets are returned in their original order.
+//
+// It returns ErrNothingPicked if the user cancels or picks nothing.
+func PickSecrets(label string, secrets []models.Secret) ([]models.Secret, error) {
+	if len(secrets) == 0 {
+		return nil, ErrNothingPicked
+	}
+
+	picked := make([]bool, len(secrets))
+	count := 0
+
+	cursor, scroll := 1, 0
+	for {
+		rows := []*pickerRow{{Done: true, Count: count}}
+		for i := range secrets {
+			rows = append(rows, &pickerRow{Secret: &secrets[i], Picked: picked[i], index: i})
+		}
+
+		picker := promptui.Select{
+			Label:     label + " (/ to filter, Enter to toggle)",
+			Items:     rows,
+			Templates: multiPickerTemplates,
+			Size:      pickerSize,
+			// Keep the screen quiet between toggles
+			HideSelected: true,
+			// Finishing is always possible
+			Searcher: rankingSearcher(rows, 1),
+		}
+
+		i, _, err := picker.RunCursorAt(cursor, scroll)
+		if err != nil {
+			if errors.Is(err, promptui.ErrInterrupt) || errors.Is(err, promptui.ErrEOF) {
+				return nil, ErrNothingPicked
+			}
+			return nil, err
+		}
+
+		if i == 0 {
+			break
+		}
+
+		// Toggle the secret and stay on it, in the unfiltered list
+		index := rows[i].index
+		picked[index] = !picked[index]
+		if picked[index] {
+			count++
+		} else {
+			count--
+		}
+
+		cursor, scroll = index+1, max(0, index+1-pickerSize+1)
+	}
+
+	var result []models.Secret
+	for i, secret := range secrets {
+		if picked[i] {
+			result = append(result, secret)
+		}
+	}
+
+	if len(result) == 0 {
+		return nil, ErrNothingPicked
+	}
+
+	return result, nil
+}
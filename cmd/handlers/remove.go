@@ -1,75 +1,82 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/Isaac-Fate/myst/cmd/context"
+	"github.com/Isaac-Fate/myst/internal/manager"
+	"github.com/Isaac-Fate/myst/internal/models"
 	"github.com/manifoldco/promptui"
 )
 
 func RemoveSecret(appContext *context.AppContext) error {
-	// Prompt for the secret key
-	prompt := promptui.Prompt{
-		Label: "Enter the secret key to remove",
-		Validate: func(input string) error {
-			if len(input) == 0 {
-				return fmt.Errorf("key cannot be empty")
-			}
-			return nil
-		},
+	secrets, err := appContext.SecretManager.ListSecrets()
+	if err != nil {
+		return fmt.Errorf("failed to list secrets: %w", err)
 	}
 
-	key, err := prompt.Run()
-	if err != nil {
-		return err
+	if len(secrets) == 0 {
+		fmt.Println("No secrets found")
+		return nil
+	}
+
+	// Ask for the exact key, or let the user pick the secrets
+	keyPrompt := promptui.Prompt{
+		Label: "Enter the key of the secret to remove (leave empty to pick several)",
 	}
 
-	// Search for the secret
-	secrets, err := appContext.SecretManager.FindSecrets(key)
+	key, err := keyPrompt.Run()
 	if err != nil {
-		return fmt.Errorf("failed to find secret: %w", err)
+		return err
 	}
 
-	if len(secrets) == 0 {
-		return fmt.Errorf("secret with key '%s' not found", key)
+	var secretsToRemove []models.Secret
+	if key == "" {
+		secretsToRemove, err = PickSecrets("Select the secrets to remove", secrets)
+	} else {
+		var secret *models.Secret
+		secret, err = LookupSecret(appContext, key, manager.MatchExactKey)
+		if secret != nil {
+			secretsToRemove = []models.Secret{*secret}
+		}
+	}
+	if errors.Is(err, ErrNothingPicked) {
+		return nil
+	}
+	if err != nil {
+		return err
 	}
 
-	// If multiple secrets found, let user select which one to remove
-	var secretToRemove = secrets[0]
-	if len(secrets) > 1 {
-		items := make([]string, len(secrets))
-		for i, s := range secrets {
-			items[i] = fmt.Sprintf("%s (%s)", s.Key, s.Notes)
+	// Confirm removal
+	if len(secretsToRemove) == 1 {
+		confirmPrompt := promptui.Prompt{
+			Label:     fmt.Sprintf("Are you sure you want to remove secret '%s'", secretsToRemove[0].Key),
+			IsConfirm: true,
 		}
 
-		selectPrompt := promptui.Select{
-			Label: "Multiple secrets found. Select one to remove",
-			Items: items,
+		result, err := confirmPrompt.Run()
+		if err != nil || result != "y" {
+			return nil // User cancelled
 		}
+	} else {
+		fmt.Println("The following secrets will be removed:")
+		PreviewSecrets(secretsToRemove)
 
-		idx, _, err := selectPrompt.Run()
-		if err != nil {
+		confirmed, err := ConfirmCount("remove", len(secretsToRemove))
+		if err != nil || !confirmed {
 			return err
 		}
-		secretToRemove = secrets[idx]
 	}
 
-	// Confirm removal
-	confirmPrompt := promptui.Prompt{
-		Label:     fmt.Sprintf("Are you sure you want to remove secret '%s'", secretToRemove.Key),
-		IsConfirm: true,
+	if err := appContext.SecretManager.RemoveSecrets(secretsToRemove); err != nil {
+		return fmt.Errorf("failed to remove secrets: %w", err)
 	}
 
-	result, err := confirmPrompt.Run()
-	if err != nil {
-		return nil // User cancelled
-	}
-
-	if result == "y" {
-		if err := appContext.SecretManager.RemoveSecret(&secretToRemove); err != nil {
-			return fmt.Errorf("failed to remove secret: %w", err)
-		}
-		fmt.Printf("✅ Secret '%s' removed successfully\n", secretToRemove.Key)
+	if len(secretsToRemove) == 1 {
+		fmt.Printf("✅ Secret '%s' moved to the trash, 'myst restore %s' brings it back\n", secretsToRemove[0].Key, secretsToRemove[0].Key)
+	} else {
+		fmt.Printf("✅ %d secrets moved to the trash, 'myst trash' lists them\n", len(secretsToRemove))
 	}
 
 	return nil
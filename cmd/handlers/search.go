@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Isaac-Fate/myst/internal/manager"
+	"github.com/Isaac-Fate/myst/internal/search"
+)
+
+// Highlights matches in bold yellow.
+var TerminalHighlight = search.Highlight{
+	Before: "\033[1;33m",
+	After:  "\033[0m",
+}
+
+// Prints the metadata of a found secret, with the matches highlighted.
+func PrintSearchHit(hit manager.SearchHit) {
+	field := func(name string, value string) string {
+		if fragment, ok := hit.Fragments[name]; ok {
+			return fragment
+		}
+		return value
+	}
+
+	fmt.Printf("🔑 Key: %s\n", field("key", hit.Secret.Key))
+	if hit.Secret.Username != "" {
+		fmt.Printf("👤 Username: %s\n", field("username", hit.Secret.Username))
+	}
+	if hit.Secret.Website != "" {
+		fmt.Printf("🌐 Website: %s\n", field("website", hit.Secret.Website))
+	}
+	if hit.Secret.Notes != "" {
+		fmt.Printf("📝 Notes: %s\n", field("notes", hit.Secret.Notes))
+	}
+	if hit.Secret.Folder != "" {
+		fmt.Printf("📁 Folder: %s\n", hit.Secret.Folder)
+	}
+	if len(hit.Secret.Tags) > 0 {
+		fmt.Printf("🏷️  Tags: %s\n", strings.Join(hit.Secret.Tags, ", "))
+	}
+}
@@ -1,15 +1,18 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/Isaac-Fate/myst/cmd/context"
 	mycrypto "github.com/Isaac-Fate/myst/internal/crypto"
+	"github.com/Isaac-Fate/myst/internal/models"
 	"github.com/manifoldco/promptui"
 )
 
 func UpdateSecret(appContext *context.AppContext) error {
-	// First list all secrets for selection
+	// First list all secrets to pick from
 	secrets, err := appContext.SecretManager.ListSecrets()
 	if err != nil {
 		return fmt.Errorf("failed to list secrets: %w", err)
@@ -20,37 +23,29 @@ func UpdateSecret(appContext *context.AppContext) error {
 		return nil
 	}
 
-	// Create items for selection
-	items := make([]string, len(secrets))
-	for i, s := range secrets {
-		desc := s.Key
-		if s.Notes != "" {
-			desc += fmt.Sprintf(" (%s)", s.Notes)
-		}
-		items[i] = fmt.Sprintf("[%d] %s", i+1, desc)
-	}
-
-	// Let user select which secret to update
-	selectPrompt := promptui.Select{
-		Label: "Select a secret to update",
-		Items: items,
-		Size:  10, // Show 10 items at a time if list is long
+	// Let user pick which secret to update
+	picked, err := PickSecret("Select a secret to update", secrets)
+	if errors.Is(err, ErrNothingPicked) {
+		return nil
 	}
-
-	idx, _, err := selectPrompt.Run()
 	if err != nil {
 		return err
 	}
 
-	selectedSecret := secrets[idx]
+	selectedSecret := *picked
 
 	// Ask what to update
 	updatePrompt := promptui.Select{
 		Label: "What would you like to update",
 		Items: []string{
 			"Value",
+			"Username",
 			"Website",
 			"Notes",
+			"Folder",
+			"Tags",
+			"Expiry date",
+			"Rotation period",
 		},
 	}
 
@@ -77,6 +72,11 @@ func UpdateSecret(appContext *context.AppContext) error {
 			return err
 		}
 
+		if !ConfirmUnbreached(appContext, newValue) {
+			fmt.Println("Cancelled, nothing was changed")
+			return nil
+		}
+
 		// Encrypt the new value
 		encryptedValue, err := mycrypto.Encrypt(appContext.Passphrase, newValue)
 		if err != nil {
@@ -85,7 +85,20 @@ func UpdateSecret(appContext *context.AppContext) error {
 
 		selectedSecret.EncryptedValue = encryptedValue
 
-	case 1: // Update website
+	case 1: // Update username
+		prompt := promptui.Prompt{
+			Label:   "Enter new username",
+			Default: selectedSecret.Username,
+		}
+
+		newUsername, err := prompt.Run()
+		if err != nil {
+			return err
+		}
+
+		selectedSecret.Username = newUsername
+
+	case 2: // Update website
 		prompt := promptui.Prompt{
 			Label:   "Enter new website",
 			Default: selectedSecret.Website,
@@ -98,7 +111,7 @@ func UpdateSecret(appContext *context.AppContext) error {
 
 		selectedSecret.Website = newWebsite
 
-	case 2: // Update notes
+	case 3: // Update notes
 		prompt := promptui.Prompt{
 			Label:   "Enter new notes",
 			Default: selectedSecret.Notes,
@@ -110,6 +123,70 @@ func UpdateSecret(appContext *context.AppContext) error {
 		}
 
 		selectedSecret.Notes = newNotes
+
+	case 4: // Update folder
+		prompt := promptui.Prompt{
+			Label:   "Enter new folder",
+			Default: selectedSecret.Folder,
+		}
+
+		newFolder, err := prompt.Run()
+		if err != nil {
+			return err
+		}
+
+		selectedSecret.Folder = newFolder
+
+	case 5: // Update tags
+		prompt := promptui.Prompt{
+			Label:   "Enter new tags, separated by commas",
+			Default: strings.Join(selectedSecret.Tags, ", "),
+			Validate: func(input string) error {
+				_, err := models.ParseTags(input)
+				return err
+			},
+		}
+
+		input, err := prompt.Run()
+		if err != nil {
+			return err
+		}
+
+		selectedSecret.Tags, _ = models.ParseTags(input)
+
+	case 6: // Update expiry date
+		prompt := promptui.Prompt{
+			Label:   "Enter new expiry date, YYYY-MM-DD, or nothing for none",
+			Default: formatExpiry(selectedSecret.ExpiresAt),
+			Validate: func(input string) error {
+				_, err := parseExpiry(input)
+				return err
+			},
+		}
+
+		input, err := prompt.Run()
+		if err != nil {
+			return err
+		}
+
+		selectedSecret.ExpiresAt, _ = parseExpiry(input)
+
+	case 7: // Update rotation period
+		prompt := promptui.Prompt{
+			Label:   "Rotate the value every, e.g. 90d, or nothing for never",
+			Default: formatRotation(selectedSecret.RotateEvery),
+			Validate: func(input string) error {
+				_, err := parseRotation(input)
+				return err
+			},
+		}
+
+		input, err := prompt.Run()
+		if err != nil {
+			return err
+		}
+
+		selectedSecret.RotateEvery, _ = parseRotation(input)
 	}
 
 	// Confirm update
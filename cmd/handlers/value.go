@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"fmt"
+
+	"github.com/Isaac-Fate/myst/cmd/context"
+	"github.com/Isaac-Fate/myst/internal/audit"
+	mycrypto "github.com/Isaac-Fate/myst/internal/crypto"
+	"github.com/Isaac-Fate/myst/internal/models"
+	"github.com/atotto/clipboard"
+	"github.com/manifoldco/promptui"
+)
+
+// Asks whether to display or copy the value of the secret, and does it.
+func offerValueActions(appContext *context.AppContext, secret *models.Secret) error {
+	actionPrompt := promptui.Select{
+		Label: "Choose action for secret value",
+		Items: []string{
+			"Skip",
+			"Display in terminal",
+			"Copy to clipboard",
+		},
+	}
+
+	idx, _, err := actionPrompt.Run()
+	if err != nil {
+		return err
+	}
+
+	if idx == 0 { // Skip
+		return nil
+	}
+
+	// Record the access before handing out the value
+	action := audit.ActionReveal
+	if idx == 2 {
+		action = audit.ActionCopy
+	}
+
+	if err := appContext.SecretManager.RecordAccess(action, secret, ""); err != nil {
+		return err
+	}
+
+	// Decrypt the secret value
+	decryptedValue, err := mycrypto.Decrypt(appContext.Passphrase, secret.EncryptedValue)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt secret value: %w", err)
+	}
+
+	switch idx {
+	case 1: // Display in terminal
+		fmt.Printf("\n🔒 Value for '%s': %s\n", secret.Key, decryptedValue)
+	case 2: // Copy to clipboard
+		if err := clipboard.WriteAll(decryptedValue); err != nil {
+			return fmt.Errorf("failed to copy to clipboard: %w", err)
+		}
+		fmt.Printf("\n✅ Value for '%s' copied to clipboard\n", secret.Key)
+	}
+
+	return nil
+}
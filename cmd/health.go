@@ -0,0 +1,109 @@
+/*
+Copyright © 2024 Isaac Fei
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Isaac-Fate/myst/internal/health"
+	"github.com/Isaac-Fate/myst/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var healthCmd = &cobra.Command{
+	Use:   "health",
+	Short: "Report weak, reused and old secret values",
+	Long: `Report weak, reused and old secret values, with a score from 0 to 100.
+
+Values are decrypted in memory only and nothing leaves the machine. Reused
+values are found by comparing keyed hashes under a key that only lives for
+the check, so the report never holds anything that helps guess a value.
+
+  myst health --max-age 180d
+  myst health --output json`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		maxAgeFlag, _ := cmd.Flags().GetString("max-age")
+		output, _ := cmd.Flags().GetString("output")
+
+		maxAge, err := utils.ParseDuration(maxAgeFlag)
+		if err != nil {
+			return err
+		}
+
+		if output != "text" && output != "json" {
+			return fmt.Errorf("unknown output '%s', expected text or json", output)
+		}
+
+		if err := unlock(); err != nil {
+			return err
+		}
+		defer appContext.SecretManager.Close()
+
+		secrets, err := appContext.SecretManager.ListSecrets()
+		if err != nil {
+			return err
+		}
+
+		report, err := health.Check(secrets, appContext.Passphrase, health.Options{MaxAge: maxAge, Now: time.Now()})
+		if err != nil {
+			return err
+		}
+
+		if output == "json" {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(report)
+		}
+
+		printHealthReport(report, maxAge)
+		return nil
+	},
+}
+
+// Prints a health report for reading in the terminal.
+func printHealthReport(report *health.Report, maxAge time.Duration) {
+	fmt.Printf("🩺 Health score: %d/100 (%d secrets checked)\n", report.Score, report.Total)
+
+	if len(report.Weak) == 0 && len(report.Reused) == 0 && len(report.Old) == 0 {
+		fmt.Println("✅ No weak, reused or old values")
+		return
+	}
+
+	if len(report.Weak) > 0 {
+		fmt.Printf("\n⚠️  Weak values (%d):\n", len(report.Weak))
+		for _, secret := range report.Weak {
+			line := fmt.Sprintf("  • %s  %s", secret.Key, secret.Strength)
+			if len(secret.Reasons) > 0 {
+				line += ": " + strings.Join(secret.Reasons, ", ")
+			}
+			fmt.Println(line)
+		}
+	}
+
+	if len(report.Reused) > 0 {
+		fmt.Printf("\n🔁 Reused values (%d groups):\n", len(report.Reused))
+		for _, keys := range report.Reused {
+			fmt.Printf("  • %s\n", strings.Join(keys, ", "))
+		}
+	}
+
+	if len(report.Old) > 0 {
+		fmt.Printf("\n🕰️  Values not changed for over %s (%d):\n", utils.FormatDuration(maxAge), len(report.Old))
+		for _, secret := range report.Old {
+			fmt.Printf("  • %s  changed %d days ago\n", secret.Key, secret.AgeDays)
+		}
+	}
+}
+
+func init() {
+	healthCmd.Flags().String("max-age", "365d", "report values not updated for longer than this, or 0 to skip")
+	healthCmd.Flags().StringP("output", "o", "text", "output format: text or json")
+
+	rootCmd.AddCommand(healthCmd)
+}
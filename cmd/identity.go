@@ -0,0 +1,92 @@
+/*
+Copyright © 2024 Isaac Fei
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/Isaac-Fate/myst/internal/config"
+	"github.com/Isaac-Fate/myst/internal/sharing"
+	"github.com/spf13/cobra"
+)
+
+var identityCmd = &cobra.Command{
+	Use:   "identity",
+	Short: "Manage your identity for receiving shared secrets",
+	Long: `Manage your identity for receiving shared secrets.
+
+An identity is an X25519 key pair in the age format. Give your public key to
+teammates so they can share secrets with you; the private key is kept
+encrypted with your master passphrase.`,
+}
+
+var identityCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create a new identity",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name, _ := cmd.Flags().GetString("name")
+		force, _ := cmd.Flags().GetBool("force")
+
+		if _, err := os.Stat(config.IdentityPath()); err == nil && !force {
+			return errors.New("an identity already exists, use --force to replace it")
+		}
+
+		if err := unlockConfig(); err != nil {
+			return err
+		}
+
+		identity, err := sharing.GenerateIdentity(name)
+		if err != nil {
+			return err
+		}
+
+		if err := sharing.SaveIdentity(config.IdentityPath(), appContext.Passphrase, identity); err != nil {
+			return err
+		}
+
+		fmt.Println("✅ Identity created")
+		fmt.Printf("Share your public key with teammates:\n\n  myst recipient add %s %s\n", identity.Name, identity.Recipient())
+		return nil
+	},
+}
+
+var identityExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Print your public key, or your private key with --private",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		private, _ := cmd.Flags().GetBool("private")
+
+		if err := unlockConfig(); err != nil {
+			return err
+		}
+
+		identity, err := sharing.LoadIdentity(config.IdentityPath(), appContext.Passphrase)
+		if err != nil {
+			return err
+		}
+
+		if private {
+			fmt.Fprintln(os.Stderr, "⚠️  Anyone with this key can read secrets shared with you")
+			fmt.Println(identity.PrivateKey())
+			return nil
+		}
+
+		fmt.Printf("%s %s\n", identity.Name, identity.Recipient())
+		return nil
+	},
+}
+
+func init() {
+	username := os.Getenv("USER")
+	identityCreateCmd.Flags().String("name", username, "name teammates know you by")
+	identityCreateCmd.Flags().Bool("force", false, "replace an existing identity")
+	identityExportCmd.Flags().Bool("private", false, "print the private key for backup")
+
+	identityCmd.AddCommand(identityCreateCmd, identityExportCmd)
+	rootCmd.AddCommand(identityCmd)
+}
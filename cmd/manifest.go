@@ -0,0 +1,197 @@
+/*
+Copyright © 2024 Isaac Fei
+*/
+package cmd
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/Isaac-Fate/myst/internal/audit"
+	mycrypto "github.com/Isaac-Fate/myst/internal/crypto"
+	"github.com/Isaac-Fate/myst/internal/manifest"
+	"github.com/spf13/cobra"
+)
+
+// Manifests are printed to stdout to be piped to kubectl or docker, so the
+// passphrase is asked for on the terminal instead.
+
+var k8sCmd = &cobra.Command{
+	Use:   "k8s",
+	Short: "Render Kubernetes manifests from secrets",
+}
+
+var k8sSecretCmd = &cobra.Command{
+	Use:   "secret <name>",
+	Short: "Render a Kubernetes Secret, or a SealedSecret, from secrets",
+	Long: `Render a v1 Secret holding the values of the given secrets, to pipe to
+kubectl. Each value is stored under the key of its secret, or under another
+name given as name=key:
+
+  myst k8s secret app-secrets --from DB_PASSWORD=work/db-password,api-key \
+    --namespace dev | kubectl apply -f -
+
+With --seal-cert, a SealedSecret is rendered instead, encrypted with the
+certificate of the sealed secrets controller ('kubeseal --fetch-cert') so
+that only the cluster can read it and it can be committed.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		froms, _ := cmd.Flags().GetStringSlice("from")
+		namespace, _ := cmd.Flags().GetString("namespace")
+		secretType, _ := cmd.Flags().GetString("type")
+		stringData, _ := cmd.Flags().GetBool("string-data")
+		sealCert, _ := cmd.Flags().GetString("seal-cert")
+
+		if len(froms) == 0 {
+			return errors.New("no secrets given, use --from key1,key2")
+		}
+		if err := manifest.ValidateObjectName(args[0]); err != nil {
+			return err
+		}
+		if stringData && sealCert != "" {
+			return errors.New("--string-data cannot be used with --seal-cert")
+		}
+		if sealCert != "" && namespace == "" {
+			return errors.New("--seal-cert needs a --namespace, as sealed values are bound to it")
+		}
+
+		options := manifest.SecretOptions{Namespace: namespace, Type: secretType, StringData: stringData}
+
+		// Check the certificate before asking for the passphrase
+		var publicKey *rsa.PublicKey
+		if sealCert != "" {
+			certificate, err := os.ReadFile(sealCert)
+			if err != nil {
+				return fmt.Errorf("failed to read the certificate: %w", err)
+			}
+
+			publicKey, err = manifest.ParsePublicKey(certificate)
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := unlockOnTerminal(); err != nil {
+			return err
+		}
+		defer appContext.SecretManager.Close()
+
+		entries, err := loadManifestEntries(froms, "k8s secret "+args[0])
+		if err != nil {
+			return err
+		}
+
+		content, err := renderK8sSecret(args[0], entries, options, publicKey)
+		if err != nil {
+			return err
+		}
+
+		_, err = os.Stdout.Write(content)
+		return err
+	},
+}
+
+var dockerCmd = &cobra.Command{
+	Use:   "docker",
+	Short: "Hand secrets to docker",
+}
+
+var dockerSecretCmd = &cobra.Command{
+	Use:   "secret <key...>",
+	Short: "Print a value for 'docker secret create', or write secret files",
+	Long: `Print the value of a secret as is, without a newline, for
+'docker secret create':
+
+  myst docker secret work/db-password | docker secret create db_password -
+
+With --dir, the values of the secrets are written to files in the
+directory instead, named after their keys or as given by name=key, for the
+secrets of compose files:
+
+  myst docker secret --dir ./secrets db_password=work/db-password api-key`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, _ := cmd.Flags().GetString("dir")
+
+		if dir == "" && len(args) > 1 {
+			return errors.New("only one secret can be printed, use --dir to write several")
+		}
+
+		if err := unlockOnTerminal(); err != nil {
+			return err
+		}
+		defer appContext.SecretManager.Close()
+
+		entries, err := loadManifestEntries(args, "docker secret")
+		if err != nil {
+			return err
+		}
+
+		if dir == "" {
+			_, err = os.Stdout.WriteString(entries[0].Value)
+			return err
+		}
+
+		if err := manifest.WriteSecretFiles(dir, entries); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(os.Stderr, "✅ Wrote %d secret files to %s\n", len(entries), dir)
+		return nil
+	},
+}
+
+// Renders a Secret holding the entries, or a SealedSecret if there is a
+// public key to seal them with.
+func renderK8sSecret(name string, entries []manifest.Entry, options manifest.SecretOptions, publicKey *rsa.PublicKey) ([]byte, error) {
+	if publicKey == nil {
+		return manifest.KubernetesSecret(name, entries, options)
+	}
+
+	return manifest.SealedSecret(name, entries, options, publicKey)
+}
+
+// Reads the values of the secrets given as "name=key" or "key", recording
+// the export with the details.
+func loadManifestEntries(froms []string, details string) ([]manifest.Entry, error) {
+	entries := make([]manifest.Entry, len(froms))
+
+	for i, from := range froms {
+		name, key := manifest.ParseFrom(from)
+
+		secret, err := findSecretByKey(key)
+		if err != nil {
+			return nil, err
+		}
+
+		// Record the export before the value leaves the vault
+		if err := appContext.SecretManager.RecordAccess(audit.ActionExport, secret, details); err != nil {
+			return nil, err
+		}
+
+		value, err := mycrypto.Decrypt(appContext.Passphrase, secret.EncryptedValue)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt secret value: %w", err)
+		}
+
+		entries[i] = manifest.Entry{Name: name, Value: value}
+	}
+
+	return entries, nil
+}
+
+func init() {
+	k8sSecretCmd.Flags().StringSlice("from", nil, "secrets to include, as key or name=key")
+	k8sSecretCmd.Flags().StringP("namespace", "n", "", "namespace of the Secret")
+	k8sSecretCmd.Flags().String("type", manifest.OpaqueType, "type of the Secret")
+	k8sSecretCmd.Flags().Bool("string-data", false, "put the values in plaintext under stringData")
+	k8sSecretCmd.Flags().String("seal-cert", "", "certificate or public key to render a SealedSecret with")
+
+	dockerSecretCmd.Flags().String("dir", "", "write the values to files in this directory")
+
+	k8sCmd.AddCommand(k8sSecretCmd)
+	dockerCmd.AddCommand(dockerSecretCmd)
+	rootCmd.AddCommand(k8sCmd, dockerCmd)
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Isaac-Fate/myst/internal/manifest"
+)
+
+func TestK8sSecretSealWithoutNamespace(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	publicKey, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certPath := filepath.Join(t.TempDir(), "cert.pem")
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKey}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// Fails before asking for the passphrase
+	rootCmd.SetArgs([]string{"k8s", "secret", "app", "--from", "x", "--seal-cert", certPath})
+	defer rootCmd.SetArgs(nil)
+
+	if err := rootCmd.Execute(); err == nil || !strings.Contains(err.Error(), "--namespace") {
+		t.Errorf("expected an error asking for a namespace, got %v", err)
+	}
+
+	// And sealing errors are not lost
+	entries := []manifest.Entry{{Name: "x", Value: "value"}}
+	if _, err := renderK8sSecret("app", entries, manifest.SecretOptions{}, &privateKey.PublicKey); err == nil {
+		t.Error("expected an error sealing without a namespace")
+	}
+
+	content, err := renderK8sSecret("app", entries, manifest.SecretOptions{Namespace: "dev"}, &privateKey.PublicKey)
+	if err != nil || !strings.Contains(string(content), "kind: SealedSecret") {
+		t.Errorf("expected a SealedSecret, got %q, %v", content, err)
+	}
+}
@@ -0,0 +1,195 @@
+/*
+Copyright © 2024 Isaac Fei
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/Isaac-Fate/myst/internal/config"
+	"github.com/Isaac-Fate/myst/internal/sharing"
+	"github.com/spf13/cobra"
+)
+
+var recipientCmd = &cobra.Command{
+	Use:   "recipient",
+	Short: "Manage the teammates you share secrets with",
+}
+
+var recipientAddCmd = &cobra.Command{
+	Use:   "add <name> <public-key>",
+	Short: "Add a teammate's public key",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return updateAddressBook(func(book *sharing.AddressBook) error {
+			if err := book.AddRecipient(args[0], args[1]); err != nil {
+				return err
+			}
+
+			fmt.Printf("✅ Recipient '%s' added\n", args[0])
+			return nil
+		})
+	},
+}
+
+var recipientRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Remove a teammate",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return updateAddressBook(func(book *sharing.AddressBook) error {
+			if err := book.RemoveRecipient(args[0]); err != nil {
+				return err
+			}
+
+			fmt.Printf("✅ Recipient '%s' removed\n", args[0])
+			return nil
+		})
+	},
+}
+
+var recipientListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List teammates and the folders shared with them",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		book, err := sharing.LoadAddressBook(config.AddressBookPath())
+		if err != nil {
+			return err
+		}
+
+		if len(book.Recipients) == 0 {
+			fmt.Println("No recipients found")
+			return nil
+		}
+
+		names := make([]string, 0, len(book.Recipients))
+		for name := range book.Recipients {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			fmt.Printf("👤 %s %s\n", name, book.Recipients[name])
+		}
+
+		folders := make([]string, 0, len(book.Folders))
+		for folder := range book.Folders {
+			folders = append(folders, folder)
+		}
+		sort.Strings(folders)
+
+		for _, folder := range folders {
+			fmt.Printf("📁 %s: %v\n", folder, book.Folders[folder])
+		}
+
+		return nil
+	},
+}
+
+var recipientAssignCmd = &cobra.Command{
+	Use:   "assign <name>",
+	Short: "Share a secret or folder with a teammate by default",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return assignRecipient(cmd, args[0], true)
+	},
+}
+
+var recipientUnassignCmd = &cobra.Command{
+	Use:   "unassign <name>",
+	Short: "Stop sharing a secret or folder with a teammate by default",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return assignRecipient(cmd, args[0], false)
+	},
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{recipientAssignCmd, recipientUnassignCmd} {
+		cmd.Flags().String("secret", "", "key of the secret")
+		cmd.Flags().String("folder", "", "name of the folder")
+		cmd.MarkFlagsOneRequired("secret", "folder")
+		cmd.MarkFlagsMutuallyExclusive("secret", "folder")
+	}
+
+	recipientCmd.AddCommand(
+		recipientAddCmd,
+		recipientRemoveCmd,
+		recipientListCmd,
+		recipientAssignCmd,
+		recipientUnassignCmd,
+	)
+	rootCmd.AddCommand(recipientCmd)
+}
+
+// Loads the address book, applies fn and saves it.
+func updateAddressBook(fn func(book *sharing.AddressBook) error) error {
+	book, err := sharing.LoadAddressBook(config.AddressBookPath())
+	if err != nil {
+		return err
+	}
+
+	if err := fn(book); err != nil {
+		return err
+	}
+
+	return book.Save(config.AddressBookPath())
+}
+
+// Adds the recipient to, or removes it from, the default recipients of the
+// secret or folder given by the flags.
+func assignRecipient(cmd *cobra.Command, name string, assign bool) error {
+	secretKey, _ := cmd.Flags().GetString("secret")
+	folder, _ := cmd.Flags().GetString("folder")
+
+	// Folder recipients live in the address book
+	if folder != "" {
+		return updateAddressBook(func(book *sharing.AddressBook) error {
+			if assign {
+				return book.AssignFolder(folder, name)
+			}
+
+			book.UnassignFolder(folder, name)
+			return nil
+		})
+	}
+
+	// Secret recipients live with the secret
+	book, err := sharing.LoadAddressBook(config.AddressBookPath())
+	if err != nil {
+		return err
+	}
+
+	if _, ok := book.Recipients[name]; assign && !ok {
+		return fmt.Errorf("unknown recipient '%s'", name)
+	}
+
+	if err := unlock(); err != nil {
+		return err
+	}
+	defer appContext.SecretManager.Close()
+
+	secret, err := findSecretByKey(secretKey)
+	if err != nil {
+		return err
+	}
+
+	hasRecipient := slices.Contains(secret.Recipients, name)
+
+	switch {
+	case assign && !hasRecipient:
+		secret.Recipients = append(secret.Recipients, name)
+	case !assign && hasRecipient:
+		secret.Recipients = slices.DeleteFunc(secret.Recipients, func(n string) bool {
+			return n == name
+		})
+	default:
+		return errors.New("nothing to change")
+	}
+
+	return appContext.SecretManager.UpdateSecret(secret)
+}
@@ -0,0 +1,306 @@
+/*
+Copyright © 2024 Isaac Fei
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/Isaac-Fate/myst/internal/config"
+	"github.com/Isaac-Fate/myst/internal/recovery"
+	"github.com/Isaac-Fate/myst/internal/sharing"
+	"github.com/Isaac-Fate/myst/internal/vaultfile"
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/cobra"
+)
+
+var recoveryCmd = &cobra.Command{
+	Use:   "recovery",
+	Short: "Recover a forgotten master passphrase with Shamir shares",
+	Long: `Recover a forgotten master passphrase with Shamir shares.
+
+'split' creates a random recovery key, stores the master passphrase encrypted
+with it, and splits the key into shares for trusted people. Any threshold of
+them can later restore access with 'restore' and set a new passphrase.`,
+}
+
+var recoverySplitCmd = &cobra.Command{
+	Use:   "split",
+	Short: "Split a new recovery key into shares",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		shares, _ := cmd.Flags().GetInt("shares")
+		threshold, _ := cmd.Flags().GetInt("threshold")
+
+		if err := unlockConfig(); err != nil {
+			return err
+		}
+
+		if appContext.Config.RecoveryWrappedPassphrase != "" {
+			fmt.Println("⚠️  Recovery is already set up. Splitting again invalidates all existing shares.")
+
+			confirmPrompt := promptui.Prompt{
+				Label:     "Continue",
+				IsConfirm: true,
+			}
+
+			if _, err := confirmPrompt.Run(); err != nil {
+				return nil // User cancelled
+			}
+		}
+
+		wrappedPassphrase, texts, err := recovery.Split(appContext.Passphrase, shares, threshold)
+		if err != nil {
+			return err
+		}
+
+		appContext.Config.RecoveryWrappedPassphrase = wrappedPassphrase
+		if err := config.Save(&appContext.Config); err != nil {
+			return err
+		}
+
+		fmt.Printf("✅ Recovery key split into %d shares, any %d of them restore access\n", shares, threshold)
+		fmt.Println("Give each share to a different person and keep it somewhere safe:")
+		for i, text := range texts {
+			fmt.Printf("\nShare %d of %d:\n  %s\n", i+1, shares, text)
+		}
+
+		return nil
+	},
+}
+
+var recoveryRestoreCmd = &cobra.Command{
+	Use:   "restore",
+	Short: "Restore access with shares and set a new passphrase",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := config.LoadConfig(&appContext.Config); err != nil {
+			return err
+		}
+
+		if appContext.Config.RecoveryWrappedPassphrase == "" {
+			return errors.New("recovery is not set up, it must be set up with 'myst recovery split' before the passphrase is lost")
+		}
+
+		// Collect shares until the threshold is reached
+		var shares []*recovery.Share
+
+		for len(shares) == 0 || len(shares) < shares[0].Threshold {
+			label := "Enter a recovery share"
+			if len(shares) > 0 {
+				label = fmt.Sprintf("Enter recovery share %d of %d", len(shares)+1, shares[0].Threshold)
+			}
+
+			sharePrompt := promptui.Prompt{
+				Label: label,
+				Validate: func(input string) error {
+					share, err := recovery.ParseShare(input)
+					if err != nil {
+						return err
+					}
+
+					// The same share twice does not count towards the threshold
+					if slices.ContainsFunc(shares, func(entered *recovery.Share) bool {
+						return entered.X == share.X
+					}) {
+						return fmt.Errorf("share number %d was already entered, enter another one", share.X)
+					}
+
+					return nil
+				},
+			}
+
+			text, err := sharePrompt.Run()
+			if err != nil {
+				return err
+			}
+
+			share, _ := recovery.ParseShare(text)
+			shares = append(shares, share)
+		}
+
+		oldPassphrase, recoveryKey, err := recovery.Recover(appContext.Config.RecoveryWrappedPassphrase, shares)
+		if err != nil {
+			return err
+		}
+
+		if !appContext.Config.VerifyPassphrase(oldPassphrase) {
+			return recovery.ErrRecoveryFailed
+		}
+
+		fmt.Println("✅ Shares accepted")
+
+		newPassphrase, err := promptNewPassphrase()
+		if err != nil {
+			return err
+		}
+
+		// Open the vault with the recovered passphrase
+		appContext.Passphrase = oldPassphrase
+		if appContext.Config.ChangingPassphrase() {
+			if err := finishPassphraseChange(); err != nil {
+				return err
+			}
+		}
+		if err := initializeSecretManager(); err != nil {
+			return err
+		}
+		defer appContext.SecretManager.Close()
+
+		if err := changePassphrase(newPassphrase, recoveryKey); err != nil {
+			return err
+		}
+
+		fmt.Println("✅ New passphrase set. The existing recovery shares remain valid.")
+		return nil
+	},
+}
+
+func init() {
+	recoverySplitCmd.Flags().Int("shares", 5, "number of shares to create")
+	recoverySplitCmd.Flags().Int("threshold", 3, "number of shares needed to restore access")
+
+	recoveryCmd.AddCommand(recoverySplitCmd, recoveryRestoreCmd)
+	rootCmd.AddCommand(recoveryCmd)
+}
+
+// Prompts for a new passphrase twice.
+func promptNewPassphrase() (string, error) {
+	passphrasePrompt := promptui.Prompt{
+		Label: "Enter your new master passphrase (min 8 characters)",
+		Mask:  '*',
+		Validate: func(input string) error {
+			if len(input) < 8 {
+				return errors.New("passphrase must be at least 8 characters")
+			}
+			return nil
+		},
+	}
+
+	passphrase, err := passphrasePrompt.Run()
+	if err != nil {
+		return "", err
+	}
+
+	confirmPrompt := promptui.Prompt{
+		Label: "Confirm your new master passphrase",
+		Mask:  '*',
+		Validate: func(input string) error {
+			if input != passphrase {
+				return errors.New("passphrases do not match")
+			}
+			return nil
+		},
+	}
+
+	if _, err := confirmPrompt.Run(); err != nil {
+		return "", err
+	}
+
+	return passphrase, nil
+}
+
+// Re-encrypts everything protected by the current passphrase with a new one.
+//
+// The recovery key, if given, wraps the new passphrase so that the existing
+// shares keep working.
+//
+// The new passphrase is saved as pending before the secrets are
+// re-encrypted, so that whichever passphrase the vault ends up encrypted
+// with still unlocks it if anything fails half way.
+func changePassphrase(newPassphrase string, recoveryKey []byte) error {
+	// Re-encrypt the identity, if there is one
+	identity, err := sharing.LoadIdentity(config.IdentityPath(), appContext.Passphrase)
+	if err != nil && !errors.Is(err, sharing.ErrNoIdentity) {
+		return err
+	}
+
+	// The old shares can no longer unwrap the new passphrase
+	var wrappedPassphrase string
+	if recoveryKey != nil {
+		wrappedPassphrase, err = recovery.Wrap(recoveryKey, newPassphrase)
+		if err != nil {
+			return err
+		}
+	}
+
+	// The audit log stays chained with the same key
+	auditKey, err := appContext.Config.AuditKey(config.DataDir(), appContext.Passphrase)
+	if err != nil {
+		return err
+	}
+
+	if err := appContext.Config.BeginPassphraseChange(newPassphrase, wrappedPassphrase, auditKey); err != nil {
+		return err
+	}
+	if err := config.Save(&appContext.Config); err != nil {
+		return err
+	}
+
+	if identity != nil {
+		if err := sharing.SaveIdentity(config.PendingIdentityPath(config.IdentityPath()), newPassphrase, identity); err != nil {
+			abandonPassphraseChange(newPassphrase)
+			return err
+		}
+	}
+
+	// Re-encrypt the secrets
+	if err := appContext.SecretManager.ChangePassphrase(appContext.Passphrase, newPassphrase); err != nil {
+		abandonPassphraseChange(newPassphrase)
+		return err
+	}
+
+	// Only now does the new passphrase replace the old one
+	if err := appContext.Config.FinishPassphraseChange(config.DataDir(), newPassphrase, true); err != nil {
+		return err
+	}
+
+	appContext.Passphrase = newPassphrase
+
+	if _, err := os.Stat(config.SyncDir()); err == nil {
+		fmt.Println("ℹ️  Run 'myst sync push' and use the new passphrase on your other devices")
+	}
+
+	return nil
+}
+
+// Drops the pending new passphrase after the secrets failed to be
+// re-encrypted. If that fails too, it is dropped at the next unlock.
+func abandonPassphraseChange(newPassphrase string) {
+	appContext.Config.FinishPassphraseChange(config.DataDir(), newPassphrase, false)
+}
+
+// Finishes a change of passphrase that was interrupted, now that a
+// passphrase the configuration verifies was entered, by checking which
+// passphrase the vault is encrypted with. It fails unless it is the one
+// entered.
+func finishPassphraseChange() error {
+	opens := true
+
+	secretManager, err := openSecretManager()
+	switch {
+	case errors.Is(err, vaultfile.ErrInvalidVault):
+		opens = false
+	case err != nil:
+		return fmt.Errorf("failed to initialize secret manager: %w", err)
+	default:
+		opens, err = secretManager.OpensWith(appContext.Passphrase)
+		secretManager.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := appContext.Config.FinishPassphraseChange(config.DataDir(), appContext.Passphrase, opens); err != nil {
+		return fmt.Errorf("failed to finish changing the passphrase: %w", err)
+	}
+
+	if !opens {
+		return errors.New("wrong passphrase, the vault is encrypted with the other passphrase since changing it was interrupted")
+	}
+
+	return nil
+}
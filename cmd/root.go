@@ -6,14 +6,17 @@ package cmd
 import (
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 
 	"github.com/Isaac-Fate/myst/cmd/context"
 	"github.com/Isaac-Fate/myst/cmd/handlers"
+	"github.com/Isaac-Fate/myst/internal/audit"
 	"github.com/Isaac-Fate/myst/internal/config"
 	mycrypto "github.com/Isaac-Fate/myst/internal/crypto"
 	"github.com/Isaac-Fate/myst/internal/manager"
+	"github.com/Isaac-Fate/myst/internal/models"
 	"github.com/manifoldco/promptui"
 	"github.com/spf13/cobra"
 )
@@ -26,20 +29,12 @@ var rootCmd = &cobra.Command{
 	Short: "MyST (My SecreTs) -- A Simple Secret Value Manager",
 	Long:  `A simple and secure secret manager for storing and retrieving sensitive information.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		// First, ensure we have a valid configuration
-		if err := initializeConfig(); err != nil {
+		if err := unlock(); err != nil {
 			return err
 		}
+		defer appContext.SecretManager.Close()
 
-		// Prompt for passphrase
-		if err := loadPassphrase(); err != nil {
-			return err
-		}
-
-		// Then initialize the secret manager
-		if err := initializeSecretManager(); err != nil {
-			return err
-		}
+		handlers.WarnDueSecrets(&appContext)
 
 		// Start the interactive command loop
 		return startCommandLoop()
@@ -47,12 +42,45 @@ var rootCmd = &cobra.Command{
 }
 
 func Execute() {
+	// Run as a docker credential helper when linked under its name
+	if runAsDockerCredential() {
+		rootCmd.SetArgs(append([]string{dockerCredentialCmd.Name()}, os.Args[1:]...))
+	}
+
+	// And as the Ansible vault password client
+	if runAsAnsibleVaultClient() {
+		rootCmd.SetArgs(append([]string{ansibleVaultClientCmd.Name()}, os.Args[1:]...))
+	}
+
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
 }
 
+// Loads the configuration, prompts for the passphrase and opens the secret
+// manager. Callers must close the secret manager when they are done.
+func unlock() error {
+	if err := unlockConfig(); err != nil {
+		return err
+	}
+
+	// Then initialize the secret manager
+	return initializeSecretManager()
+}
+
+// Loads the configuration and prompts for the passphrase without opening the
+// secret manager.
+func unlockConfig() error {
+	// First, ensure we have a valid configuration
+	if err := initializeConfig(); err != nil {
+		return err
+	}
+
+	// Prompt for passphrase
+	return loadPassphrase()
+}
+
 // Initialize the configuration
 func initializeConfig() error {
 	// Try to load existing config
@@ -92,9 +120,29 @@ func createInitialConfig() error {
 		return err
 	}
 
+	// Prompt for the storage backend
+	backendPrompt := promptui.Select{
+		Label: "Choose how to store your secrets",
+		Items: []string{
+			"SQLite database (default)",
+			"Single encrypted file (easy to sync and back up)",
+		},
+	}
+
+	backendIdx, _, err := backendPrompt.Run()
+	if err != nil {
+		return err
+	}
+
+	backend := config.SQLiteBackend
+	if backendIdx == 1 {
+		backend = config.FileBackend
+	}
+
 	// Create and save the configuration
 	appContext.Config = config.Config{
 		DigestedPassphrase: mycrypto.DigestPassphrase(passphrase),
+		Backend:            backend,
 	}
 
 	if err := config.Save(&appContext.Config); err != nil {
@@ -107,9 +155,17 @@ func createInitialConfig() error {
 
 // Load the passphrase from the user
 func loadPassphrase() error {
+	return promptPassphrase(nil, nil)
+}
+
+// Prompts for the passphrase on the given input and output, or on stdin and
+// stdout if they are nil, and verifies it.
+func promptPassphrase(stdin io.ReadCloser, stdout io.WriteCloser) error {
 	passphrasePrompt := promptui.Prompt{
-		Label: "🔑 Enter your master passphrase",
-		Mask:  '*',
+		Label:  "🔑 Enter your master passphrase",
+		Mask:   '*',
+		Stdin:  stdin,
+		Stdout: stdout,
 	}
 
 	inputPassphrase, err := passphrasePrompt.Run()
@@ -118,25 +174,65 @@ func loadPassphrase() error {
 	}
 
 	// Verify the passphrase
-	if !mycrypto.VerifyPassphrase(inputPassphrase, appContext.Config.DigestedPassphrase) {
+	if !appContext.Config.VerifyPassphrase(inputPassphrase) {
 		return errors.New("wrong passphrase")
 	}
 
 	// Set the passphrase
 	appContext.Passphrase = inputPassphrase
 
+	if appContext.Config.ChangingPassphrase() {
+		return finishPassphraseChange()
+	}
+
 	return nil
 }
 
 func initializeSecretManager() error {
 	var err error
-	appContext.SecretManager, err = manager.NewSecretManager(config.SecretStorePath(), config.SecretIndexPath())
+	appContext.SecretManager, err = openSecretManager()
 	if err != nil {
 		return fmt.Errorf("failed to initialize secret manager: %w", err)
 	}
+
+	auditLog, err := openAuditLog()
+	if err != nil {
+		appContext.SecretManager.Close()
+		return err
+	}
+
+	appContext.SecretManager.SetAuditLog(auditLog)
 	return nil
 }
 
+// Opens the audit log with the key the passphrase unlocks.
+func openAuditLog() (*audit.Log, error) {
+	key, err := appContext.Config.AuditKey(config.DataDir(), appContext.Passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	return audit.Open(config.AuditLogPath(), key), nil
+}
+
+// Opens the secret manager of the configured backend.
+func openSecretManager() (*manager.SecretManager, error) {
+	switch backend := appContext.Config.StorageBackend(); backend {
+	case config.SQLiteBackend:
+		return manager.NewSecretManager(config.SecretStorePath(), config.SecretIndexPath())
+	case config.FileBackend:
+		return manager.NewFileSecretManager(config.VaultFilePath(), appContext.Passphrase)
+	default:
+		return nil, fmt.Errorf("unknown storage backend '%s'", backend)
+	}
+}
+
+// Finds the secret with exactly the given key, suggesting similar keys if
+// there is none.
+func findSecretByKey(key string) (*models.Secret, error) {
+	return handlers.LookupSecret(&appContext, key, manager.MatchExactKey)
+}
+
 func startCommandLoop() error {
 	commands := []struct {
 		Name        string
@@ -147,7 +243,7 @@ func startCommandLoop() error {
 		{"find", "Search for secrets", handlers.FindSecrets},
 		{"list", "List all secrets", handlers.ListSecrets},
 		{"update", "Update an existing secret", handlers.UpdateSecret},
-		{"remove", "Remove a secret", handlers.RemoveSecret},
+		{"remove", "Move a secret to the trash", handlers.RemoveSecret},
 		{"help", "Show help message", handlers.ShowHelp},
 		{"quit", "Exit the application", nil},
 	}
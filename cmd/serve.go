@@ -0,0 +1,110 @@
+/*
+Copyright © 2024 Isaac Fei
+*/
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/Isaac-Fate/myst/internal/server"
+	"github.com/spf13/cobra"
+)
+
+// How long running requests may take to finish when the server stops.
+const shutdownTimeout time.Duration = 5 * time.Second
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve a REST API for programs to read and change secrets",
+	Long: `Serve a REST API for programs to read and change secrets.
+
+Requests need a token created with 'myst token create'. The API is
+described at /v1/openapi.json. The vault stays unlocked while the server
+runs, so listen on the loopback address or on a Unix socket:
+
+  myst serve --listen 127.0.0.1:7755
+  myst serve --listen unix:$HOME/.myst.sock`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		address, _ := cmd.Flags().GetString("listen")
+
+		if err := unlock(); err != nil {
+			return err
+		}
+		defer appContext.SecretManager.Close()
+
+		listener, err := server.Listen(address)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %s: %w", address, err)
+		}
+
+		if !isLocalAddress(listener.Addr()) {
+			fmt.Fprintf(os.Stderr, "⚠️  Listening on %s, which other machines may reach. Tokens and values are sent unencrypted.\n", listener.Addr())
+		}
+
+		httpServer := &http.Server{
+			Handler:           server.New(appContext.SecretManager, appContext.Passphrase, server.Options{}),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+
+		// Stop on Ctrl+C or SIGTERM, letting running requests finish
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		go func() {
+			<-ctx.Done()
+
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+			defer cancel()
+
+			httpServer.Shutdown(shutdownCtx)
+		}()
+
+		fmt.Printf("🚀 Serving the API on %s, press Ctrl+C to stop\n", describeListener(listener.Addr()))
+
+		if err := httpServer.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+
+		fmt.Println("👋 Server stopped")
+		return nil
+	},
+}
+
+// Reports whether only this machine can connect to the address.
+func isLocalAddress(address net.Addr) bool {
+	if address.Network() == "unix" {
+		return true
+	}
+
+	host, _, err := net.SplitHostPort(address.String())
+	if err != nil {
+		return false
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Describes where the server listens, as a URL for TCP addresses.
+func describeListener(address net.Addr) string {
+	if address.Network() == "unix" {
+		return "unix:" + address.String()
+	}
+
+	return "http://" + address.String()
+}
+
+func init() {
+	serveCmd.Flags().String("listen", "127.0.0.1:7755", "address to listen on, host:port or unix:/path/to/socket")
+
+	rootCmd.AddCommand(serveCmd)
+}
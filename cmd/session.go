@@ -0,0 +1,113 @@
+/*
+Copyright © 2024 Isaac Fei
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Isaac-Fate/myst/internal/config"
+	"github.com/Isaac-Fate/myst/internal/session"
+	"github.com/spf13/cobra"
+)
+
+// Helpers run by other programs, such as the git credential helper, use
+// stdin and stdout to talk to them. They reach the secrets through a running
+// 'myst serve' if given one, so that the passphrase is not asked for every
+// time, and otherwise ask for the passphrase on the terminal.
+
+// Adds the flags for reaching a running 'myst serve'.
+func addSessionFlags(cmd *cobra.Command) {
+	cmd.Flags().String("server", os.Getenv("MYST_SERVER"), "address of a running 'myst serve' ($MYST_SERVER)")
+	cmd.Flags().String("token-file", os.Getenv("MYST_TOKEN_FILE"), "file holding the API token, instead of $MYST_TOKEN ($MYST_TOKEN_FILE)")
+}
+
+// Opens a session on the server given by the flags, or on the vault
+// unlocked with the passphrase asked for on the terminal. Accesses are
+// recorded in the audit log with the details.
+func openSession(cmd *cobra.Command, details string) (session.Session, error) {
+	address, _ := cmd.Flags().GetString("server")
+	if address == "" {
+		if err := unlockOnTerminal(); err != nil {
+			return nil, err
+		}
+
+		return session.Local(appContext.SecretManager, appContext.Passphrase, details), nil
+	}
+
+	token := os.Getenv("MYST_TOKEN")
+	if tokenFile, _ := cmd.Flags().GetString("token-file"); tokenFile != "" {
+		content, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the token file: %w", err)
+		}
+		token = strings.TrimSpace(string(content))
+	}
+
+	if token == "" {
+		return nil, errors.New("an API token is needed to use the server, set $MYST_TOKEN or pass --token-file")
+	}
+
+	return session.Remote(address, token), nil
+}
+
+// Unlocks the vault, asking for the passphrase on the terminal rather than
+// on stdin and stdout.
+func unlockOnTerminal() error {
+	err := config.LoadConfig(&appContext.Config)
+	if errors.Is(err, os.ErrNotExist) {
+		return errors.New("no vault is set up yet, run 'myst' first")
+	}
+	if err != nil {
+		return err
+	}
+
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("failed to open the terminal to ask for the passphrase, pass --server to use a running 'myst serve': %w", err)
+	}
+	defer tty.Close()
+
+	if err := promptPassphrase(tty, tty); err != nil {
+		return err
+	}
+
+	return initializeSecretManager()
+}
+
+// An error a helper exits with a code of its own for, rather than 1.
+type helperExitError struct {
+	err  error
+	code int
+}
+
+func (e *helperExitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *helperExitError) Unwrap() error {
+	return e.err
+}
+
+// Runs a helper, writing any error to w rather than printing it with the
+// usual prefix, and exiting with a failure. The exit code is 1, or that of a
+// helperExitError.
+//
+// It exits only once fn has returned, so the deferred calls of fn have run.
+func runHelper(w io.Writer, fn func() error) {
+	if err := fn(); err != nil {
+		fmt.Fprintf(w, "%v\n", err)
+
+		code := 1
+		var exitErr *helperExitError
+		if errors.As(err, &exitErr) {
+			code = exitErr.code
+		}
+
+		os.Exit(code)
+	}
+}
@@ -0,0 +1,350 @@
+/*
+Copyright © 2024 Isaac Fei
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/Isaac-Fate/myst/internal/audit"
+	"github.com/Isaac-Fate/myst/internal/config"
+	mycrypto "github.com/Isaac-Fate/myst/internal/crypto"
+	"github.com/Isaac-Fate/myst/internal/manager"
+	"github.com/Isaac-Fate/myst/internal/models"
+	"github.com/Isaac-Fate/myst/internal/sharing"
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/cobra"
+)
+
+var shareCmd = &cobra.Command{
+	Use:   "share [key...]",
+	Short: "Encrypt secrets into a bundle only the recipients can import",
+	Long: `Encrypt secrets into a bundle only the recipients can import.
+
+The bundle is printed as armored text, or written to the file given by
+--output. Without --to, every secret is shared with its default recipients,
+which are those assigned to the secret and to its folder.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		to, _ := cmd.Flags().GetStringSlice("to")
+		folder, _ := cmd.Flags().GetString("folder")
+		output, _ := cmd.Flags().GetString("output")
+
+		if len(args) == 0 && folder == "" {
+			return errors.New("specify the keys of the secrets to share or a --folder")
+		}
+
+		book, err := sharing.LoadAddressBook(config.AddressBookPath())
+		if err != nil {
+			return err
+		}
+
+		// Keep the prompt out of a bundle written to stdout
+		unlockVault := unlock
+		if output == "" {
+			unlockVault = unlockOnTerminal
+		}
+
+		if err := unlockVault(); err != nil {
+			return err
+		}
+		defer appContext.SecretManager.Close()
+
+		identity, err := sharing.LoadIdentity(config.IdentityPath(), appContext.Passphrase)
+		if err != nil {
+			return err
+		}
+
+		// Collect the secrets
+		var secrets []models.Secret
+
+		for _, key := range args {
+			secret, err := findSecretByKey(key)
+			if err != nil {
+				return err
+			}
+
+			secrets = append(secrets, *secret)
+		}
+
+		if folder != "" {
+			allSecrets, err := appContext.SecretManager.ListSecrets()
+			if err != nil {
+				return err
+			}
+
+			for _, secret := range allSecrets {
+				if secret.Folder == folder && !slices.ContainsFunc(secrets, func(s models.Secret) bool {
+					return s.ID == secret.ID
+				}) {
+					secrets = append(secrets, secret)
+				}
+			}
+		}
+
+		if len(secrets) == 0 {
+			return fmt.Errorf("no secrets found in folder '%s'", folder)
+		}
+
+		// Default to the recipients assigned to the secrets
+		if len(to) == 0 {
+			for _, secret := range secrets {
+				for _, name := range book.RecipientsOf(&secret) {
+					if !slices.Contains(to, name) {
+						to = append(to, name)
+					}
+				}
+			}
+		}
+
+		if len(to) == 0 {
+			return errors.New("no recipients, use --to or assign recipients with 'myst recipient assign'")
+		}
+
+		recipients, err := book.Resolve(to)
+		if err != nil {
+			return err
+		}
+
+		// Decrypt the values into the bundle
+		sharedSecrets := make([]sharing.SharedSecret, 0, len(secrets))
+
+		for _, secret := range secrets {
+			details := "shared with " + strings.Join(to, ", ")
+			if err := appContext.SecretManager.RecordAccess(audit.ActionExport, &secret, details); err != nil {
+				return err
+			}
+
+			value, err := mycrypto.Decrypt(appContext.Passphrase, secret.EncryptedValue)
+			if err != nil {
+				return fmt.Errorf("failed to decrypt secret value: %w", err)
+			}
+
+			sharedSecrets = append(sharedSecrets, sharing.SharedSecret{
+				Key:      secret.Key,
+				Value:    value,
+				Kind:     secret.Kind,
+				Username: secret.Username,
+				Website:  secret.Website,
+				Notes:    secret.Notes,
+				Folder:   secret.Folder,
+			})
+		}
+
+		bundle := sharing.NewBundle(identity, sharedSecrets)
+
+		var dst io.Writer = os.Stdout
+		if output != "" {
+			file, err := os.OpenFile(output, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+
+			dst = file
+		}
+
+		if err := sharing.Seal(dst, bundle, recipients...); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(os.Stderr, "✅ Shared %d secret(s) with %v\n", len(sharedSecrets), to)
+		return nil
+	},
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import [bundle-file]",
+	Short: "Import secrets from a bundle shared with you",
+	Long: `Import secrets from a bundle shared with you.
+
+The bundle is read from the given file, or from standard input if the file
+is omitted or '-'. Imported values are re-encrypted with your own master
+passphrase.
+
+The sender is shown by their name in your address book. Bundles from keys
+not in it are marked as unverified, and imported only once you confirm.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var src io.Reader = os.Stdin
+		if len(args) == 1 && args[0] != "-" {
+			file, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+
+			src = file
+		}
+
+		// Prompts use the standard streams, or the terminal if the bundle is
+		// read from stdin
+		var promptIn io.ReadCloser
+		var promptOut io.WriteCloser
+
+		unlockVault := unlock
+		if src == os.Stdin {
+			tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+			if err != nil {
+				return fmt.Errorf("failed to open the terminal to ask for the passphrase: %w", err)
+			}
+			defer tty.Close()
+
+			promptIn, promptOut = tty, tty
+			unlockVault = unlockOnTerminal
+		}
+
+		if err := unlockVault(); err != nil {
+			return err
+		}
+		defer appContext.SecretManager.Close()
+
+		identity, err := sharing.LoadIdentity(config.IdentityPath(), appContext.Passphrase)
+		if err != nil {
+			return err
+		}
+
+		bundle, err := sharing.Open(src, identity)
+		if err != nil {
+			return err
+		}
+
+		book, err := sharing.LoadAddressBook(config.AddressBookPath())
+		if err != nil {
+			return err
+		}
+
+		// The bundle proves who holds the key it was sent with, but only the
+		// address book tells whose key that is
+		if name, ok := book.NameOf(bundle.FromRecipient); ok {
+			fmt.Printf("📦 Bundle from %s with %d secret(s)\n", name, len(bundle.Secrets))
+		} else {
+			fmt.Printf("⚠️  Bundle from an unverified sender calling themselves '%s', whose key %s is not in your address book\n", bundle.From, bundle.FromRecipient)
+			fmt.Printf("It has %d secret(s):\n", len(bundle.Secrets))
+			for _, shared := range bundle.Secrets {
+				fmt.Printf("  - %s\n", shared.Key)
+			}
+
+			confirmPrompt := promptui.Prompt{
+				Label:     "Import them anyway",
+				IsConfirm: true,
+				Stdin:     promptIn,
+				Stdout:    promptOut,
+			}
+
+			if _, err := confirmPrompt.Run(); err != nil {
+				return errors.New("import cancelled, add the sender with 'myst recipient add' once you have checked their key")
+			}
+		}
+
+		imported := 0
+		for _, shared := range bundle.Secrets {
+			ok, err := importSharedSecret(&shared, promptIn, promptOut)
+			if err != nil {
+				return err
+			}
+
+			if ok {
+				imported++
+			}
+		}
+
+		fmt.Printf("✅ Imported %d secret(s)\n", imported)
+		return nil
+	},
+}
+
+func init() {
+	shareCmd.Flags().StringSlice("to", nil, "names of the recipients (default: recipients assigned to the secrets)")
+	shareCmd.Flags().String("folder", "", "share every secret in the folder")
+	shareCmd.Flags().StringP("output", "o", "", "write the bundle to a file instead of standard output")
+
+	rootCmd.AddCommand(shareCmd, importCmd)
+}
+
+// Adds a shared secret, asking what to do if the key is taken. It reports
+// whether the secret was imported.
+//
+// The prompts use stdin and stdout, or the standard streams if nil.
+func importSharedSecret(shared *sharing.SharedSecret, stdin io.ReadCloser, stdout io.WriteCloser) (bool, error) {
+	encryptedValue, err := mycrypto.Encrypt(appContext.Passphrase, shared.Value)
+	if err != nil {
+		return false, err
+	}
+
+	label := fmt.Sprintf("Secret '%s' already exists", shared.Key)
+
+	existing, err := appContext.SecretManager.GetSecretByKey(shared.Key, manager.MatchExactKey)
+	if errors.Is(err, models.ErrSecretNotFound) {
+		// The key may still be taken by a secret in the trash
+		existing, err = appContext.SecretManager.GetTrashedSecretByKey(shared.Key)
+		if err == nil {
+			label = fmt.Sprintf("Secret '%s' is in the trash", shared.Key)
+		}
+	}
+	if errors.Is(err, models.ErrSecretNotFound) {
+		// The key is free
+		return true, appContext.SecretManager.AddSecret(&models.Secret{
+			Key:            shared.Key,
+			EncryptedValue: encryptedValue,
+			Kind:           shared.Kind,
+			Username:       shared.Username,
+			Website:        shared.Website,
+			Notes:          shared.Notes,
+			Folder:         shared.Folder,
+		})
+	}
+	if err != nil {
+		return false, err
+	}
+
+	trashed := existing.DeletedAt.Valid
+
+	overwrite := "Overwrite the value"
+	if trashed {
+		overwrite = "Restore it and overwrite the value"
+	}
+
+	prompt := promptui.Select{
+		Label:  label,
+		Items:  []string{"Skip", overwrite},
+		Stdin:  stdin,
+		Stdout: stdout,
+	}
+
+	idx, _, err := prompt.Run()
+	if err != nil {
+		return false, err
+	}
+
+	if idx == 0 {
+		return false, nil
+	}
+
+	if trashed {
+		if err := appContext.SecretManager.RestoreSecret(existing); err != nil {
+			return false, fmt.Errorf("failed to restore secret '%s': %w", existing.Key, err)
+		}
+	}
+
+	existing.EncryptedValue = encryptedValue
+	existing.Kind = shared.Kind
+	if shared.Username != "" {
+		existing.Username = shared.Username
+	}
+	if shared.Website != "" {
+		existing.Website = shared.Website
+	}
+	if shared.Notes != "" {
+		existing.Notes = shared.Notes
+	}
+	if shared.Folder != "" {
+		existing.Folder = shared.Folder
+	}
+
+	return true, appContext.SecretManager.UpdateSecret(existing)
+}
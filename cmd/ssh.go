@@ -0,0 +1,380 @@
+/*
+Copyright © 2024 Isaac Fei
+*/
+package cmd
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/Isaac-Fate/myst/internal/audit"
+	"github.com/Isaac-Fate/myst/internal/config"
+	mycrypto "github.com/Isaac-Fate/myst/internal/crypto"
+	"github.com/Isaac-Fate/myst/internal/models"
+	"github.com/Isaac-Fate/myst/internal/server"
+	"github.com/Isaac-Fate/myst/internal/sshagent"
+	"github.com/Isaac-Fate/myst/internal/sshkey"
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh"
+)
+
+// SSH private keys are kept as secrets of the SSH key kind, so that they
+// need not sit unencrypted in ~/.ssh, and are served by 'myst ssh-agent'.
+
+var sshKeyCmd = &cobra.Command{
+	Use:   "ssh-key",
+	Short: "Generate, import and list SSH keys kept in the vault",
+}
+
+var sshKeyGenerateCmd = &cobra.Command{
+	Use:   "generate <key>",
+	Short: "Generate an SSH key and print its public key",
+	Long: `Generate an SSH key, keep it as a secret and print its public key, to
+add to authorized_keys or to a code host.
+
+  myst ssh-key generate github-ssh
+  myst ssh-key generate legacy-ssh --type rsa --bits 4096`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		keyType, _ := cmd.Flags().GetString("type")
+		bits, _ := cmd.Flags().GetInt("bits")
+		comment, _ := cmd.Flags().GetString("comment")
+		folder, _ := cmd.Flags().GetString("folder")
+
+		if err := unlock(); err != nil {
+			return err
+		}
+		defer appContext.SecretManager.Close()
+
+		value, err := sshkey.Generate(keyType, bits, comment)
+		if err != nil {
+			return fmt.Errorf("failed to generate key: %w", err)
+		}
+
+		return addSSHKey(args[0], value, comment, folder)
+	},
+}
+
+var sshKeyImportCmd = &cobra.Command{
+	Use:   "import <key> <file>",
+	Short: "Import an SSH private key from a file",
+	Long: `Import an SSH private key from a file, such as ~/.ssh/id_ed25519, and
+keep it as a secret. A passphrase protecting the file is asked for; the key
+is then only protected by the vault.
+
+Once imported, the file can be deleted.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		comment, _ := cmd.Flags().GetString("comment")
+		folder, _ := cmd.Flags().GetString("folder")
+
+		content, err := os.ReadFile(args[1])
+		if err != nil {
+			return fmt.Errorf("failed to read the key file: %w", err)
+		}
+
+		if err := unlock(); err != nil {
+			return err
+		}
+		defer appContext.SecretManager.Close()
+
+		value, err := sshkey.Import(content, nil, comment)
+		if errors.Is(err, sshkey.ErrPassphraseNeeded) {
+			passphrasePrompt := promptui.Prompt{
+				Label: "🔑 Enter the passphrase of the key file",
+				Mask:  '*',
+			}
+
+			var passphrase string
+			passphrase, err = passphrasePrompt.Run()
+			if err != nil {
+				return err
+			}
+
+			value, err = sshkey.Import(content, []byte(passphrase), comment)
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := addSSHKey(args[0], value, comment, folder); err != nil {
+			return err
+		}
+
+		fmt.Printf("You can now delete %s\n", args[1])
+		return nil
+	},
+}
+
+var sshKeyListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the SSH keys with their fingerprints",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := unlock(); err != nil {
+			return err
+		}
+		defer appContext.SecretManager.Close()
+
+		keys, err := loadSSHKeys(nil)
+		if err != nil {
+			return err
+		}
+
+		if len(keys) == 0 {
+			fmt.Println("No SSH keys yet, see 'myst ssh-key generate' and 'myst ssh-key import'")
+			return nil
+		}
+
+		for _, key := range keys {
+			fmt.Printf("🗝️  %s  %s\n", key.secret.Key, sshkey.Describe(key.publicKey))
+		}
+
+		return nil
+	},
+}
+
+var sshKeyPublicCmd = &cobra.Command{
+	Use:   "public <key>",
+	Short: "Print the public key of an SSH key",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := unlock(); err != nil {
+			return err
+		}
+		defer appContext.SecretManager.Close()
+
+		keys, err := loadSSHKeys(args)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(sshkey.AuthorizedKey(keys[0].publicKey, publicKeyComment(keys[0].secret)))
+		return nil
+	},
+}
+
+var sshAgentCmd = &cobra.Command{
+	Use:   "ssh-agent [key...]",
+	Short: "Serve the SSH keys in the vault to ssh",
+	Long: `Serve the SSH keys in the vault, or only those given, as an SSH agent on a
+Unix socket only you can access. Point ssh at it with SSH_AUTH_SOCK:
+
+  myst ssh-agent
+  export SSH_AUTH_SOCK=~/myst/ssh-agent.sock
+  ssh-add -l
+
+Every signature is recorded in the audit log. With --confirm, each use of
+a key has to be allowed in the terminal the agent runs in.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		socket, _ := cmd.Flags().GetString("socket")
+		confirm, _ := cmd.Flags().GetBool("confirm")
+
+		if err := unlock(); err != nil {
+			return err
+		}
+		defer appContext.SecretManager.Close()
+
+		keys, err := loadSSHKeys(args)
+		if err != nil {
+			return err
+		}
+
+		if len(keys) == 0 {
+			return errors.New("no SSH keys to serve, see 'myst ssh-key generate' and 'myst ssh-key import'")
+		}
+
+		secretsByName := make(map[string]*models.Secret, len(keys))
+		agentKeys := make([]sshagent.Key, len(keys))
+		for i, key := range keys {
+			secretsByName[key.secret.Key] = key.secret
+			agentKeys[i] = sshagent.Key{Name: key.secret.Key, PrivateKey: key.privateKey}
+		}
+
+		options := sshagent.Options{
+			Record: func(name string) error {
+				return appContext.SecretManager.RecordAccess(audit.ActionSign, secretsByName[name], "ssh-agent")
+			},
+		}
+
+		if confirm {
+			options.Confirm = func(name string) bool {
+				prompt := promptui.Prompt{
+					Label:     fmt.Sprintf("🔐 Allow signing with '%s'", name),
+					IsConfirm: true,
+				}
+
+				result, err := prompt.Run()
+				return err == nil && strings.EqualFold(result, "y")
+			}
+		}
+
+		sshAgent, err := sshagent.New(agentKeys, options)
+		if err != nil {
+			return err
+		}
+
+		if socket == "" {
+			socket = config.SSHAgentSocketPath()
+		}
+
+		listener, err := server.Listen("unix:" + socket)
+		if err != nil {
+			return fmt.Errorf("failed to listen on %s: %w", socket, err)
+		}
+		defer os.Remove(socket)
+
+		// Stop on Ctrl+C or SIGTERM
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		go func() {
+			<-ctx.Done()
+			listener.Close()
+		}()
+
+		fmt.Printf("🚀 Serving %d SSH keys, press Ctrl+C to stop\n", len(keys))
+		for _, key := range keys {
+			fmt.Printf("🗝️  %s  %s\n", key.secret.Key, sshkey.Describe(key.publicKey))
+		}
+		fmt.Printf("SSH_AUTH_SOCK=%s; export SSH_AUTH_SOCK;\n", socket)
+
+		if err := sshAgent.Serve(listener); err != nil {
+			return err
+		}
+
+		fmt.Println("👋 Agent stopped")
+		return nil
+	},
+}
+
+// Adds an SSH key as a secret and prints its public key.
+func addSSHKey(key string, value string, comment string, folder string) error {
+	publicKey, err := sshkey.PublicKey(value)
+	if err != nil {
+		return err
+	}
+
+	encryptedValue, err := mycrypto.Encrypt(appContext.Passphrase, value)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt secret value: %w", err)
+	}
+
+	secret := &models.Secret{
+		Key:            key,
+		EncryptedValue: encryptedValue,
+		Kind:           models.KindSSHKey,
+		Notes:          comment,
+		Folder:         folder,
+	}
+
+	err = appContext.SecretManager.AddSecret(secret)
+	if errors.Is(err, models.ErrDuplicateKey) || errors.Is(err, models.ErrKeyInTrash) {
+		return fmt.Errorf("a secret with key '%s' already exists", key)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to add secret: %w", err)
+	}
+
+	fmt.Printf("✅ SSH key '%s' added, %s\n", key, sshkey.Describe(publicKey))
+	fmt.Println(sshkey.AuthorizedKey(publicKey, publicKeyComment(secret)))
+	return nil
+}
+
+// Returns the comment printed after the public key of an SSH key: the
+// comment given when it was added, kept in the notes, or else its key.
+func publicKeyComment(secret *models.Secret) string {
+	if secret.Notes != "" {
+		return secret.Notes
+	}
+
+	return secret.Key
+}
+
+// An SSH key kept as a secret.
+type storedSSHKey struct {
+	secret     *models.Secret
+	privateKey crypto.PrivateKey
+	publicKey  ssh.PublicKey
+}
+
+// Loads the SSH keys with the given keys, or every SSH key if none are
+// given, by key.
+func loadSSHKeys(keys []string) ([]storedSSHKey, error) {
+	var secrets []models.Secret
+
+	if len(keys) == 0 {
+		all, err := appContext.SecretManager.ListSecrets()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, secret := range all {
+			if secret.Kind == models.KindSSHKey {
+				secrets = append(secrets, secret)
+			}
+		}
+
+		slices.SortFunc(secrets, func(a, b models.Secret) int {
+			return strings.Compare(a.Key, b.Key)
+		})
+	}
+
+	for _, key := range keys {
+		secret, err := findSecretByKey(key)
+		if err != nil {
+			return nil, err
+		}
+		if secret.Kind != models.KindSSHKey {
+			return nil, fmt.Errorf("secret '%s' is not an SSH key", key)
+		}
+
+		secrets = append(secrets, *secret)
+	}
+
+	stored := make([]storedSSHKey, len(secrets))
+	for i := range secrets {
+		value, err := mycrypto.Decrypt(appContext.Passphrase, secrets[i].EncryptedValue)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt secret value: %w", err)
+		}
+
+		privateKey, err := sshkey.ParsePrivateKey(value)
+		if err != nil {
+			return nil, fmt.Errorf("secret '%s': %w", secrets[i].Key, err)
+		}
+
+		publicKey, err := sshkey.PublicKey(value)
+		if err != nil {
+			return nil, fmt.Errorf("secret '%s': %w", secrets[i].Key, err)
+		}
+
+		stored[i] = storedSSHKey{secret: &secrets[i], privateKey: privateKey, publicKey: publicKey}
+	}
+
+	return stored, nil
+}
+
+func init() {
+	sshKeyGenerateCmd.Flags().String("type", sshkey.TypeEd25519, "key type, ed25519 or rsa")
+	sshKeyGenerateCmd.Flags().Int("bits", sshkey.DefaultRSABits, "size of RSA keys")
+
+	for _, cmd := range []*cobra.Command{sshKeyGenerateCmd, sshKeyImportCmd} {
+		cmd.Flags().String("comment", "", "comment of the public key, such as you@laptop")
+		cmd.Flags().String("folder", "", "folder to put the key in")
+	}
+
+	sshAgentCmd.Flags().String("socket", "", "path of the socket (default ~/myst/ssh-agent.sock)")
+	sshAgentCmd.Flags().Bool("confirm", false, "ask before each use of a key")
+
+	sshKeyCmd.AddCommand(sshKeyGenerateCmd, sshKeyImportCmd, sshKeyListCmd, sshKeyPublicCmd)
+	rootCmd.AddCommand(sshKeyCmd, sshAgentCmd)
+}
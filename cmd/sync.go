@@ -0,0 +1,166 @@
+/*
+Copyright © 2024 Isaac Fei
+*/
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Isaac-Fate/myst/internal/config"
+	"github.com/Isaac-Fate/myst/internal/gitsync"
+	"github.com/Isaac-Fate/myst/internal/models"
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/cobra"
+)
+
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "Sync the vault with a private git repository",
+	Long: `Sync the vault with a private git repository.
+
+Every secret is stored in its own encrypted file named after its ID, so
+commits and merges only touch the secrets that changed. All devices syncing
+the same repository must use the same master passphrase.`,
+}
+
+var syncInitCmd = &cobra.Command{
+	Use:   "init <remote>",
+	Short: "Set the git remote to sync with",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runSync(func(syncer *gitsync.Syncer) error {
+			if err := syncer.Init(args[0]); err != nil {
+				return err
+			}
+
+			fmt.Printf("✅ Sync initialized with %s\n", args[0])
+			fmt.Println("Run 'myst sync pull' to fetch the secrets already in the remote")
+			return nil
+		})
+	},
+}
+
+var syncPushCmd = &cobra.Command{
+	Use:   "push",
+	Short: "Pull remote changes, then push local changes",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runSync(func(syncer *gitsync.Syncer) error {
+			report, err := syncer.Push()
+			if err != nil {
+				return err
+			}
+
+			printSyncReport(report)
+			return nil
+		})
+	},
+}
+
+var syncPullCmd = &cobra.Command{
+	Use:   "pull",
+	Short: "Apply remote changes to the local vault",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runSync(func(syncer *gitsync.Syncer) error {
+			report, err := syncer.Pull()
+			if err != nil {
+				return err
+			}
+
+			printSyncReport(report)
+			return nil
+		})
+	},
+}
+
+func init() {
+	syncCmd.AddCommand(syncInitCmd, syncPushCmd, syncPullCmd)
+	rootCmd.AddCommand(syncCmd)
+}
+
+// Unlocks the vault and runs fn with a syncer.
+func runSync(fn func(syncer *gitsync.Syncer) error) error {
+	if err := unlock(); err != nil {
+		return err
+	}
+	defer appContext.SecretManager.Close()
+
+	syncer := gitsync.New(
+		config.SyncDir(),
+		config.SyncStatePath(),
+		appContext.Passphrase,
+		appContext.SecretManager,
+		resolveSyncConflict,
+	)
+	syncer.SetAttachmentsDir(appContext.SecretManager.Attachments().Dir())
+
+	return fn(syncer)
+}
+
+// Asks the user which version of a conflicting secret to keep.
+func resolveSyncConflict(conflict *gitsync.Conflict) (gitsync.Resolution, error) {
+	key := ""
+	if conflict.Local != nil {
+		key = conflict.Local.Key
+	} else {
+		key = conflict.Remote.Key
+	}
+
+	fmt.Printf("\n⚠️  Secret '%s' was changed both locally and remotely\n", key)
+	fmt.Printf("    Local:  %s\n", describeSyncVersion(conflict.Local))
+	fmt.Printf("    Remote: %s\n", describeSyncVersion(conflict.Remote))
+
+	prompt := promptui.Select{
+		Label: "Which version do you want to keep",
+		Items: []string{
+			"Keep local version",
+			"Keep remote version",
+		},
+	}
+
+	idx, _, err := prompt.Run()
+	if err != nil {
+		return gitsync.KeepLocal, err
+	}
+
+	if idx == 1 {
+		return gitsync.KeepRemote, nil
+	}
+
+	return gitsync.KeepLocal, nil
+}
+
+// Describes one side of a conflict.
+func describeSyncVersion(secret *models.Secret) string {
+	if secret == nil {
+		return "removed"
+	}
+
+	description := fmt.Sprintf("updated %s", secret.UpdatedAt.Local().Format(time.DateTime))
+	if secret.Username != "" {
+		description += fmt.Sprintf(", username %s", secret.Username)
+	}
+	if secret.Website != "" {
+		description += fmt.Sprintf(", website %s", secret.Website)
+	}
+	if secret.Notes != "" {
+		description += fmt.Sprintf(", notes %q", secret.Notes)
+	}
+
+	return description
+}
+
+func printSyncReport(report *gitsync.Report) {
+	for _, message := range report.Skipped {
+		fmt.Printf("⚠️  Skipped: %s\n", message)
+	}
+
+	fmt.Printf(
+		"✅ Sync complete: %d pulled, %d pushed, %d conflict(s) resolved\n",
+		report.Pulled,
+		report.Pushed,
+		report.Conflicts,
+	)
+}
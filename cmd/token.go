@@ -0,0 +1,171 @@
+/*
+Copyright © 2024 Isaac Fei
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Isaac-Fate/myst/internal/models"
+	"github.com/Isaac-Fate/myst/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var tokenCmd = &cobra.Command{
+	Use:   "token",
+	Short: "Create, list and revoke API tokens for 'myst serve'",
+}
+
+var tokenCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "Create an API token",
+	Long: `Create an API token for programs using 'myst serve'.
+
+The token is printed once and only its hash is stored, so copy it right
+away. Tokens may be limited to reading, to folders (with their subfolders)
+and to secrets with any of some tags, and may expire.
+
+  myst token create ci --read-only --folder work --expires 90d`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		readOnly, _ := cmd.Flags().GetBool("read-only")
+		folders, _ := cmd.Flags().GetStringSlice("folder")
+		tags, _ := cmd.Flags().GetStringSlice("tag")
+		expires, _ := cmd.Flags().GetString("expires")
+
+		for _, tag := range tags {
+			if err := models.ValidateTag(tag); err != nil {
+				return err
+			}
+		}
+
+		token := models.APIToken{
+			Name:     args[0],
+			ReadOnly: readOnly,
+			Folders:  folders,
+			Tags:     tags,
+		}
+
+		if expires != "" {
+			duration, err := utils.ParseDuration(expires)
+			if err != nil {
+				return err
+			}
+
+			expiresAt := time.Now().Add(duration)
+			token.ExpiresAt = &expiresAt
+		}
+
+		if err := unlock(); err != nil {
+			return err
+		}
+		defer appContext.SecretManager.Close()
+
+		raw, err := appContext.SecretManager.CreateToken(&token)
+		if errors.Is(err, models.ErrDuplicateTokenName) {
+			return fmt.Errorf("a token named '%s' already exists", token.Name)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create token: %w", err)
+		}
+
+		fmt.Printf("✅ Token '%s' created, %s\n", token.Name, describeTokenScope(&token))
+		fmt.Println("Copy it now, it will not be shown again:")
+		fmt.Println(raw)
+		return nil
+	},
+}
+
+var tokenListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the API tokens",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := unlock(); err != nil {
+			return err
+		}
+		defer appContext.SecretManager.Close()
+
+		tokens, err := appContext.SecretManager.ListTokens()
+		if err != nil {
+			return err
+		}
+
+		if len(tokens) == 0 {
+			fmt.Println("No tokens yet")
+			return nil
+		}
+
+		now := time.Now()
+		for _, token := range tokens {
+			line := fmt.Sprintf("🔑 %s  %s, created %s", token.Name, describeTokenScope(&token), token.CreatedAt.Local().Format("2006-01-02"))
+
+			switch {
+			case token.Expired(now):
+				line += ", expired"
+			case token.ExpiresAt != nil:
+				line += ", expires " + utils.FormatRelative(*token.ExpiresAt, now)
+			}
+
+			fmt.Println(line)
+		}
+
+		return nil
+	},
+}
+
+var tokenRevokeCmd = &cobra.Command{
+	Use:   "revoke <name>",
+	Short: "Revoke an API token",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := unlock(); err != nil {
+			return err
+		}
+		defer appContext.SecretManager.Close()
+
+		err := appContext.SecretManager.RevokeToken(args[0])
+		if errors.Is(err, models.ErrTokenNotFound) {
+			return fmt.Errorf("no token named '%s'", args[0])
+		}
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("✅ Token '%s' revoked\n", args[0])
+		return nil
+	},
+}
+
+// Describes what a token may do, such as "read-only, folders work".
+func describeTokenScope(token *models.APIToken) string {
+	scope := []string{"read-write"}
+	if token.ReadOnly {
+		scope[0] = "read-only"
+	}
+
+	if len(token.Folders) > 0 {
+		scope = append(scope, "folders "+strings.Join(token.Folders, ", "))
+	}
+	if len(token.Tags) > 0 {
+		scope = append(scope, "tags "+strings.Join(token.Tags, ", "))
+	}
+	if len(token.Folders) == 0 && len(token.Tags) == 0 {
+		scope = append(scope, "all secrets")
+	}
+
+	return strings.Join(scope, ", ")
+}
+
+func init() {
+	tokenCreateCmd.Flags().Bool("read-only", false, "only allow reading secrets")
+	tokenCreateCmd.Flags().StringSlice("folder", nil, "only allow secrets in these folders and their subfolders")
+	tokenCreateCmd.Flags().StringSlice("tag", nil, "only allow secrets with any of these tags")
+	tokenCreateCmd.Flags().String("expires", "", "make the token expire after this long (24h, 90d)")
+
+	tokenCmd.AddCommand(tokenCreateCmd, tokenListCmd, tokenRevokeCmd)
+	rootCmd.AddCommand(tokenCmd)
+}
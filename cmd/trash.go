@@ -0,0 +1,197 @@
+/*
+Copyright © 2024 Isaac Fei
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/Isaac-Fate/myst/cmd/handlers"
+	"github.com/Isaac-Fate/myst/internal/models"
+	"github.com/Isaac-Fate/myst/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+// Removed secrets are moved to the trash rather than deleted. They are left
+// out of listings and searches, but keep their keys until they are purged,
+// so they can always be restored as they were.
+
+var trashCmd = &cobra.Command{
+	Use:   "trash",
+	Short: "List the secrets in the trash",
+	Long: `List the secrets in the trash, the most recently removed first.
+
+Bring a secret back with 'myst restore <key>', or delete secrets for good
+with 'myst trash purge'.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := unlock(); err != nil {
+			return err
+		}
+		defer appContext.SecretManager.Close()
+
+		trash, err := appContext.SecretManager.ListTrash()
+		if err != nil {
+			return err
+		}
+
+		if len(trash) == 0 {
+			fmt.Println("The trash is empty")
+			return nil
+		}
+
+		now := time.Now()
+		for _, secret := range trash {
+			line := "🗑️  " + secret.Key
+			if secret.Folder != "" {
+				line += " [" + secret.Folder + "]"
+			}
+			line += "  removed " + utils.FormatRelative(secret.DeletedAt.Time, now)
+
+			fmt.Println(line)
+		}
+
+		return nil
+	},
+}
+
+var trashPurgeCmd = &cobra.Command{
+	Use:   "purge [key...]",
+	Short: "Delete secrets in the trash for good",
+	Long: `Delete secrets in the trash for good.
+
+Without keys, every secret in the trash is purged, or only those removed
+longer ago than --older-than.
+
+  myst trash purge old-token
+  myst trash purge --older-than 30d --yes`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		olderThanFlag, _ := cmd.Flags().GetString("older-than")
+
+		var olderThan time.Duration
+		if olderThanFlag != "" {
+			var err error
+			olderThan, err = utils.ParseDuration(olderThanFlag)
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := unlock(); err != nil {
+			return err
+		}
+		defer appContext.SecretManager.Close()
+
+		trash, err := appContext.SecretManager.ListTrash()
+		if err != nil {
+			return err
+		}
+
+		secrets := trash
+		if len(args) > 0 {
+			secrets = nil
+			for _, key := range args {
+				i := slices.IndexFunc(trash, func(secret models.Secret) bool {
+					return secret.Key == key
+				})
+				if i < 0 {
+					return fmt.Errorf("secret with key '%s' is not in the trash", key)
+				}
+
+				secrets = append(secrets, trash[i])
+			}
+		}
+
+		if olderThanFlag != "" {
+			cutoff := time.Now().Add(-olderThan)
+			secrets = slices.DeleteFunc(secrets, func(secret models.Secret) bool {
+				return secret.DeletedAt.Time.After(cutoff)
+			})
+		}
+
+		if len(secrets) == 0 {
+			fmt.Println("Nothing to purge")
+			return nil
+		}
+
+		if confirmed, err := confirmBulk(cmd, "purge", secrets); err != nil || !confirmed {
+			return err
+		}
+
+		if err := appContext.SecretManager.PurgeSecrets(secrets); err != nil {
+			return fmt.Errorf("failed to purge secrets: %w", err)
+		}
+
+		fmt.Printf("✅ Purged %d secrets\n", len(secrets))
+		return nil
+	},
+}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore [key...]",
+	Short: "Bring secrets back from the trash",
+	Long: `Bring secrets back from the trash, as they were when removed.
+
+Without keys, the secrets to restore are picked from the trash.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := unlock(); err != nil {
+			return err
+		}
+		defer appContext.SecretManager.Close()
+
+		var secrets []models.Secret
+
+		if len(args) == 0 {
+			trash, err := appContext.SecretManager.ListTrash()
+			if err != nil {
+				return err
+			}
+
+			if len(trash) == 0 {
+				fmt.Println("The trash is empty")
+				return nil
+			}
+
+			secrets, err = handlers.PickSecrets("Select the secrets to restore", trash)
+			if errors.Is(err, handlers.ErrNothingPicked) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, key := range args {
+			secret, err := appContext.SecretManager.GetTrashedSecretByKey(key)
+			if errors.Is(err, models.ErrSecretNotFound) {
+				return fmt.Errorf("secret with key '%s' is not in the trash, see 'myst trash'", key)
+			}
+			if err != nil {
+				return err
+			}
+
+			secrets = append(secrets, *secret)
+		}
+
+		for i := range secrets {
+			if err := appContext.SecretManager.RestoreSecret(&secrets[i]); err != nil {
+				return fmt.Errorf("failed to restore secret '%s': %w", secrets[i].Key, err)
+			}
+
+			fmt.Printf("✅ Secret '%s' restored\n", secrets[i].Key)
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	trashPurgeCmd.Flags().String("older-than", "", "only purge secrets removed longer ago than this (24h, 30d)")
+	trashPurgeCmd.Flags().BoolP("yes", "y", false, "do not ask for confirmation")
+
+	trashCmd.AddCommand(trashPurgeCmd)
+	rootCmd.AddCommand(trashCmd, restoreCmd)
+}
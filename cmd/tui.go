@@ -0,0 +1,71 @@
+/*
+Copyright © 2024 Isaac Fei
+*/
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Isaac-Fate/myst/internal/tui"
+	"github.com/atotto/clipboard"
+	"github.com/gdamore/tcell/v2"
+	"github.com/spf13/cobra"
+)
+
+var tuiCmd = &cobra.Command{
+	Use:   "tui",
+	Short: "Browse and edit secrets in a full-screen terminal UI",
+	Long: `Browse and edit secrets in a full-screen terminal UI.
+
+Type to search, move with the arrow keys, and press Enter to reveal the value
+of the selected secret. The shortcuts are shown at the bottom of the screen.
+The UI locks itself after a while without a key press. A copied value is
+cleared from the clipboard after a while, and when the UI locks or exits,
+unless something else was copied since.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		lockAfter, _ := cmd.Flags().GetDuration("lock-after")
+		if lockAfter < 0 {
+			return fmt.Errorf("invalid lock timeout %s", lockAfter)
+		}
+
+		clearClipboardAfter, _ := cmd.Flags().GetDuration("clear-clipboard-after")
+		if clearClipboardAfter < 0 {
+			return fmt.Errorf("invalid clipboard timeout %s", clearClipboardAfter)
+		}
+
+		if err := unlock(); err != nil {
+			return err
+		}
+		defer appContext.SecretManager.Close()
+
+		screen, err := tcell.NewScreen()
+		if err != nil {
+			return fmt.Errorf("failed to open terminal: %w", err)
+		}
+		if err := screen.Init(); err != nil {
+			return fmt.Errorf("failed to open terminal: %w", err)
+		}
+		defer screen.Fini()
+
+		app := tui.New(screen, tui.Options{
+			Manager:             appContext.SecretManager,
+			Passphrase:          appContext.Passphrase,
+			VerifyPassphrase:    appContext.Config.VerifyPassphrase,
+			AutoLock:            lockAfter,
+			Clipboard:           clipboard.WriteAll,
+			ReadClipboard:       clipboard.ReadAll,
+			ClearClipboardAfter: clearClipboardAfter,
+		})
+
+		return app.Run()
+	},
+}
+
+func init() {
+	tuiCmd.Flags().Duration("lock-after", 5*time.Minute, "lock after this long without a key press, or never if 0")
+	tuiCmd.Flags().Duration("clear-clipboard-after", 30*time.Second, "clear a copied value from the clipboard after this long, or only on lock and exit if 0")
+
+	rootCmd.AddCommand(tuiCmd)
+}
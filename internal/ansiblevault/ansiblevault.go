@@ -0,0 +1,225 @@
+package ansiblevault
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// Reads and writes files in the format of ansible-vault, so that Ansible
+// repositories can be inspected and edited with passwords held in myst.
+//
+// A vault file starts with a header such as
+//
+//	$ANSIBLE_VAULT;1.1;AES256
+//
+// or, when it is encrypted for a vault ID, $ANSIBLE_VAULT;1.2;AES256;<id>.
+// The rest is the hex encoding, in lines of 80 characters, of three hex
+// encoded lines: a random salt, an HMAC-SHA256 of the ciphertext, and the
+// ciphertext. The salt and the password give, with PBKDF2-HMAC-SHA256, the
+// AES-256 key, the HMAC key and the initial counter of AES-CTR, which
+// encrypts the plaintext padded as in PKCS #7.
+
+// The first field of the header of vault files.
+const headerPrefix string = "$ANSIBLE_VAULT"
+
+// The only cipher ansible-vault writes.
+const cipherName string = "AES256"
+
+// The ID of the password used when none is given, which is not written to
+// the header.
+const DefaultID string = "default"
+
+const (
+	saltSize   int = 32
+	keySize    int = 32
+	iterations int = 10000
+	lineWidth  int = 80
+)
+
+var (
+	// The data does not start with a vault header.
+	ErrNotEncrypted = errors.New("not a vault encrypted file")
+
+	// The password does not match the file, or the file was changed.
+	ErrWrongPassword = errors.New("decryption failed, the password does not match")
+)
+
+// The header of a vault file.
+type Header struct {
+	// "1.1", or "1.2" when there is an ID.
+	Version string
+
+	// The vault ID the file is encrypted for, if any.
+	ID string
+}
+
+// Reports whether the data is vault encrypted.
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte(headerPrefix+";"))
+}
+
+// Reads the header of vault encrypted data.
+func ParseHeader(data []byte) (*Header, error) {
+	header, _, err := split(data)
+	return header, err
+}
+
+// Encrypts the plaintext with the password, for the vault ID if it is not
+// empty or DefaultID.
+func Encrypt(plaintext []byte, password string, id string) ([]byte, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	return encrypt(plaintext, password, id, salt)
+}
+
+func encrypt(plaintext []byte, password string, id string, salt []byte) ([]byte, error) {
+	cipherKey, hmacKey, counter := deriveKeys(password, salt)
+
+	block, err := aes.NewCipher(cipherKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	ciphertext := pad(plaintext)
+	cipher.NewCTR(block, counter).XORKeyStream(ciphertext, ciphertext)
+
+	mac := hmac.New(sha256.New, hmacKey)
+	mac.Write(ciphertext)
+
+	inner := strings.Join([]string{
+		hex.EncodeToString(salt),
+		hex.EncodeToString(mac.Sum(nil)),
+		hex.EncodeToString(ciphertext),
+	}, "\n")
+	body := hex.EncodeToString([]byte(inner))
+
+	var buffer bytes.Buffer
+	if id == "" || id == DefaultID {
+		fmt.Fprintf(&buffer, "%s;1.1;%s\n", headerPrefix, cipherName)
+	} else {
+		fmt.Fprintf(&buffer, "%s;1.2;%s;%s\n", headerPrefix, cipherName, id)
+	}
+	for len(body) > 0 {
+		n := min(lineWidth, len(body))
+		buffer.WriteString(body[:n])
+		buffer.WriteByte('\n')
+		body = body[n:]
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// Decrypts vault encrypted data with the password.
+func Decrypt(data []byte, password string) ([]byte, error) {
+	_, body, err := split(data)
+	if err != nil {
+		return nil, err
+	}
+
+	inner, err := hex.DecodeString(body)
+	if err != nil {
+		return nil, fmt.Errorf("malformed vault data: %w", err)
+	}
+
+	parts := strings.Split(string(inner), "\n")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed vault data: expected salt, HMAC and ciphertext")
+	}
+
+	decoded := make([][]byte, len(parts))
+	for i, part := range parts {
+		if decoded[i], err = hex.DecodeString(part); err != nil {
+			return nil, fmt.Errorf("malformed vault data: %w", err)
+		}
+	}
+	salt, sum, ciphertext := decoded[0], decoded[1], decoded[2]
+
+	cipherKey, hmacKey, counter := deriveKeys(password, salt)
+
+	mac := hmac.New(sha256.New, hmacKey)
+	mac.Write(ciphertext)
+	if !hmac.Equal(mac.Sum(nil), sum) {
+		return nil, ErrWrongPassword
+	}
+
+	block, err := aes.NewCipher(cipherKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCTR(block, counter).XORKeyStream(plaintext, ciphertext)
+
+	return unpad(plaintext)
+}
+
+// Splits vault encrypted data into its header and the body with the line
+// breaks removed.
+func split(data []byte) (*Header, string, error) {
+	if !IsEncrypted(data) {
+		return nil, "", ErrNotEncrypted
+	}
+
+	text := strings.TrimSpace(string(data))
+	firstLine, body, _ := strings.Cut(text, "\n")
+
+	fields := strings.Split(strings.TrimSpace(firstLine), ";")
+	if len(fields) < 3 {
+		return nil, "", fmt.Errorf("malformed vault header '%s'", firstLine)
+	}
+
+	header := &Header{Version: fields[1]}
+	switch {
+	case header.Version == "1.1" && len(fields) == 3:
+	case header.Version == "1.2" && len(fields) == 4:
+		header.ID = fields[3]
+	case header.Version == "1.0":
+		return nil, "", errors.New("vault format 1.0 is not supported, rekey the file with ansible-vault")
+	default:
+		return nil, "", fmt.Errorf("unsupported vault header '%s'", firstLine)
+	}
+
+	if fields[2] != cipherName {
+		return nil, "", fmt.Errorf("unsupported vault cipher '%s'", fields[2])
+	}
+
+	body = strings.Join(strings.Fields(body), "")
+	return header, body, nil
+}
+
+// Derives the AES key, the HMAC key and the initial counter.
+func deriveKeys(password string, salt []byte) ([]byte, []byte, []byte) {
+	derived := pbkdf2.Key([]byte(password), salt, iterations, 2*keySize+aes.BlockSize, sha256.New)
+	return derived[:keySize], derived[keySize : 2*keySize], derived[2*keySize:]
+}
+
+func pad(plaintext []byte) []byte {
+	n := aes.BlockSize - len(plaintext)%aes.BlockSize
+	return append(bytes.Clone(plaintext), bytes.Repeat([]byte{byte(n)}, n)...)
+}
+
+func unpad(plaintext []byte) ([]byte, error) {
+	if len(plaintext) == 0 || len(plaintext)%aes.BlockSize != 0 {
+		return nil, errors.New("malformed vault data: bad padding")
+	}
+
+	n := int(plaintext[len(plaintext)-1])
+	if n == 0 || n > aes.BlockSize || !bytes.Equal(plaintext[len(plaintext)-n:], bytes.Repeat([]byte{byte(n)}, n)) {
+		return nil, errors.New("malformed vault data: bad padding")
+	}
+
+	return plaintext[:len(plaintext)-n], nil
+}
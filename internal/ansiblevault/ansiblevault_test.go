@@ -0,0 +1,156 @@
+package ansiblevault_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Isaac-Fate/myst/internal/ansiblevault"
+)
+
+// Encrypted by ansible-vault, from the unit tests of Ansible.
+const ansibleFixture = `$ANSIBLE_VAULT;1.1;AES256
+33363965326261303234626463623963633531343539616138316433353830356566396130353436
+3562643163366231316662386565383735653432386435610a306664636137376132643732393835
+63383038383730306639353234326630666539346233376330303938323639306661313032396437
+6233623062366136310a633866373936313238333730653739323461656662303864663666653563
+3138
+`
+
+// Encrypted for the vault ID "prod" with the salt 00 01 .. 1f, following
+// the steps of ansible-vault with Python's hashlib and openssl.
+const labeledFixture = `$ANSIBLE_VAULT;1.2;AES256;prod
+30303031303230333034303530363037303830393061306230633064306530663130313131323133
+3134313531363137313831393161316231633164316531660a356435636663383639653064396438
+65306163363136323566626238343162396564376161346539356363313535376463386233343561
+3330343262363431390a306262366436633364353363616238626233393636313132306663663861
+64663934613138376463363764356262336332323937636232646633643134626331
+`
+
+const fixturePassword = "test-vault-password"
+
+func TestDecryptFixtures(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		data      string
+		plaintext string
+		header    ansiblevault.Header
+	}{
+		{"ansible-vault", ansibleFixture, "Setec Astronomy", ansiblevault.Header{Version: "1.1"}},
+		{"vault ID", labeledFixture, "Setec Astronomy\n", ansiblevault.Header{Version: "1.2", ID: "prod"}},
+		{"CRLF line breaks", strings.ReplaceAll(ansibleFixture, "\n", "\r\n"), "Setec Astronomy", ansiblevault.Header{Version: "1.1"}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			header, err := ansiblevault.ParseHeader([]byte(test.data))
+			if err != nil {
+				t.Fatalf("failed to parse header: %v", err)
+			}
+			if *header != test.header {
+				t.Errorf("header = %+v, want %+v", *header, test.header)
+			}
+
+			plaintext, err := ansiblevault.Decrypt([]byte(test.data), fixturePassword)
+			if err != nil {
+				t.Fatalf("failed to decrypt: %v", err)
+			}
+			if string(plaintext) != test.plaintext {
+				t.Errorf("plaintext = %q, want %q", plaintext, test.plaintext)
+			}
+		})
+	}
+}
+
+func TestDecryptWrongPassword(t *testing.T) {
+	_, err := ansiblevault.Decrypt([]byte(ansibleFixture), "wrong")
+	if !errors.Is(err, ansiblevault.ErrWrongPassword) {
+		t.Errorf("err = %v, want ErrWrongPassword", err)
+	}
+}
+
+func TestDecryptTampered(t *testing.T) {
+	// Change the last hex digit of the ciphertext
+	data := strings.TrimSuffix(ansibleFixture, "8\n") + "9\n"
+
+	_, err := ansiblevault.Decrypt([]byte(data), fixturePassword)
+	if !errors.Is(err, ansiblevault.ErrWrongPassword) {
+		t.Errorf("err = %v, want ErrWrongPassword", err)
+	}
+}
+
+func TestDecryptMalformed(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		data string
+	}{
+		{"plaintext", "db_password: hunter2\n"},
+		{"format 1.0", "$ANSIBLE_VAULT;1.0;AES\n3031\n"},
+		{"unknown cipher", "$ANSIBLE_VAULT;1.1;AES128\n3031\n"},
+		{"missing ID", "$ANSIBLE_VAULT;1.2;AES256\n3031\n"},
+		{"not hex", "$ANSIBLE_VAULT;1.1;AES256\nxyz\n"},
+		{"missing parts", "$ANSIBLE_VAULT;1.1;AES256\n30310a3031\n"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := ansiblevault.Decrypt([]byte(test.data), fixturePassword); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+
+	if _, err := ansiblevault.Decrypt([]byte("db_password: hunter2\n"), fixturePassword); !errors.Is(err, ansiblevault.ErrNotEncrypted) {
+		t.Errorf("err = %v, want ErrNotEncrypted", err)
+	}
+}
+
+func TestEncrypt(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		plaintext string
+		id        string
+		header    string
+	}{
+		{"default ID", "db_password: hunter2\n", "", "$ANSIBLE_VAULT;1.1;AES256\n"},
+		{"named default ID", "db_password: hunter2\n", ansiblevault.DefaultID, "$ANSIBLE_VAULT;1.1;AES256\n"},
+		{"vault ID", "db_password: hunter2\n", "prod", "$ANSIBLE_VAULT;1.2;AES256;prod\n"},
+		{"empty", "", "", "$ANSIBLE_VAULT;1.1;AES256\n"},
+		{"whole blocks", strings.Repeat("x", 64), "", "$ANSIBLE_VAULT;1.1;AES256\n"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := ansiblevault.Encrypt([]byte(test.plaintext), "s3cret", test.id)
+			if err != nil {
+				t.Fatalf("failed to encrypt: %v", err)
+			}
+
+			if !strings.HasPrefix(string(data), test.header) {
+				t.Errorf("data starts with %q, want %q", strings.SplitN(string(data), "\n", 2)[0], test.header)
+			}
+			if !ansiblevault.IsEncrypted(data) {
+				t.Error("encrypted data is not recognized")
+			}
+
+			// Lines are wrapped as ansible-vault does
+			lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+			for i, line := range lines[1:] {
+				if len(line) > 80 || (i < len(lines)-2 && len(line) != 80) {
+					t.Errorf("line %d has %d characters", i+2, len(line))
+				}
+			}
+
+			plaintext, err := ansiblevault.Decrypt(data, "s3cret")
+			if err != nil {
+				t.Fatalf("failed to decrypt: %v", err)
+			}
+			if string(plaintext) != test.plaintext {
+				t.Errorf("plaintext = %q, want %q", plaintext, test.plaintext)
+			}
+		})
+	}
+}
+
+func TestEncryptUsesRandomSalt(t *testing.T) {
+	a, _ := ansiblevault.Encrypt([]byte("same"), "s3cret", "")
+	b, _ := ansiblevault.Encrypt([]byte("same"), "s3cret", "")
+
+	if string(a) == string(b) {
+		t.Error("encrypting twice gave the same data")
+	}
+}
@@ -0,0 +1,229 @@
+package attachment
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	mycrypto "github.com/Isaac-Fate/myst/internal/crypto"
+	"github.com/Isaac-Fate/myst/internal/models"
+	"github.com/Isaac-Fate/myst/internal/utils"
+	"github.com/google/uuid"
+)
+
+// Keeps the contents of attachments, such as certificates, keystores and
+// kubeconfigs, encrypted in a directory, one file per attachment named by
+// its ID. Contents are streamed through the encryption in chunks, so large
+// files are never held in memory as a whole.
+//
+// The files never change once written: replacing an attachment writes a new
+// file under a new ID.
+
+// Returned when an attachment is larger than the limit.
+var ErrTooLarge = errors.New("attachment is too large")
+
+// The longest attachment name allowed.
+const maxNameLength int = 255
+
+// The name of the directory kept next to a vault for the contents of its
+// attachments.
+const DirName string = "attachments"
+
+// A directory of encrypted attachment contents.
+type Store struct {
+	dir string
+}
+
+// Creates a store keeping the contents in dir, which is created when first
+// needed.
+func NewStore(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// Returns the directory holding the contents.
+func (store *Store) Dir() string {
+	return store.dir
+}
+
+// Returns the path of the file holding the contents of the attachment with
+// the ID.
+func (store *Store) Path(id string) string {
+	return filepath.Join(store.dir, id)
+}
+
+// Checks that a name can be used for an attachment, and so as a file name
+// when it is saved.
+func ValidateName(name string) error {
+	switch {
+	case name == "" || name == "." || name == "..":
+		return fmt.Errorf("invalid attachment name '%s'", name)
+	case strings.ContainsAny(name, `/\`):
+		return fmt.Errorf("invalid attachment name '%s': it cannot contain slashes", name)
+	case len(name) > maxNameLength:
+		return fmt.Errorf("invalid attachment name: it is longer than %d characters", maxNameLength)
+	}
+
+	return nil
+}
+
+// Encrypts what is read from r into a new attachment with the name, failing
+// with ErrTooLarge if there is more than limit bytes to read.
+//
+// The data key of the attachment is encrypted with the passphrase.
+func (store *Store) Add(passphrase string, name string, r io.Reader, limit int64) (*models.Attachment, error) {
+	if err := ValidateName(name); err != nil {
+		return nil, err
+	}
+
+	key, err := mycrypto.GenerateDataKey()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+
+	encryptedKey, err := mycrypto.WrapDataKey(passphrase, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt data key: %w", err)
+	}
+
+	attachment := &models.Attachment{
+		ID:           uuid.New().String(),
+		Name:         name,
+		EncryptedKey: encryptedKey,
+		AddedAt:      time.Now(),
+	}
+
+	if err := os.MkdirAll(store.dir, 0700); err != nil {
+		return nil, err
+	}
+
+	attachment.Size, err = writeAtomically(store.Path(attachment.ID), func(w io.Writer) (int64, error) {
+		return mycrypto.EncryptStream(key, w, &limitedReader{r: r, remaining: limit})
+	})
+	if errors.Is(err, ErrTooLarge) {
+		return nil, fmt.Errorf("%w, the limit is %s", ErrTooLarge, utils.FormatSize(limit))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt attachment: %w", err)
+	}
+
+	return attachment, nil
+}
+
+// Decrypts the contents of the attachment into w.
+//
+// If decrypting fails half way, what was written to w should be discarded.
+func (store *Store) Open(passphrase string, attachment *models.Attachment, w io.Writer) (int64, error) {
+	key, err := mycrypto.UnwrapDataKey(passphrase, attachment.EncryptedKey)
+	if err != nil {
+		return 0, fmt.Errorf("failed to decrypt the data key of attachment '%s': %w", attachment.Name, err)
+	}
+
+	file, err := os.Open(store.Path(attachment.ID))
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, fmt.Errorf("the contents of attachment '%s' are missing", attachment.Name)
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	n, err := mycrypto.DecryptStream(key, w, file)
+	if err != nil {
+		return n, fmt.Errorf("failed to decrypt attachment '%s': %w", attachment.Name, err)
+	}
+
+	return n, nil
+}
+
+// Decrypts the attachment into a file at path that only the user can read,
+// replacing any file there. Nothing is left at path if decrypting fails.
+func (store *Store) Save(passphrase string, attachment *models.Attachment, path string) error {
+	_, err := writeAtomically(path, func(w io.Writer) (int64, error) {
+		return store.Open(passphrase, attachment, w)
+	})
+
+	return err
+}
+
+// Copies contents encrypted elsewhere, such as in a backup, under the ID of
+// their attachment, leaving the file alone if it is already there.
+func (store *Store) Put(id string, r io.Reader) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return fmt.Errorf("invalid attachment ID '%s'", id)
+	}
+
+	if _, err := os.Stat(store.Path(id)); err == nil {
+		return nil
+	}
+
+	if err := os.MkdirAll(store.dir, 0700); err != nil {
+		return err
+	}
+
+	_, err := writeAtomically(store.Path(id), func(w io.Writer) (int64, error) {
+		return io.Copy(w, r)
+	})
+
+	return err
+}
+
+// Deletes the contents of attachments, ignoring those already gone.
+func (store *Store) Remove(attachments ...models.Attachment) error {
+	for _, attachment := range attachments {
+		if err := os.Remove(store.Path(attachment.ID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Writes a file at path through a temporary file in the same directory,
+// readable only by the user, which replaces path only if write succeeds.
+func writeAtomically(path string, write func(w io.Writer) (int64, error)) (int64, error) {
+	tempFile, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return 0, err
+	}
+	tempPath := tempFile.Name()
+	defer os.Remove(tempPath)
+
+	n, err := write(tempFile)
+	if err == nil {
+		err = tempFile.Sync()
+	}
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return n, err
+	}
+
+	if err := os.Chmod(tempPath, 0600); err != nil {
+		return n, err
+	}
+
+	return n, os.Rename(tempPath, path)
+}
+
+// Reads from r, failing with ErrTooLarge once more than the remaining bytes
+// were read.
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (reader *limitedReader) Read(p []byte) (int, error) {
+	n, err := reader.r.Read(p)
+
+	reader.remaining -= int64(n)
+	if reader.remaining < 0 {
+		return 0, ErrTooLarge
+	}
+
+	return n, err
+}
@@ -0,0 +1,187 @@
+package attachment_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Isaac-Fate/myst/internal/attachment"
+)
+
+const testPassphrase string = "hello, world"
+
+// Produces size bytes without holding them in memory.
+type sizedReader struct {
+	remaining int64
+}
+
+func (reader *sizedReader) Read(p []byte) (int, error) {
+	if reader.remaining == 0 {
+		return 0, io.EOF
+	}
+
+	n := int(min(int64(len(p)), reader.remaining))
+	for i := range p[:n] {
+		p[i] = 'x'
+	}
+	reader.remaining -= int64(n)
+
+	return n, nil
+}
+
+func TestAddOpen(t *testing.T) {
+	store := attachment.NewStore(filepath.Join(t.TempDir(), "attachments"))
+
+	contents := make([]byte, 200*1024+3)
+	rand.Read(contents)
+
+	added, err := store.Add(testPassphrase, "keystore.p12", bytes.NewReader(contents), 1<<20)
+	if err != nil {
+		t.Fatalf("failed to add: %v", err)
+	}
+	if added.Name != "keystore.p12" || added.Size != int64(len(contents)) || added.ID == "" {
+		t.Errorf("unexpected attachment %+v", added)
+	}
+
+	// The contents are encrypted and private
+	info, err := os.Stat(store.Path(added.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	stored, _ := os.ReadFile(store.Path(added.ID))
+	if bytes.Contains(stored, contents[:64]) {
+		t.Error("the contents are stored in plaintext")
+	}
+
+	var opened bytes.Buffer
+	if _, err := store.Open(testPassphrase, added, &opened); err != nil {
+		t.Fatalf("failed to open: %v", err)
+	}
+	if !bytes.Equal(opened.Bytes(), contents) {
+		t.Error("opened contents differ")
+	}
+
+	if _, err := store.Open("wrong passphrase", added, io.Discard); err == nil {
+		t.Error("expected an error for the wrong passphrase")
+	}
+}
+
+func TestAddTooLarge(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "attachments")
+	store := attachment.NewStore(dir)
+
+	_, err := store.Add(testPassphrase, "big.bin", &sizedReader{remaining: 3 << 20}, 1<<20)
+	if !errors.Is(err, attachment.ErrTooLarge) {
+		t.Fatalf("err = %v, want ErrTooLarge", err)
+	}
+
+	// Nothing is left behind
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Errorf("expected an empty directory, found %d files", len(entries))
+	}
+
+	// Exactly the limit is fine
+	if _, err := store.Add(testPassphrase, "fits.bin", &sizedReader{remaining: 1 << 20}, 1<<20); err != nil {
+		t.Errorf("failed to add an attachment of the limit: %v", err)
+	}
+}
+
+func TestSave(t *testing.T) {
+	store := attachment.NewStore(filepath.Join(t.TempDir(), "attachments"))
+
+	added, err := store.Add(testPassphrase, "kubeconfig", strings.NewReader("apiVersion: v1\n"), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "kubeconfig")
+	if err := os.WriteFile(path, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Save(testPassphrase, added, path); err != nil {
+		t.Fatalf("failed to save: %v", err)
+	}
+
+	content, _ := os.ReadFile(path)
+	if string(content) != "apiVersion: v1\n" {
+		t.Errorf("content = %q", content)
+	}
+
+	info, _ := os.Stat(path)
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	// A failed save leaves the file as it was
+	if err := store.Save("wrong passphrase", added, path); err == nil {
+		t.Error("expected an error for the wrong passphrase")
+	}
+	if content, _ := os.ReadFile(path); string(content) != "apiVersion: v1\n" {
+		t.Errorf("content changed to %q", content)
+	}
+}
+
+func TestSaveCorrupted(t *testing.T) {
+	store := attachment.NewStore(filepath.Join(t.TempDir(), "attachments"))
+
+	contents := make([]byte, 100*1024)
+	added, err := store.Add(testPassphrase, "cert.pem", bytes.NewReader(contents), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Cut the last chunk short
+	stored, _ := os.ReadFile(store.Path(added.ID))
+	if err := os.WriteFile(store.Path(added.ID), stored[:len(stored)-10], 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "cert.pem")
+	if err := store.Save(testPassphrase, added, path); err == nil {
+		t.Error("expected an error for corrupted contents")
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Error("expected nothing to be saved")
+	}
+}
+
+func TestRemove(t *testing.T) {
+	store := attachment.NewStore(filepath.Join(t.TempDir(), "attachments"))
+
+	added, err := store.Add(testPassphrase, "cert.pem", strings.NewReader("cert"), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Remove(*added, *added); err != nil {
+		t.Fatalf("failed to remove: %v", err)
+	}
+	if _, err := os.Stat(store.Path(added.ID)); !errors.Is(err, os.ErrNotExist) {
+		t.Error("expected the contents to be deleted")
+	}
+}
+
+func TestValidateName(t *testing.T) {
+	for _, name := range []string{"cert.pem", "id_rsa", ".kube-config", "app store.p12"} {
+		if err := attachment.ValidateName(name); err != nil {
+			t.Errorf("%q: unexpected error %v", name, err)
+		}
+	}
+
+	for _, name := range []string{"", ".", "..", "dir/cert.pem", `dir\cert.pem`, strings.Repeat("a", 256)} {
+		if err := attachment.ValidateName(name); err == nil {
+			t.Errorf("%q: expected an error", name)
+		}
+	}
+}
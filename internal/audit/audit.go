@@ -0,0 +1,302 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"time"
+)
+
+// The audit log is an append-only file with one JSON event per line.
+//
+// Events are chained: every event records the hash of the previous one, and
+// its own hash is the HMAC-SHA256 of the previous hash followed by the JSON
+// encoding of the event without its hash. The HMAC is keyed with a key only
+// the master passphrase unlocks, so without it an event cannot be edited,
+// removed, reordered or inserted without breaking the chain from that point
+// on, which Verify reports. Removing the most recent events leaves a valid
+// chain, though, so that is not detected.
+//
+// The log only holds secret IDs, never keys, values or other metadata, so
+// it does not leak anything the vault keeps encrypted.
+
+// What was done to a secret.
+type Action string
+
+const (
+	ActionAdd    Action = "add"
+	ActionUpdate Action = "update"
+
+	// The secret was moved to the trash
+	ActionRemove Action = "remove"
+
+	// The secret was taken back out of the trash
+	ActionRestore Action = "restore"
+
+	// The secret was deleted from the trash for good
+	ActionPurge Action = "purge"
+
+	// A secret saved as it is, such as one pulled from the sync repository
+	ActionImport Action = "import"
+
+	// The value was displayed in the terminal
+	ActionReveal Action = "reveal"
+
+	// The value was copied to the clipboard
+	ActionCopy Action = "copy"
+
+	// The value left the vault, such as in a shared bundle
+	ActionExport Action = "export"
+
+	// The value was used without leaving the vault, such as an SSH key
+	// signing for the agent
+	ActionSign Action = "sign"
+
+	// Values were re-encrypted, with a new passphrase or fresh salts
+	ActionRekey Action = "rekey"
+)
+
+// The hash the first event links to.
+const genesisHash string = "0000000000000000000000000000000000000000000000000000000000000000"
+
+// Returned by Verify when the chain is broken.
+var ErrTampered = errors.New("audit log has been tampered with")
+
+// A single entry of the audit log.
+type Event struct {
+	// Position in the log, starting at 1
+	Sequence int64 `json:"seq"`
+
+	Time   time.Time `json:"time"`
+	Action Action    `json:"action"`
+
+	// Empty for actions not about a single secret
+	SecretId string `json:"secret_id,omitempty"`
+
+	// The operating system user and host that performed the action
+	Actor string `json:"actor"`
+
+	// Optional context, such as who a secret was shared with
+	Details string `json:"details,omitempty"`
+
+	PrevHash string `json:"prev_hash"`
+	Hash     string `json:"hash"`
+}
+
+// An audit log file.
+type Log struct {
+	path  string
+	key   []byte
+	actor string
+}
+
+// Opens the audit log at path, whose events are chained with the key. The
+// file is created on the first event.
+func Open(path string, key []byte) *Log {
+	return &Log{path: path, key: key, actor: currentActor()}
+}
+
+// Appends an event for the action.
+func (log *Log) Record(action Action, secretId string, details string) error {
+	file, err := os.OpenFile(log.path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// Keep other processes from appending between reading the last event and
+	// writing the new one
+	if err := lockFile(file); err != nil {
+		return err
+	}
+	defer unlockFile(file)
+
+	last, err := readLastEvent(file)
+	if err != nil {
+		return err
+	}
+
+	event := Event{
+		Sequence: 1,
+		Time:     time.Now().UTC(),
+		Action:   action,
+		SecretId: secretId,
+		Actor:    log.actor,
+		Details:  details,
+		PrevHash: genesisHash,
+	}
+
+	if last != nil {
+		event.Sequence = last.Sequence + 1
+		event.PrevHash = last.Hash
+	}
+
+	event.Hash, err = event.computeHash(log.key)
+	if err != nil {
+		return err
+	}
+
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		return err
+	}
+
+	return file.Sync()
+}
+
+// Reads all events in order. A missing log has no events.
+func (log *Log) Events() ([]Event, error) {
+	file, err := os.Open(log.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var events []Event
+
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, fmt.Errorf("%w: line %d is not a valid event", ErrTampered, lineNumber)
+		}
+
+		events = append(events, event)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
+
+// Checks the hash chain and returns the number of verified events.
+//
+// The returned error wraps ErrTampered and names the first broken event. A
+// log chained with another key is broken from the first event.
+func (log *Log) Verify() (int, error) {
+	events, err := log.Events()
+	if err != nil {
+		return 0, err
+	}
+
+	prevHash := genesisHash
+
+	for i, event := range events {
+		if event.Sequence != int64(i+1) {
+			return i, fmt.Errorf("%w: expected event %d, found event %d", ErrTampered, i+1, event.Sequence)
+		}
+
+		if event.PrevHash != prevHash {
+			return i, fmt.Errorf("%w: event %d does not link to the previous event", ErrTampered, event.Sequence)
+		}
+
+		hash, err := event.computeHash(log.key)
+		if err != nil {
+			return i, err
+		}
+
+		if hash != event.Hash {
+			return i, fmt.Errorf("%w: event %d has been modified", ErrTampered, event.Sequence)
+		}
+
+		prevHash = event.Hash
+	}
+
+	return len(events), nil
+}
+
+// Computes the hash of the event from its previous hash and content.
+func (event *Event) computeHash(key []byte) (string, error) {
+	unhashed := *event
+	unhashed.Hash = ""
+
+	content, err := json.Marshal(unhashed)
+	if err != nil {
+		return "", err
+	}
+
+	hash := hmac.New(sha256.New, key)
+	hash.Write([]byte(event.PrevHash))
+	hash.Write(content)
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Reads the last event of the file, or nil if the file is empty.
+func readLastEvent(file *os.File) (*Event, error) {
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	size := info.Size()
+	if size == 0 {
+		return nil, nil
+	}
+
+	// Read backwards in chunks until a whole line is found
+	const chunkSize int64 = 4096
+
+	var tail []byte
+	for offset := size; offset > 0; {
+		readSize := min(chunkSize, offset)
+		offset -= readSize
+
+		chunk := make([]byte, readSize)
+		if _, err := file.ReadAt(chunk, offset); err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+
+		tail = append(chunk, tail...)
+
+		// Skip the trailing newline when looking for the start of the line
+		trimmed := bytes.TrimRight(tail, "\n")
+		if i := bytes.LastIndexByte(trimmed, '\n'); i >= 0 || offset == 0 {
+			line := trimmed[i+1:]
+
+			var event Event
+			if err := json.Unmarshal(line, &event); err != nil {
+				return nil, fmt.Errorf("%w: the last event is not valid", ErrTampered)
+			}
+
+			return &event, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// Returns "user@host" for the current process.
+func currentActor() string {
+	username := "unknown"
+	if current, err := user.Current(); err == nil {
+		username = current.Username
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+
+	return username + "@" + hostname
+}
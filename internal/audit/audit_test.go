@@ -0,0 +1,126 @@
+package audit_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Isaac-Fate/myst/internal/audit"
+)
+
+var testKey = []byte("0123456789abcdef0123456789abcdef")
+
+func createLog(t *testing.T) (*audit.Log, string) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	log := audit.Open(path, testKey)
+
+	for _, action := range []audit.Action{audit.ActionAdd, audit.ActionReveal, audit.ActionCopy, audit.ActionRemove} {
+		if err := log.Record(action, "a-secret-id", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return log, path
+}
+
+func TestRecordAndVerify(t *testing.T) {
+	log, _ := createLog(t)
+
+	events, err := log.Events()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 4 {
+		t.Fatalf("expected 4 events, got %d", len(events))
+	}
+
+	for i, event := range events {
+		if event.Sequence != int64(i+1) {
+			t.Errorf("event %d has sequence %d", i, event.Sequence)
+		}
+		if i > 0 && event.PrevHash != events[i-1].Hash {
+			t.Errorf("event %d does not link to the previous event", i)
+		}
+	}
+
+	if events[2].Action != audit.ActionCopy || events[2].SecretId != "a-secret-id" {
+		t.Errorf("unexpected event %+v", events[2])
+	}
+
+	count, err := log.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 4 {
+		t.Errorf("expected 4 verified events, got %d", count)
+	}
+}
+
+func TestVerifyEmptyLog(t *testing.T) {
+	log := audit.Open(filepath.Join(t.TempDir(), "audit.log"), testKey)
+
+	count, err := log.Verify()
+	if err != nil || count != 0 {
+		t.Errorf("expected an empty valid log, got %d events and %v", count, err)
+	}
+}
+
+func TestDetectTampering(t *testing.T) {
+	tamper := map[string]func(lines []string) []string{
+		"modified": func(lines []string) []string {
+			lines[1] = strings.Replace(lines[1], `"reveal"`, `"update"`, 1)
+			return lines
+		},
+		"removed": func(lines []string) []string {
+			return append(lines[:1], lines[2:]...)
+		},
+		"reordered": func(lines []string) []string {
+			lines[1], lines[2] = lines[2], lines[1]
+			return lines
+		},
+	}
+
+	for name, fn := range tamper {
+		t.Run(name, func(t *testing.T) {
+			log, path := createLog(t)
+
+			content, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+			lines = fn(lines)
+
+			if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			count, err := log.Verify()
+			if !errors.Is(err, audit.ErrTampered) {
+				t.Fatalf("expected ErrTampered, got %v", err)
+			}
+			if count != 1 {
+				t.Errorf("expected the first event to be intact, got %d", count)
+			}
+		})
+	}
+}
+
+func TestVerifyWithAnotherKey(t *testing.T) {
+	_, path := createLog(t)
+
+	// Someone without the key cannot chain events that verify
+	log := audit.Open(path, []byte("fedcba9876543210fedcba9876543210"))
+
+	count, err := log.Verify()
+	if !errors.Is(err, audit.ErrTampered) {
+		t.Fatalf("expected ErrTampered, got %v", err)
+	}
+	if count != 0 {
+		t.Errorf("expected no intact events, got %d", count)
+	}
+}
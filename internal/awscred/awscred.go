@@ -0,0 +1,93 @@
+package awscred
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/Isaac-Fate/myst/internal/models"
+)
+
+// Turns secrets into the output of an AWS credential_process, see
+// https://docs.aws.amazon.com/sdkref/latest/guide/feature-process-credentials.html,
+// so that access keys are handed to the AWS SDKs without being written to
+// ~/.aws/credentials.
+//
+// A secret holds credentials in either of two ways:
+//
+//   - The access key ID as the username and the secret access key as the
+//     value, with the expiry date, if any, as the expiration.
+//   - A JSON value with AccessKeyId, SecretAccessKey and optionally
+//     SessionToken and Expiration, as printed by 'aws sts' commands, which
+//     may be wrapped in a "Credentials" object.
+
+// The version of the output format.
+const version int = 1
+
+// Credentials as printed for the AWS SDKs.
+type Credentials struct {
+	Version         int        `json:"Version"`
+	AccessKeyId     string     `json:"AccessKeyId"`
+	SecretAccessKey string     `json:"SecretAccessKey"`
+	SessionToken    string     `json:"SessionToken,omitempty"`
+	Expiration      *time.Time `json:"Expiration,omitempty"`
+}
+
+// Reads the credentials held by a secret with the value.
+func FromSecret(secret *models.Secret, value string) (*Credentials, error) {
+	credentials := &Credentials{Version: version}
+
+	if trimmed := strings.TrimSpace(value); strings.HasPrefix(trimmed, "{") {
+		var parsed struct {
+			Credentials
+			Wrapped *Credentials `json:"Credentials"`
+		}
+		if err := json.Unmarshal([]byte(trimmed), &parsed); err != nil {
+			return nil, fmt.Errorf("failed to parse the JSON value of secret '%s': %w", secret.Key, err)
+		}
+
+		if parsed.Wrapped != nil {
+			*credentials = *parsed.Wrapped
+		} else {
+			*credentials = parsed.Credentials
+		}
+		credentials.Version = version
+	} else {
+		credentials.AccessKeyId = strings.TrimSpace(secret.Username)
+		credentials.SecretAccessKey = trimmed
+	}
+
+	if credentials.AccessKeyId == "" || credentials.SecretAccessKey == "" {
+		return nil, fmt.Errorf("secret '%s' has no access key ID or secret access key; set the access key ID as its username, or store the keys as JSON", secret.Key)
+	}
+
+	if credentials.Expiration == nil {
+		credentials.Expiration = secret.ExpiresAt
+	}
+
+	// The SDKs expect ISO 8601 in UTC, such as "2025-01-02T15:04:05Z"
+	if credentials.Expiration != nil {
+		expiration := credentials.Expiration.UTC().Truncate(time.Second)
+		credentials.Expiration = &expiration
+	}
+
+	return credentials, nil
+}
+
+// Returns an error if the credentials expired by now, since the SDKs would
+// only run the process again.
+func (credentials *Credentials) Check(now time.Time) error {
+	if credentials.Expiration != nil && !credentials.Expiration.After(now) {
+		return errors.New("the credentials expired on " + credentials.Expiration.Local().Format(time.DateTime))
+	}
+
+	return nil
+}
+
+// Writes the credentials as JSON.
+func (credentials *Credentials) Write(w io.Writer) error {
+	return json.NewEncoder(w).Encode(credentials)
+}
@@ -0,0 +1,96 @@
+package awscred_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Isaac-Fate/myst/internal/awscred"
+	"github.com/Isaac-Fate/myst/internal/models"
+)
+
+func TestFromSecret(t *testing.T) {
+	expiresAt := time.Date(2030, 1, 2, 15, 4, 5, 123, time.FixedZone("HKT", 8*3600))
+
+	for _, test := range []struct {
+		name   string
+		secret models.Secret
+		value  string
+		output string
+	}{
+		{
+			"username and value",
+			models.Secret{Key: "prod-admin", Username: "AKIAEXAMPLE"},
+			"wJalrXUtnFEMI\n",
+			`{"Version":1,"AccessKeyId":"AKIAEXAMPLE","SecretAccessKey":"wJalrXUtnFEMI"}`,
+		},
+		{
+			"expiry date",
+			models.Secret{Key: "prod-admin", Username: "AKIAEXAMPLE", ExpiresAt: &expiresAt},
+			"wJalrXUtnFEMI",
+			`{"Version":1,"AccessKeyId":"AKIAEXAMPLE","SecretAccessKey":"wJalrXUtnFEMI","Expiration":"2030-01-02T07:04:05Z"}`,
+		},
+		{
+			"JSON value",
+			models.Secret{Key: "prod-session"},
+			`{"AccessKeyId":"ASIAEXAMPLE","SecretAccessKey":"secret","SessionToken":"token","Expiration":"2030-01-02T07:04:05Z"}`,
+			`{"Version":1,"AccessKeyId":"ASIAEXAMPLE","SecretAccessKey":"secret","SessionToken":"token","Expiration":"2030-01-02T07:04:05Z"}`,
+		},
+		{
+			"output of aws sts",
+			models.Secret{Key: "prod-session", ExpiresAt: &expiresAt},
+			`{"Credentials":{"AccessKeyId":"ASIAEXAMPLE","SecretAccessKey":"secret","SessionToken":"token"}}`,
+			`{"Version":1,"AccessKeyId":"ASIAEXAMPLE","SecretAccessKey":"secret","SessionToken":"token","Expiration":"2030-01-02T07:04:05Z"}`,
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			credentials, err := awscred.FromSecret(&test.secret, test.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var output strings.Builder
+			if err := credentials.Write(&output); err != nil {
+				t.Fatal(err)
+			}
+
+			if strings.TrimSpace(output.String()) != test.output {
+				t.Errorf("expected %s, got %s", test.output, output.String())
+			}
+		})
+	}
+}
+
+func TestInvalidSecrets(t *testing.T) {
+	for _, test := range []struct {
+		secret models.Secret
+		value  string
+	}{
+		{models.Secret{Key: "no-username"}, "secret"},
+		{models.Secret{Key: "bad-json"}, `{"AccessKeyId":`},
+		{models.Secret{Key: "missing-key"}, `{"AccessKeyId":"AKIAEXAMPLE"}`},
+	} {
+		if _, err := awscred.FromSecret(&test.secret, test.value); err == nil {
+			t.Errorf("expected an error for secret '%s'", test.secret.Key)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	now := time.Now()
+	expiresAt := now.Add(-time.Minute)
+
+	credentials, err := awscred.FromSecret(&models.Secret{Key: "old", Username: "AKIAEXAMPLE", ExpiresAt: &expiresAt}, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := credentials.Check(now); err == nil {
+		t.Error("expected an error for expired credentials")
+	}
+
+	credentials.Expiration = nil
+	if err := credentials.Check(now); err != nil {
+		t.Errorf("expected credentials without an expiration to be valid, got %v", err)
+	}
+}
@@ -0,0 +1,168 @@
+package breach
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Checks values against a local copy of the Pwned Passwords list, without
+// any network access.
+//
+// The list comes either as a single file of "<SHA-1>:<count>" lines ordered
+// by hash, or as a directory of range files, where "<prefix>.txt" holds the
+// "<suffix>:<count>" lines of the hashes starting with the 5 character
+// prefix. Hashes are upper case hex. Either way, lines are found by binary
+// search over byte offsets, so even the full 30+ GB file takes a few dozen
+// reads per value.
+
+// Length of the hash prefixes naming range files.
+const prefixLength int = 5
+
+// Returned when a line of the list cannot be parsed.
+var ErrMalformedList = errors.New("malformed Pwned Passwords list")
+
+// A local copy of the Pwned Passwords list.
+type List struct {
+	path  string
+	isDir bool
+}
+
+// Opens the Pwned Passwords list at the path, a sorted hash file or a
+// directory of range files.
+func Open(path string) (*List, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the Pwned Passwords list: %w", err)
+	}
+
+	return &List{path: path, isDir: info.IsDir()}, nil
+}
+
+// Returns how many times the value appears in breaches, or 0 if it is not
+// in the list.
+func (list *List) Count(value string) (int, error) {
+	sum := sha1.Sum([]byte(value))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	if !list.isDir {
+		return searchFile(list.path, hash)
+	}
+
+	// Mirrors name range files with or without an extension
+	prefix, suffix := hash[:prefixLength], hash[prefixLength:]
+	for _, name := range []string{prefix + ".txt", prefix} {
+		count, err := searchFile(filepath.Join(list.path, name), suffix)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+
+		return count, err
+	}
+
+	// Every prefix has a range file, so a missing one means a partial mirror
+	return 0, fmt.Errorf("no range file for prefix %s in %s", prefix, list.path)
+}
+
+// Finds the line of the hash in a sorted file and returns its count, or 0
+// if there is none.
+func searchFile(path string, hash string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	// The target line, if any, starts in [low, high)
+	low, high := int64(0), info.Size()
+	for low < high {
+		middle := low + (high-low)/2
+
+		line, start, next, err := lineFrom(file, middle, info.Size())
+		if err != nil {
+			return 0, err
+		}
+
+		// No line starts in [middle, high)
+		if line == "" || start >= high {
+			high = middle
+			continue
+		}
+
+		lineHash, count, err := parseLine(line)
+		if err != nil {
+			return 0, err
+		}
+
+		switch strings.Compare(lineHash, hash) {
+		case 0:
+			return count, nil
+		case -1:
+			low = next
+		default:
+			high = middle
+		}
+	}
+
+	return 0, nil
+}
+
+// Reads the first line starting at or after the offset, returning it
+// without its line break, along with where it and the next line start. The
+// line is empty at the end of the file.
+func lineFrom(file *os.File, offset int64, size int64) (string, int64, int64, error) {
+	start := offset
+
+	// Unless the offset follows a line break, skip to the next line
+	if offset > 0 {
+		reader := bufio.NewReader(io.NewSectionReader(file, offset-1, size-offset+1))
+
+		skipped, err := reader.ReadString('\n')
+		if errors.Is(err, io.EOF) {
+			return "", size, size, nil
+		}
+		if err != nil {
+			return "", 0, 0, err
+		}
+
+		start = offset - 1 + int64(len(skipped))
+	}
+
+	reader := bufio.NewReader(io.NewSectionReader(file, start, size-start))
+
+	line, err := reader.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", 0, 0, err
+	}
+
+	next := start + int64(len(line))
+
+	return strings.TrimRight(line, "\r\n"), start, next, nil
+}
+
+// Parses a "<hash>:<count>" line. Lists without counts are accepted too.
+func parseLine(line string) (string, int, error) {
+	hash, countText, found := strings.Cut(line, ":")
+	if !found {
+		return strings.ToUpper(hash), 1, nil
+	}
+
+	count, err := strconv.Atoi(strings.TrimSpace(countText))
+	if err != nil {
+		return "", 0, fmt.Errorf("%w: %q", ErrMalformedList, line)
+	}
+
+	return strings.ToUpper(hash), count, nil
+}
@@ -0,0 +1,105 @@
+package breach_test
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/Isaac-Fate/myst/internal/breach"
+)
+
+func hashOf(value string) string {
+	sum := sha1.Sum([]byte(value))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// Writes a sorted hash file where the i-th breached value appears i+1 times.
+func writeHashFile(t *testing.T, breached []string, lineBreak string) string {
+	lines := make([]string, len(breached))
+	for i, value := range breached {
+		lines[i] = fmt.Sprintf("%s:%d", hashOf(value), i+1)
+	}
+	sort.Strings(lines)
+
+	path := filepath.Join(t.TempDir(), "pwned-passwords-sha1-ordered-by-hash.txt")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, lineBreak)+lineBreak), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func breachedValues() []string {
+	values := []string{"password", "123456", "hunter2"}
+	for i := 0; i < 500; i++ {
+		values = append(values, fmt.Sprintf("leaked-%d", i))
+	}
+	return values
+}
+
+func TestHashFile(t *testing.T) {
+	values := breachedValues()
+
+	for _, lineBreak := range []string{"\n", "\r\n"} {
+		list, err := breach.Open(writeHashFile(t, values, lineBreak))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Every value is found, including the first and last lines
+		for i, value := range values {
+			count, err := list.Count(value)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if count != i+1 {
+				t.Errorf("%q: expected count %d, got %d", value, i+1, count)
+			}
+		}
+
+		for i := 0; i < 200; i++ {
+			value := fmt.Sprintf("safe-%d", i)
+			if count, err := list.Count(value); err != nil || count != 0 {
+				t.Errorf("%q: expected not to be found, got %d, %v", value, count, err)
+			}
+		}
+	}
+}
+
+func TestRangeFiles(t *testing.T) {
+	values := breachedValues()
+	dir := t.TempDir()
+
+	ranges := make(map[string][]string)
+	for i, value := range values {
+		hash := hashOf(value)
+		ranges[hash[:5]] = append(ranges[hash[:5]], fmt.Sprintf("%s:%d", hash[5:], i+1))
+	}
+
+	for prefix, lines := range ranges {
+		sort.Strings(lines)
+		if err := os.WriteFile(filepath.Join(dir, prefix+".txt"), []byte(strings.Join(lines, "\r\n")), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list, err := breach.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count, err := list.Count("hunter2"); err != nil || count != 3 {
+		t.Errorf("expected hunter2 to be found 3 times, got %d, %v", count, err)
+	}
+
+	// A missing range file means the mirror is incomplete
+	if _, err := list.Count("not in any range file"); err == nil {
+		t.Error("expected an error for a missing range file")
+	}
+}
@@ -1,25 +1,87 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 
+	"github.com/Isaac-Fate/myst/internal/utils"
+	"github.com/Isaac-Fate/myst/internal/vaultfile"
 	"gopkg.in/yaml.v3"
 )
 
 const dataDirName = "myst"
 
+// Names of the files in the data directory
+const (
+	ConfigFileName      = "config.yml"
+	SecretStoreFileName = "secret-store.db"
+	SecretIndexDirName  = "secret-index"
+	VaultFileName       = "vault.myst"
+	AuditLogFileName    = "audit.log"
+	IdentityFileName    = "identity.yml"
+)
+
+// The largest attachment allowed unless configured otherwise.
+const DefaultMaxAttachmentSize int64 = 64 << 20
+
+// Storage backends
+const (
+	// Secrets in a SQLite database with an on-disk search index
+	SQLiteBackend = "sqlite"
+
+	// The whole vault in a single encrypted file with an in-memory index
+	FileBackend = "file"
+)
+
 type Config struct {
 	DigestedPassphrase string `yaml:"digested_passphrase"`
+
+	// Storage backend, either SQLiteBackend or FileBackend. Configurations
+	// written before backends were introduced leave it empty, which means
+	// SQLiteBackend.
+	Backend string `yaml:"backend,omitempty"`
+
+	// The master passphrase encrypted with the recovery key, which is split
+	// into shares by 'myst recovery split'. Empty if recovery is not set up.
+	RecoveryWrappedPassphrase string `yaml:"recovery_wrapped_passphrase,omitempty"`
+
+	// A local copy of the Pwned Passwords list, a sorted hash file or a
+	// directory of range files. If set, new values are checked against it.
+	HIBPFile string `yaml:"hibp_file,omitempty"`
+
+	// The largest attachment allowed, such as "256MiB", if not the default.
+	MaxAttachmentSize string `yaml:"max_attachment_size,omitempty"`
+
+	// The key the audit log is chained with, encrypted with the passphrase.
+	// It is made the first time the vault is unlocked, see AuditKey.
+	WrappedAuditKey string `yaml:"wrapped_audit_key,omitempty"`
+
+	// The digest of a new passphrase, and the new passphrase and the audit
+	// key encrypted with the recovery key and the new passphrase, while the
+	// secrets are re-encrypted with it. They replace DigestedPassphrase,
+	// RecoveryWrappedPassphrase and WrappedAuditKey once that is done, see
+	// FinishPassphraseChange.
+	PendingDigestedPassphrase        string `yaml:"pending_digested_passphrase,omitempty"`
+	PendingRecoveryWrappedPassphrase string `yaml:"pending_recovery_wrapped_passphrase,omitempty"`
+	PendingWrappedAuditKey           string `yaml:"pending_wrapped_audit_key,omitempty"`
 }
 
-func DataDir() string {
+// Returns the path of the data directory, without creating it.
+func DefaultDataDir() (string, error) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
-		panic(err)
+		return "", err
 	}
 
-	dataDir := filepath.Join(homeDir, dataDirName)
+	return filepath.Join(homeDir, dataDirName), nil
+}
+
+func DataDir() string {
+	dataDir, err := DefaultDataDir()
+	if err != nil {
+		panic(err)
+	}
 
 	// Create the data directory if it doesn't exist
 	if err := os.MkdirAll(dataDir, 0755); err != nil {
@@ -30,15 +92,72 @@ func DataDir() string {
 }
 
 func ConfigPath() string {
-	return filepath.Join(DataDir(), "config.yml")
+	return filepath.Join(DataDir(), ConfigFileName)
 }
 
 func SecretStorePath() string {
-	return filepath.Join(DataDir(), "secret-store.db")
+	return filepath.Join(DataDir(), SecretStoreFileName)
 }
 
 func SecretIndexPath() string {
-	return filepath.Join(DataDir(), "secret-index")
+	return filepath.Join(DataDir(), SecretIndexDirName)
+}
+
+func VaultFilePath() string {
+	return filepath.Join(DataDir(), VaultFileName)
+}
+
+// The git working copy used to sync the vault.
+func SyncDir() string {
+	return filepath.Join(DataDir(), "sync")
+}
+
+// The state of the secrets at the last sync, kept outside the git working copy.
+func SyncStatePath() string {
+	return filepath.Join(DataDir(), "sync-state.json")
+}
+
+// The personal X25519 identity used to receive shared secrets.
+func IdentityPath() string {
+	return filepath.Join(DataDir(), IdentityFileName)
+}
+
+// The public keys of teammates secrets can be shared with.
+func AddressBookPath() string {
+	return filepath.Join(DataDir(), "recipients.yml")
+}
+
+// The hash-chained log of every change to and access of a secret.
+func AuditLogPath() string {
+	return filepath.Join(DataDir(), AuditLogFileName)
+}
+
+// The default socket of 'myst ssh-agent'.
+func SSHAgentSocketPath() string {
+	return filepath.Join(DataDir(), "ssh-agent.sock")
+}
+
+// Returns the configured storage backend.
+func (config *Config) StorageBackend() string {
+	if config.Backend == "" {
+		return SQLiteBackend
+	}
+
+	return config.Backend
+}
+
+// Returns the largest attachment allowed.
+func (config *Config) AttachmentSizeLimit() (int64, error) {
+	if config.MaxAttachmentSize == "" {
+		return DefaultMaxAttachmentSize, nil
+	}
+
+	limit, err := utils.ParseSize(config.MaxAttachmentSize)
+	if err != nil {
+		return 0, fmt.Errorf("invalid max_attachment_size in the config: %w", err)
+	}
+
+	return limit, nil
 }
 
 func (config *Config) IsComplete() bool {
@@ -46,6 +165,12 @@ func (config *Config) IsComplete() bool {
 }
 
 func Save(config *Config) error {
+	return SaveFile(ConfigPath(), config)
+}
+
+// Saves the configuration to a file other than the default one. The file is
+// replaced as a whole, so it is never left half written.
+func SaveFile(path string, config *Config) error {
 	// Marshal the Config struct into YAML
 	yamlContent, err := yaml.Marshal(config)
 	if err != nil {
@@ -53,12 +178,16 @@ func Save(config *Config) error {
 	}
 
 	// Write the YAML content to a file
-	return os.WriteFile(ConfigPath(), yamlContent, 0644)
+	return vaultfile.WriteFileAtomic(path, yamlContent, 0644)
 }
 
 func LoadConfig(config *Config) error {
+	return LoadConfigFile(ConfigPath(), config)
+}
 
-	content, err := os.ReadFile(ConfigPath())
+// Loads the configuration from a file other than the default one.
+func LoadConfigFile(path string, config *Config) error {
+	content, err := os.ReadFile(path)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,126 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	mycrypto "github.com/Isaac-Fate/myst/internal/crypto"
+)
+
+// A change of passphrase re-encrypts the secrets in a single transaction of
+// the store, but the configuration and the identity live in files of their
+// own. To never end up with a configuration that matches neither the old
+// nor the new passphrase, the new digest is saved as pending before the
+// secrets are re-encrypted, and the identity encrypted with the new
+// passphrase is saved next to the current one. Until the change is
+// finished, either passphrase unlocks, and whichever the vault opens with
+// tells whether the transaction went through.
+
+// The suffix of the identity file encrypted with a pending passphrase.
+const pendingSuffix string = ".pending"
+
+// Returns the path the identity encrypted with a pending passphrase is saved
+// to, next to the identity at path.
+func PendingIdentityPath(path string) string {
+	return path + pendingSuffix
+}
+
+// Reports whether the passphrase matches the digest, or the pending digest
+// of a change of passphrase that has not finished.
+func (config *Config) VerifyPassphrase(passphrase string) bool {
+	if mycrypto.VerifyPassphrase(passphrase, config.DigestedPassphrase) {
+		return true
+	}
+
+	return config.ChangingPassphrase() && mycrypto.VerifyPassphrase(passphrase, config.PendingDigestedPassphrase)
+}
+
+// Reports whether a change of passphrase has begun and not finished.
+func (config *Config) ChangingPassphrase() bool {
+	return config.PendingDigestedPassphrase != ""
+}
+
+// Records the new passphrase as pending, along with the new passphrase
+// encrypted with the recovery key, if any, and the audit key, if any,
+// encrypted with the new passphrase. The configuration must be saved before
+// the secrets are re-encrypted.
+func (config *Config) BeginPassphraseChange(newPassphrase string, recoveryWrappedPassphrase string, auditKey []byte) error {
+	var wrappedAuditKey string
+	if auditKey != nil {
+		var err error
+		wrappedAuditKey, err = mycrypto.WrapDataKey(newPassphrase, auditKey)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt the audit key: %w", err)
+		}
+	}
+
+	config.PendingDigestedPassphrase = mycrypto.DigestPassphrase(newPassphrase)
+	config.PendingRecoveryWrappedPassphrase = recoveryWrappedPassphrase
+	config.PendingWrappedAuditKey = wrappedAuditKey
+	return nil
+}
+
+// Finishes a change of passphrase in the data directory dir, given a
+// passphrase the configuration verifies and whether the vault opens with it.
+//
+// If the vault is encrypted with the new passphrase, the pending digest,
+// audit key and identity replace the current ones; otherwise they are dropped. The
+// configuration is saved either way.
+func (config *Config) FinishPassphraseChange(dir string, passphrase string, opens bool) error {
+	isNew := mycrypto.VerifyPassphrase(passphrase, config.PendingDigestedPassphrase)
+	identityPath := filepath.Join(dir, IdentityFileName)
+
+	if opens == isNew {
+		err := os.Rename(PendingIdentityPath(identityPath), identityPath)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+
+		config.DigestedPassphrase = config.PendingDigestedPassphrase
+		config.RecoveryWrappedPassphrase = config.PendingRecoveryWrappedPassphrase
+		config.WrappedAuditKey = config.PendingWrappedAuditKey
+	} else {
+		err := os.Remove(PendingIdentityPath(identityPath))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	config.PendingDigestedPassphrase = ""
+	config.PendingRecoveryWrappedPassphrase = ""
+	config.PendingWrappedAuditKey = ""
+
+	return SaveFile(filepath.Join(dir, ConfigFileName), config)
+}
+
+// Returns the key the audit log is chained with, decrypting it with the
+// passphrase. The first time, a new key is made and the configuration is
+// saved in the data directory dir.
+func (config *Config) AuditKey(dir string, passphrase string) ([]byte, error) {
+	if config.WrappedAuditKey != "" {
+		key, err := mycrypto.UnwrapDataKey(passphrase, config.WrappedAuditKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt the audit key: %w", err)
+		}
+
+		return key, nil
+	}
+
+	key, err := mycrypto.GenerateDataKey()
+	if err != nil {
+		return nil, err
+	}
+
+	config.WrappedAuditKey, err = mycrypto.WrapDataKey(passphrase, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt the audit key: %w", err)
+	}
+
+	if err := SaveFile(filepath.Join(dir, ConfigFileName), config); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
@@ -1,7 +1,13 @@
 package crypto_test
 
 import (
+	"bytes"
+	"crypto/rand"
+	"errors"
 	"fmt"
+	"io"
+	"slices"
+	"strings"
 	"testing"
 
 	mycrypto "github.com/Isaac-Fate/myst/internal/crypto"
@@ -43,3 +49,153 @@ func TestPassphrase(t *testing.T) {
 		t.Errorf("expected true, got false")
 	}
 }
+
+func TestGeneratePassword(t *testing.T) {
+	generated, err := mycrypto.GeneratePassword(20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(generated) != 20 {
+		t.Errorf("expected 20 characters, got %d", len(generated))
+	}
+
+	for _, class := range []string{"abcdefghijkmnopqrstuvwxyz", "ABCDEFGHJKLMNPQRSTUVWXYZ", "23456789", "!#$%&*+-=?@^_~"} {
+		if !strings.ContainsAny(generated, class) {
+			t.Errorf("expected %q to contain one of %q", generated, class)
+		}
+	}
+
+	other, err := mycrypto.GeneratePassword(20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == generated {
+		t.Error("expected different passwords")
+	}
+
+	if _, err := mycrypto.GeneratePassword(4); err == nil {
+		t.Error("expected an error for a short password")
+	}
+}
+
+func TestKeyedHash(t *testing.T) {
+	key, err := mycrypto.NewHashKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if mycrypto.KeyedHash(key, password) != mycrypto.KeyedHash(key, password) {
+		t.Error("expected equal values to have equal hashes")
+	}
+
+	if mycrypto.KeyedHash(key, password) == mycrypto.KeyedHash(key, password+"!") {
+		t.Error("expected different values to have different hashes")
+	}
+
+	otherKey, err := mycrypto.NewHashKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if mycrypto.KeyedHash(key, password) == mycrypto.KeyedHash(otherKey, password) {
+		t.Error("expected the hash to depend on the key")
+	}
+}
+
+func TestEncryptDecryptStream(t *testing.T) {
+	key, err := mycrypto.GenerateDataKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const chunk = 64 * 1024
+	for _, size := range []int{0, 1, chunk - 1, chunk, chunk + 1, 3*chunk + 17} {
+		t.Run(fmt.Sprint(size), func(t *testing.T) {
+			plaintext := make([]byte, size)
+			rand.Read(plaintext)
+
+			var encrypted bytes.Buffer
+			n, err := mycrypto.EncryptStream(key, &encrypted, bytes.NewReader(plaintext))
+			if err != nil {
+				t.Fatalf("failed to encrypt: %v", err)
+			}
+			if n != int64(size) {
+				t.Errorf("encrypted %d bytes, want %d", n, size)
+			}
+
+			var decrypted bytes.Buffer
+			n, err = mycrypto.DecryptStream(key, &decrypted, bytes.NewReader(encrypted.Bytes()))
+			if err != nil {
+				t.Fatalf("failed to decrypt: %v", err)
+			}
+			if n != int64(size) || !bytes.Equal(decrypted.Bytes(), plaintext) {
+				t.Errorf("decrypted %d bytes that differ from the plaintext", n)
+			}
+		})
+	}
+}
+
+func TestDecryptStreamCorrupted(t *testing.T) {
+	key, _ := mycrypto.GenerateDataKey()
+	otherKey, _ := mycrypto.GenerateDataKey()
+
+	const chunk = 64 * 1024
+	plaintext := make([]byte, 2*chunk)
+	rand.Read(plaintext)
+
+	var encrypted bytes.Buffer
+	if _, err := mycrypto.EncryptStream(key, &encrypted, bytes.NewReader(plaintext)); err != nil {
+		t.Fatal(err)
+	}
+	data := encrypted.Bytes()
+
+	// The header, the first sealed chunk, and the last sealed chunk
+	header := 8 + 7
+	sealed := chunk + 16
+
+	flipped := bytes.Clone(data)
+	flipped[header+10] ^= 1
+
+	for _, test := range []struct {
+		name string
+		key  []byte
+		data []byte
+	}{
+		{"other key", otherKey, data},
+		{"changed", key, flipped},
+		{"truncated in a chunk", key, data[:len(data)-5]},
+		{"last chunk dropped", key, data[:header+sealed]},
+		{"chunks swapped", key, slices.Concat(data[:header], data[header+sealed:header+2*sealed], data[header:header+sealed], data[header+2*sealed:])},
+		{"appended", key, append(bytes.Clone(data), data[header:header+sealed]...)},
+		{"no chunks", key, data[:header]},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := mycrypto.DecryptStream(test.key, io.Discard, bytes.NewReader(test.data))
+			if !errors.Is(err, mycrypto.ErrStreamCorrupted) {
+				t.Errorf("err = %v, want ErrStreamCorrupted", err)
+			}
+		})
+	}
+}
+
+func TestWrapDataKey(t *testing.T) {
+	key, _ := mycrypto.GenerateDataKey()
+
+	wrapped, err := mycrypto.WrapDataKey(passphrase, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unwrapped, err := mycrypto.UnwrapDataKey(passphrase, wrapped)
+	if err != nil {
+		t.Fatalf("failed to unwrap: %v", err)
+	}
+	if !bytes.Equal(unwrapped, key) {
+		t.Error("unwrapped key differs")
+	}
+
+	if _, err := mycrypto.UnwrapDataKey("wrong", wrapped); err == nil {
+		t.Error("expected an error for the wrong passphrase")
+	}
+}
@@ -0,0 +1,70 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"math/big"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Character classes of generated passwords. Look-alike characters such as
+// 'l', '1', 'O' and '0' are left out so that passwords can be read aloud.
+const lowerCharacters string = "abcdefghijkmnopqrstuvwxyz"
+const upperCharacters string = "ABCDEFGHJKLMNPQRSTUVWXYZ"
+const digitCharacters string = "23456789"
+const symbolCharacters string = "!#$%&*+-=?@^_~"
+
+// The shortest password GeneratePassword creates.
+const MinPasswordLength int = 8
+
+// Generates a random password of the given length.
+//
+// The password contains at least one lower case letter, upper case letter,
+// digit and symbol.
+func GeneratePassword(length int) (string, error) {
+	if length < MinPasswordLength {
+		return "", errors.Errorf("password length must be at least %d", MinPasswordLength)
+	}
+
+	classes := []string{lowerCharacters, upperCharacters, digitCharacters, symbolCharacters}
+	all := strings.Join(classes, "")
+
+	password := make([]byte, length)
+
+	// One character of each class, then any character
+	for i := range password {
+		characters := all
+		if i < len(classes) {
+			characters = classes[i]
+		}
+
+		c, err := randomCharacter(characters)
+		if err != nil {
+			return "", err
+		}
+
+		password[i] = c
+	}
+
+	// Move the guaranteed characters to random positions
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return "", err
+		}
+
+		password[i], password[j.Int64()] = password[j.Int64()], password[i]
+	}
+
+	return string(password), nil
+}
+
+func randomCharacter(characters string) (byte, error) {
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(len(characters))))
+	if err != nil {
+		return 0, err
+	}
+
+	return characters[i.Int64()], nil
+}
@@ -0,0 +1,36 @@
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Length of the keys for KeyedHash.
+const hashKeyLength int = 32
+
+// Generates a random key for KeyedHash.
+//
+// A key should only live as long as the comparison it is used for, so that
+// the hashes cannot be matched against anything outside of it.
+func NewHashKey() ([]byte, error) {
+	key := make([]byte, hashKeyLength)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// Hashes a value with HMAC-SHA256 under the key.
+//
+// Equal values have equal hashes under the same key, so values can be
+// compared without keeping them around, and without the hashes being useful
+// for guessing the values to anyone without the key.
+func KeyedHash(key []byte, value string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(value))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
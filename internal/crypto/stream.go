@@ -0,0 +1,202 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// Files too large to hold in memory, such as attachments, are encrypted as
+// a stream of chunks, each sealed with AES-256-GCM under a random data key:
+//
+//	<magic> <nonce prefix> <sealed chunk> <sealed chunk> ...
+//
+// Every chunk but the last holds streamChunkSize bytes of plaintext. The
+// nonce of a chunk is the random nonce prefix, the chunk's index, and a
+// final byte that is 1 for the last chunk only, so chunks cannot be
+// reordered, dropped or appended without the decryption failing. The header
+// is authenticated with every chunk.
+
+const streamMagic string = "MYSTSTR1"
+const streamNoncePrefixLength int = 7
+const streamChunkSize int = 64 * 1024
+
+// The size of data keys for EncryptStream.
+const DataKeyLength int = secretKeyLength
+
+// Returned when a stream was cut short, changed, or encrypted with another
+// key.
+var ErrStreamCorrupted = errors.New("encrypted stream is corrupted or was encrypted with another key")
+
+// Generates a random key for EncryptStream.
+func GenerateDataKey() ([]byte, error) {
+	key := make([]byte, DataKeyLength)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// Encrypts a data key with the passphrase, so it can be kept with the data.
+func WrapDataKey(passphrase string, key []byte) (string, error) {
+	return Encrypt(passphrase, hex.EncodeToString(key))
+}
+
+// Decrypts a data key encrypted with WrapDataKey.
+func UnwrapDataKey(passphrase string, wrappedKey string) ([]byte, error) {
+	encodedKey, err := Decrypt(passphrase, wrappedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := hex.DecodeString(encodedKey)
+	if err != nil || len(key) != DataKeyLength {
+		return nil, errors.New("invalid data key")
+	}
+
+	return key, nil
+}
+
+// Encrypts everything read from src with the data key into dst, returning
+// the number of plaintext bytes read.
+func EncryptStream(key []byte, dst io.Writer, src io.Reader) (int64, error) {
+	aead, err := newStreamCipher(key)
+	if err != nil {
+		return 0, err
+	}
+
+	header := make([]byte, len(streamMagic)+streamNoncePrefixLength)
+	copy(header, streamMagic)
+	if _, err := rand.Read(header[len(streamMagic):]); err != nil {
+		return 0, err
+	}
+
+	if _, err := dst.Write(header); err != nil {
+		return 0, err
+	}
+
+	// Read one byte ahead to know whether a chunk is the last
+	buffer := make([]byte, streamChunkSize+1)
+	sealed := make([]byte, 0, streamChunkSize+aead.Overhead())
+
+	var total int64
+	n, err := io.ReadFull(src, buffer)
+	for index := uint32(0); ; index++ {
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			return total, err
+		}
+
+		last := n <= streamChunkSize
+		chunk := buffer[:min(n, streamChunkSize)]
+		total += int64(len(chunk))
+
+		sealed = aead.Seal(sealed[:0], streamNonce(header, index, last), chunk, header)
+		if _, err := dst.Write(sealed); err != nil {
+			return total, err
+		}
+
+		if last {
+			return total, nil
+		}
+
+		if index == ^uint32(0) {
+			return total, errors.New("stream is too long to encrypt")
+		}
+
+		// Carry the byte read ahead into the next chunk
+		buffer[0] = buffer[streamChunkSize]
+		n, err = io.ReadFull(src, buffer[1:])
+		n++
+	}
+}
+
+// Decrypts a stream encrypted with EncryptStream from src into dst,
+// returning the number of plaintext bytes written.
+//
+// Only authenticated chunks are written, but if the stream turns out to be
+// corrupted, what was written before the error should be discarded.
+func DecryptStream(key []byte, dst io.Writer, src io.Reader) (int64, error) {
+	aead, err := newStreamCipher(key)
+	if err != nil {
+		return 0, err
+	}
+
+	header := make([]byte, len(streamMagic)+streamNoncePrefixLength)
+	if _, err := io.ReadFull(src, header); err != nil {
+		return 0, ErrStreamCorrupted
+	}
+	if !bytes.HasPrefix(header, []byte(streamMagic)) {
+		return 0, errors.New("not an encrypted stream")
+	}
+
+	sealedChunkSize := streamChunkSize + aead.Overhead()
+
+	// Read one byte ahead to know whether a chunk is the last
+	buffer := make([]byte, sealedChunkSize+1)
+	plaintext := make([]byte, 0, streamChunkSize)
+
+	var total int64
+	n, err := io.ReadFull(src, buffer)
+	for index := uint32(0); ; index++ {
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			return total, err
+		}
+
+		last := n <= sealedChunkSize
+		sealed := buffer[:min(n, sealedChunkSize)]
+
+		plaintext, err = aead.Open(plaintext[:0], streamNonce(header, index, last), sealed, header)
+		if err != nil {
+			return total, ErrStreamCorrupted
+		}
+
+		if _, err := dst.Write(plaintext); err != nil {
+			return total, err
+		}
+		total += int64(len(plaintext))
+
+		if last {
+			return total, nil
+		}
+
+		if index == ^uint32(0) {
+			return total, ErrStreamCorrupted
+		}
+
+		buffer[0] = buffer[sealedChunkSize]
+		n, err = io.ReadFull(src, buffer[1:])
+		n++
+	}
+}
+
+func newStreamCipher(key []byte) (cipher.AEAD, error) {
+	if len(key) != DataKeyLength {
+		return nil, errors.New("invalid data key")
+	}
+
+	blockCipher, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(blockCipher)
+}
+
+// Returns the nonce of the chunk with the index.
+func streamNonce(header []byte, index uint32, last bool) []byte {
+	nonce := make([]byte, ivLength)
+	copy(nonce, header[len(streamMagic):])
+	binary.BigEndian.PutUint32(nonce[streamNoncePrefixLength:], index)
+	if last {
+		nonce[ivLength-1] = 1
+	}
+
+	return nonce
+}
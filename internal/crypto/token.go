@@ -0,0 +1,34 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// Prefix of API tokens, which makes them easy to recognize, such as by
+// secret scanners.
+const tokenPrefix string = "myst_"
+
+// Number of random bytes in an API token.
+const tokenLength int = 32
+
+// Generates a random API token.
+func GenerateToken() (string, error) {
+	bytes := make([]byte, tokenLength)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+
+	return tokenPrefix + base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+// Hashes an API token for storing and looking it up.
+//
+// Tokens are random and long, so unlike passphrases they need no salt or
+// key stretching.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
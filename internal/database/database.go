@@ -2,6 +2,7 @@ package database
 
 import (
 	"errors"
+	"slices"
 
 	"github.com/Isaac-Fate/myst/internal/models"
 	"gorm.io/driver/sqlite"
@@ -10,14 +11,18 @@ import (
 
 func OpenSecretStore(path string) (*gorm.DB, error) {
 	// Open the database
-	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{})
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{
+		// Translate driver errors such as unique constraint violations into
+		// GORM's dialect-independent errors
+		TranslateError: true,
+	})
 
 	if err != nil {
 		return nil, err
 	}
 
 	// Migrate
-	err = db.AutoMigrate(&models.Secret{})
+	err = db.AutoMigrate(&models.Secret{}, &models.APIToken{})
 
 	if err != nil {
 		return nil, err
@@ -30,6 +35,10 @@ func AddSecret(db *gorm.DB, secret *models.Secret) error {
 	err := db.Create(secret).Error
 
 	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return models.ErrDuplicateKey
+		}
+
 		return err
 	}
 
@@ -48,7 +57,26 @@ func GetSecret(db *gorm.DB, id string) (*models.Secret, error) {
 
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("secret not found")
+			return nil, models.ErrSecretNotFound
+		}
+
+		return nil, err
+	}
+
+	return &secret, nil
+}
+
+// Gets a secret from the database by its exact key.
+//
+// If the secret does not exist or the retrieval fails, an error is returned.
+func GetSecretByKey(db *gorm.DB, key string) (*models.Secret, error) {
+	var secret models.Secret
+
+	err := db.Where("key = ?", key).First(&secret).Error
+
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, models.ErrSecretNotFound
 		}
 
 		return nil, err
@@ -57,6 +85,9 @@ func GetSecret(db *gorm.DB, id string) (*models.Secret, error) {
 	return &secret, nil
 }
 
+// Gets the secrets with the given IDs, in the same order as the IDs.
+//
+// Unknown IDs are ignored.
 func GetSecrets(db *gorm.DB, ids []string) ([]models.Secret, error) {
 	// Secrets to return
 	var secrets []models.Secret
@@ -68,5 +99,104 @@ func GetSecrets(db *gorm.DB, ids []string) ([]models.Secret, error) {
 		return nil, err
 	}
 
+	// Restore the order of the IDs, which the database does not keep
+	positions := make(map[string]int, len(ids))
+	for i, id := range ids {
+		positions[id] = i
+	}
+
+	slices.SortFunc(secrets, func(a, b models.Secret) int {
+		return positions[a.ID.String()] - positions[b.ID.String()]
+	})
+
+	return secrets, nil
+}
+
+// Lists all secrets in the database.
+func ListSecrets(db *gorm.DB) ([]models.Secret, error) {
+	var secrets []models.Secret
+
+	err := db.Find(&secrets).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return secrets, nil
+}
+
+// Saves all fields of an existing secret.
+func UpdateSecret(db *gorm.DB, secret *models.Secret) error {
+	err := db.Save(secret).Error
+
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return models.ErrDuplicateKey
+	}
+
+	return err
+}
+
+// Saves all fields of a secret without touching its timestamps.
+//
+// The secret is created if it does not exist yet. A secret in the trash is
+// overwritten too, and stays there only if the saved secret is deleted.
+func ImportSecret(db *gorm.DB, secret *models.Secret) error {
+	// UpdateColumns skips the automatic update time tracking
+	result := db.Unscoped().Model(secret).Select("*").UpdateColumns(secret)
+
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrDuplicatedKey) {
+			return models.ErrDuplicateKey
+		}
+
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return AddSecret(db, secret)
+	}
+
+	return nil
+}
+
+// Moves a secret to the trash by its ID.
+//
+// Secrets in the trash are left out of every other query, but keep their key.
+func RemoveSecret(db *gorm.DB, secret *models.Secret) error {
+	return db.Delete(secret).Error
+}
+
+// Lists the secrets in the trash, the most recently removed first.
+func ListTrash(db *gorm.DB) ([]models.Secret, error) {
+	var secrets []models.Secret
+
+	err := db.Unscoped().Where("deleted_at IS NOT NULL").Order("deleted_at DESC").Find(&secrets).Error
+
+	if err != nil {
+		return nil, err
+	}
+
 	return secrets, nil
 }
+
+// Takes a secret out of the trash.
+func RestoreSecret(db *gorm.DB, secret *models.Secret) error {
+	result := db.Unscoped().Model(secret).Where("deleted_at IS NOT NULL").Update("deleted_at", nil)
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return models.ErrSecretNotFound
+	}
+
+	secret.DeletedAt = gorm.DeletedAt{}
+
+	return nil
+}
+
+// Deletes a secret in the trash for good.
+func PurgeSecret(db *gorm.DB, secret *models.Secret) error {
+	return db.Unscoped().Where("deleted_at IS NOT NULL").Delete(secret).Error
+}
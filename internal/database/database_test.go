@@ -2,21 +2,17 @@ package database_test
 
 import (
 	"errors"
-	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/Isaac-Fate/myst/internal/database"
 	"github.com/Isaac-Fate/myst/internal/models"
 	"github.com/google/uuid"
-	"github.com/joho/godotenv"
 )
 
 func TestCrud(t *testing.T) {
 	// Get the test secret store path
-	secretStorePath, err := getTestSecretStorePath()
-	if err != nil {
-		t.Error(err)
-	}
+	secretStorePath := getTestSecretStorePath(t)
 
 	// Open the database
 	db, err := database.OpenSecretStore(secretStorePath)
@@ -39,19 +35,70 @@ func TestCrud(t *testing.T) {
 	}
 }
 
-func getTestSecretStorePath() (string, error) {
-	// Load .env file
-	err := godotenv.Load("../../.env")
+func TestUpdateAndRemove(t *testing.T) {
+	db, err := database.OpenSecretStore(getTestSecretStorePath(t))
 	if err != nil {
-		return "", err
+		t.Fatal(err)
 	}
 
-	// Get the test secret store database path
-	secretStorePath := os.Getenv("TEST_SECRET_STORE_PATH")
+	secret := models.Secret{
+		ID:             uuid.New(),
+		Key:            "test-secret",
+		EncryptedValue: "xxx",
+		Notes:          "this is a test secret",
+	}
 
-	if secretStorePath == "" {
-		return "", errors.New("TEST_SECRET_STORE_PATH is not set")
+	err = database.AddSecret(db, &secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Update the secret
+	secret.Notes = "updated notes"
+
+	err = database.UpdateSecret(db, &secret)
+	if err != nil {
+		t.Error(err)
+	}
+
+	found, err := database.GetSecret(db, secret.ID.String())
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	return secretStorePath, nil
+	if found.Notes != "updated notes" {
+		t.Errorf("expected updated notes, got %s", found.Notes)
+	}
+
+	// Remove the secret
+	err = database.RemoveSecret(db, &secret)
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, err = database.GetSecret(db, secret.ID.String())
+	if !errors.Is(err, models.ErrSecretNotFound) {
+		t.Errorf("expected ErrSecretNotFound, got %v", err)
+	}
+}
+
+func TestDuplicateKey(t *testing.T) {
+	db, err := database.OpenSecretStore(getTestSecretStorePath(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = database.AddSecret(db, &models.Secret{ID: uuid.New(), Key: "duplicate", EncryptedValue: "xxx"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = database.AddSecret(db, &models.Secret{ID: uuid.New(), Key: "duplicate", EncryptedValue: "yyy"})
+	if !errors.Is(err, models.ErrDuplicateKey) {
+		t.Errorf("expected ErrDuplicateKey, got %v", err)
+	}
+}
+
+func getTestSecretStorePath(t *testing.T) string {
+	return filepath.Join(t.TempDir(), "secret-store.db")
 }
@@ -0,0 +1,64 @@
+package database
+
+import (
+	"errors"
+
+	"github.com/Isaac-Fate/myst/internal/models"
+	"gorm.io/gorm"
+)
+
+// Adds a new API token.
+func AddToken(db *gorm.DB, token *models.APIToken) error {
+	err := db.Create(token).Error
+
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return models.ErrDuplicateTokenName
+	}
+
+	return err
+}
+
+// Lists all API tokens, oldest first.
+func ListTokens(db *gorm.DB) ([]models.APIToken, error) {
+	var tokens []models.APIToken
+
+	err := db.Order("created_at").Find(&tokens).Error
+
+	if err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// Gets an API token by the hash of the token.
+func GetTokenByHash(db *gorm.DB, hash string) (*models.APIToken, error) {
+	var token models.APIToken
+
+	err := db.Where("hash = ?", hash).First(&token).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, models.ErrTokenNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// Deletes an API token by its ID.
+func RemoveToken(db *gorm.DB, token *models.APIToken) error {
+	result := db.Delete(token)
+
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return models.ErrTokenNotFound
+	}
+
+	return nil
+}
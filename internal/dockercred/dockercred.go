@@ -0,0 +1,222 @@
+package dockercred
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Isaac-Fate/myst/internal/models"
+	"github.com/Isaac-Fate/myst/internal/session"
+)
+
+// Implements the protocol of docker credential helpers, see
+// https://github.com/docker/docker-credential-helpers, so that registry
+// passwords are kept in the vault rather than in ~/.docker/config.json.
+//
+// Credentials are kept as secrets in their own folder and with their own
+// tag, keyed by the registry, and are left out of normal listings.
+
+// The folder and tag of the secrets kept for docker.
+const (
+	Folder string = "docker"
+	Tag    string = "docker-credential"
+)
+
+// Returned when there are no credentials for a registry. Docker recognizes
+// this exact message.
+var ErrNotFound = errors.New("credentials not found in native keychain")
+
+// Credentials for a registry, as exchanged with docker.
+type Credentials struct {
+	ServerURL string
+	Username  string
+	Secret    string
+}
+
+// Runs an action of the protocol, reading its input from in and writing its
+// output to out.
+func Run(ctx context.Context, action string, in io.Reader, out io.Writer, s session.Session) error {
+	switch action {
+	case "store":
+		var credentials Credentials
+		if err := json.NewDecoder(in).Decode(&credentials); err != nil {
+			return fmt.Errorf("failed to read credentials: %w", err)
+		}
+
+		return Store(ctx, &credentials, s)
+	case "get":
+		serverURL, err := readServerURL(in)
+		if err != nil {
+			return err
+		}
+
+		credentials, err := Get(ctx, serverURL, s)
+		if err != nil {
+			return err
+		}
+
+		return json.NewEncoder(out).Encode(credentials)
+	case "erase":
+		serverURL, err := readServerURL(in)
+		if err != nil {
+			return err
+		}
+
+		return Erase(ctx, serverURL, s)
+	case "list":
+		list, err := List(ctx, s)
+		if err != nil {
+			return err
+		}
+
+		return json.NewEncoder(out).Encode(list)
+	default:
+		return fmt.Errorf("unknown credential action '%s'", action)
+	}
+}
+
+// Reads the server URL given on its own as the input.
+func readServerURL(in io.Reader) (string, error) {
+	content, err := io.ReadAll(in)
+	if err != nil {
+		return "", err
+	}
+
+	serverURL := strings.TrimSpace(string(content))
+	if serverURL == "" {
+		return "", errors.New("no credentials server URL")
+	}
+
+	return serverURL, nil
+}
+
+// Stores the credentials for a registry, replacing those kept before.
+func Store(ctx context.Context, credentials *Credentials, s session.Session) error {
+	if strings.TrimSpace(credentials.ServerURL) == "" {
+		return errors.New("no credentials server URL")
+	}
+	if credentials.Secret == "" {
+		return errors.New("no credentials secret")
+	}
+
+	secret := &models.Secret{
+		Key:      "docker/" + normalize(credentials.ServerURL),
+		Username: credentials.Username,
+		Website:  credentials.ServerURL,
+		Folder:   Folder,
+		Tags:     []string{Tag},
+	}
+
+	existing, err := find(ctx, credentials.ServerURL, s)
+	if err != nil {
+		return err
+	}
+
+	if existing != nil {
+		// Leave unchanged credentials alone
+		_, value, err := s.Get(ctx, existing.Key)
+		if err != nil || (value == credentials.Secret && existing.Username == credentials.Username) {
+			return err
+		}
+
+		// Keep the notes, folder and tags the user may have changed
+		updated := *existing
+		updated.Username = credentials.Username
+
+		return s.Update(ctx, existing.Key, &updated, credentials.Secret)
+	}
+
+	// Number the key if it is taken by an unrelated secret
+	key := secret.Key
+	for i := 2; ; i++ {
+		err := s.Add(ctx, secret, credentials.Secret)
+		if !errors.Is(err, models.ErrDuplicateKey) && !errors.Is(err, models.ErrKeyInTrash) {
+			return err
+		}
+
+		secret.Key = fmt.Sprintf("%s-%d", key, i)
+	}
+}
+
+// Gets the credentials for a registry, or ErrNotFound.
+func Get(ctx context.Context, serverURL string, s session.Session) (*Credentials, error) {
+	secret, err := find(ctx, serverURL, s)
+	if err != nil {
+		return nil, err
+	}
+	if secret == nil {
+		return nil, ErrNotFound
+	}
+
+	_, value, err := s.Get(ctx, secret.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Credentials{ServerURL: serverURL, Username: secret.Username, Secret: value}, nil
+}
+
+// Moves the credentials for a registry to the trash, or returns
+// ErrNotFound.
+func Erase(ctx context.Context, serverURL string, s session.Session) error {
+	secret, err := find(ctx, serverURL, s)
+	if err != nil {
+		return err
+	}
+	if secret == nil {
+		return ErrNotFound
+	}
+
+	return s.Remove(ctx, secret.Key)
+}
+
+// Lists the registries with credentials and their usernames.
+func List(ctx context.Context, s session.Session) (map[string]string, error) {
+	secrets, err := s.Find(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	list := map[string]string{}
+	for _, secret := range secrets {
+		if IsCredential(&secret) {
+			list[secret.Website] = secret.Username
+		}
+	}
+
+	return list, nil
+}
+
+// Reports whether the secret holds docker credentials.
+func IsCredential(secret *models.Secret) bool {
+	return secret.Folder == Folder && secret.HasTag(Tag) && secret.Website != ""
+}
+
+// Finds the credentials for a registry, or nil if there are none.
+func find(ctx context.Context, serverURL string, s session.Session) (*models.Secret, error) {
+	secrets, err := s.Find(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, secret := range secrets {
+		if IsCredential(&secret) && normalize(secret.Website) == normalize(serverURL) {
+			return &secret, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// Normalizes a server URL, so that "https://index.docker.io/v1/" and
+// "index.docker.io/v1" are the same registry.
+func normalize(serverURL string) string {
+	serverURL = strings.ToLower(strings.TrimSpace(serverURL))
+	serverURL = strings.TrimPrefix(serverURL, "https://")
+	serverURL = strings.TrimPrefix(serverURL, "http://")
+
+	return strings.TrimRight(serverURL, "/")
+}
@@ -0,0 +1,115 @@
+package dockercred_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Isaac-Fate/myst/internal/dockercred"
+	"github.com/Isaac-Fate/myst/internal/models"
+	"github.com/Isaac-Fate/myst/internal/session"
+	"github.com/Isaac-Fate/myst/internal/session/sessiontest"
+)
+
+// Runs an action with the input as docker would send it, returning the
+// output.
+func run(s session.Session, action string, input string) (string, error) {
+	var out strings.Builder
+	err := dockercred.Run(context.Background(), action, strings.NewReader(input), &out, s)
+
+	return out.String(), err
+}
+
+func TestStoreGetEraseList(t *testing.T) {
+	s := sessiontest.Open(t, "docker-credential")
+	ctx := context.Background()
+
+	if _, err := run(s, "store", `{"ServerURL":"https://index.docker.io/v1/","Username":"alice","Secret":"dckr_1"}`); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := run(s, "get", "https://index.docker.io/v1/\n")
+	if err != nil || output != `{"ServerURL":"https://index.docker.io/v1/","Username":"alice","Secret":"dckr_1"}`+"\n" {
+		t.Errorf("expected the stored credentials, got %q, %v", output, err)
+	}
+
+	secrets, err := s.Find(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secrets) != 1 || secrets[0].Key != "docker/index.docker.io/v1" || secrets[0].Folder != dockercred.Folder || !secrets[0].HasTag(dockercred.Tag) {
+		t.Fatalf("expected a secret in the docker folder, got %+v", secrets)
+	}
+
+	// Storing again replaces the credentials of the registry, keeping what
+	// the user changed
+	edited := secrets[0]
+	edited.Notes = "pull-only token"
+	edited.AddTag("ci")
+	if err := s.Update(ctx, edited.Key, &edited, "dckr_1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := run(s, "store", `{"ServerURL":"index.docker.io/v1","Username":"bob","Secret":"dckr_2"}`); err != nil {
+		t.Fatal(err)
+	}
+
+	if credentials, err := dockercred.Get(ctx, "https://index.docker.io/v1/", s); err != nil || credentials.Username != "bob" || credentials.Secret != "dckr_2" {
+		t.Errorf("expected the new credentials, got %+v, %v", credentials, err)
+	}
+
+	if stored, _, err := s.Get(ctx, edited.Key); err != nil || stored.Notes != "pull-only token" || !stored.HasTag("ci") || !stored.HasTag(dockercred.Tag) {
+		t.Errorf("expected the notes and tags to be kept, got %+v, %v", stored, err)
+	}
+
+	// Secrets of the user for the same website are not docker credentials
+	add := &models.Secret{Key: "ghcr", Username: "carol", Website: "ghcr.io"}
+	if err := s.Add(ctx, add, "ghp"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := run(s, "get", "ghcr.io"); !errors.Is(err, dockercred.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	if _, err := run(s, "store", `{"ServerURL":"ghcr.io","Username":"carol","Secret":"ghp_docker"}`); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err = run(s, "list", "")
+	if err != nil || output != `{"ghcr.io":"carol","https://index.docker.io/v1/":"bob"}`+"\n" {
+		t.Errorf("expected both registries, got %q, %v", output, err)
+	}
+
+	if _, err := run(s, "erase", "ghcr.io\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := run(s, "erase", "ghcr.io\n"); !errors.Is(err, dockercred.ErrNotFound) {
+		t.Errorf("expected ErrNotFound erasing twice, got %v", err)
+	}
+
+	if _, value, err := s.Get(ctx, "ghcr"); err != nil || value != "ghp" {
+		t.Errorf("expected the secret of the user to be kept, got %q, %v", value, err)
+	}
+}
+
+func TestInvalidInput(t *testing.T) {
+	s := sessiontest.Open(t, "docker-credential")
+
+	for _, test := range []struct {
+		action string
+		input  string
+	}{
+		{"store", "not json"},
+		{"store", `{"ServerURL":"","Secret":"x"}`},
+		{"store", `{"ServerURL":"ghcr.io","Secret":""}`},
+		{"get", "\n"},
+		{"version", ""},
+	} {
+		if _, err := run(s, test.action, test.input); err == nil {
+			t.Errorf("expected an error for %s with %q", test.action, test.input)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package fuzzy
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Fuzzy matching in the style of fzf: a pattern matches a text if all of its
+// characters appear in the text in the same order, ignoring case. "ghtk"
+// matches "github-token".
+//
+// Matches are scored so that the better ones can be shown first. Characters
+// matched next to each other, or at the start of a word, score higher than
+// characters scattered across the text.
+
+const (
+	scoreMatch       int = 16
+	bonusConsecutive int = 24
+	bonusWordStart   int = 20
+	bonusFirstChar   int = 8
+	penaltyGap       int = 1
+)
+
+// Scores the pattern against the text.
+//
+// It returns false if the pattern does not match. An empty pattern matches
+// every text with a score of 0.
+func Match(pattern string, text string) (int, bool) {
+	patternRunes := []rune(strings.ToLower(pattern))
+	if len(patternRunes) == 0 {
+		return 0, true
+	}
+
+	textRunes := []rune(text)
+	loweredRunes := []rune(strings.ToLower(text))
+
+	// Lowering can change the number of runes in rare cases
+	if len(loweredRunes) != len(textRunes) {
+		textRunes = loweredRunes
+	}
+
+	return bestAlignment(patternRunes, textRunes, loweredRunes)
+}
+
+// Scores a query of space-separated patterns against several fields.
+//
+// Every pattern must match at least one field, and the score is the sum of
+// the best score of each pattern.
+func MatchFields(query string, fields ...string) (int, bool) {
+	total := 0
+
+	for _, pattern := range strings.Fields(query) {
+		best, matched := 0, false
+
+		for _, field := range fields {
+			if score, ok := Match(pattern, field); ok && (!matched || score > best) {
+				best, matched = score, true
+			}
+		}
+
+		if !matched {
+			return 0, false
+		}
+
+		total += best
+	}
+
+	return total, true
+}
+
+// Finds the best scoring positions of the pattern in the text.
+//
+// scores[i] holds the best score of the pattern so far with its current
+// character matched at position i of the text. Each row is computed in a
+// single pass by keeping the best score to continue from with a gap.
+func bestAlignment(pattern []rune, text []rune, lowered []rune) (int, bool) {
+	const unmatched = -1 << 31
+
+	scores := make([]int, len(lowered))
+	next := make([]int, len(lowered))
+
+	for p, patternRune := range pattern {
+		// Best of scores[j] + penaltyGap*j over the positions j before i-1,
+		// so continuing from j scores it minus penaltyGap*(i-1)
+		bestGapped := unmatched
+
+		for i, textRune := range lowered {
+			next[i] = unmatched
+
+			if p > 0 && i >= 2 && scores[i-2] != unmatched {
+				bestGapped = max(bestGapped, scores[i-2]+penaltyGap*(i-2))
+			}
+
+			if textRune != patternRune {
+				continue
+			}
+
+			charScore := scoreMatch
+			if i == 0 {
+				charScore += bonusFirstChar
+			}
+			if isWordStart(text, i) {
+				charScore += bonusWordStart
+			}
+
+			if p == 0 {
+				next[i] = charScore
+				continue
+			}
+
+			// Continue from the best position of the previous character
+			if i >= 1 && scores[i-1] != unmatched {
+				next[i] = scores[i-1] + charScore + bonusConsecutive
+			}
+			if bestGapped != unmatched {
+				next[i] = max(next[i], bestGapped-penaltyGap*(i-1)+charScore)
+			}
+		}
+
+		scores, next = next, scores
+	}
+
+	best, matched := 0, false
+	for _, score := range scores {
+		if score != unmatched && (!matched || score > best) {
+			best, matched = score, true
+		}
+	}
+
+	return best, matched
+}
+
+// Reports whether a word starts at position i, such as after a separator or
+// at an upper case letter following a lower case one.
+func isWordStart(text []rune, i int) bool {
+	if i == 0 {
+		return true
+	}
+
+	current, previous := text[i], text[i-1]
+
+	if !unicode.IsLetter(previous) && !unicode.IsDigit(previous) {
+		return unicode.IsLetter(current) || unicode.IsDigit(current)
+	}
+
+	return unicode.IsUpper(current) && unicode.IsLower(previous)
+}
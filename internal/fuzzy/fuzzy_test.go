@@ -0,0 +1,80 @@
+package fuzzy_test
+
+import (
+	"testing"
+
+	"github.com/Isaac-Fate/myst/internal/fuzzy"
+)
+
+func TestMatch(t *testing.T) {
+	cases := []struct {
+		pattern string
+		text    string
+		matched bool
+	}{
+		{"", "anything", true},
+		{"ghtk", "github-token", true},
+		{"GitHub", "github-token", true},
+		{"tkn", "github-token", true},
+		{"kt", "github-token", false},
+		{"tokens", "github-token", false},
+		{"hg", "github", false},
+		{"über", "Über-Konto", true},
+	}
+
+	for _, c := range cases {
+		if _, matched := fuzzy.Match(c.pattern, c.text); matched != c.matched {
+			t.Errorf("%q in %q: expected %v, got %v", c.pattern, c.text, c.matched, matched)
+		}
+	}
+}
+
+func TestScore(t *testing.T) {
+	// Better matches score higher
+	better := []struct {
+		pattern string
+		better  string
+		worse   string
+	}{
+		// Consecutive characters
+		{"git", "github-token", "gallery-item-token"},
+		// Word starts
+		{"gt", "github-token", "gateway"},
+		// Camel case word starts
+		{"at", "awsToken", "abstract"},
+		// Earlier starts
+		{"token", "token-github", "github-token"},
+	}
+
+	for _, c := range better {
+		betterScore, ok := fuzzy.Match(c.pattern, c.better)
+		if !ok {
+			t.Fatalf("%q does not match %q", c.pattern, c.better)
+		}
+
+		worseScore, ok := fuzzy.Match(c.pattern, c.worse)
+		if !ok {
+			t.Fatalf("%q does not match %q", c.pattern, c.worse)
+		}
+
+		if betterScore <= worseScore {
+			t.Errorf("%q: expected %q (%d) to score higher than %q (%d)", c.pattern, c.better, betterScore, c.worse, worseScore)
+		}
+	}
+}
+
+func TestMatchFields(t *testing.T) {
+	fields := []string{"github-token", "github.com", "personal access token"}
+
+	if _, ok := fuzzy.MatchFields("gh access", fields...); !ok {
+		t.Error("expected every pattern to match some field")
+	}
+
+	if _, ok := fuzzy.MatchFields("gh gitlab", fields...); ok {
+		t.Error("expected no match when a pattern matches no field")
+	}
+
+	if score, ok := fuzzy.MatchFields("  ", fields...); !ok || score != 0 {
+		t.Errorf("expected a blank query to match with a score of 0, got %d, %v", score, ok)
+	}
+}
@@ -0,0 +1,381 @@
+package gitcred
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/Isaac-Fate/myst/internal/models"
+	"github.com/Isaac-Fate/myst/internal/session"
+)
+
+// Implements the git credential helper protocol, see git-credential(1), on
+// top of the secrets in a vault.
+//
+// Git asks for a credential by protocol, host and optionally path and
+// username. It is matched against the websites of the secrets, which may be
+// a bare host such as "github.com", a host and path such as
+// "github.com/org", or a URL such as "https://github.com/org". A website
+// without a protocol is taken to be https.
+//
+// Any matching secret is given to git, but git only stores and erases
+// credentials in the git folder, so that other secrets for the same website,
+// such as the password of a web login, are never replaced or removed.
+
+// The folder credentials stored by git are added to.
+const Folder string = "git"
+
+// A credential as exchanged with git.
+type Credential struct {
+	Protocol string
+	Host     string
+	Path     string
+	Username string
+	Password string
+}
+
+// Reads a credential from lines of "key=value", up to a blank line or the
+// end of the input. Attributes other than those of a Credential are ignored.
+func Read(r io.Reader) (*Credential, error) {
+	credential := &Credential{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if line == "" {
+			break
+		}
+
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid line '%s', expected key=value", line)
+		}
+
+		switch key {
+		case "protocol":
+			credential.Protocol = value
+		case "host":
+			credential.Host = value
+		case "path":
+			credential.Path = value
+		case "username":
+			credential.Username = value
+		case "password":
+			credential.Password = value
+		case "url":
+			if err := credential.setURL(value); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return credential, nil
+}
+
+// Sets the fields given by a URL, as git does for the "url" attribute.
+func (credential *Credential) setURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid url '%s': %w", rawURL, err)
+	}
+
+	credential.Protocol = u.Scheme
+	credential.Host = u.Host
+	credential.Path = strings.TrimPrefix(u.Path, "/")
+
+	if u.User != nil {
+		credential.Username = u.User.Username()
+		credential.Password, _ = u.User.Password()
+	}
+
+	return nil
+}
+
+// Writes the non-empty fields of the credential as lines of "key=value".
+func (credential *Credential) Write(w io.Writer) error {
+	for _, field := range []struct {
+		key   string
+		value string
+	}{
+		{"protocol", credential.Protocol},
+		{"host", credential.Host},
+		{"path", credential.Path},
+		{"username", credential.Username},
+		{"password", credential.Password},
+	} {
+		if field.value == "" {
+			continue
+		}
+
+		if strings.ContainsAny(field.value, "\n\x00") {
+			return fmt.Errorf("the %s contains a newline or NUL", field.key)
+		}
+
+		if _, err := fmt.Fprintf(w, "%s=%s\n", field.key, field.value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Returns the website a credential is stored with.
+func (credential *Credential) website() string {
+	website := credential.Host
+	if credential.Protocol != "" {
+		website = credential.Protocol + "://" + website
+	}
+	if credential.Path != "" {
+		website += "/" + credential.Path
+	}
+
+	return website
+}
+
+// Returns the key a credential is stored under, such as
+// "alice@github.com".
+func (credential *Credential) key() string {
+	key := credential.Host
+	if credential.Username != "" {
+		key = credential.Username + "@" + key
+	}
+	if credential.Path != "" {
+		key += "/" + credential.Path
+	}
+
+	return key
+}
+
+// Runs an action of the protocol, reading the credential from in and
+// writing the answer, if any, to out. Unknown actions are ignored, as git
+// asks of helpers.
+func Run(ctx context.Context, action string, in io.Reader, out io.Writer, s session.Session) error {
+	credential, err := Read(in)
+	if err != nil {
+		return err
+	}
+
+	switch action {
+	case "get":
+		return Get(ctx, credential, out, s)
+	case "store":
+		return Store(ctx, credential, s)
+	case "erase":
+		return Erase(ctx, credential, s)
+	default:
+		return nil
+	}
+}
+
+// Writes the username and password of the secret best matching the
+// credential. Nothing is written if there is none, so git moves on to the
+// next helper or prompts.
+func Get(ctx context.Context, credential *Credential, out io.Writer, s session.Session) error {
+	matches, err := find(ctx, credential, false, s)
+	if err != nil || len(matches) == 0 {
+		return err
+	}
+
+	secret := matches[0]
+	_, password, err := s.Get(ctx, secret.Key)
+	if err != nil {
+		return err
+	}
+
+	answer := &Credential{Username: secret.Username, Password: password}
+	if answer.Username == "" {
+		answer.Username = credential.Username
+	}
+
+	return answer.Write(out)
+}
+
+// Stores a credential git found to work. The value of a secret in the git
+// folder for the same website and username is replaced if it changed;
+// otherwise a secret is added to the git folder.
+func Store(ctx context.Context, credential *Credential, s session.Session) error {
+	if credential.Host == "" || credential.Password == "" {
+		return nil
+	}
+
+	matches, err := find(ctx, credential, true, s)
+	if err != nil {
+		return err
+	}
+	matches = slices.DeleteFunc(matches, notInFolder)
+
+	if len(matches) > 0 {
+		// Leave an unchanged value alone, so that its age still tells when
+		// it was last rotated
+		_, value, err := s.Get(ctx, matches[0].Key)
+		if err != nil || value == credential.Password {
+			return err
+		}
+
+		return s.Update(ctx, matches[0].Key, &matches[0], credential.Password)
+	}
+
+	secret := &models.Secret{
+		Key:      credential.key(),
+		Username: credential.Username,
+		Website:  credential.website(),
+		Folder:   Folder,
+	}
+
+	// Number the key if it is taken by an unrelated secret
+	for i := 2; ; i++ {
+		err := s.Add(ctx, secret, credential.Password)
+		if !errors.Is(err, models.ErrDuplicateKey) && !errors.Is(err, models.ErrKeyInTrash) {
+			return err
+		}
+
+		secret.Key = fmt.Sprintf("%s-%d", credential.key(), i)
+	}
+}
+
+// Moves the secrets in the git folder matching a credential git found not
+// to work to the trash. If git gives the password, only secrets with that
+// value are removed, so that a value changed since is kept.
+func Erase(ctx context.Context, credential *Credential, s session.Session) error {
+	if credential.Host == "" {
+		return nil
+	}
+
+	matches, err := find(ctx, credential, false, s)
+	if err != nil {
+		return err
+	}
+	matches = slices.DeleteFunc(matches, notInFolder)
+
+	for _, secret := range matches {
+		if credential.Password != "" {
+			_, value, err := s.Get(ctx, secret.Key)
+			if err != nil {
+				return err
+			}
+			if value != credential.Password {
+				continue
+			}
+		}
+
+		if err := s.Remove(ctx, secret.Key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Finds the secrets matching the credential, the most specific first. If
+// exact is set, the website must have the same path as the credential.
+func find(ctx context.Context, credential *Credential, exact bool, s session.Session) ([]models.Secret, error) {
+	if credential.Host == "" {
+		return nil, nil
+	}
+
+	secrets, err := s.Find(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+
+	type match struct {
+		secret     models.Secret
+		pathLength int
+	}
+
+	var matches []match
+	for _, secret := range secrets {
+		website, ok := parseWebsite(secret.Website)
+		if !ok || !website.matches(credential, exact) {
+			continue
+		}
+		if credential.Username != "" && secret.Username != credential.Username {
+			continue
+		}
+
+		matches = append(matches, match{secret: secret, pathLength: len(website.Path)})
+	}
+
+	// Prefer the longest path matching the path git gives, or the shortest
+	// if it gives none, keeping the order by key otherwise
+	slices.SortStableFunc(matches, func(a, b match) int {
+		if credential.Path == "" {
+			return a.pathLength - b.pathLength
+		}
+		return b.pathLength - a.pathLength
+	})
+
+	result := make([]models.Secret, len(matches))
+	for i, match := range matches {
+		result[i] = match.secret
+	}
+
+	return result, nil
+}
+
+// Reports whether a secret is outside the git folder, and so not git's to
+// replace or remove.
+func notInFolder(secret models.Secret) bool {
+	return secret.Folder != Folder && !strings.HasPrefix(secret.Folder, Folder+"/")
+}
+
+// Parses the website of a secret into a credential without a username or
+// password, reporting whether there is a host.
+func parseWebsite(website string) (*Credential, bool) {
+	website = strings.TrimSpace(website)
+	if website == "" {
+		return nil, false
+	}
+
+	if strings.Contains(website, "://") {
+		credential := &Credential{}
+		if err := credential.setURL(website); err != nil {
+			return nil, false
+		}
+		credential.Username, credential.Password = "", ""
+		credential.Path = strings.TrimSuffix(credential.Path, "/")
+
+		return credential, credential.Host != ""
+	}
+
+	host, path, _ := strings.Cut(website, "/")
+	return &Credential{Host: host, Path: strings.TrimSuffix(path, "/")}, host != ""
+}
+
+// Reports whether a website, parsed into a credential, applies to the
+// credential git asks about. A website without a protocol applies to https
+// only, so that a password is never sent in the clear unless the website
+// says so, and one with a path applies to the paths under it.
+func (website *Credential) matches(credential *Credential, exact bool) bool {
+	if !strings.EqualFold(website.Host, credential.Host) {
+		return false
+	}
+
+	protocol := website.Protocol
+	if protocol == "" {
+		protocol = "https"
+	}
+	if protocol != credential.Protocol {
+		return false
+	}
+
+	if exact {
+		return website.Path == credential.Path
+	}
+
+	// Git only sends the path if asked to with credential.useHttpPath
+	if website.Path == "" || credential.Path == "" {
+		return true
+	}
+
+	return credential.Path == website.Path || strings.HasPrefix(credential.Path, website.Path+"/")
+}
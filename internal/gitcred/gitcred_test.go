@@ -0,0 +1,164 @@
+package gitcred_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Isaac-Fate/myst/internal/gitcred"
+	"github.com/Isaac-Fate/myst/internal/models"
+	"github.com/Isaac-Fate/myst/internal/session"
+	"github.com/Isaac-Fate/myst/internal/session/sessiontest"
+)
+
+// Runs an action with the input as git would send it, returning the output.
+func run(t *testing.T, s session.Session, action string, input string) string {
+	var out strings.Builder
+	if err := gitcred.Run(context.Background(), action, strings.NewReader(input), &out, s); err != nil {
+		t.Fatal(err)
+	}
+
+	return out.String()
+}
+
+func add(t *testing.T, s session.Session, key string, username string, website string, value string) {
+	addToFolder(t, s, key, username, website, "", value)
+}
+
+func addToFolder(t *testing.T, s session.Session, key string, username string, website string, folder string, value string) {
+	secret := &models.Secret{Key: key, Username: username, Website: website, Folder: folder}
+	if err := s.Add(context.Background(), secret, value); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRead(t *testing.T) {
+	input := "protocol=https\r\nhost=github.com\r\nwwwauth[]=Basic\r\n\r\nusername=ignored\r\n"
+
+	credential, err := gitcred.Read(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *credential != (gitcred.Credential{Protocol: "https", Host: "github.com"}) {
+		t.Errorf("expected the attributes up to the blank line, got %+v", credential)
+	}
+
+	credential, err = gitcred.Read(strings.NewReader("url=https://alice@example.com:8443/org/repo.git\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *credential != (gitcred.Credential{Protocol: "https", Host: "example.com:8443", Path: "org/repo.git", Username: "alice"}) {
+		t.Errorf("expected the fields of the url, got %+v", credential)
+	}
+
+	if _, err := gitcred.Read(strings.NewReader("protocol\n")); err == nil {
+		t.Error("expected an error for a line without '='")
+	}
+}
+
+func TestGet(t *testing.T) {
+	s := sessiontest.Open(t, "git-credential")
+	add(t, s, "github", "alice", "github.com", "ghp_alice")
+	add(t, s, "github-work", "alice-work", "https://github.com/work", "ghp_work")
+	add(t, s, "gitlab", "bob", "http://gitlab.example.com", "glpat")
+
+	for _, test := range []struct {
+		input  string
+		output string
+	}{
+		{"protocol=https\nhost=github.com\n", "username=alice\npassword=ghp_alice\n"},
+		{"protocol=https\nhost=GitHub.com\npath=work/repo.git\n", "username=alice-work\npassword=ghp_work\n"},
+		{"protocol=https\nhost=github.com\npath=other/repo.git\n", "username=alice\npassword=ghp_alice\n"},
+		{"protocol=https\nhost=github.com\nusername=alice-work\n", "username=alice-work\npassword=ghp_work\n"},
+		{"protocol=https\nhost=github.com\nusername=carol\n", ""},
+		// The protocol of a URL must match
+		{"protocol=https\nhost=gitlab.example.com\n", ""},
+		{"protocol=http\nhost=gitlab.example.com\n", "username=bob\npassword=glpat\n"},
+		{"protocol=https\nhost=example.com\n", ""},
+		// A website without a protocol is not sent over http
+		{"protocol=http\nhost=github.com\n", ""},
+	} {
+		if output := run(t, s, "get", test.input); output != test.output {
+			t.Errorf("expected %q for %q, got %q", test.output, test.input, output)
+		}
+	}
+}
+
+func TestStore(t *testing.T) {
+	s := sessiontest.Open(t, "git-credential")
+	ctx := context.Background()
+
+	run(t, s, "store", "protocol=https\nhost=github.com\nusername=alice\npassword=ghp_1\n")
+
+	secrets, err := s.Find(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secrets) != 1 || secrets[0].Key != "alice@github.com" || secrets[0].Website != "https://github.com" || secrets[0].Folder != gitcred.Folder {
+		t.Fatalf("expected a secret for the credential, got %+v", secrets)
+	}
+
+	if output := run(t, s, "get", "protocol=https\nhost=github.com\n"); output != "username=alice\npassword=ghp_1\n" {
+		t.Errorf("expected the stored credential, got %q", output)
+	}
+
+	// Storing a new password replaces the value
+	run(t, s, "store", "protocol=https\nhost=github.com\nusername=alice\npassword=ghp_2\n")
+
+	if _, value, err := s.Get(ctx, "alice@github.com"); err != nil || value != "ghp_2" {
+		t.Errorf("expected the new password, got %q, %v", value, err)
+	}
+
+	// A taken key is numbered
+	add(t, s, "bob@example.com", "", "", "unrelated")
+	run(t, s, "store", "protocol=https\nhost=example.com\nusername=bob\npassword=x\n")
+
+	if _, value, err := s.Get(ctx, "bob@example.com-2"); err != nil || value != "x" {
+		t.Errorf("expected a numbered key, got %q, %v", value, err)
+	}
+
+	// A secret outside the git folder, such as a web login, is left alone
+	add(t, s, "gitea-login", "carol", "https://gitea.example.com", "web password")
+	run(t, s, "store", "protocol=https\nhost=gitea.example.com\nusername=carol\npassword=token\n")
+
+	if _, value, err := s.Get(ctx, "gitea-login"); err != nil || value != "web password" {
+		t.Errorf("expected the web login to be kept, got %q, %v", value, err)
+	}
+	if _, value, err := s.Get(ctx, "carol@gitea.example.com"); err != nil || value != "token" {
+		t.Errorf("expected a secret in the git folder, got %q, %v", value, err)
+	}
+
+	// Unknown actions are ignored
+	if output := run(t, s, "capability", "protocol=https\n"); output != "" {
+		t.Errorf("expected no output for an unknown action, got %q", output)
+	}
+}
+
+func TestErase(t *testing.T) {
+	s := sessiontest.Open(t, "git-credential")
+	ctx := context.Background()
+	addToFolder(t, s, "github", "alice", "github.com", gitcred.Folder, "ghp_new")
+	add(t, s, "github-login", "alice", "https://github.com", "web password")
+
+	// A password changed since is kept
+	run(t, s, "erase", "protocol=https\nhost=github.com\nusername=alice\npassword=ghp_old\n")
+
+	if _, _, err := s.Get(ctx, "github"); err != nil {
+		t.Errorf("expected the secret to be kept, got %v", err)
+	}
+
+	run(t, s, "erase", "protocol=https\nhost=github.com\nusername=alice\npassword=ghp_new\n")
+
+	if _, _, err := s.Get(ctx, "github"); !errors.Is(err, models.ErrSecretNotFound) {
+		t.Errorf("expected the secret to be removed, got %v", err)
+	}
+
+	// A secret outside the git folder is never removed, even when git gives
+	// its password
+	run(t, s, "erase", "protocol=https\nhost=github.com\nusername=alice\npassword=web password\n")
+
+	if _, _, err := s.Get(ctx, "github-login"); err != nil {
+		t.Errorf("expected the web login to be kept, got %v", err)
+	}
+}
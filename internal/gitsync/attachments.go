@@ -0,0 +1,174 @@
+package gitsync
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/Isaac-Fate/myst/internal/models"
+)
+
+// The contents of attachments are kept next to the secret files, in
+// attachments/<attachment ID>, as they are stored locally: already
+// encrypted, under data keys only the secrets hold. The contents of an
+// attachment never change, so a file only needs copying when it is missing,
+// and is deleted once no version of its secret has it anymore.
+
+const attachmentsDirName = "attachments"
+
+// Sets the directory holding the contents of local attachments, so that they
+// are synced along with their secrets. Without it, only the details of the
+// attachments are synced.
+func (syncer *Syncer) SetAttachmentsDir(dir string) {
+	syncer.attachmentsDir = dir
+}
+
+// Copies the contents of the attachments of the local version of a secret
+// into the working copy, and deletes those only the version being replaced
+// had. The secret is nil if it was removed.
+func (syncer *Syncer) pushAttachments(id string, secret *models.Secret) error {
+	if syncer.attachmentsDir == "" {
+		return nil
+	}
+
+	previous, err := syncer.previousAttachments(id)
+	if err != nil {
+		return err
+	}
+
+	var current []models.Attachment
+	if secret != nil {
+		current = secret.Attachments
+	}
+
+	for _, attachment := range current {
+		path := syncer.repoAttachmentPath(attachment.ID)
+		if _, err := os.Stat(path); err == nil {
+			continue
+		}
+
+		if err := copyFile(filepath.Join(syncer.attachmentsDir, attachment.ID), path); err != nil {
+			return fmt.Errorf("failed to copy attachment '%s' of secret '%s': %w", attachment.Name, secret.Key, err)
+		}
+	}
+
+	return removeAttachments(filepath.Join(syncer.repo.dir, attachmentsDirName), previous, current)
+}
+
+// Copies the contents of the attachments of the remote version of a secret
+// into the local directory, reporting any missing from the working copy.
+func (syncer *Syncer) pullAttachments(remote *models.Secret, report *Report) error {
+	if syncer.attachmentsDir == "" {
+		return nil
+	}
+
+	for _, attachment := range remote.Attachments {
+		path := filepath.Join(syncer.attachmentsDir, attachment.ID)
+		if _, err := os.Stat(path); err == nil {
+			continue
+		}
+
+		err := copyFile(syncer.repoAttachmentPath(attachment.ID), path)
+		if errors.Is(err, os.ErrNotExist) {
+			report.Skipped = append(
+				report.Skipped,
+				fmt.Sprintf("attachment '%s' of remote secret '%s' is missing from the remote", attachment.Name, remote.Key),
+			)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to copy attachment '%s' of secret '%s': %w", attachment.Name, remote.Key, err)
+		}
+	}
+
+	return nil
+}
+
+// Deletes the contents of the local attachments the remote version of a
+// secret, now applied, no longer has.
+func (syncer *Syncer) prunePulledAttachments(local *models.Secret, remote *models.Secret) error {
+	if syncer.attachmentsDir == "" || local == nil {
+		return nil
+	}
+
+	return removeAttachments(syncer.attachmentsDir, local.Attachments, remote.Attachments)
+}
+
+// Returns the attachments of the version of a secret in the working copy.
+func (syncer *Syncer) previousAttachments(id string) ([]models.Attachment, error) {
+	content, err := os.ReadFile(secretFilePath(syncer.repo.dir, id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var file secretFile
+	if err := json.Unmarshal(content, &file); err != nil {
+		return nil, errors.New("invalid secret file " + id)
+	}
+
+	secret, err := file.decrypt(syncer.passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	return secret.Attachments, nil
+}
+
+func (syncer *Syncer) repoAttachmentPath(id string) string {
+	return filepath.Join(syncer.repo.dir, attachmentsDirName, id)
+}
+
+// Deletes the contents of the previous attachments that are not current.
+func removeAttachments(dir string, previous []models.Attachment, current []models.Attachment) error {
+	kept := make(map[string]bool, len(current))
+	for _, attachment := range current {
+		kept[attachment.ID] = true
+	}
+
+	for _, attachment := range previous {
+		if kept[attachment.ID] {
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(dir, attachment.ID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Copies a file through a temporary file, so dst is only there once whole.
+func copyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+		return err
+	}
+
+	out, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name())
+
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(out.Name(), dst)
+}
@@ -0,0 +1,113 @@
+package gitsync
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	mycrypto "github.com/Isaac-Fate/myst/internal/crypto"
+	"github.com/Isaac-Fate/myst/internal/models"
+)
+
+// Every secret is kept in its own file, secrets/<id>.json:
+//
+//	{
+//	  "id": "<secret ID>",
+//	  "updated_at": "<RFC 3339 time of the last change>",
+//	  "data": "<encrypted JSON of the secret>"
+//	}
+//
+// Only the ID and the update time are readable without the passphrase. They
+// are enough to tell which files changed without decrypting every one of
+// them. A file is only rewritten when its secret changed, so commits and
+// merges touch exactly the secrets that were edited.
+
+const secretsDirName = "secrets"
+const secretFileExt = ".json"
+
+// A secret as stored in the sync repository.
+type secretFile struct {
+	ID        string    `json:"id"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Data      string    `json:"data"`
+}
+
+// Encrypts a secret into a secret file.
+func encodeSecretFile(passphrase string, secret *models.Secret) ([]byte, error) {
+	plaintext, err := json.Marshal(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := mycrypto.Encrypt(passphrase, string(plaintext))
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := json.MarshalIndent(secretFile{
+		ID:        secret.ID.String(),
+		UpdatedAt: secret.UpdatedAt.UTC(),
+		Data:      data,
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append(content, '\n'), nil
+}
+
+// Decrypts the secret held by a secret file.
+func (file *secretFile) decrypt(passphrase string) (*models.Secret, error) {
+	plaintext, err := mycrypto.Decrypt(passphrase, file.Data)
+	if err != nil {
+		return nil, errors.New("failed to decrypt secret file " + file.ID + ": wrong passphrase?")
+	}
+
+	var secret models.Secret
+	if err := json.Unmarshal([]byte(plaintext), &secret); err != nil {
+		return nil, err
+	}
+
+	return &secret, nil
+}
+
+// Reads all secret files in the working copy, keyed by secret ID.
+func readSecretFiles(dir string) (map[string]*secretFile, error) {
+	files := make(map[string]*secretFile)
+
+	entries, err := os.ReadDir(filepath.Join(dir, secretsDirName))
+	if errors.Is(err, os.ErrNotExist) {
+		return files, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), secretFileExt) {
+			continue
+		}
+
+		content, err := os.ReadFile(filepath.Join(dir, secretsDirName, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		var file secretFile
+		if err := json.Unmarshal(content, &file); err != nil {
+			return nil, errors.New("invalid secret file " + entry.Name())
+		}
+
+		files[file.ID] = &file
+	}
+
+	return files, nil
+}
+
+// Returns the path of the file holding the secret with the given ID.
+func secretFilePath(dir string, id string) string {
+	return filepath.Join(dir, secretsDirName, id+secretFileExt)
+}
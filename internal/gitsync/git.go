@@ -0,0 +1,42 @@
+package gitsync
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Runs git commands in a working copy.
+type gitRepo struct {
+	dir string
+}
+
+// Runs a git command and returns its trimmed standard output.
+//
+// On failure, the error includes what git printed to standard error.
+func (repo *gitRepo) run(args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = repo.dir
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		message := strings.TrimSpace(stderr.String())
+		if message == "" {
+			message = err.Error()
+		}
+
+		return "", fmt.Errorf("git %s: %s", args[0], message)
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// Reports whether the revision exists.
+func (repo *gitRepo) hasRevision(revision string) bool {
+	_, err := repo.run("rev-parse", "--verify", "--quiet", revision+"^{commit}")
+	return err == nil
+}
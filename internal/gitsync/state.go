@@ -0,0 +1,72 @@
+package gitsync
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"time"
+
+	"github.com/Isaac-Fate/myst/internal/vaultfile"
+)
+
+// The update time of every secret as of the last sync.
+//
+// It is the common ancestor of the local store and the remote repository.
+// A secret whose update time differs from the recorded one has been edited
+// since the last sync on that side.
+type syncState struct {
+	Secrets map[string]time.Time `json:"secrets"`
+}
+
+// Loads the sync state, starting empty if it was never saved.
+func loadState(path string) (*syncState, error) {
+	state := &syncState{Secrets: make(map[string]time.Time)}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(content, state); err != nil {
+		return nil, err
+	}
+
+	if state.Secrets == nil {
+		state.Secrets = make(map[string]time.Time)
+	}
+
+	return state, nil
+}
+
+func (state *syncState) save(path string) error {
+	content, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return vaultfile.WriteFileAtomic(path, content, 0600)
+}
+
+// Reports whether a secret changed since the last sync given whether it
+// currently exists and its current update time.
+func (state *syncState) changed(id string, exists bool, updatedAt time.Time) bool {
+	base, synced := state.Secrets[id]
+
+	if exists != synced {
+		return true
+	}
+
+	return exists && !updatedAt.Equal(base)
+}
+
+// Records the current state of a secret as synced.
+func (state *syncState) record(id string, exists bool, updatedAt time.Time) {
+	if exists {
+		state.Secrets[id] = updatedAt
+	} else {
+		delete(state.Secrets, id)
+	}
+}
@@ -0,0 +1,510 @@
+package gitsync
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/Isaac-Fate/myst/internal/manager"
+	"github.com/Isaac-Fate/myst/internal/models"
+)
+
+// The branch holding the vault in the remote repository.
+const branch string = "main"
+
+// Returned when syncing before the working copy was initialized.
+var ErrNotInitialized = errors.New("sync is not initialized, run 'myst sync init <remote>' first")
+
+// Returned when initializing a working copy that already exists.
+var ErrAlreadyInitialized = errors.New("sync is already initialized")
+
+// Returned when a conflict is found and there is no resolver.
+var ErrConflict = errors.New("secret was changed both locally and remotely")
+
+// A secret that was changed both locally and remotely since the last sync.
+type Conflict struct {
+	// The local version, nil if the secret was removed locally
+	Local *models.Secret
+
+	// The remote version, nil if the secret was removed remotely
+	Remote *models.Secret
+}
+
+// How to resolve a conflict.
+type Resolution int
+
+const (
+	// Keep the local version and push it on the next push
+	KeepLocal Resolution = iota
+
+	// Replace the local version with the remote one
+	KeepRemote
+)
+
+// Decides how to resolve a conflict, usually by asking the user.
+type ConflictResolver func(conflict *Conflict) (Resolution, error)
+
+// What a pull or push did.
+type Report struct {
+	// Number of secrets added, updated or removed locally
+	Pulled int
+
+	// Number of secrets written to or removed from the remote repository
+	Pushed int
+
+	// Number of conflicts that were resolved
+	Conflicts int
+
+	// Remote secrets that could not be applied locally
+	Skipped []string
+}
+
+// Syncs the secrets of a secret manager with a remote git repository.
+type Syncer struct {
+	repo       *gitRepo
+	statePath  string
+	passphrase string
+	manager    *manager.SecretManager
+	resolve    ConflictResolver
+
+	// Optional, the contents of local attachments
+	attachmentsDir string
+}
+
+// Creates a syncer that keeps its git working copy in dir and the state of
+// the last sync at statePath.
+//
+// Secret files are encrypted with the passphrase, so every device syncing
+// the same repository must use the same master passphrase. Conflicts are
+// passed to resolve; if it is nil, syncing stops at the first conflict.
+func New(
+	dir string,
+	statePath string,
+	passphrase string,
+	secretManager *manager.SecretManager,
+	resolve ConflictResolver,
+) *Syncer {
+	return &Syncer{
+		repo:       &gitRepo{dir: dir},
+		statePath:  statePath,
+		passphrase: passphrase,
+		manager:    secretManager,
+		resolve:    resolve,
+	}
+}
+
+// Reports whether the working copy has been initialized.
+func (syncer *Syncer) IsInitialized() bool {
+	_, err := os.Stat(filepath.Join(syncer.repo.dir, ".git"))
+	return err == nil
+}
+
+// Initializes the working copy with the given remote.
+//
+// Secrets already in the remote repository are not applied until the next
+// pull or push.
+func (syncer *Syncer) Init(remote string) error {
+	if syncer.IsInitialized() {
+		return ErrAlreadyInitialized
+	}
+
+	if err := os.MkdirAll(syncer.repo.dir, 0700); err != nil {
+		return err
+	}
+
+	if _, err := syncer.repo.run("init", "--quiet", "--initial-branch", branch); err != nil {
+		return err
+	}
+
+	if _, err := syncer.repo.run("remote", "add", "origin", remote); err != nil {
+		return err
+	}
+
+	// Commits need an identity, so set a local one if git has none
+	if email, _ := syncer.repo.run("config", "user.email"); email == "" {
+		hostname, _ := os.Hostname()
+
+		if _, err := syncer.repo.run("config", "user.name", "myst"); err != nil {
+			return err
+		}
+
+		if _, err := syncer.repo.run("config", "user.email", "myst@"+hostname); err != nil {
+			return err
+		}
+	}
+
+	// A new remote means nothing has been synced yet
+	if err := os.Remove(syncer.statePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return syncer.fetch()
+}
+
+// Applies the changes in the remote repository to the local secrets.
+func (syncer *Syncer) Pull() (*Report, error) {
+	if !syncer.IsInitialized() {
+		return nil, ErrNotInitialized
+	}
+
+	state, err := loadState(syncer.statePath)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{}
+
+	if err := syncer.fetch(); err != nil {
+		return nil, err
+	}
+
+	// Save whatever was merged, even if the merge stopped half way
+	mergeErr := syncer.merge(state, report)
+
+	if err := state.save(syncer.statePath); err != nil {
+		return nil, err
+	}
+
+	if mergeErr != nil {
+		return nil, mergeErr
+	}
+
+	return report, nil
+}
+
+// Pulls and then pushes the local changes to the remote repository.
+func (syncer *Syncer) Push() (*Report, error) {
+	report, err := syncer.Pull()
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := loadState(syncer.statePath)
+	if err != nil {
+		return nil, err
+	}
+
+	localSecrets, err := syncer.localSecrets()
+	if err != nil {
+		return nil, err
+	}
+
+	// Write the secrets changed locally since the last sync
+	var pending []string
+
+	ids := make(map[string]bool)
+	addIds(ids, localSecrets)
+	addIds(ids, state.Secrets)
+
+	for _, id := range sortedIds(ids) {
+		local, inLocal := localSecrets[id]
+
+		if !state.changed(id, inLocal, updatedAt(local)) {
+			continue
+		}
+
+		if err := syncer.writeSecret(id, local); err != nil {
+			return nil, err
+		}
+
+		pending = append(pending, id)
+	}
+
+	if len(pending) == 0 {
+		return report, nil
+	}
+
+	// Commit and push
+	if _, err := syncer.repo.run("add", "--all"); err != nil {
+		return nil, err
+	}
+
+	hostname, _ := os.Hostname()
+	message := fmt.Sprintf("Sync %d secret(s) from %s", len(pending), hostname)
+
+	if _, err := syncer.repo.run("commit", "--quiet", "--message", message); err != nil {
+		return nil, err
+	}
+
+	if _, err := syncer.repo.run("push", "--quiet", "origin", "HEAD:refs/heads/"+branch); err != nil {
+		return nil, fmt.Errorf("%w (the remote may have changed, pull and try again)", err)
+	}
+
+	// The pushed secrets are now in sync
+	for _, id := range pending {
+		local, inLocal := localSecrets[id]
+		state.record(id, inLocal, updatedAt(local))
+	}
+
+	if err := state.save(syncer.statePath); err != nil {
+		return nil, err
+	}
+
+	report.Pushed = len(pending)
+
+	return report, nil
+}
+
+// Resets the working copy to the remote branch.
+func (syncer *Syncer) fetch() error {
+	if _, err := syncer.repo.run("fetch", "--quiet", "origin"); err != nil {
+		return err
+	}
+
+	remoteBranch := "origin/" + branch
+
+	if syncer.repo.hasRevision(remoteBranch) {
+		if _, err := syncer.repo.run("reset", "--quiet", "--hard", remoteBranch); err != nil {
+			return err
+		}
+	} else {
+		// The remote is still empty
+		for _, name := range []string{secretsDirName, attachmentsDirName} {
+			if err := os.RemoveAll(filepath.Join(syncer.repo.dir, name)); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Drop files left over by a push that failed
+	_, err := syncer.repo.run("clean", "--quiet", "--force", "-d")
+	return err
+}
+
+// Merges the secret files in the working copy into the local secrets.
+func (syncer *Syncer) merge(state *syncState, report *Report) error {
+	files, err := readSecretFiles(syncer.repo.dir)
+	if err != nil {
+		return err
+	}
+
+	localSecrets, err := syncer.localSecrets()
+	if err != nil {
+		return err
+	}
+
+	ids := make(map[string]bool)
+	addIds(ids, localSecrets)
+	addIds(ids, files)
+	addIds(ids, state.Secrets)
+
+	for _, id := range sortedIds(ids) {
+		local, inLocal := localSecrets[id]
+		file, inRemote := files[id]
+
+		localChanged := state.changed(id, inLocal, updatedAt(local))
+		remoteChanged := state.changed(id, inRemote, fileUpdatedAt(file))
+
+		switch {
+		case !remoteChanged:
+			// Local changes, if any, are pushed later
+			continue
+
+		case !localChanged:
+			if err := syncer.applyRemote(id, local, file, state, report); err != nil {
+				return err
+			}
+
+		case inLocal && inRemote && local.UpdatedAt.Equal(file.UpdatedAt):
+			// The same version on both sides
+			state.record(id, true, file.UpdatedAt)
+
+		case !inLocal && !inRemote:
+			// Removed on both sides
+			state.record(id, false, time.Time{})
+
+		default:
+			if err := syncer.resolveConflict(id, local, file, state, report); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Replaces the local version of a secret with the remote one.
+func (syncer *Syncer) applyRemote(
+	id string,
+	local *models.Secret,
+	file *secretFile,
+	state *syncState,
+	report *Report,
+) error {
+	if file == nil {
+		if err := syncer.manager.RemoveSecret(local); err != nil {
+			return err
+		}
+
+		state.record(id, false, time.Time{})
+		report.Pulled++
+
+		return nil
+	}
+
+	remote, err := file.decrypt(syncer.passphrase)
+	if err != nil {
+		return err
+	}
+
+	if err := syncer.pullAttachments(remote, report); err != nil {
+		return err
+	}
+
+	err = syncer.manager.ImportSecret(remote)
+
+	// Another local secret already uses the key, leave this one for later
+	if errors.Is(err, models.ErrDuplicateKey) {
+		report.Skipped = append(
+			report.Skipped,
+			fmt.Sprintf("remote secret '%s' has the same key as a different local secret", remote.Key),
+		)
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if err := syncer.prunePulledAttachments(local, remote); err != nil {
+		return err
+	}
+
+	state.record(id, true, file.UpdatedAt)
+	report.Pulled++
+
+	return nil
+}
+
+// Asks the resolver which version of a conflicting secret to keep.
+func (syncer *Syncer) resolveConflict(
+	id string,
+	local *models.Secret,
+	file *secretFile,
+	state *syncState,
+	report *Report,
+) error {
+	conflict := &Conflict{Local: local}
+
+	if file != nil {
+		remote, err := file.decrypt(syncer.passphrase)
+		if err != nil {
+			return err
+		}
+
+		conflict.Remote = remote
+	}
+
+	if syncer.resolve == nil {
+		return fmt.Errorf("%w: %s", ErrConflict, conflictKey(conflict))
+	}
+
+	resolution, err := syncer.resolve(conflict)
+	if err != nil {
+		return err
+	}
+
+	report.Conflicts++
+
+	switch resolution {
+	case KeepRemote:
+		return syncer.applyRemote(id, local, file, state, report)
+
+	default:
+		// Treat the remote version as the common ancestor so that the local
+		// version counts as a newer change and is pushed
+		state.record(id, file != nil, fileUpdatedAt(file))
+		return nil
+	}
+}
+
+// Writes the local version of a secret to the working copy, or removes the
+// file if the secret was removed.
+func (syncer *Syncer) writeSecret(id string, secret *models.Secret) error {
+	path := secretFilePath(syncer.repo.dir, id)
+
+	if err := syncer.pushAttachments(id, secret); err != nil {
+		return err
+	}
+
+	if secret == nil {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+
+		return nil
+	}
+
+	content, err := encodeSecretFile(syncer.passphrase, secret)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, content, 0600)
+}
+
+// Returns the local secrets keyed by ID.
+func (syncer *Syncer) localSecrets() (map[string]*models.Secret, error) {
+	secrets, err := syncer.manager.ListSecrets()
+	if err != nil {
+		return nil, err
+	}
+
+	localSecrets := make(map[string]*models.Secret, len(secrets))
+	for i := range secrets {
+		localSecrets[secrets[i].ID.String()] = &secrets[i]
+	}
+
+	return localSecrets, nil
+}
+
+// Returns the key of the conflicting secret for messages.
+func conflictKey(conflict *Conflict) string {
+	if conflict.Local != nil {
+		return conflict.Local.Key
+	}
+
+	return conflict.Remote.Key
+}
+
+func updatedAt(secret *models.Secret) time.Time {
+	if secret == nil {
+		return time.Time{}
+	}
+
+	return secret.UpdatedAt
+}
+
+func fileUpdatedAt(file *secretFile) time.Time {
+	if file == nil {
+		return time.Time{}
+	}
+
+	return file.UpdatedAt
+}
+
+// Adds the keys of m to the set of IDs.
+func addIds[V any](ids map[string]bool, m map[string]V) {
+	for id := range m {
+		ids[id] = true
+	}
+}
+
+// Returns the IDs of the set in sorted order.
+func sortedIds(ids map[string]bool) []string {
+	sorted := make([]string, 0, len(ids))
+	for id := range ids {
+		sorted = append(sorted, id)
+	}
+
+	slices.Sort(sorted)
+
+	return sorted
+}
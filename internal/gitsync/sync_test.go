@@ -0,0 +1,353 @@
+package gitsync_test
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Isaac-Fate/myst/internal/attachment"
+	"github.com/Isaac-Fate/myst/internal/gitsync"
+	"github.com/Isaac-Fate/myst/internal/manager"
+	"github.com/Isaac-Fate/myst/internal/models"
+)
+
+const passphrase string = "hello, world"
+
+// A device with its own secret store syncing with the shared remote.
+type device struct {
+	manager     *manager.SecretManager
+	syncer      *gitsync.Syncer
+	attachments *attachment.Store
+}
+
+func TestPushPull(t *testing.T) {
+	remote := createRemote(t)
+
+	alice := createDevice(t, remote, nil)
+	bob := createDevice(t, remote, nil)
+
+	// Alice adds a secret and pushes it
+	secret := &models.Secret{Key: "github-token", EncryptedValue: "xxx", Website: "github.com"}
+	if err := alice.manager.AddSecret(secret); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := alice.syncer.Push()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Pushed != 1 {
+		t.Errorf("expected 1 pushed secret, got %d", report.Pushed)
+	}
+
+	// Bob pulls it with the original timestamps
+	report, err = bob.syncer.Pull()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Pulled != 1 {
+		t.Errorf("expected 1 pulled secret, got %d", report.Pulled)
+	}
+
+	pulled, err := bob.manager.GetSecret(secret.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pulled.Website != "github.com" || !pulled.UpdatedAt.Equal(secret.UpdatedAt) {
+		t.Errorf("unexpected pulled secret %v", pulled)
+	}
+
+	// Pulled secrets are searchable
+	found, err := bob.manager.FindSecrets("github")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(found) != 1 {
+		t.Errorf("expected to find the pulled secret, got %v", found)
+	}
+
+	// Nothing changed on Bob's side, so there is nothing to push
+	report, err = bob.syncer.Push()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Pushed != 0 {
+		t.Errorf("expected nothing to push, got %d", report.Pushed)
+	}
+
+	// Bob removes the secret and Alice pulls the removal
+	if err := bob.manager.RemoveSecret(pulled); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := bob.syncer.Push(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := alice.syncer.Pull(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := alice.manager.GetSecret(secret.ID.String()); !errors.Is(err, models.ErrSecretNotFound) {
+		t.Errorf("expected the secret to be removed, got %v", err)
+	}
+}
+
+func TestConflict(t *testing.T) {
+	remote := createRemote(t)
+
+	var conflicts []*gitsync.Conflict
+	keepRemote := func(conflict *gitsync.Conflict) (gitsync.Resolution, error) {
+		conflicts = append(conflicts, conflict)
+		return gitsync.KeepRemote, nil
+	}
+
+	alice := createDevice(t, remote, nil)
+	bob := createDevice(t, remote, keepRemote)
+
+	secret := &models.Secret{Key: "aws", EncryptedValue: "xxx", Notes: "original"}
+	if err := alice.manager.AddSecret(secret); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := alice.syncer.Push(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := bob.syncer.Pull(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Both edit the same secret
+	secret.Notes = "edited by alice"
+	if err := alice.manager.UpdateSecret(secret); err != nil {
+		t.Fatal(err)
+	}
+
+	bobSecret, err := bob.manager.GetSecret(secret.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bobSecret.Notes = "edited by bob"
+	if err := bob.manager.UpdateSecret(bobSecret); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := alice.syncer.Push(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Bob's push runs into the conflict and keeps Alice's version
+	if _, err := bob.syncer.Push(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(conflicts) != 1 {
+		t.Fatalf("expected 1 conflict, got %d", len(conflicts))
+	}
+
+	if conflicts[0].Local.Notes != "edited by bob" || conflicts[0].Remote.Notes != "edited by alice" {
+		t.Errorf("unexpected conflict %v", conflicts[0])
+	}
+
+	resolved, err := bob.manager.GetSecret(secret.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resolved.Notes != "edited by alice" {
+		t.Errorf("expected the remote version to be kept, got %s", resolved.Notes)
+	}
+}
+
+func TestConflictWithoutResolver(t *testing.T) {
+	remote := createRemote(t)
+
+	alice := createDevice(t, remote, nil)
+	bob := createDevice(t, remote, nil)
+
+	secret := &models.Secret{Key: "aws", EncryptedValue: "xxx"}
+	if err := alice.manager.AddSecret(secret); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := alice.syncer.Push(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := bob.syncer.Pull(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Alice removes the secret while Bob edits it
+	if err := alice.manager.RemoveSecret(secret); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := alice.syncer.Push(); err != nil {
+		t.Fatal(err)
+	}
+
+	secret.Notes = "edited by bob"
+	if err := bob.manager.UpdateSecret(secret); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := bob.syncer.Pull(); !errors.Is(err, gitsync.ErrConflict) {
+		t.Errorf("expected ErrConflict, got %v", err)
+	}
+}
+
+func TestAttachments(t *testing.T) {
+	remote := createRemote(t)
+
+	alice := createDevice(t, remote, nil)
+	bob := createDevice(t, remote, nil)
+
+	// Alice attaches a certificate and pushes the secret
+	cert, err := alice.attachments.Add(passphrase, "cert.pem", strings.NewReader("-----BEGIN CERTIFICATE-----"), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secret := &models.Secret{Key: "tls", EncryptedValue: "xxx", Attachments: []models.Attachment{*cert}}
+	if err := alice.manager.AddSecret(secret); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := alice.syncer.Push(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Bob pulls the secret with the contents of its attachment
+	if _, err := bob.syncer.Pull(); err != nil {
+		t.Fatal(err)
+	}
+
+	pulled, err := bob.manager.GetSecret(secret.ID.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pulledCert, err := pulled.Attachment("cert.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var contents strings.Builder
+	if _, err := bob.attachments.Open(passphrase, pulledCert, &contents); err != nil {
+		t.Fatal(err)
+	}
+	if contents.String() != "-----BEGIN CERTIFICATE-----" {
+		t.Errorf("unexpected contents %q", contents.String())
+	}
+
+	// Alice replaces the certificate, which drops the old contents from the
+	// remote and then from Bob's attachments
+	renewed, err := alice.attachments.Add(passphrase, "cert.pem", strings.NewReader("renewed"), 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secret.Attachments = []models.Attachment{*renewed}
+	if err := alice.manager.UpdateSecret(secret); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := alice.syncer.Push(); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := exec.Command("git", "-C", remote, "ls-tree", "-r", "--name-only", "main").CombinedOutput()
+	if err != nil {
+		t.Fatal(string(output))
+	}
+	if strings.Contains(string(output), cert.ID) || !strings.Contains(string(output), "attachments/"+renewed.ID) {
+		t.Errorf("unexpected files in the remote:\n%s", output)
+	}
+
+	if _, err := bob.syncer.Pull(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(bob.attachments.Path(cert.ID)); !errors.Is(err, os.ErrNotExist) {
+		t.Error("expected the old contents to be deleted")
+	}
+	if _, err := os.Stat(bob.attachments.Path(renewed.ID)); err != nil {
+		t.Errorf("expected the new contents to be pulled: %v", err)
+	}
+}
+
+func TestSecretFilesAreEncrypted(t *testing.T) {
+	remote := createRemote(t)
+	alice := createDevice(t, remote, nil)
+
+	if err := alice.manager.AddSecret(&models.Secret{Key: "github-token", EncryptedValue: "xxx"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := alice.syncer.Push(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Grep the whole remote history for the key
+	output, err := exec.Command("git", "-C", remote, "log", "--all", "-p", "-S", "github-token").CombinedOutput()
+	if err != nil {
+		t.Fatal(string(output))
+	}
+
+	if len(output) != 0 {
+		t.Errorf("secret key found in plaintext in the remote:\n%s", output)
+	}
+}
+
+// Creates an empty bare repository to act as the remote.
+func createRemote(t *testing.T) string {
+	remote := filepath.Join(t.TempDir(), "remote.git")
+
+	output, err := exec.Command("git", "init", "--quiet", "--bare", "--initial-branch", "main", remote).CombinedOutput()
+	if err != nil {
+		t.Fatal(string(output))
+	}
+
+	return remote
+}
+
+// Creates a device with a fresh vault and an initialized sync working copy.
+func createDevice(t *testing.T, remote string, resolve gitsync.ConflictResolver) *device {
+	dir := t.TempDir()
+
+	secretManager, err := manager.NewFileSecretManager(filepath.Join(dir, "vault.myst"), passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { secretManager.Close() })
+
+	syncer := gitsync.New(
+		filepath.Join(dir, "sync"),
+		filepath.Join(dir, "sync-state.json"),
+		passphrase,
+		secretManager,
+		resolve,
+	)
+	syncer.SetAttachmentsDir(filepath.Join(dir, "attachments"))
+
+	if err := syncer.Init(remote); err != nil {
+		t.Fatal(err)
+	}
+
+	return &device{
+		manager:     secretManager,
+		syncer:      syncer,
+		attachments: attachment.NewStore(filepath.Join(dir, "attachments")),
+	}
+}
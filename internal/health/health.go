@@ -0,0 +1,158 @@
+package health
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	mycrypto "github.com/Isaac-Fate/myst/internal/crypto"
+	"github.com/Isaac-Fate/myst/internal/models"
+)
+
+// The health report decrypts every value in memory only. Values are never
+// kept beyond estimating their strength: reuse is found by comparing keyed
+// hashes under a key that only lives for the check, so the report holds
+// nothing that helps guess a value.
+
+// How much an issue takes off a secret's share of the score. A secret with
+// several issues loses at most its whole share.
+const (
+	weakPenalty   float64 = 1
+	reusedPenalty float64 = 1
+	oldPenalty    float64 = 0.5
+)
+
+// What to check.
+type Options struct {
+	// Values not updated for longer than this are reported as old, unless
+	// it is zero
+	MaxAge time.Duration
+
+	// The time ages are counted up to
+	Now time.Time
+}
+
+// A secret with a value that is easy to guess.
+type WeakSecret struct {
+	Key      string   `json:"key"`
+	Strength Strength `json:"strength"`
+	Reasons  []string `json:"reasons,omitempty"`
+}
+
+// A secret whose value has not been updated for a long time.
+type OldSecret struct {
+	Key string `json:"key"`
+
+	// When the value last changed
+	UpdatedAt time.Time `json:"updated_at"`
+	AgeDays   int       `json:"age_days"`
+}
+
+// The findings of a health check.
+type Report struct {
+	// From 0 to 100, where 100 means no secret has any issue
+	Score int `json:"score"`
+
+	// The number of secrets checked
+	Total int `json:"total"`
+
+	Weak []WeakSecret `json:"weak"`
+
+	// Groups of keys sharing the same value
+	Reused [][]string `json:"reused"`
+
+	Old []OldSecret `json:"old"`
+}
+
+// Checks the secrets, decrypting their values with the passphrase.
+func Check(secrets []models.Secret, passphrase string, options Options) (*Report, error) {
+	hashKey, err := mycrypto.NewHashKey()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{
+		Total:  len(secrets),
+		Weak:   []WeakSecret{},
+		Reused: [][]string{},
+		Old:    []OldSecret{},
+	}
+
+	penalties := make(map[string]float64, len(secrets))
+	keysByHash := make(map[string][]string)
+
+	for _, secret := range secrets {
+		value, err := mycrypto.Decrypt(passphrase, secret.EncryptedValue)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt secret '%s': %w", secret.Key, err)
+		}
+
+		if estimate := EstimateStrength(value); estimate.Strength < minStrength {
+			report.Weak = append(report.Weak, WeakSecret{
+				Key:      secret.Key,
+				Strength: estimate.Strength,
+				Reasons:  estimate.Reasons,
+			})
+			penalties[secret.Key] += weakPenalty
+		}
+
+		hash := mycrypto.KeyedHash(hashKey, value)
+		keysByHash[hash] = append(keysByHash[hash], secret.Key)
+
+		if age := options.Now.Sub(secret.ValueChangedAt()); options.MaxAge > 0 && age > options.MaxAge {
+			report.Old = append(report.Old, OldSecret{
+				Key:       secret.Key,
+				UpdatedAt: secret.ValueChangedAt(),
+				AgeDays:   int(age / (24 * time.Hour)),
+			})
+			penalties[secret.Key] += oldPenalty
+		}
+	}
+
+	for _, keys := range keysByHash {
+		if len(keys) < 2 {
+			continue
+		}
+
+		sort.Strings(keys)
+		report.Reused = append(report.Reused, keys)
+
+		for _, key := range keys {
+			penalties[key] += reusedPenalty
+		}
+	}
+
+	// Order the findings so that reports can be compared
+	sort.Slice(report.Weak, func(i, j int) bool {
+		if report.Weak[i].Strength != report.Weak[j].Strength {
+			return report.Weak[i].Strength < report.Weak[j].Strength
+		}
+		return report.Weak[i].Key < report.Weak[j].Key
+	})
+	sort.Slice(report.Reused, func(i, j int) bool {
+		return report.Reused[i][0] < report.Reused[j][0]
+	})
+	sort.Slice(report.Old, func(i, j int) bool {
+		return report.Old[i].UpdatedAt.Before(report.Old[j].UpdatedAt)
+	})
+
+	report.Score = score(penalties, len(secrets))
+
+	return report, nil
+}
+
+// Scores the secrets from 0 to 100 by the share of them without issues,
+// where each secret loses at most its own share.
+func score(penalties map[string]float64, total int) int {
+	if total == 0 {
+		return 100
+	}
+
+	lost := 0.0
+	for _, penalty := range penalties {
+		lost += min(penalty, 1)
+	}
+
+	return int(math.Round(100 * (1 - lost/float64(total))))
+}
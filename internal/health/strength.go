@@ -0,0 +1,218 @@
+package health
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Estimates how hard values are to guess, in the spirit of zxcvbn but much
+// simpler: the entropy of a random value drawn from the character classes
+// the value uses, where characters that continue a repetition, a sequence
+// or a keyboard row count for little, and common passwords count for
+// almost nothing.
+
+// How hard a value is to guess, from 0 (trivially) to 4 (very hard), on the
+// same scale as zxcvbn.
+type Strength int
+
+const (
+	VeryWeak Strength = iota
+	Weak
+	Fair
+	Strong
+	VeryStrong
+)
+
+// Values below this strength are reported as weak.
+const minStrength Strength = Fair
+
+// The least entropy in bits for each strength above VeryWeak.
+var strengthBits = []float64{28, 36, 60, 80}
+
+// The share of a full character's entropy that a character continuing a
+// pattern is worth.
+const patternWeight float64 = 0.25
+
+// Runs of characters that are next to each other on a keyboard.
+var keyboardRows = []string{
+	"`1234567890-=",
+	"qwertyuiop[]\\",
+	"asdfghjkl;'",
+	"zxcvbnm,./",
+}
+
+// Passwords that come first in every guessing list, lower case.
+var commonPasswords = []string{
+	"123456", "password", "12345678", "qwerty", "123456789", "12345",
+	"1234", "111111", "1234567", "dragon", "123123", "baseball", "abc123",
+	"football", "monkey", "letmein", "696969", "shadow", "master", "666666",
+	"qwertyuiop", "123321", "mustang", "1234567890", "michael", "654321",
+	"superman", "1qaz2wsx", "7777777", "121212", "000000", "qazwsx",
+	"123qwe", "killer", "trustno1", "jordan", "jennifer", "zxcvbnm",
+	"asdfgh", "hunter", "buster", "soccer", "harley", "batman", "andrew",
+	"tigger", "sunshine", "iloveyou", "2000", "charlie", "robert", "thomas",
+	"hockey", "ranger", "daniel", "starwars", "klaster", "112233", "george",
+	"computer", "michelle", "jessica", "pepper", "1111", "zxcvbn", "555555",
+	"11111111", "131313", "freedom", "777777", "pass", "maggie", "159753",
+	"aaaaaa", "ginger", "princess", "joshua", "cheese", "amanda", "summer",
+	"love", "ashley", "nicole", "chelsea", "biteme", "matthew", "access",
+	"yankees", "987654321", "dallas", "austin", "thunder", "taylor",
+	"matrix", "admin", "welcome", "login", "passw0rd", "p@ssw0rd", "secret",
+	"changeme", "default", "root", "test", "guest", "qwerty123", "password1",
+}
+
+// An estimate of how hard a value is to guess.
+type Estimate struct {
+	Bits     float64
+	Strength Strength
+
+	// Why the value is weak, if it is
+	Reasons []string
+}
+
+// Estimates how hard the value is to guess.
+func EstimateStrength(value string) Estimate {
+	var reasons []string
+
+	if isCommonPassword(value) {
+		return Estimate{Strength: VeryWeak, Reasons: []string{"a common password"}}
+	}
+
+	pool := characterPool(value)
+	length := 0.0
+	repeated, sequential := false, false
+
+	runes := []rune(value)
+	for i, r := range runes {
+		switch {
+		case i > 0 && r == runes[i-1]:
+			length += patternWeight
+			repeated = true
+		case i > 0 && isSequential(runes[i-1], r):
+			length += patternWeight
+			sequential = true
+		default:
+			length++
+		}
+	}
+
+	bits := length * math.Log2(float64(pool))
+
+	strength := VeryWeak
+	for _, min := range strengthBits {
+		if bits >= min {
+			strength++
+		}
+	}
+
+	if strength < minStrength {
+		if len(runes) < 12 {
+			reasons = append(reasons, "short")
+		}
+		if pool <= 36 {
+			reasons = append(reasons, "few kinds of characters")
+		}
+		if repeated {
+			reasons = append(reasons, "repeated characters")
+		}
+		if sequential {
+			reasons = append(reasons, "sequences like abc or qwerty")
+		}
+	}
+
+	return Estimate{Bits: bits, Strength: strength, Reasons: reasons}
+}
+
+// Reports whether the value is a common password, possibly with a few
+// digits or symbols appended, such as "password123!".
+func isCommonPassword(value string) bool {
+	base := strings.TrimRightFunc(strings.ToLower(value), func(r rune) bool {
+		return unicode.IsDigit(r) || unicode.IsPunct(r) || unicode.IsSymbol(r)
+	})
+
+	for _, common := range commonPasswords {
+		if base == common || strings.ToLower(value) == common {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns how many characters a random value using the same character
+// classes as the value could be drawn from.
+func characterPool(value string) int {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range value {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < unicode.MaxASCII:
+			symbol = true
+		default:
+			other = true
+		}
+	}
+
+	pool := 0
+	if lower {
+		pool += 26
+	}
+	if upper {
+		pool += 26
+	}
+	if digit {
+		pool += 10
+	}
+	if symbol {
+		pool += 33
+	}
+	if other {
+		pool += 100
+	}
+
+	return max(pool, 1)
+}
+
+// Reports whether b follows a in the alphabet, in digits or on a keyboard
+// row, either way.
+func isSequential(a rune, b rune) bool {
+	a, b = unicode.ToLower(a), unicode.ToLower(b)
+
+	if (unicode.IsLetter(a) || unicode.IsDigit(a)) && (b == a+1 || b == a-1) {
+		return true
+	}
+
+	for _, row := range keyboardRows {
+		i := strings.IndexRune(row, a)
+		if i < 0 {
+			continue
+		}
+
+		if (i+1 < len(row) && rune(row[i+1]) == b) || (i > 0 && rune(row[i-1]) == b) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (strength Strength) String() string {
+	switch strength {
+	case VeryWeak:
+		return "very weak"
+	case Weak:
+		return "weak"
+	case Fair:
+		return "fair"
+	case Strong:
+		return "strong"
+	default:
+		return "very strong"
+	}
+}
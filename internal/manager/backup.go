@@ -0,0 +1,198 @@
+package manager
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	mycrypto "github.com/Isaac-Fate/myst/internal/crypto"
+	"github.com/Isaac-Fate/myst/internal/models"
+)
+
+// A backup is a tar archive of the secrets and the contents of their
+// attachments:
+//
+//	secrets.json           the secrets, as JSON encrypted with the passphrase
+//	attachments/<ID>       the contents of an attachment, as stored in the vault
+//
+// The contents of attachments are already encrypted, under data keys only
+// the secrets hold, so they are copied as they are. Secrets in the trash are
+// left out.
+
+const (
+	backupSecretsName     = "secrets.json"
+	backupAttachmentsName = "attachments/"
+)
+
+// Returned when a file is not a backup, or is a damaged one.
+var ErrInvalidBackup = errors.New("invalid backup")
+
+// Writes a backup of the secrets and the contents of their attachments to w,
+// returning the number of secrets backed up.
+func (manager *SecretManager) Backup(w io.Writer, passphrase string) (int, error) {
+	secrets, err := manager.ListSecrets()
+	if err != nil {
+		return 0, err
+	}
+
+	plaintext, err := json.Marshal(secrets)
+	if err != nil {
+		return 0, err
+	}
+
+	encrypted, err := mycrypto.Encrypt(passphrase, string(plaintext))
+	if err != nil {
+		return 0, fmt.Errorf("failed to encrypt the backup: %w", err)
+	}
+
+	archive := tar.NewWriter(w)
+	now := time.Now()
+
+	// The secrets come first, so restoring knows which files it needs
+	if err := archive.WriteHeader(&tar.Header{
+		Name:    backupSecretsName,
+		Mode:    0600,
+		Size:    int64(len(encrypted)),
+		ModTime: now,
+	}); err != nil {
+		return 0, err
+	}
+	if _, err := io.WriteString(archive, encrypted); err != nil {
+		return 0, err
+	}
+
+	for _, secret := range secrets {
+		for _, attachment := range secret.Attachments {
+			if manager.attachments == nil {
+				return 0, fmt.Errorf("the contents of attachment '%s' of '%s' are not available", attachment.Name, secret.Key)
+			}
+
+			if err := backupAttachment(archive, manager.attachments.Path(attachment.ID), attachment.ID, now); err != nil {
+				return 0, fmt.Errorf("failed to back up attachment '%s' of '%s': %w", attachment.Name, secret.Key, err)
+			}
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return 0, err
+	}
+
+	return len(secrets), nil
+}
+
+// Copies the file holding the contents of an attachment into the archive.
+func backupAttachment(archive *tar.Writer, filePath string, id string, modTime time.Time) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	if err := archive.WriteHeader(&tar.Header{
+		Name:    backupAttachmentsName + id,
+		Mode:    0600,
+		Size:    info.Size(),
+		ModTime: modTime,
+	}); err != nil {
+		return err
+	}
+
+	_, err = io.Copy(archive, file)
+	return err
+}
+
+// Restores the secrets of a backup read from r that are not in the vault,
+// along with the contents of their attachments, returning the number of
+// secrets restored. Secrets already in the vault are left as they are.
+//
+// The backup must have been made with the same passphrase.
+func (manager *SecretManager) RestoreBackup(r io.Reader, passphrase string) (int, error) {
+	archive := tar.NewReader(r)
+
+	header, err := archive.Next()
+	if err != nil || header.Name != backupSecretsName {
+		return 0, ErrInvalidBackup
+	}
+
+	encrypted, err := io.ReadAll(archive)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrInvalidBackup, err)
+	}
+
+	plaintext, err := mycrypto.Decrypt(passphrase, string(encrypted))
+	if err != nil {
+		return 0, fmt.Errorf("failed to decrypt the backup: wrong passphrase? %w", err)
+	}
+
+	var secrets []models.Secret
+	if err := json.Unmarshal([]byte(plaintext), &secrets); err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrInvalidBackup, err)
+	}
+
+	// Leave out the secrets already in the vault
+	missing := secrets[:0]
+	for _, secret := range secrets {
+		_, err := manager.store.GetSecret(secret.ID.String())
+		if errors.Is(err, models.ErrSecretNotFound) {
+			missing = append(missing, secret)
+		} else if err != nil {
+			return 0, err
+		}
+	}
+
+	// Copy the contents of the attachments before the secrets that need them
+	needed := make(map[string]bool)
+	for _, secret := range missing {
+		for _, attachment := range secret.Attachments {
+			needed[attachment.ID] = true
+		}
+	}
+
+	for {
+		header, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return 0, fmt.Errorf("%w: %w", ErrInvalidBackup, err)
+		}
+
+		id, ok := strings.CutPrefix(path.Clean(header.Name), backupAttachmentsName)
+		if !ok || !needed[id] {
+			continue
+		}
+
+		if manager.attachments == nil {
+			return 0, errors.New("there is nowhere to restore the contents of attachments to")
+		}
+
+		if err := manager.attachments.Put(id, archive); err != nil {
+			return 0, fmt.Errorf("failed to restore attachment contents: %w", err)
+		}
+
+		delete(needed, id)
+	}
+
+	if len(needed) > 0 {
+		return 0, fmt.Errorf("%w: the contents of %d attachments are missing", ErrInvalidBackup, len(needed))
+	}
+
+	for i := range missing {
+		if err := manager.ImportSecret(&missing[i]); err != nil {
+			return i, fmt.Errorf("failed to restore secret '%s': %w", missing[i].Key, err)
+		}
+	}
+
+	return len(missing), nil
+}
@@ -0,0 +1,59 @@
+package manager
+
+import (
+	"github.com/Isaac-Fate/myst/internal/models"
+	"github.com/Isaac-Fate/myst/internal/search"
+	"github.com/blevesearch/bleve/v2"
+)
+
+// A secret index backed by bleve.
+//
+// The same type wraps both the on-disk index used with the SQLite store and
+// the in-memory index used with the vault file.
+type bleveIndex struct {
+	index bleve.Index
+}
+
+// Opens the on-disk bleve index at path, creating it if necessary.
+func OpenBleveIndex(path string) (SecretIndex, error) {
+	index, err := search.OpenIndex(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &bleveIndex{index: index}, nil
+}
+
+// Opens an empty bleve index that lives only in memory.
+func OpenMemoryIndex() (SecretIndex, error) {
+	index, err := search.OpenMemoryIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	return &bleveIndex{index: index}, nil
+}
+
+func (index *bleveIndex) AddSecret(secret *models.Secret) error {
+	return search.AddSecret(index.index, secret)
+}
+
+func (index *bleveIndex) UpdateSecret(secret *models.Secret) error {
+	return search.UpdateSecret(index.index, secret)
+}
+
+func (index *bleveIndex) RemoveSecret(secret *models.Secret) error {
+	return search.RemoveSecret(index.index, secret)
+}
+
+func (index *bleveIndex) Search(query string, options search.Options) (*search.Result, error) {
+	return search.Search(index.index, query, options)
+}
+
+func (index *bleveIndex) Count() (uint64, error) {
+	return index.index.DocCount()
+}
+
+func (index *bleveIndex) Close() error {
+	return index.index.Close()
+}
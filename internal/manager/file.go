@@ -0,0 +1,499 @@
+package manager
+
+import (
+	"errors"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Isaac-Fate/myst/internal/models"
+	"github.com/Isaac-Fate/myst/internal/vaultfile"
+	"gorm.io/gorm"
+)
+
+// A secret store kept in a single encrypted vault file.
+//
+// The whole vault is decrypted into memory when the store is opened. Every
+// committed transaction rewrites the file atomically, so the file on disk
+// always holds a complete vault.
+type fileStore struct {
+	mutex      sync.Mutex
+	path       string
+	passphrase string
+	secrets    *secretList
+}
+
+// Opens the vault file at path with the master passphrase.
+//
+// An empty vault file is created if none exists yet.
+func OpenFileStore(path string, passphrase string) (SecretStore, error) {
+	store := &fileStore{
+		path:       path,
+		passphrase: passphrase,
+		secrets:    &secretList{},
+	}
+
+	vault, err := vaultfile.Read(path, passphrase)
+
+	if errors.Is(err, os.ErrNotExist) {
+		// Create the file right away so that a later unlock with a different
+		// passphrase is rejected instead of silently starting a second vault
+		if err := store.save(passphrase, store.secrets); err != nil {
+			return nil, err
+		}
+
+		return store, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	store.secrets.secrets = vault.Secrets
+	store.secrets.tokens = vault.Tokens
+
+	return store, nil
+}
+
+func (store *fileStore) Transaction(fn func(store SecretStore) error) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	// Work on a copy so that a failed transaction leaves the vault untouched
+	tx := &fileTx{secrets: store.secrets.clone()}
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	// The transaction may have changed the passphrase
+	passphrase := store.passphrase
+	if tx.passphrase != "" {
+		passphrase = tx.passphrase
+	}
+
+	// Persist before publishing the changes in memory
+	if err := store.save(passphrase, tx.secrets); err != nil {
+		return err
+	}
+
+	store.secrets = tx.secrets
+	store.passphrase = passphrase
+
+	return nil
+}
+
+func (store *fileStore) AddSecret(secret *models.Secret) error {
+	return store.Transaction(func(tx SecretStore) error {
+		return tx.AddSecret(secret)
+	})
+}
+
+func (store *fileStore) UpdateSecret(secret *models.Secret) error {
+	return store.Transaction(func(tx SecretStore) error {
+		return tx.UpdateSecret(secret)
+	})
+}
+
+func (store *fileStore) ImportSecret(secret *models.Secret) error {
+	return store.Transaction(func(tx SecretStore) error {
+		return tx.ImportSecret(secret)
+	})
+}
+
+func (store *fileStore) RemoveSecret(secret *models.Secret) error {
+	return store.Transaction(func(tx SecretStore) error {
+		return tx.RemoveSecret(secret)
+	})
+}
+
+func (store *fileStore) GetSecret(id string) (*models.Secret, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	return store.secrets.get(id)
+}
+
+func (store *fileStore) GetSecretByKey(key string) (*models.Secret, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	return store.secrets.getByKey(key)
+}
+
+func (store *fileStore) GetSecrets(ids []string) ([]models.Secret, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	return store.secrets.getAll(ids), nil
+}
+
+func (store *fileStore) ListSecrets() ([]models.Secret, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	return store.secrets.live(), nil
+}
+
+func (store *fileStore) ListTrash() ([]models.Secret, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	return store.secrets.trash(), nil
+}
+
+func (store *fileStore) RestoreSecret(secret *models.Secret) error {
+	return store.Transaction(func(tx SecretStore) error {
+		return tx.RestoreSecret(secret)
+	})
+}
+
+func (store *fileStore) PurgeSecret(secret *models.Secret) error {
+	return store.Transaction(func(tx SecretStore) error {
+		return tx.PurgeSecret(secret)
+	})
+}
+
+func (store *fileStore) AddToken(token *models.APIToken) error {
+	return store.Transaction(func(tx SecretStore) error {
+		return tx.AddToken(token)
+	})
+}
+
+func (store *fileStore) ListTokens() ([]models.APIToken, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	return slices.Clone(store.secrets.tokens), nil
+}
+
+func (store *fileStore) GetTokenByHash(hash string) (*models.APIToken, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	return store.secrets.getToken(hash)
+}
+
+func (store *fileStore) RemoveToken(token *models.APIToken) error {
+	return store.Transaction(func(tx SecretStore) error {
+		return tx.RemoveToken(token)
+	})
+}
+
+func (store *fileStore) Close() error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	// Drop the decrypted secrets
+	store.secrets = &secretList{}
+	store.passphrase = ""
+
+	return nil
+}
+
+// Writes the secrets to the vault file.
+func (store *fileStore) save(passphrase string, secrets *secretList) error {
+	return vaultfile.Write(store.path, passphrase, &vaultfile.Vault{
+		Secrets: secrets.secrets,
+		Tokens:  secrets.tokens,
+	})
+}
+
+// The store handed to the function run by fileStore.Transaction.
+type fileTx struct {
+	secrets *secretList
+
+	// The new passphrase, if it is changed by the transaction
+	passphrase string
+}
+
+func (tx *fileTx) SetPassphrase(passphrase string) {
+	tx.passphrase = passphrase
+}
+
+func (tx *fileTx) Transaction(fn func(store SecretStore) error) error {
+	// A nested transaction rolls back to the state at its start
+	snapshot := tx.secrets.clone()
+
+	if err := fn(tx); err != nil {
+		tx.secrets = snapshot
+		return err
+	}
+
+	return nil
+}
+
+func (tx *fileTx) AddSecret(secret *models.Secret) error {
+	return tx.secrets.add(secret)
+}
+
+func (tx *fileTx) UpdateSecret(secret *models.Secret) error {
+	return tx.secrets.update(secret)
+}
+
+func (tx *fileTx) ImportSecret(secret *models.Secret) error {
+	return tx.secrets.put(secret)
+}
+
+func (tx *fileTx) RemoveSecret(secret *models.Secret) error {
+	tx.secrets.remove(secret.ID.String())
+	return nil
+}
+
+func (tx *fileTx) GetSecret(id string) (*models.Secret, error) {
+	return tx.secrets.get(id)
+}
+
+func (tx *fileTx) GetSecretByKey(key string) (*models.Secret, error) {
+	return tx.secrets.getByKey(key)
+}
+
+func (tx *fileTx) GetSecrets(ids []string) ([]models.Secret, error) {
+	return tx.secrets.getAll(ids), nil
+}
+
+func (tx *fileTx) ListSecrets() ([]models.Secret, error) {
+	return tx.secrets.live(), nil
+}
+
+func (tx *fileTx) ListTrash() ([]models.Secret, error) {
+	return tx.secrets.trash(), nil
+}
+
+func (tx *fileTx) AddToken(token *models.APIToken) error {
+	return tx.secrets.addToken(token)
+}
+
+func (tx *fileTx) ListTokens() ([]models.APIToken, error) {
+	return slices.Clone(tx.secrets.tokens), nil
+}
+
+func (tx *fileTx) GetTokenByHash(hash string) (*models.APIToken, error) {
+	return tx.secrets.getToken(hash)
+}
+
+func (tx *fileTx) RemoveToken(token *models.APIToken) error {
+	return tx.secrets.removeToken(token.ID.String())
+}
+
+func (tx *fileTx) RestoreSecret(secret *models.Secret) error {
+	return tx.secrets.restore(secret)
+}
+
+func (tx *fileTx) PurgeSecret(secret *models.Secret) error {
+	tx.secrets.purge(secret.ID.String())
+	return nil
+}
+
+func (tx *fileTx) Close() error {
+	return nil
+}
+
+// Secrets kept in insertion order, mirroring the row order of the database.
+//
+// Like rows of the database, secrets in the trash stay in the list with
+// DeletedAt set, keeping their ID and key taken. The list also holds the API
+// tokens, which are saved in the same vault file.
+type secretList struct {
+	secrets []models.Secret
+	tokens  []models.APIToken
+}
+
+func (list *secretList) clone() *secretList {
+	return &secretList{secrets: slices.Clone(list.secrets), tokens: slices.Clone(list.tokens)}
+}
+
+func (list *secretList) addToken(token *models.APIToken) error {
+	if slices.ContainsFunc(list.tokens, func(other models.APIToken) bool {
+		return other.Name == token.Name
+	}) {
+		return models.ErrDuplicateTokenName
+	}
+
+	if token.CreatedAt.IsZero() {
+		token.CreatedAt = time.Now()
+	}
+
+	list.tokens = append(list.tokens, *token)
+
+	return nil
+}
+
+func (list *secretList) getToken(hash string) (*models.APIToken, error) {
+	i := slices.IndexFunc(list.tokens, func(token models.APIToken) bool {
+		return token.Hash == hash
+	})
+	if i < 0 {
+		return nil, models.ErrTokenNotFound
+	}
+
+	token := list.tokens[i]
+	return &token, nil
+}
+
+func (list *secretList) removeToken(id string) error {
+	i := slices.IndexFunc(list.tokens, func(token models.APIToken) bool {
+		return token.ID.String() == id
+	})
+	if i < 0 {
+		return models.ErrTokenNotFound
+	}
+
+	list.tokens = slices.Delete(list.tokens, i, i+1)
+
+	return nil
+}
+
+func (list *secretList) indexOf(id string) int {
+	return slices.IndexFunc(list.secrets, func(secret models.Secret) bool {
+		return secret.ID.String() == id
+	})
+}
+
+func (list *secretList) hasKey(key string, exceptId string) bool {
+	return slices.ContainsFunc(list.secrets, func(secret models.Secret) bool {
+		return secret.Key == key && secret.ID.String() != exceptId
+	})
+}
+
+func (list *secretList) add(secret *models.Secret) error {
+	if list.indexOf(secret.ID.String()) >= 0 {
+		return errors.New("secret with this ID already exists")
+	}
+
+	if list.hasKey(secret.Key, "") {
+		return models.ErrDuplicateKey
+	}
+
+	// Fill in the timestamps the way GORM does
+	now := time.Now()
+	if secret.CreatedAt.IsZero() {
+		secret.CreatedAt = now
+	}
+	if secret.UpdatedAt.IsZero() {
+		secret.UpdatedAt = now
+	}
+
+	list.secrets = append(list.secrets, *secret)
+
+	return nil
+}
+
+func (list *secretList) update(secret *models.Secret) error {
+	i := list.indexOf(secret.ID.String())
+
+	// Saving an unknown secret creates it, like GORM's Save
+	if i < 0 {
+		return list.add(secret)
+	}
+
+	if list.hasKey(secret.Key, secret.ID.String()) {
+		return models.ErrDuplicateKey
+	}
+
+	secret.UpdatedAt = time.Now()
+	list.secrets[i] = *secret
+
+	return nil
+}
+
+func (list *secretList) put(secret *models.Secret) error {
+	if list.hasKey(secret.Key, secret.ID.String()) {
+		return models.ErrDuplicateKey
+	}
+
+	if i := list.indexOf(secret.ID.String()); i >= 0 {
+		list.secrets[i] = *secret
+	} else {
+		list.secrets = append(list.secrets, *secret)
+	}
+
+	return nil
+}
+
+// Moves a secret to the trash.
+func (list *secretList) remove(id string) {
+	if i := list.indexOf(id); i >= 0 && !list.secrets[i].DeletedAt.Valid {
+		list.secrets[i].DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	}
+}
+
+// Takes a secret out of the trash.
+func (list *secretList) restore(secret *models.Secret) error {
+	i := list.indexOf(secret.ID.String())
+	if i < 0 || !list.secrets[i].DeletedAt.Valid {
+		return models.ErrSecretNotFound
+	}
+
+	list.secrets[i].DeletedAt = gorm.DeletedAt{}
+	list.secrets[i].UpdatedAt = time.Now()
+
+	*secret = list.secrets[i]
+
+	return nil
+}
+
+// Deletes a secret in the trash for good.
+func (list *secretList) purge(id string) {
+	list.secrets = slices.DeleteFunc(list.secrets, func(secret models.Secret) bool {
+		return secret.ID.String() == id && secret.DeletedAt.Valid
+	})
+}
+
+// Returns the secrets that are not in the trash.
+func (list *secretList) live() []models.Secret {
+	return slices.DeleteFunc(slices.Clone(list.secrets), func(secret models.Secret) bool {
+		return secret.DeletedAt.Valid
+	})
+}
+
+// Returns the secrets in the trash, the most recently removed first.
+func (list *secretList) trash() []models.Secret {
+	trash := slices.DeleteFunc(slices.Clone(list.secrets), func(secret models.Secret) bool {
+		return !secret.DeletedAt.Valid
+	})
+
+	slices.SortStableFunc(trash, func(a, b models.Secret) int {
+		return b.DeletedAt.Time.Compare(a.DeletedAt.Time)
+	})
+
+	return trash
+}
+
+func (list *secretList) get(id string) (*models.Secret, error) {
+	i := list.indexOf(id)
+	if i < 0 || list.secrets[i].DeletedAt.Valid {
+		return nil, models.ErrSecretNotFound
+	}
+
+	secret := list.secrets[i]
+
+	return &secret, nil
+}
+
+func (list *secretList) getByKey(key string) (*models.Secret, error) {
+	i := slices.IndexFunc(list.secrets, func(secret models.Secret) bool {
+		return secret.Key == key && !secret.DeletedAt.Valid
+	})
+	if i < 0 {
+		return nil, models.ErrSecretNotFound
+	}
+
+	secret := list.secrets[i]
+
+	return &secret, nil
+}
+
+// Gets the secrets with the given IDs, in the same order as the IDs.
+func (list *secretList) getAll(ids []string) []models.Secret {
+	var secrets []models.Secret
+
+	for _, id := range ids {
+		if i := list.indexOf(id); i >= 0 && !list.secrets[i].DeletedAt.Valid {
+			secrets = append(secrets, list.secrets[i])
+		}
+	}
+
+	return secrets
+}
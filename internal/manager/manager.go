@@ -1,152 +1,788 @@
 package manager
 
 import (
+	"errors"
 	"fmt"
-
-	"github.com/Isaac-Fate/myst/internal/database"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Isaac-Fate/myst/internal/attachment"
+	"github.com/Isaac-Fate/myst/internal/audit"
+	mycrypto "github.com/Isaac-Fate/myst/internal/crypto"
 	"github.com/Isaac-Fate/myst/internal/models"
 	"github.com/Isaac-Fate/myst/internal/search"
-	"github.com/blevesearch/bleve/v2"
-	"gorm.io/gorm"
+	"github.com/google/uuid"
 )
 
 type SecretManager struct {
-	db    *gorm.DB
-	index bleve.Index
+	store SecretStore
+	index SecretIndex
+
+	// Optional, records every change and access
+	auditLog *audit.Log
+
+	// Optional, holds the contents of attachments
+	attachments *attachment.Store
+}
+
+// Creates a secret manager on top of a secret store and a search index.
+//
+// The index is expected to be in sync with the store.
+func New(store SecretStore, index SecretIndex) *SecretManager {
+	return &SecretManager{
+		store: store,
+		index: index,
+	}
 }
 
+// Creates a secret manager backed by the SQLite database at dbPath and the
+// bleve index at indexPath.
+//
+// The contents of attachments are kept in the attachments directory next to
+// the database.
 func NewSecretManager(dbPath, indexPath string) (*SecretManager, error) {
 	// Open the database
-	db, err := database.OpenSecretStore(dbPath)
+	store, err := OpenSQLiteStore(dbPath)
 
 	if err != nil {
 		return nil, err
 	}
 
 	// Open the index
-	index, err := search.OpenIndex(indexPath)
+	index, err := OpenBleveIndex(indexPath)
 
 	if err != nil {
+		store.Close()
 		return nil, err
 	}
 
-	return &SecretManager{
-		db:    db,
-		index: index,
-	}, nil
+	manager := New(store, index)
+	manager.SetAttachments(attachment.NewStore(filepath.Join(filepath.Dir(dbPath), attachment.DirName)))
+
+	// Rebuild an index that was just created, such as after the index
+	// mapping changed
+	count, err := index.Count()
+	if err == nil && count == 0 {
+		err = manager.Reindex()
+	}
+	if err != nil {
+		manager.Close()
+		return nil, err
+	}
+
+	return manager, nil
 }
 
-func (manager *SecretManager) AddSecret(secret *models.Secret) error {
-	// Create a transaction
-	tx := manager.db.Begin()
+// Creates a secret manager backed by the single encrypted vault file at
+// vaultPath.
+//
+// The vault is decrypted with the passphrase and indexed in memory. The
+// contents of attachments are kept in the attachments directory next to it.
+func NewFileSecretManager(vaultPath string, passphrase string) (*SecretManager, error) {
+	// Open the vault file
+	store, err := OpenFileStore(vaultPath, passphrase)
+
+	if err != nil {
+		return nil, err
+	}
 
-	// Add the secret to the database
-	err := database.AddSecret(tx, secret)
+	// Open an in-memory index
+	index, err := OpenMemoryIndex()
 
 	if err != nil {
-		tx.Rollback()
-		return err
+		store.Close()
+		return nil, err
+	}
+
+	manager := New(store, index)
+	manager.SetAttachments(attachment.NewStore(filepath.Join(filepath.Dir(vaultPath), attachment.DirName)))
+
+	// Index the secrets in the vault
+	if err := manager.Reindex(); err != nil {
+		manager.Close()
+		return nil, err
 	}
 
-	// Add the secret to the index
-	err = search.AddSecret(manager.index, secret)
+	return manager, nil
+}
+
+// Closes the underlying store and index.
+func (manager *SecretManager) Close() error {
+	indexErr := manager.index.Close()
+	storeErr := manager.store.Close()
+
+	if storeErr != nil {
+		return storeErr
+	}
+
+	return indexErr
+}
+
+// Records changes made through the manager, and accesses reported with
+// RecordAccess, in the audit log.
+func (manager *SecretManager) SetAuditLog(auditLog *audit.Log) {
+	manager.auditLog = auditLog
+}
+
+// Keeps the contents of the attachments of the secrets in the store, so that
+// they are deleted along with their secrets and included in backups.
+func (manager *SecretManager) SetAttachments(attachments *attachment.Store) {
+	manager.attachments = attachments
+}
+
+// Returns the store holding the contents of attachments, or nil if there is
+// none.
+func (manager *SecretManager) Attachments() *attachment.Store {
+	return manager.attachments
+}
+
+// Records that the secret's value was accessed, such as displayed or copied.
+//
+// The caller should not hand out the value if this fails.
+func (manager *SecretManager) RecordAccess(action audit.Action, secret *models.Secret, details string) error {
+	return manager.record(action, secret.ID.String(), details)
+}
 
+// Records an event in the audit log, if there is one.
+//
+// Changes are recorded once the store has saved them, so that the log never
+// lists a change that did not happen.
+func (manager *SecretManager) record(action audit.Action, secretId string, details string) error {
+	if manager.auditLog == nil {
+		return nil
+	}
+
+	if err := manager.auditLog.Record(action, secretId, details); err != nil {
+		return fmt.Errorf("failed to write the audit log: %w", err)
+	}
+
+	return nil
+}
+
+// Records an event for each of the secrets.
+func (manager *SecretManager) recordEach(action audit.Action, secrets []models.Secret) error {
+	for i := range secrets {
+		if err := manager.record(action, secrets[i].ID.String(), ""); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Adds every secret in the store to the index.
+func (manager *SecretManager) Reindex() error {
+	secrets, err := manager.store.ListSecrets()
 	if err != nil {
-		tx.Rollback()
 		return err
 	}
 
-	// Commit
-	tx.Commit()
+	for i := range secrets {
+		if err := manager.index.UpdateSecret(&secrets[i]); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
 
+func (manager *SecretManager) AddSecret(secret *models.Secret) error {
+	// Assign an ID if the caller did not
+	if secret.ID == uuid.Nil {
+		secret.ID = uuid.New()
+	}
+
+	if secret.ValueUpdatedAt.IsZero() {
+		secret.ValueUpdatedAt = time.Now()
+	}
+
+	if err := manager.store.Transaction(func(tx SecretStore) error {
+		// Add the secret to the store
+		if err := tx.AddSecret(secret); err != nil {
+			return keyInTrashError(tx, err, secret.Key)
+		}
+
+		// Add the secret to the index
+		if err := manager.index.AddSecret(secret); err != nil {
+			return err
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return manager.record(audit.ActionAdd, secret.ID.String(), "")
+}
+
+// Finds all secrets matching the query, best first.
 func (manager *SecretManager) FindSecrets(query string) ([]models.Secret, error) {
-	// Create a transaction
-	tx := manager.db.Begin()
+	result, err := manager.SearchSecrets(query, search.Options{Limit: search.NoLimit})
+	if err != nil {
+		return nil, err
+	}
 
-	// Search the index and find the secret IDs
-	secretIds, err := search.FindSecretIds(manager.index, query)
+	secrets := make([]models.Secret, len(result.Hits))
+	for i, hit := range result.Hits {
+		secrets[i] = hit.Secret
+	}
 
+	return secrets, nil
+}
+
+// A secret matching a search.
+type SearchHit struct {
+	Secret models.Secret
+	Score  float64
+
+	// The key, website and notes with the matches highlighted, for the
+	// fields with a match and if highlighting was asked for
+	Fragments map[string]string
+}
+
+// The results of a search.
+type SearchResult struct {
+	// The hits within the limit and offset, best first
+	Hits []SearchHit
+
+	// The number of matching secrets, regardless of the limit and offset
+	Total uint64
+}
+
+// Searches the secrets, returning the best hits first.
+func (manager *SecretManager) SearchSecrets(query string, options search.Options) (*SearchResult, error) {
+	// Search the index
+	indexResult, err := manager.index.Search(query, options)
 	if err != nil {
-		tx.Rollback()
 		return nil, err
 	}
 
-	// Find the secrets in the database
-	secrets, err := database.GetSecrets(tx, secretIds)
+	secretIds := make([]string, len(indexResult.Hits))
+	for i, hit := range indexResult.Hits {
+		secretIds[i] = hit.Id
+	}
 
+	// Find the secrets in the store
+	secrets, err := manager.store.GetSecrets(secretIds)
 	if err != nil {
-		tx.Rollback()
 		return nil, err
 	}
 
-	// Commit
-	tx.Commit()
+	secretsById := make(map[string]models.Secret, len(secrets))
+	for _, secret := range secrets {
+		secretsById[secret.ID.String()] = secret
+	}
+
+	result := &SearchResult{
+		Hits:  make([]SearchHit, 0, len(indexResult.Hits)),
+		Total: indexResult.Total,
+	}
 
-	return secrets, nil
+	for _, hit := range indexResult.Hits {
+		// Skip hits the store no longer has
+		secret, ok := secretsById[hit.Id]
+		if !ok {
+			continue
+		}
+
+		result.Hits = append(result.Hits, SearchHit{
+			Secret:    secret,
+			Score:     hit.Score,
+			Fragments: hit.Fragments,
+		})
+	}
+
+	return result, nil
 }
 
-// UpdateSecret updates an existing secret in both the database and search index
+// UpdateSecret updates an existing secret in both the store and search index
 func (manager *SecretManager) UpdateSecret(secret *models.Secret) error {
-	// Create a transaction
-	tx := manager.db.Begin()
-
-	// Update the secret in the database
-	err := tx.Save(secret).Error
-	if err != nil {
-		tx.Rollback()
+	if err := manager.store.Transaction(func(tx SecretStore) error {
+		if err := stampValueChange(tx, secret); err != nil {
+			return err
+		}
+
+		// Update the secret in the store
+		if err := tx.UpdateSecret(secret); err != nil {
+			return keyInTrashError(tx, err, secret.Key)
+		}
+
+		// Update the secret in the search index
+		if err := manager.index.UpdateSecret(secret); err != nil {
+			return err
+		}
+
+		return nil
+	}); err != nil {
 		return err
 	}
 
-	// Update the secret in the search index
-	err = search.UpdateSecret(manager.index, secret)
-	if err != nil {
-		tx.Rollback()
+	return manager.record(audit.ActionUpdate, secret.ID.String(), "")
+}
+
+// ImportSecret saves a secret changed elsewhere, such as on another device,
+// keeping its timestamps
+func (manager *SecretManager) ImportSecret(secret *models.Secret) error {
+	if err := manager.store.Transaction(func(tx SecretStore) error {
+		// Save the secret in the store
+		if err := tx.ImportSecret(secret); err != nil {
+			return err
+		}
+
+		// Update the secret in the search index
+		if err := manager.index.UpdateSecret(secret); err != nil {
+			return err
+		}
+
+		return nil
+	}); err != nil {
 		return err
 	}
 
-	// Commit the transaction
-	tx.Commit()
-	return nil
+	return manager.record(audit.ActionImport, secret.ID.String(), "")
 }
 
-// RemoveSecret removes a secret from both the database and search index
+// RemoveSecret moves a secret to the trash, removing it from the search index
 func (manager *SecretManager) RemoveSecret(secret *models.Secret) error {
-	// Create a transaction
-	tx := manager.db.Begin()
+	if err := manager.store.Transaction(func(tx SecretStore) error {
+		// Remove the secret from the store
+		if err := tx.RemoveSecret(secret); err != nil {
+			return err
+		}
+
+		// Remove the secret from the search index
+		if err := manager.index.RemoveSecret(secret); err != nil {
+			return err
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
 
-	// Remove the secret from the database
-	err := tx.Delete(secret).Error
+	return manager.record(audit.ActionRemove, secret.ID.String(), "")
+}
+
+// Moves the secrets to the trash in a single transaction, so either all of
+// them are removed or none is.
+func (manager *SecretManager) RemoveSecrets(secrets []models.Secret) error {
+	if err := manager.bulk(func(tx SecretStore) error {
+		for i := range secrets {
+			if err := tx.RemoveSecret(&secrets[i]); err != nil {
+				return fmt.Errorf("failed to remove secret '%s': %w", secrets[i].Key, err)
+			}
+
+			if err := manager.index.RemoveSecret(&secrets[i]); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return manager.recordEach(audit.ActionRemove, secrets)
+}
+
+// Saves changes to the secrets in a single transaction, so either all of them
+// are saved or none is.
+func (manager *SecretManager) UpdateSecrets(secrets []models.Secret) error {
+	if err := manager.bulk(func(tx SecretStore) error {
+		for i := range secrets {
+			if err := stampValueChange(tx, &secrets[i]); err != nil {
+				return err
+			}
+
+			if err := tx.UpdateSecret(&secrets[i]); err != nil {
+				err = keyInTrashError(tx, err, secrets[i].Key)
+				return fmt.Errorf("failed to update secret '%s': %w", secrets[i].Key, err)
+			}
+
+			if err := manager.index.UpdateSecret(&secrets[i]); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return manager.recordEach(audit.ActionUpdate, secrets)
+}
+
+// Encrypts the values of the secrets again with the passphrase, giving each a
+// fresh salt and nonce, in a single transaction.
+func (manager *SecretManager) ReencryptSecrets(secrets []models.Secret, passphrase string) error {
+	if err := manager.bulk(func(tx SecretStore) error {
+		for i := range secrets {
+			if err := rekeySecret(&secrets[i], passphrase, passphrase); err != nil {
+				return fmt.Errorf("failed to decrypt secret '%s': %w", secrets[i].Key, err)
+			}
+
+			if err := tx.UpdateSecret(&secrets[i]); err != nil {
+				return fmt.Errorf("failed to update secret '%s': %w", secrets[i].Key, err)
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return manager.recordEach(audit.ActionRekey, secrets)
+}
+
+// Lists the secrets in the trash, the most recently removed first.
+func (manager *SecretManager) ListTrash() ([]models.Secret, error) {
+	secrets, err := manager.store.ListTrash()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list the trash: %w", err)
+	}
+	return secrets, nil
+}
+
+// Gets the secret in the trash with the exact key.
+//
+// It returns models.ErrSecretNotFound if no secret in the trash has the key.
+func (manager *SecretManager) GetTrashedSecretByKey(key string) (*models.Secret, error) {
+	trash, err := manager.ListTrash()
 	if err != nil {
-		tx.Rollback()
+		return nil, err
+	}
+
+	for i := range trash {
+		if trash[i].Key == key {
+			return &trash[i], nil
+		}
+	}
+
+	return nil, models.ErrSecretNotFound
+}
+
+// Takes a secret out of the trash and adds it back to the search index.
+func (manager *SecretManager) RestoreSecret(secret *models.Secret) error {
+	if err := manager.store.Transaction(func(tx SecretStore) error {
+		if err := tx.RestoreSecret(secret); err != nil {
+			return err
+		}
+
+		if err := manager.index.AddSecret(secret); err != nil {
+			return err
+		}
+
+		return nil
+	}); err != nil {
 		return err
 	}
 
-	// Remove the secret from the search index
-	err = search.RemoveSecret(manager.index, secret)
+	return manager.record(audit.ActionRestore, secret.ID.String(), "")
+}
+
+// Deletes secrets in the trash for good, in a single transaction, along with
+// the contents of their attachments.
+func (manager *SecretManager) PurgeSecrets(secrets []models.Secret) error {
+	var attachments []models.Attachment
+
+	err := manager.store.Transaction(func(tx SecretStore) error {
+		// Go by the attachments in the store rather than those passed in
+		trash, err := tx.ListTrash()
+		if err != nil {
+			return err
+		}
+
+		for i := range secrets {
+			for _, trashed := range trash {
+				if trashed.ID == secrets[i].ID {
+					attachments = append(attachments, trashed.Attachments...)
+				}
+			}
+
+			if err := tx.PurgeSecret(&secrets[i]); err != nil {
+				return fmt.Errorf("failed to purge secret '%s': %w", secrets[i].Key, err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := manager.recordEach(audit.ActionPurge, secrets); err != nil || manager.attachments == nil {
+		return err
+	}
+
+	// The secrets can no longer be restored, so neither can their files
+	if err := manager.attachments.Remove(attachments...); err != nil {
+		return fmt.Errorf("failed to delete the attachments of the purged secrets: %w", err)
+	}
+
+	return nil
+}
+
+// Records that the value of a secret about to be updated changed, if it is
+// not the value in the store. Values only encrypted again, when re-keying,
+// are saved without this, so that rotation deadlines keep counting from the
+// last real change.
+func stampValueChange(tx SecretStore, secret *models.Secret) error {
+	previous, err := tx.GetSecret(secret.ID.String())
+	if errors.Is(err, models.ErrSecretNotFound) {
+		secret.ValueUpdatedAt = time.Now()
+		return nil
+	}
 	if err != nil {
-		tx.Rollback()
 		return err
 	}
 
-	// Commit the transaction
-	tx.Commit()
+	if previous.EncryptedValue != secret.EncryptedValue {
+		secret.ValueUpdatedAt = time.Now()
+	} else {
+		secret.ValueUpdatedAt = previous.ValueUpdatedAt
+	}
+
 	return nil
 }
 
+// Returns models.ErrKeyInTrash instead of models.ErrDuplicateKey if the
+// secret holding the key is in the trash, so the user knows where it is.
+func keyInTrashError(tx SecretStore, err error, key string) error {
+	if !errors.Is(err, models.ErrDuplicateKey) {
+		return err
+	}
+
+	trash, trashErr := tx.ListTrash()
+	if trashErr != nil {
+		return err
+	}
+
+	for _, secret := range trash {
+		if secret.Key == key {
+			return models.ErrKeyInTrash
+		}
+	}
+
+	return err
+}
+
+// Runs a change to several secrets in a transaction.
+//
+// The index is not transactional, so if the transaction fails, the index is
+// rebuilt from the store to drop the changes that were rolled back.
+func (manager *SecretManager) bulk(fn func(tx SecretStore) error) error {
+	err := manager.store.Transaction(fn)
+	if err == nil {
+		return nil
+	}
+
+	if reindexErr := manager.Reindex(); reindexErr != nil {
+		return fmt.Errorf("%w (and failed to restore the search index: %v)", err, reindexErr)
+	}
+
+	return err
+}
+
 // GetSecret retrieves a secret by its ID
 func (manager *SecretManager) GetSecret(id string) (*models.Secret, error) {
-	return database.GetSecret(manager.db, id)
+	return manager.store.GetSecret(id)
 }
 
-// ListSecrets returns all secrets in the database
+// How keys are compared when looking up a secret by its key.
+type KeyMatch int
+
+const (
+	// Keys must be equal
+	MatchExactKey KeyMatch = iota
+
+	// Keys may differ in case, as long as only one secret matches. An exact
+	// match always wins.
+	MatchKeyIgnoringCase
+)
+
+// Gets a secret by its key, without going through the search index.
+//
+// It returns models.ErrSecretNotFound if no secret has the key, and
+// models.ErrAmbiguousKey if the case is ignored and several secrets match.
+func (manager *SecretManager) GetSecretByKey(key string, match KeyMatch) (*models.Secret, error) {
+	secret, err := manager.store.GetSecretByKey(key)
+	if err == nil || !errors.Is(err, models.ErrSecretNotFound) || match == MatchExactKey {
+		return secret, err
+	}
+
+	secrets, err := manager.ListSecrets()
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []models.Secret
+	for _, secret := range secrets {
+		if strings.EqualFold(secret.Key, key) {
+			matches = append(matches, secret)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, models.ErrSecretNotFound
+	case 1:
+		return &matches[0], nil
+	default:
+		return nil, models.ErrAmbiguousKey
+	}
+}
+
+// Words of at least this many runes may have a typo in suggestions.
+const suggestFuzzyLength int = 4
+
+// Finds secrets with keys similar to the key, best first, to suggest when a
+// key is not found.
+//
+// The key is split into words that are searched for separately in the keys,
+// so any search syntax in it is ignored.
+func (manager *SecretManager) SuggestSecrets(key string, limit int) ([]models.Secret, error) {
+	words := strings.FieldsFunc(key, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		return nil, nil
+	}
+
+	var terms []string
+	for _, word := range words {
+		terms = append(terms, "key:"+word)
+		if utf8.RuneCountInString(word) >= suggestFuzzyLength {
+			terms = append(terms, "key:"+word+"~")
+		}
+	}
+
+	result, err := manager.SearchSecrets(strings.Join(terms, " OR "), search.Options{Limit: limit})
+	if err != nil {
+		return nil, err
+	}
+
+	secrets := make([]models.Secret, len(result.Hits))
+	for i, hit := range result.Hits {
+		secrets[i] = hit.Secret
+	}
+
+	return secrets, nil
+}
+
+// ListSecrets returns all secrets in the store
 func (manager *SecretManager) ListSecrets() ([]models.Secret, error) {
-	var secrets []models.Secret
-	err := manager.db.Find(&secrets).Error
+	secrets, err := manager.store.ListSecrets()
 	if err != nil {
 		return nil, fmt.Errorf("failed to list secrets: %w", err)
 	}
 	return secrets, nil
 }
+
+// Re-encrypts every secret value with a new passphrase.
+//
+// A store that is itself encrypted with the passphrase, like the vault file,
+// is re-encrypted in the same transaction. Either everything is re-encrypted
+// or nothing is.
+func (manager *SecretManager) ChangePassphrase(oldPassphrase string, newPassphrase string) error {
+	count := 0
+
+	err := manager.store.Transaction(func(tx SecretStore) error {
+		secrets, err := tx.ListSecrets()
+		if err != nil {
+			return err
+		}
+
+		for i := range secrets {
+			if err := rekeySecret(&secrets[i], oldPassphrase, newPassphrase); err != nil {
+				return fmt.Errorf("failed to decrypt secret '%s': %w", secrets[i].Key, err)
+			}
+
+			if err := tx.UpdateSecret(&secrets[i]); err != nil {
+				return err
+			}
+		}
+
+		// Secrets in the trash may still be restored, so they are re-encrypted
+		// too, keeping their timestamps
+		trash, err := tx.ListTrash()
+		if err != nil {
+			return err
+		}
+
+		for i := range trash {
+			if err := rekeySecret(&trash[i], oldPassphrase, newPassphrase); err != nil {
+				return fmt.Errorf("failed to decrypt secret '%s' in the trash: %w", trash[i].Key, err)
+			}
+
+			if err := tx.ImportSecret(&trash[i]); err != nil {
+				return err
+			}
+		}
+
+		if store, ok := tx.(passphraseStore); ok {
+			store.SetPassphrase(newPassphrase)
+		}
+
+		count = len(secrets) + len(trash)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return manager.record(audit.ActionRekey, "", fmt.Sprintf("%d secrets", count))
+}
+
+// Reports whether the values in the store are encrypted with the passphrase,
+// judging by the first secret, in the trash or not. Any passphrase opens an
+// empty store.
+func (manager *SecretManager) OpensWith(passphrase string) (bool, error) {
+	secrets, err := manager.store.ListSecrets()
+	if err != nil {
+		return false, err
+	}
+
+	if len(secrets) == 0 {
+		if secrets, err = manager.store.ListTrash(); err != nil {
+			return false, err
+		}
+	}
+
+	if len(secrets) == 0 {
+		return true, nil
+	}
+
+	_, err = mycrypto.Decrypt(passphrase, secrets[0].EncryptedValue)
+	return err == nil, nil
+}
+
+// Encrypts the value of a secret, and the data keys of its attachments, with
+// a new passphrase. The attachments themselves stay as they are.
+func rekeySecret(secret *models.Secret, oldPassphrase string, newPassphrase string) error {
+	value, err := mycrypto.Decrypt(oldPassphrase, secret.EncryptedValue)
+	if err != nil {
+		return err
+	}
+
+	secret.EncryptedValue, err = mycrypto.Encrypt(newPassphrase, value)
+	if err != nil {
+		return err
+	}
+
+	for i := range secret.Attachments {
+		key, err := mycrypto.UnwrapDataKey(oldPassphrase, secret.Attachments[i].EncryptedKey)
+		if err != nil {
+			return fmt.Errorf("attachment '%s': %w", secret.Attachments[i].Name, err)
+		}
+
+		secret.Attachments[i].EncryptedKey, err = mycrypto.WrapDataKey(newPassphrase, key)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
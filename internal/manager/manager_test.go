@@ -1,18 +1,28 @@
 package manager_test
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/Isaac-Fate/myst/internal/audit"
+	mycrypto "github.com/Isaac-Fate/myst/internal/crypto"
 	"github.com/Isaac-Fate/myst/internal/manager"
 	"github.com/Isaac-Fate/myst/internal/models"
+	"github.com/Isaac-Fate/myst/internal/search"
+	"github.com/Isaac-Fate/myst/internal/vaultfile"
 	"github.com/google/uuid"
-	"github.com/joho/godotenv"
 )
 
+const testPassphrase string = "hello, world"
+
 func TestAddSecret(t *testing.T) {
-	secretManager, err := createSecretManager()
+	secretManager, err := createSecretManager(t)
 
 	if err != nil {
 		t.Fatal(err)
@@ -32,7 +42,7 @@ func TestAddSecret(t *testing.T) {
 }
 
 func TestFindSecrets(t *testing.T) {
-	secretManager, err := createSecretManager()
+	secretManager, err := createSecretManager(t)
 
 	if err != nil {
 		t.Fatal(err)
@@ -50,7 +60,7 @@ func TestFindSecrets(t *testing.T) {
 }
 
 func TestUpdateSecret(t *testing.T) {
-	secretManager, err := createSecretManager()
+	secretManager, err := createSecretManager(t)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -89,7 +99,7 @@ func TestUpdateSecret(t *testing.T) {
 }
 
 func TestRemoveSecret(t *testing.T) {
-	secretManager, err := createSecretManager()
+	secretManager, err := createSecretManager(t)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -119,27 +129,821 @@ func TestRemoveSecret(t *testing.T) {
 	}
 }
 
-func createSecretManager() (*manager.SecretManager, error) {
-	// Load .env file
-	err := godotenv.Load("../../.env")
+func TestDuplicateKey(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, secretManager *manager.SecretManager) {
+		err := secretManager.AddSecret(&models.Secret{Key: "duplicate", EncryptedValue: "xxx"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = secretManager.AddSecret(&models.Secret{Key: "duplicate", EncryptedValue: "yyy"})
+		if !errors.Is(err, models.ErrDuplicateKey) {
+			t.Errorf("expected ErrDuplicateKey, got %v", err)
+		}
+
+		// The failed insertion must not leave anything behind
+		secrets, err := secretManager.ListSecrets()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(secrets) != 1 {
+			t.Errorf("expected 1 secret, got %d", len(secrets))
+		}
+	})
+}
+
+func TestFindSecretsOnEachBackend(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, secretManager *manager.SecretManager) {
+		for _, secret := range []models.Secret{
+			{Key: "github-token", EncryptedValue: "xxx", Website: "github.com"},
+			{Key: "deepseek", EncryptedValue: "xxx", Notes: "for deepseek service"},
+		} {
+			if err := secretManager.AddSecret(&secret); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		secrets, err := secretManager.FindSecrets("deepseek")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(secrets) != 1 || secrets[0].Key != "deepseek" {
+			t.Errorf("expected to find deepseek, got %v", secrets)
+		}
+	})
+}
+
+func TestFileSecretManagerPersists(t *testing.T) {
+	vaultPath := filepath.Join(t.TempDir(), "vault.myst")
+
+	secretManager, err := manager.NewFileSecretManager(vaultPath, testPassphrase)
 	if err != nil {
-		return nil, err
+		t.Fatal(err)
+	}
+
+	secret := &models.Secret{
+		Key:            "persisted-secret",
+		EncryptedValue: "xxx",
+		Notes:          "survives a restart",
+	}
+
+	if err := secretManager.AddSecret(secret); err != nil {
+		t.Fatal(err)
+	}
+
+	secretManager.Close()
+
+	// Reopen the vault and search the rebuilt index
+	secretManager, err = manager.NewFileSecretManager(vaultPath, testPassphrase)
+	if err != nil {
+		t.Fatal(err)
 	}
+	defer secretManager.Close()
 
-	secretStorePath := os.Getenv("TEST_SECRET_STORE_PATH")
-	if secretStorePath == "" {
-		return nil, errors.New("TEST_SECRET_STORE_PATH is not set")
+	secrets, err := secretManager.FindSecrets("restart")
+	if err != nil {
+		t.Fatal(err)
 	}
 
-	indexPath := os.Getenv("TEST_SECRET_INDEX_PATH")
-	if indexPath == "" {
-		return nil, errors.New("TEST_SECRET_INDEX_PATH is not set")
+	if len(secrets) != 1 || secrets[0].ID != secret.ID {
+		t.Errorf("expected to find the persisted secret, got %v", secrets)
 	}
+}
+
+func TestFileSecretManagerWrongPassphrase(t *testing.T) {
+	vaultPath := filepath.Join(t.TempDir(), "vault.myst")
 
-	secretManager, err := manager.NewSecretManager(secretStorePath, indexPath)
+	secretManager, err := manager.NewFileSecretManager(vaultPath, testPassphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secretManager.Close()
+
+	_, err = manager.NewFileSecretManager(vaultPath, "wrong passphrase")
+	if !errors.Is(err, vaultfile.ErrInvalidVault) {
+		t.Errorf("expected ErrInvalidVault, got %v", err)
+	}
+}
+
+// Runs the test against a secret manager for every storage backend.
+func forEachBackend(t *testing.T, test func(t *testing.T, secretManager *manager.SecretManager)) {
+	t.Run("sqlite", func(t *testing.T) {
+		secretManager, err := createSecretManager(t)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		test(t, secretManager)
+	})
+
+	t.Run("file", func(t *testing.T) {
+		secretManager, err := manager.NewFileSecretManager(filepath.Join(t.TempDir(), "vault.myst"), testPassphrase)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { secretManager.Close() })
+
+		test(t, secretManager)
+	})
+}
+
+// Creates a SQLite-backed secret manager in a temporary directory.
+func createSecretManager(t *testing.T) (*manager.SecretManager, error) {
+	dir := t.TempDir()
+
+	secretManager, err := manager.NewSecretManager(
+		filepath.Join(dir, "secret-store.db"),
+		filepath.Join(dir, "secret-index"),
+	)
 	if err != nil {
 		return nil, err
 	}
 
+	t.Cleanup(func() { secretManager.Close() })
+
 	return secretManager, nil
 }
+
+func TestImportSecretKeepsTimestamps(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, secretManager *manager.SecretManager) {
+		updatedAt := time.Date(2024, 1, 2, 3, 4, 5, 6, time.UTC)
+
+		secret := &models.Secret{
+			ID:             uuid.New(),
+			Key:            "imported",
+			EncryptedValue: "xxx",
+			CreatedAt:      updatedAt,
+			UpdatedAt:      updatedAt,
+		}
+
+		// Import a new secret and then a change to it
+		if err := secretManager.ImportSecret(secret); err != nil {
+			t.Fatal(err)
+		}
+
+		secret.Notes = "changed elsewhere"
+		if err := secretManager.ImportSecret(secret); err != nil {
+			t.Fatal(err)
+		}
+
+		imported, err := secretManager.GetSecret(secret.ID.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if imported.Notes != "changed elsewhere" {
+			t.Errorf("expected the imported notes, got %s", imported.Notes)
+		}
+
+		if !imported.UpdatedAt.Equal(updatedAt) {
+			t.Errorf("expected update time %v, got %v", updatedAt, imported.UpdatedAt)
+		}
+	})
+}
+
+func TestValueUpdatedAtOnEachBackend(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, secretManager *manager.SecretManager) {
+		encryptedValue, _ := mycrypto.Encrypt(testPassphrase, "v1")
+		secret := &models.Secret{Key: "rotated", EncryptedValue: encryptedValue, RotateEvery: 24 * time.Hour}
+		if err := secretManager.AddSecret(secret); err != nil {
+			t.Fatal(err)
+		}
+
+		get := func() *models.Secret {
+			secret, err := secretManager.GetSecret(secret.ID.String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			return secret
+		}
+
+		added := get().ValueUpdatedAt
+		if added.IsZero() {
+			t.Fatal("expected the time the value was added")
+		}
+
+		// Changing other fields, re-encrypting and re-keying leave it alone
+		time.Sleep(10 * time.Millisecond)
+
+		tagged := get()
+		tagged.AddTag("prod")
+		if err := secretManager.UpdateSecret(tagged); err != nil {
+			t.Fatal(err)
+		}
+		if err := secretManager.ReencryptSecrets([]models.Secret{*get()}, testPassphrase); err != nil {
+			t.Fatal(err)
+		}
+		if err := secretManager.ChangePassphrase(testPassphrase, "new passphrase"); err != nil {
+			t.Fatal(err)
+		}
+
+		current := get()
+		if !current.ValueUpdatedAt.Equal(added) || !current.UpdatedAt.After(added) {
+			t.Errorf("expected only the update time to change, got %v and %v", current.ValueUpdatedAt, current.UpdatedAt)
+		}
+		if deadlines := current.Deadlines(); len(deadlines) != 1 || !deadlines[0].At.Equal(added.Add(24*time.Hour)) {
+			t.Errorf("expected the rotation to count from the value, got %+v", deadlines)
+		}
+
+		// Changing the value moves it
+		current.EncryptedValue, _ = mycrypto.Encrypt("new passphrase", "v2")
+		if err := secretManager.UpdateSecret(current); err != nil {
+			t.Fatal(err)
+		}
+		if !get().ValueUpdatedAt.After(added) {
+			t.Error("expected the time the value changed")
+		}
+	})
+}
+
+func TestChangePassphrase(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, secretManager *manager.SecretManager) {
+		encryptedValue, err := mycrypto.Encrypt(testPassphrase, "password123456!")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		dataKey, err := mycrypto.GenerateDataKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		wrappedKey, err := mycrypto.WrapDataKey(testPassphrase, dataKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		secret := &models.Secret{
+			Key:            "rekeyed",
+			EncryptedValue: encryptedValue,
+			Attachments:    []models.Attachment{{ID: "cert", Name: "cert.pem", Size: 42, EncryptedKey: wrappedKey}},
+		}
+		if err := secretManager.AddSecret(secret); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := secretManager.ChangePassphrase(testPassphrase, "new passphrase"); err != nil {
+			t.Fatal(err)
+		}
+
+		rekeyed, err := secretManager.GetSecret(secret.ID.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		value, err := mycrypto.Decrypt("new passphrase", rekeyed.EncryptedValue)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if value != "password123456!" {
+			t.Errorf("expected the original value, got %s", value)
+		}
+
+		// The data keys of attachments are re-encrypted, not the contents
+		attachment, err := rekeyed.Attachment("cert.pem")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		unwrapped, err := mycrypto.UnwrapDataKey("new passphrase", attachment.EncryptedKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(unwrapped, dataKey) || attachment.Size != 42 {
+			t.Error("expected the attachment to keep its data key and size")
+		}
+	})
+
+	// The vault file itself is re-encrypted
+	vaultPath := filepath.Join(t.TempDir(), "vault.myst")
+
+	secretManager, err := manager.NewFileSecretManager(vaultPath, testPassphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := secretManager.ChangePassphrase(testPassphrase, "new passphrase"); err != nil {
+		t.Fatal(err)
+	}
+	secretManager.Close()
+
+	secretManager, err = manager.NewFileSecretManager(vaultPath, "new passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	secretManager.Close()
+}
+
+func TestAuditLog(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, secretManager *manager.SecretManager) {
+		auditLog := audit.Open(filepath.Join(t.TempDir(), "audit.log"), []byte("an-audit-key"))
+		secretManager.SetAuditLog(auditLog)
+
+		secret := &models.Secret{Key: "audited", EncryptedValue: "xxx"}
+		if err := secretManager.AddSecret(secret); err != nil {
+			t.Fatal(err)
+		}
+
+		// A failed change is not recorded
+		if err := secretManager.AddSecret(&models.Secret{Key: "audited", EncryptedValue: "yyy"}); err == nil {
+			t.Fatal("expected an error for a duplicate key")
+		}
+
+		secret.Notes = "updated"
+		if err := secretManager.UpdateSecret(secret); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := secretManager.RecordAccess(audit.ActionReveal, secret, ""); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := secretManager.RemoveSecret(secret); err != nil {
+			t.Fatal(err)
+		}
+
+		events, err := auditLog.Events()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := []audit.Action{audit.ActionAdd, audit.ActionUpdate, audit.ActionReveal, audit.ActionRemove}
+		if len(events) != len(expected) {
+			t.Fatalf("expected %d events, got %d", len(expected), len(events))
+		}
+
+		for i, event := range events {
+			if event.Action != expected[i] || event.SecretId != secret.ID.String() {
+				t.Errorf("event %d: expected %s of %s, got %+v", i, expected[i], secret.ID, event)
+			}
+		}
+
+		if _, err := auditLog.Verify(); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func TestAuditLogAfterFailedSave(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "vault")
+	if err := os.Mkdir(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	secretManager, err := manager.NewFileSecretManager(filepath.Join(dir, "vault.myst"), testPassphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer secretManager.Close()
+
+	auditLog := audit.Open(filepath.Join(t.TempDir(), "audit.log"), []byte("an-audit-key"))
+	secretManager.SetAuditLog(auditLog)
+
+	// Writing the vault file fails once its directory is gone
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := secretManager.AddSecret(&models.Secret{Key: "unsaved", EncryptedValue: "xxx"}); err == nil {
+		t.Fatal("expected an error writing the vault")
+	}
+
+	events, err := auditLog.Events()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		t.Fatal(err)
+	}
+	if len(events) != 0 {
+		t.Errorf("expected no events for a change that was not saved, got %+v", events)
+	}
+}
+
+func TestSearchSecretsOnEachBackend(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, secretManager *manager.SecretManager) {
+		// More secrets than bleve returns by default
+		for i := range 15 {
+			secret := &models.Secret{Key: fmt.Sprintf("service-%02d", i), EncryptedValue: "xxx"}
+			if err := secretManager.AddSecret(secret); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		secrets, err := secretManager.FindSecrets("service")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(secrets) != 15 {
+			t.Errorf("expected 15 secrets, got %d", len(secrets))
+		}
+
+		result, err := secretManager.SearchSecrets("service", search.Options{Limit: 10, Offset: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if result.Total != 15 || len(result.Hits) != 5 {
+			t.Errorf("expected 5 of 15 hits, got %d of %d", len(result.Hits), result.Total)
+		}
+
+		// The exact match comes first
+		result, err = secretManager.SearchSecrets("service-07 OR service", search.Options{Limit: 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(result.Hits) != 1 || result.Hits[0].Secret.Key != "service-07" {
+			t.Errorf("expected service-07 first, got %+v", result.Hits)
+		}
+	})
+}
+
+func TestGetSecretByKeyOnEachBackend(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, secretManager *manager.SecretManager) {
+		for _, key := range []string{"github-token", "GitHub-Token", "gitlab-token", "aws-key"} {
+			if err := secretManager.AddSecret(&models.Secret{Key: key, EncryptedValue: "xxx"}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// Exact keys only, even though a search for "aws" matches
+		secret, err := secretManager.GetSecretByKey("aws-key", manager.MatchExactKey)
+		if err != nil || secret.Key != "aws-key" {
+			t.Errorf("expected aws-key, got %v, %v", secret, err)
+		}
+
+		if _, err := secretManager.GetSecretByKey("aws", manager.MatchExactKey); !errors.Is(err, models.ErrSecretNotFound) {
+			t.Errorf("expected ErrSecretNotFound, got %v", err)
+		}
+
+		if _, err := secretManager.GetSecretByKey("AWS-KEY", manager.MatchExactKey); !errors.Is(err, models.ErrSecretNotFound) {
+			t.Errorf("expected ErrSecretNotFound, got %v", err)
+		}
+
+		// Ignoring case
+		secret, err = secretManager.GetSecretByKey("AWS-KEY", manager.MatchKeyIgnoringCase)
+		if err != nil || secret.Key != "aws-key" {
+			t.Errorf("expected aws-key, got %v, %v", secret, err)
+		}
+
+		// An exact match wins over other cases
+		secret, err = secretManager.GetSecretByKey("GitHub-Token", manager.MatchKeyIgnoringCase)
+		if err != nil || secret.Key != "GitHub-Token" {
+			t.Errorf("expected GitHub-Token, got %v, %v", secret, err)
+		}
+
+		if _, err := secretManager.GetSecretByKey("GITHUB-TOKEN", manager.MatchKeyIgnoringCase); !errors.Is(err, models.ErrAmbiguousKey) {
+			t.Errorf("expected ErrAmbiguousKey, got %v", err)
+		}
+
+		// Suggestions tolerate typos and ignore search syntax
+		suggestions, err := secretManager.SuggestSecrets("gitlab-tokn", 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(suggestions) != 1 || suggestions[0].Key != "gitlab-token" {
+			t.Errorf("expected gitlab-token, got %v", suggestions)
+		}
+
+		if _, err := secretManager.SuggestSecrets("key:(aws", 3); err != nil {
+			t.Errorf("expected search syntax to be ignored, got %v", err)
+		}
+	})
+}
+
+func TestBulkOperationsOnEachBackend(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, secretManager *manager.SecretManager) {
+		for _, key := range []string{"old-db", "old-api", "new-api"} {
+			encryptedValue, err := mycrypto.Encrypt(testPassphrase, key+"-value")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := secretManager.AddSecret(&models.Secret{Key: key, EncryptedValue: encryptedValue}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		old, err := secretManager.FindSecrets("key:old*")
+		if err != nil || len(old) != 2 {
+			t.Fatalf("expected 2 old secrets, got %v, %v", old, err)
+		}
+
+		// Tag and move together
+		for i := range old {
+			old[i].AddTag("legacy")
+			old[i].Folder = "archive"
+		}
+
+		if err := secretManager.UpdateSecrets(old); err != nil {
+			t.Fatal(err)
+		}
+
+		tagged, err := secretManager.FindSecrets("tag:legacy folder:archive")
+		if err != nil || len(tagged) != 2 {
+			t.Errorf("expected 2 tagged secrets, got %v, %v", tagged, err)
+		}
+
+		// A failing change leaves every secret and the index as they were
+		failing := []models.Secret{old[0], old[1]}
+		failing[0].Folder = "trash"
+		failing[1].Key = "new-api"
+
+		if err := secretManager.UpdateSecrets(failing); !errors.Is(err, models.ErrDuplicateKey) {
+			t.Errorf("expected ErrDuplicateKey, got %v", err)
+		}
+
+		moved, err := secretManager.FindSecrets("folder:trash")
+		if err != nil || len(moved) != 0 {
+			t.Errorf("expected the failed change to be rolled back, got %v, %v", moved, err)
+		}
+
+		// Re-encrypting keeps the values
+		before := old[0].EncryptedValue
+		if err := secretManager.ReencryptSecrets(old, testPassphrase); err != nil {
+			t.Fatal(err)
+		}
+
+		secret, err := secretManager.GetSecret(old[0].ID.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if secret.EncryptedValue == before {
+			t.Error("expected a new ciphertext")
+		}
+
+		value, err := mycrypto.Decrypt(testPassphrase, secret.EncryptedValue)
+		if err != nil || value != secret.Key+"-value" {
+			t.Errorf("expected the value to be kept, got %q, %v", value, err)
+		}
+
+		// Remove together
+		if err := secretManager.RemoveSecrets(old); err != nil {
+			t.Fatal(err)
+		}
+
+		secrets, err := secretManager.ListSecrets()
+		if err != nil || len(secrets) != 1 || secrets[0].Key != "new-api" {
+			t.Errorf("expected only new-api to be left, got %v, %v", secrets, err)
+		}
+
+		remaining, err := secretManager.FindSecrets("api")
+		if err != nil || len(remaining) != 1 {
+			t.Errorf("expected only new-api to be found, got %v, %v", remaining, err)
+		}
+	})
+}
+
+func TestTrashOnEachBackend(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, secretManager *manager.SecretManager) {
+		var secrets []models.Secret
+		for _, key := range []string{"old-token", "stale-token", "live-token"} {
+			encryptedValue, err := mycrypto.Encrypt(testPassphrase, key+"-value")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			secret := models.Secret{Key: key, EncryptedValue: encryptedValue}
+			if err := secretManager.AddSecret(&secret); err != nil {
+				t.Fatal(err)
+			}
+
+			secrets = append(secrets, secret)
+		}
+
+		if err := secretManager.RemoveSecrets(secrets[:2]); err != nil {
+			t.Fatal(err)
+		}
+
+		// Trashed secrets are left out of lookups, listings and searches
+		if _, err := secretManager.GetSecretByKey("old-token", manager.MatchExactKey); !errors.Is(err, models.ErrSecretNotFound) {
+			t.Errorf("expected ErrSecretNotFound, got %v", err)
+		}
+
+		live, err := secretManager.ListSecrets()
+		if err != nil || len(live) != 1 || live[0].Key != "live-token" {
+			t.Errorf("expected only live-token to be listed, got %v, %v", live, err)
+		}
+
+		found, err := secretManager.FindSecrets("token")
+		if err != nil || len(found) != 1 {
+			t.Errorf("expected only live-token to be found, got %v, %v", found, err)
+		}
+
+		trash, err := secretManager.ListTrash()
+		if err != nil || len(trash) != 2 {
+			t.Fatalf("expected 2 secrets in the trash, got %v, %v", trash, err)
+		}
+
+		// The keys stay taken
+		err = secretManager.AddSecret(&models.Secret{Key: "old-token", EncryptedValue: "xxx"})
+		if !errors.Is(err, models.ErrKeyInTrash) {
+			t.Errorf("expected ErrKeyInTrash, got %v", err)
+		}
+
+		live[0].Key = "stale-token"
+		if err := secretManager.UpdateSecret(&live[0]); !errors.Is(err, models.ErrKeyInTrash) {
+			t.Errorf("expected ErrKeyInTrash, got %v", err)
+		}
+
+		// Trashed secrets are re-encrypted with the rest
+		if err := secretManager.ChangePassphrase(testPassphrase, "new passphrase"); err != nil {
+			t.Fatal(err)
+		}
+
+		// Restoring brings a secret back as it was
+		restored, err := secretManager.GetTrashedSecretByKey("old-token")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := secretManager.RestoreSecret(restored); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := secretManager.RestoreSecret(restored); !errors.Is(err, models.ErrSecretNotFound) {
+			t.Errorf("expected restoring twice to fail with ErrSecretNotFound, got %v", err)
+		}
+
+		secret, err := secretManager.GetSecretByKey("old-token", manager.MatchExactKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		value, err := mycrypto.Decrypt("new passphrase", secret.EncryptedValue)
+		if err != nil || value != "old-token-value" {
+			t.Errorf("expected the value to be kept, got %q, %v", value, err)
+		}
+
+		if found, err := secretManager.FindSecrets("key:old-token"); err != nil || len(found) != 1 {
+			t.Errorf("expected the restored secret to be found, got %v, %v", found, err)
+		}
+
+		// Purging frees the key
+		trash, err = secretManager.ListTrash()
+		if err != nil || len(trash) != 1 || trash[0].Key != "stale-token" {
+			t.Fatalf("expected only stale-token in the trash, got %v, %v", trash, err)
+		}
+
+		if err := secretManager.PurgeSecrets(trash); err != nil {
+			t.Fatal(err)
+		}
+
+		if trash, err := secretManager.ListTrash(); err != nil || len(trash) != 0 {
+			t.Errorf("expected an empty trash, got %v, %v", trash, err)
+		}
+
+		if err := secretManager.AddSecret(&models.Secret{Key: "stale-token", EncryptedValue: "xxx"}); err != nil {
+			t.Errorf("expected the purged key to be free, got %v", err)
+		}
+	})
+}
+
+func TestTokensOnEachBackend(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, secretManager *manager.SecretManager) {
+		raw, err := secretManager.CreateToken(&models.APIToken{Name: "ci", ReadOnly: true, Folders: []string{"work"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := secretManager.CreateToken(&models.APIToken{Name: "ci"}); !errors.Is(err, models.ErrDuplicateTokenName) {
+			t.Errorf("expected ErrDuplicateTokenName, got %v", err)
+		}
+
+		token, err := secretManager.AuthenticateToken(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if token.Name != "ci" || !token.ReadOnly || len(token.Folders) != 1 {
+			t.Errorf("expected the scopes to be kept, got %+v", token)
+		}
+
+		// Only the hash is stored
+		tokens, err := secretManager.ListTokens()
+		if err != nil || len(tokens) != 1 || tokens[0].Hash == raw {
+			t.Errorf("expected one hashed token, got %v, %v", tokens, err)
+		}
+
+		if _, err := secretManager.AuthenticateToken(raw + "x"); !errors.Is(err, models.ErrTokenNotFound) {
+			t.Errorf("expected ErrTokenNotFound for a wrong token, got %v", err)
+		}
+
+		expiresAt := time.Now().Add(-time.Second)
+		expired, err := secretManager.CreateToken(&models.APIToken{Name: "old", ExpiresAt: &expiresAt})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := secretManager.AuthenticateToken(expired); !errors.Is(err, models.ErrTokenNotFound) {
+			t.Errorf("expected ErrTokenNotFound for an expired token, got %v", err)
+		}
+
+		if err := secretManager.RevokeToken("ci"); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := secretManager.AuthenticateToken(raw); !errors.Is(err, models.ErrTokenNotFound) {
+			t.Errorf("expected ErrTokenNotFound after revoking, got %v", err)
+		}
+
+		if err := secretManager.RevokeToken("ci"); !errors.Is(err, models.ErrTokenNotFound) {
+			t.Errorf("expected ErrTokenNotFound revoking twice, got %v", err)
+		}
+	})
+}
+
+func TestBackupOnEachBackend(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, secretManager *manager.SecretManager) {
+		encryptedValue, err := mycrypto.Encrypt(testPassphrase, "s3cret")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		added, err := secretManager.Attachments().Add(testPassphrase, "tls.key", strings.NewReader("private key"), 1<<20)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		secret := &models.Secret{Key: "prod-tls", EncryptedValue: encryptedValue, Attachments: []models.Attachment{*added}}
+		if err := secretManager.AddSecret(secret); err != nil {
+			t.Fatal(err)
+		}
+
+		var backup bytes.Buffer
+		if count, err := secretManager.Backup(&backup, testPassphrase); err != nil || count != 1 {
+			t.Fatalf("expected 1 secret backed up, got %d, %v", count, err)
+		}
+
+		// Restore into an empty vault elsewhere
+		restored, err := manager.NewFileSecretManager(filepath.Join(t.TempDir(), "vault.myst"), testPassphrase)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer restored.Close()
+
+		if _, err := restored.RestoreBackup(bytes.NewReader(backup.Bytes()), "wrong passphrase"); err == nil {
+			t.Error("expected an error for the wrong passphrase")
+		}
+
+		if count, err := restored.RestoreBackup(bytes.NewReader(backup.Bytes()), testPassphrase); err != nil || count != 1 {
+			t.Fatalf("expected 1 secret restored, got %d, %v", count, err)
+		}
+
+		got, err := restored.GetSecretByKey("prod-tls", manager.MatchExactKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var contents bytes.Buffer
+		if _, err := restored.Attachments().Open(testPassphrase, &got.Attachments[0], &contents); err != nil || contents.String() != "private key" {
+			t.Errorf("expected the attachment to be restored, got %q, %v", contents.String(), err)
+		}
+
+		// Secrets already in the vault are left alone
+		if count, err := restored.RestoreBackup(bytes.NewReader(backup.Bytes()), testPassphrase); err != nil || count != 0 {
+			t.Errorf("expected nothing restored again, got %d, %v", count, err)
+		}
+
+		if _, err := restored.RestoreBackup(strings.NewReader("not a backup"), testPassphrase); !errors.Is(err, manager.ErrInvalidBackup) {
+			t.Errorf("expected ErrInvalidBackup, got %v", err)
+		}
+	})
+}
+
+func TestPurgeDeletesAttachments(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, secretManager *manager.SecretManager) {
+		added, err := secretManager.Attachments().Add(testPassphrase, "tls.key", strings.NewReader("private key"), 1<<20)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		secret := &models.Secret{Key: "prod-tls", EncryptedValue: "xxx", Attachments: []models.Attachment{*added}}
+		if err := secretManager.AddSecret(secret); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := secretManager.RemoveSecret(secret); err != nil {
+			t.Fatal(err)
+		}
+
+		// The files stay in the trash with their secret
+		path := secretManager.Attachments().Path(added.ID)
+		if _, err := os.Stat(path); err != nil {
+			t.Fatalf("expected the attachment to be kept in the trash, got %v", err)
+		}
+
+		// Purging goes by the stored attachments, not those passed in
+		if err := secretManager.PurgeSecrets([]models.Secret{{ID: secret.ID, Key: secret.Key}}); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("expected the attachment to be deleted, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,97 @@
+package manager
+
+import (
+	"github.com/Isaac-Fate/myst/internal/database"
+	"github.com/Isaac-Fate/myst/internal/models"
+	"gorm.io/gorm"
+)
+
+// A secret store backed by the SQLite database.
+type sqliteStore struct {
+	db *gorm.DB
+}
+
+// Opens the SQLite secret store at path.
+func OpenSQLiteStore(path string) (SecretStore, error) {
+	db, err := database.OpenSecretStore(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sqliteStore{db: db}, nil
+}
+
+func (store *sqliteStore) Transaction(fn func(store SecretStore) error) error {
+	return store.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&sqliteStore{db: tx})
+	})
+}
+
+func (store *sqliteStore) AddSecret(secret *models.Secret) error {
+	return database.AddSecret(store.db, secret)
+}
+
+func (store *sqliteStore) UpdateSecret(secret *models.Secret) error {
+	return database.UpdateSecret(store.db, secret)
+}
+
+func (store *sqliteStore) ImportSecret(secret *models.Secret) error {
+	return database.ImportSecret(store.db, secret)
+}
+
+func (store *sqliteStore) RemoveSecret(secret *models.Secret) error {
+	return database.RemoveSecret(store.db, secret)
+}
+
+func (store *sqliteStore) GetSecret(id string) (*models.Secret, error) {
+	return database.GetSecret(store.db, id)
+}
+
+func (store *sqliteStore) GetSecretByKey(key string) (*models.Secret, error) {
+	return database.GetSecretByKey(store.db, key)
+}
+
+func (store *sqliteStore) GetSecrets(ids []string) ([]models.Secret, error) {
+	return database.GetSecrets(store.db, ids)
+}
+
+func (store *sqliteStore) ListSecrets() ([]models.Secret, error) {
+	return database.ListSecrets(store.db)
+}
+
+func (store *sqliteStore) ListTrash() ([]models.Secret, error) {
+	return database.ListTrash(store.db)
+}
+
+func (store *sqliteStore) RestoreSecret(secret *models.Secret) error {
+	return database.RestoreSecret(store.db, secret)
+}
+
+func (store *sqliteStore) PurgeSecret(secret *models.Secret) error {
+	return database.PurgeSecret(store.db, secret)
+}
+
+func (store *sqliteStore) AddToken(token *models.APIToken) error {
+	return database.AddToken(store.db, token)
+}
+
+func (store *sqliteStore) ListTokens() ([]models.APIToken, error) {
+	return database.ListTokens(store.db)
+}
+
+func (store *sqliteStore) GetTokenByHash(hash string) (*models.APIToken, error) {
+	return database.GetTokenByHash(store.db, hash)
+}
+
+func (store *sqliteStore) RemoveToken(token *models.APIToken) error {
+	return database.RemoveToken(store.db, token)
+}
+
+func (store *sqliteStore) Close() error {
+	sqlDB, err := store.db.DB()
+	if err != nil {
+		return err
+	}
+
+	return sqlDB.Close()
+}
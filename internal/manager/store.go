@@ -0,0 +1,120 @@
+package manager
+
+import (
+	"github.com/Isaac-Fate/myst/internal/models"
+	"github.com/Isaac-Fate/myst/internal/search"
+)
+
+// Persists secrets.
+//
+// The SQLite database and the single encrypted vault file both implement
+// this interface. A store returned to the function passed to Transaction
+// must only be used inside that function.
+type SecretStore interface {
+	// Runs fn in a transaction.
+	//
+	// The changes made through the store passed to fn are committed if fn
+	// returns nil and rolled back otherwise.
+	Transaction(fn func(store SecretStore) error) error
+
+	// Adds a new secret.
+	//
+	// It returns models.ErrDuplicateKey if a secret with the same key exists.
+	AddSecret(secret *models.Secret) error
+
+	// Saves all fields of an existing secret.
+	UpdateSecret(secret *models.Secret) error
+
+	// Saves a secret as it is, creating it if it does not exist.
+	//
+	// Unlike UpdateSecret, the timestamps of the secret are kept. It is used
+	// to apply secrets that were changed on another device.
+	ImportSecret(secret *models.Secret) error
+
+	// Moves a secret to the trash by its ID.
+	//
+	// Secrets in the trash are left out of every other method except
+	// ImportSecret, but their keys stay taken.
+	RemoveSecret(secret *models.Secret) error
+
+	// Lists the secrets in the trash, the most recently removed first.
+	ListTrash() ([]models.Secret, error)
+
+	// Takes a secret out of the trash, updating its fields.
+	//
+	// It returns models.ErrSecretNotFound if the secret is not in the trash.
+	RestoreSecret(secret *models.Secret) error
+
+	// Deletes a secret in the trash for good.
+	PurgeSecret(secret *models.Secret) error
+
+	// Gets a secret by its ID.
+	//
+	// It returns models.ErrSecretNotFound if there is no such secret.
+	GetSecret(id string) (*models.Secret, error)
+
+	// Gets a secret by its key, compared exactly.
+	//
+	// It returns models.ErrSecretNotFound if there is no such secret.
+	GetSecretByKey(key string) (*models.Secret, error)
+
+	// Gets the secrets with the given IDs, in the same order as the IDs.
+	// Unknown IDs are ignored.
+	GetSecrets(ids []string) ([]models.Secret, error)
+
+	// Lists all secrets.
+	ListSecrets() ([]models.Secret, error)
+
+	// Adds a new API token.
+	//
+	// It returns models.ErrDuplicateTokenName if a token with the same name
+	// exists.
+	AddToken(token *models.APIToken) error
+
+	// Lists all API tokens, oldest first.
+	ListTokens() ([]models.APIToken, error)
+
+	// Gets an API token by the hash of the token.
+	//
+	// It returns models.ErrTokenNotFound if there is no such token.
+	GetTokenByHash(hash string) (*models.APIToken, error)
+
+	// Deletes an API token by its ID.
+	//
+	// It returns models.ErrTokenNotFound if there is no such token.
+	RemoveToken(token *models.APIToken) error
+
+	// Releases the resources held by the store.
+	Close() error
+}
+
+// Finds secrets by their searchable fields.
+//
+// The index only holds the fields needed for searching and never the
+// encrypted value. Documents are identified by secret ID.
+type SecretIndex interface {
+	// Adds a secret to the index.
+	AddSecret(secret *models.Secret) error
+
+	// Replaces the indexed document of a secret.
+	UpdateSecret(secret *models.Secret) error
+
+	// Removes a secret from the index.
+	RemoveSecret(secret *models.Secret) error
+
+	// Searches the index, returning the best hits first.
+	Search(query string, options search.Options) (*search.Result, error)
+
+	// Returns the number of indexed secrets.
+	Count() (uint64, error)
+
+	// Releases the resources held by the index.
+	Close() error
+}
+
+// Implemented by stores that are themselves encrypted with the master
+// passphrase, such as the vault file.
+type passphraseStore interface {
+	// Encrypts the store with a new passphrase when the transaction commits.
+	SetPassphrase(passphrase string)
+}
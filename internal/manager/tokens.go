@@ -0,0 +1,70 @@
+package manager
+
+import (
+	"time"
+
+	mycrypto "github.com/Isaac-Fate/myst/internal/crypto"
+	"github.com/Isaac-Fate/myst/internal/models"
+	"github.com/google/uuid"
+)
+
+// Creates an API token with the name and scopes of the given token, and
+// returns the token itself.
+//
+// Only the hash of the token is stored, so the returned token must be
+// handed to the user right away.
+func (manager *SecretManager) CreateToken(token *models.APIToken) (string, error) {
+	raw, err := mycrypto.GenerateToken()
+	if err != nil {
+		return "", err
+	}
+
+	token.ID = uuid.New()
+	token.Hash = mycrypto.HashToken(raw)
+
+	if err := manager.store.AddToken(token); err != nil {
+		return "", err
+	}
+
+	return raw, nil
+}
+
+// Lists all API tokens, oldest first.
+func (manager *SecretManager) ListTokens() ([]models.APIToken, error) {
+	return manager.store.ListTokens()
+}
+
+// Deletes the API token with the name.
+//
+// It returns models.ErrTokenNotFound if there is no such token.
+func (manager *SecretManager) RevokeToken(name string) error {
+	tokens, err := manager.store.ListTokens()
+	if err != nil {
+		return err
+	}
+
+	for i := range tokens {
+		if tokens[i].Name == name {
+			return manager.store.RemoveToken(&tokens[i])
+		}
+	}
+
+	return models.ErrTokenNotFound
+}
+
+// Gets the API token matching the token a client presented.
+//
+// It returns models.ErrTokenNotFound if there is no such token or it has
+// expired, so that clients cannot tell the two apart.
+func (manager *SecretManager) AuthenticateToken(raw string) (*models.APIToken, error) {
+	token, err := manager.store.GetTokenByHash(mycrypto.HashToken(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	if token.Expired(time.Now()) {
+		return nil, models.ErrTokenNotFound
+	}
+
+	return token, nil
+}
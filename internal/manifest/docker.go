@@ -0,0 +1,32 @@
+package manifest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Writes every entry to a file named after it in the directory, holding
+// only the value, as docker secrets and the secrets of compose files expect.
+// The directory is created if needed, and only the current user can read
+// the files.
+func WriteSecretFiles(dir string, entries []Entry) error {
+	for _, entry := range entries {
+		if err := ValidateDataKey(entry.Name); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name)
+		if err := os.WriteFile(path, []byte(entry.Value), 0600); err != nil {
+			return fmt.Errorf("failed to write '%s': %w", path, err)
+		}
+	}
+
+	return nil
+}
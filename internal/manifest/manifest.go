@@ -0,0 +1,149 @@
+package manifest
+
+import (
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Renders secrets as manifests for container platforms: Kubernetes Secrets,
+// SealedSecrets that only the cluster can decrypt, and files for docker
+// secrets.
+
+// The type of Kubernetes Secrets holding arbitrary values.
+const OpaqueType string = "Opaque"
+
+// Names of Kubernetes objects, as DNS subdomains.
+var objectNamePattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`)
+
+// Keys of the data of Kubernetes Secrets, which are also fine as file names.
+var dataKeyPattern = regexp.MustCompile(`^[-._a-zA-Z0-9]+$`)
+
+// A value to put in a manifest under a name.
+type Entry struct {
+	Name  string
+	Value string
+}
+
+// Parses an entry given as "name=secret-key", or as just the key, which is
+// then the name too. It returns the name and the key of the secret.
+func ParseFrom(from string) (string, string) {
+	if name, key, found := strings.Cut(from, "="); found {
+		return name, key
+	}
+
+	return from, from
+}
+
+// Checks that the name is valid for a Kubernetes object.
+func ValidateObjectName(name string) error {
+	if len(name) > 253 || !objectNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid name '%s': use lowercase letters, digits, '-' and '.'", name)
+	}
+
+	return nil
+}
+
+// Checks that the name is valid as a key of Secret data or a file name.
+func ValidateDataKey(name string) error {
+	if name == "." || name == ".." || len(name) > 253 || !dataKeyPattern.MatchString(name) {
+		return fmt.Errorf("invalid data key '%s': use letters, digits, '-', '_' and '.', or rename it with name=key", name)
+	}
+
+	return nil
+}
+
+// Options of a Kubernetes Secret.
+type SecretOptions struct {
+	Namespace string
+
+	// The type of the Secret, or Opaque if empty
+	Type string
+
+	// Puts the values in plaintext under stringData instead of base64
+	// encoded under data
+	StringData bool
+}
+
+type objectMeta struct {
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace,omitempty"`
+}
+
+type secretManifest struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   objectMeta        `yaml:"metadata"`
+	Type       string            `yaml:"type"`
+	Data       map[string]string `yaml:"data,omitempty"`
+	StringData map[string]string `yaml:"stringData,omitempty"`
+}
+
+// Renders a v1 Secret holding the entries as YAML.
+func KubernetesSecret(name string, entries []Entry, options SecretOptions) ([]byte, error) {
+	if err := validate(name, options.Namespace, entries); err != nil {
+		return nil, err
+	}
+
+	manifest := secretManifest{
+		APIVersion: "v1",
+		Kind:       "Secret",
+		Metadata:   objectMeta{Name: name, Namespace: options.Namespace},
+		Type:       options.Type,
+	}
+
+	if manifest.Type == "" {
+		manifest.Type = OpaqueType
+	}
+
+	values := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		if options.StringData {
+			values[entry.Name] = entry.Value
+		} else {
+			values[entry.Name] = base64.StdEncoding.EncodeToString([]byte(entry.Value))
+		}
+	}
+
+	if options.StringData {
+		manifest.StringData = values
+	} else {
+		manifest.Data = values
+	}
+
+	return yaml.Marshal(&manifest)
+}
+
+// Checks the name and namespace of a manifest and the names of its entries.
+func validate(name string, namespace string, entries []Entry) error {
+	if err := ValidateObjectName(name); err != nil {
+		return err
+	}
+
+	if namespace != "" {
+		if err := ValidateObjectName(namespace); err != nil {
+			return fmt.Errorf("invalid namespace: %w", err)
+		}
+	}
+
+	if len(entries) == 0 {
+		return fmt.Errorf("no values to put in secret '%s'", name)
+	}
+
+	names := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		if err := ValidateDataKey(entry.Name); err != nil {
+			return err
+		}
+
+		if names[entry.Name] {
+			return fmt.Errorf("data key '%s' is given twice", entry.Name)
+		}
+		names[entry.Name] = true
+	}
+
+	return nil
+}
@@ -0,0 +1,215 @@
+package manifest_test
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Isaac-Fate/myst/internal/manifest"
+	"gopkg.in/yaml.v3"
+)
+
+var entries = []manifest.Entry{
+	{Name: "DB_PASSWORD", Value: "hunter2"},
+	{Name: "api-key", Value: "abc\n123"},
+}
+
+func TestKubernetesSecret(t *testing.T) {
+	content, err := manifest.KubernetesSecret("app-secrets", entries, manifest.SecretOptions{Namespace: "dev"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `apiVersion: v1
+kind: Secret
+metadata:
+    name: app-secrets
+    namespace: dev
+type: Opaque
+data:
+    DB_PASSWORD: aHVudGVyMg==
+    api-key: YWJjCjEyMw==
+`
+	if string(content) != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, content)
+	}
+
+	content, err = manifest.KubernetesSecret("app-secrets", entries, manifest.SecretOptions{StringData: true, Type: "kubernetes.io/basic-auth"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var secret struct {
+		Metadata   map[string]string `yaml:"metadata"`
+		Type       string            `yaml:"type"`
+		StringData map[string]string `yaml:"stringData"`
+	}
+	if err := yaml.Unmarshal(content, &secret); err != nil {
+		t.Fatal(err)
+	}
+	if secret.StringData["api-key"] != "abc\n123" || secret.Type != "kubernetes.io/basic-auth" || secret.Metadata["namespace"] != "" {
+		t.Errorf("expected the values as string data, got %s", content)
+	}
+}
+
+func TestInvalidManifests(t *testing.T) {
+	for _, test := range []struct {
+		name      string
+		namespace string
+		entries   []manifest.Entry
+	}{
+		{"App_Secrets", "", entries},
+		{"app-secrets", "Dev", entries},
+		{"app-secrets", "", nil},
+		{"app-secrets", "", []manifest.Entry{{Name: "work/db", Value: "x"}}},
+		{"app-secrets", "", []manifest.Entry{{Name: "db", Value: "x"}, {Name: "db", Value: "y"}}},
+	} {
+		if _, err := manifest.KubernetesSecret(test.name, test.entries, manifest.SecretOptions{Namespace: test.namespace}); err == nil {
+			t.Errorf("expected an error for %+v", test)
+		}
+	}
+}
+
+func TestParseFrom(t *testing.T) {
+	if name, key := manifest.ParseFrom("DB_PASSWORD=work/db-password"); name != "DB_PASSWORD" || key != "work/db-password" {
+		t.Errorf("expected a renamed key, got %s, %s", name, key)
+	}
+
+	if name, key := manifest.ParseFrom("db-password"); name != "db-password" || key != "db-password" {
+		t.Errorf("expected the key as the name, got %s, %s", name, key)
+	}
+}
+
+// Decrypts a value as the sealed secrets controller does.
+func unseal(t *testing.T, privateKey *rsa.PrivateKey, ciphertext []byte, label []byte) string {
+	length := int(binary.BigEndian.Uint16(ciphertext))
+	sessionKey, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, privateKey, ciphertext[2:2+length], label)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block, err := aes.NewCipher(sessionKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plaintext, err := aead.Open(nil, make([]byte, aead.NonceSize()), ciphertext[2+length:], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(plaintext)
+}
+
+func TestSealedSecret(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A self-signed certificate, like the one of the controller
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "sealed-secret"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &privateKey.PublicKey, privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	publicKey, err := manifest.ParsePublicKey(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := manifest.SealedSecret("app-secrets", entries, manifest.SecretOptions{}, publicKey); err == nil {
+		t.Error("expected an error without a namespace")
+	}
+
+	content, err := manifest.SealedSecret("app-secrets", entries, manifest.SecretOptions{Namespace: "dev"}, publicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sealed struct {
+		Kind string `yaml:"kind"`
+		Spec struct {
+			EncryptedData map[string]string `yaml:"encryptedData"`
+			Template      struct {
+				Metadata map[string]string `yaml:"metadata"`
+			} `yaml:"template"`
+		} `yaml:"spec"`
+	}
+	if err := yaml.Unmarshal(content, &sealed); err != nil {
+		t.Fatal(err)
+	}
+
+	if sealed.Kind != "SealedSecret" || sealed.Spec.Template.Metadata["namespace"] != "dev" {
+		t.Errorf("expected a SealedSecret for the namespace, got %s", content)
+	}
+
+	for _, entry := range entries {
+		ciphertext, err := base64.StdEncoding.DecodeString(sealed.Spec.EncryptedData[entry.Name])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if value := unseal(t, privateKey, ciphertext, []byte("dev/app-secrets")); value != entry.Value {
+			t.Errorf("expected %q once unsealed, got %q", entry.Value, value)
+		}
+	}
+
+	// Public keys can be given directly too
+	der, err = x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manifest.ParsePublicKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := manifest.ParsePublicKey([]byte("not PEM")); err == nil {
+		t.Error("expected an error for an invalid public key")
+	}
+}
+
+func TestWriteSecretFiles(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "secrets")
+
+	if err := manifest.WriteSecretFiles(dir, entries); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, "api-key"))
+	if err != nil || string(content) != "abc\n123" {
+		t.Errorf("expected the value alone, got %q, %v", content, err)
+	}
+
+	info, err := os.Stat(filepath.Join(dir, "DB_PASSWORD"))
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("expected a file only the user can read, got %v, %v", info, err)
+	}
+
+	if err := manifest.WriteSecretFiles(dir, []manifest.Entry{{Name: "../escape", Value: "x"}}); err == nil {
+		t.Error("expected an error for a name outside the directory")
+	}
+}
@@ -0,0 +1,158 @@
+package manifest
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SealedSecrets, see https://github.com/bitnami-labs/sealed-secrets, are
+// encrypted with the public key of the controller in the cluster, so that
+// only it can turn them back into Secrets. They are safe to commit.
+//
+// Every value is encrypted as kubeseal does: with a fresh AES-256-GCM
+// session key, itself encrypted with RSA-OAEP, labelled with the namespace
+// and name of the Secret so that it cannot be moved to another.
+
+// The size of the AES session keys.
+const sessionKeyBytes int = 32
+
+// Reads the public key of a sealed secrets controller from PEM, either its
+// certificate, as printed by 'kubeseal --fetch-cert', or its RSA public key.
+func ParsePublicKey(content []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, errors.New("no PEM block found in the public key")
+	}
+
+	var publicKey any
+	switch block.Type {
+	case "CERTIFICATE":
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the certificate: %w", err)
+		}
+		publicKey = certificate.PublicKey
+	case "PUBLIC KEY":
+		var err error
+		publicKey, err = x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the public key: %w", err)
+		}
+	case "RSA PUBLIC KEY":
+		var err error
+		publicKey, err = x509.ParsePKCS1PublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse the public key: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unexpected PEM block '%s', expected a certificate or a public key", block.Type)
+	}
+
+	rsaKey, ok := publicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("the public key is not an RSA key")
+	}
+
+	return rsaKey, nil
+}
+
+type sealedTemplate struct {
+	Metadata objectMeta `yaml:"metadata"`
+	Type     string     `yaml:"type"`
+}
+
+type sealedSpec struct {
+	EncryptedData map[string]string `yaml:"encryptedData"`
+	Template      sealedTemplate    `yaml:"template"`
+}
+
+type sealedManifest struct {
+	APIVersion string     `yaml:"apiVersion"`
+	Kind       string     `yaml:"kind"`
+	Metadata   objectMeta `yaml:"metadata"`
+	Spec       sealedSpec `yaml:"spec"`
+}
+
+// Renders a SealedSecret holding the entries, encrypted with the public key
+// of the controller, as YAML. The namespace is required, as the values are
+// bound to it.
+func SealedSecret(name string, entries []Entry, options SecretOptions, publicKey *rsa.PublicKey) ([]byte, error) {
+	if options.Namespace == "" {
+		return nil, errors.New("a namespace is needed to seal a secret")
+	}
+
+	if err := validate(name, options.Namespace, entries); err != nil {
+		return nil, err
+	}
+
+	secretType := options.Type
+	if secretType == "" {
+		secretType = OpaqueType
+	}
+
+	metadata := objectMeta{Name: name, Namespace: options.Namespace}
+	manifest := sealedManifest{
+		APIVersion: "bitnami.com/v1alpha1",
+		Kind:       "SealedSecret",
+		Metadata:   metadata,
+		Spec: sealedSpec{
+			EncryptedData: make(map[string]string, len(entries)),
+			Template:      sealedTemplate{Metadata: metadata, Type: secretType},
+		},
+	}
+
+	label := []byte(options.Namespace + "/" + name)
+	for _, entry := range entries {
+		ciphertext, err := hybridEncrypt(rand.Reader, publicKey, []byte(entry.Value), label)
+		if err != nil {
+			return nil, fmt.Errorf("failed to seal '%s': %w", entry.Name, err)
+		}
+
+		manifest.Spec.EncryptedData[entry.Name] = base64.StdEncoding.EncodeToString(ciphertext)
+	}
+
+	return yaml.Marshal(&manifest)
+}
+
+// Encrypts the plaintext as kubeseal does: the length of the encrypted
+// session key as 2 big-endian bytes, the session key encrypted with
+// RSA-OAEP and the label, then the plaintext encrypted with AES-GCM and a
+// zero nonce, which is safe as every session key is used once.
+func hybridEncrypt(random io.Reader, publicKey *rsa.PublicKey, plaintext []byte, label []byte) ([]byte, error) {
+	sessionKey := make([]byte, sessionKeyBytes)
+	if _, err := io.ReadFull(random, sessionKey); err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(sessionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	encryptedKey, err := rsa.EncryptOAEP(sha256.New(), random, publicKey, sessionKey, label)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext := binary.BigEndian.AppendUint16(nil, uint16(len(encryptedKey)))
+	ciphertext = append(ciphertext, encryptedKey...)
+
+	return aead.Seal(ciphertext, make([]byte, aead.NonceSize()), plaintext, nil), nil
+}
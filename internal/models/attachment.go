@@ -0,0 +1,41 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// Returned when a secret has no attachment with the name.
+var ErrAttachmentNotFound = errors.New("attachment not found")
+
+// Returned when a secret already has an attachment with the name.
+var ErrDuplicateAttachment = errors.New("secret already has an attachment with this name")
+
+// A file kept with a secret, such as a certificate or a kubeconfig.
+//
+// The contents are encrypted with a random data key in a file of their own,
+// named by the ID, while the data key is encrypted with the passphrase and
+// kept here, so changing the passphrase only re-encrypts the key.
+type Attachment struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+
+	// Size of the contents in bytes
+	Size int64 `json:"size"`
+
+	// The data key of the contents, encrypted with the passphrase
+	EncryptedKey string `json:"encrypted_key"`
+
+	AddedAt time.Time `json:"added_at"`
+}
+
+// Returns the attachment with the name.
+func (secret *Secret) Attachment(name string) (*Attachment, error) {
+	for i := range secret.Attachments {
+		if secret.Attachments[i].Name == name {
+			return &secret.Attachments[i], nil
+		}
+	}
+
+	return nil, ErrAttachmentNotFound
+}
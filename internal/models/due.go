@@ -0,0 +1,58 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+// Why a secret needs attention by a date.
+type DueReason string
+
+const (
+	// The value stops working
+	DueExpiry DueReason = "expiry"
+
+	// The value should be replaced
+	DueRotation DueReason = "rotation"
+)
+
+// A date by which a secret needs attention.
+type Deadline struct {
+	Reason DueReason
+	At     time.Time
+}
+
+// Reports whether the deadline has passed.
+func (deadline Deadline) Passed(now time.Time) bool {
+	return !deadline.At.After(now)
+}
+
+// Returns when the secret expires and when its value should next be rotated,
+// the earliest first.
+func (secret *Secret) Deadlines() []Deadline {
+	var deadlines []Deadline
+
+	if secret.ExpiresAt != nil {
+		deadlines = append(deadlines, Deadline{Reason: DueExpiry, At: *secret.ExpiresAt})
+	}
+
+	if secret.RotateEvery > 0 {
+		deadlines = append(deadlines, Deadline{Reason: DueRotation, At: secret.ValueChangedAt().Add(secret.RotateEvery)})
+	}
+
+	slices.SortFunc(deadlines, func(a, b Deadline) int {
+		return a.At.Compare(b.At)
+	})
+
+	return deadlines
+}
+
+// Returns the deadlines of the secret that have passed or are at most the
+// duration away, the earliest first.
+func (secret *Secret) DueWithin(now time.Time, within time.Duration) []Deadline {
+	cutoff := now.Add(within)
+
+	return slices.DeleteFunc(secret.Deadlines(), func(deadline Deadline) bool {
+		return deadline.At.After(cutoff)
+	})
+}
@@ -0,0 +1,22 @@
+package models
+
+import "errors"
+
+// Returned when a secret cannot be found in the secret store.
+var ErrSecretNotFound = errors.New("secret not found")
+
+// Returned when a secret with the same key already exists in the secret store.
+var ErrDuplicateKey = errors.New("secret with this key already exists")
+
+// Returned when a secret with the same key is in the trash. The key stays
+// taken until the secret is purged, so the secret can always be restored.
+var ErrKeyInTrash = errors.New("secret with this key is in the trash")
+
+// Returned when a key matches several secrets, such as when ignoring case.
+var ErrAmbiguousKey = errors.New("key matches several secrets")
+
+// Returned when an API token cannot be found.
+var ErrTokenNotFound = errors.New("token not found")
+
+// Returned when an API token with the same name already exists.
+var ErrDuplicateTokenName = errors.New("token with this name already exists")
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/Isaac-Fate/myst/internal/models"
 	"github.com/joho/godotenv"
@@ -57,3 +58,77 @@ func getTestSecretStorePath() (string, error) {
 
 	return secretStorePath, nil
 }
+
+func TestTags(t *testing.T) {
+	tags, err := models.ParseTags(" prod, aws,, prod ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tags) != 2 || tags[0] != "aws" || tags[1] != "prod" {
+		t.Errorf("expected [aws prod], got %v", tags)
+	}
+
+	if _, err := models.ParseTags("prod, old stuff"); err == nil {
+		t.Error("expected tags with spaces to be rejected")
+	}
+
+	secret := models.Secret{Tags: tags}
+
+	if !secret.AddTag("ci") || secret.AddTag("ci") {
+		t.Error("expected ci to be added once")
+	}
+
+	if !secret.HasTag("ci") || secret.HasTag("old") {
+		t.Errorf("unexpected tags %v", secret.Tags)
+	}
+
+	if !secret.RemoveTag("aws") || secret.RemoveTag("aws") {
+		t.Error("expected aws to be removed once")
+	}
+
+	if len(secret.Tags) != 2 || secret.Tags[0] != "ci" || secret.Tags[1] != "prod" {
+		t.Errorf("expected [ci prod], got %v", secret.Tags)
+	}
+}
+
+func TestDeadlines(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	expiresAt := now.Add(10 * day)
+	secret := models.Secret{
+		ExpiresAt:   &expiresAt,
+		RotateEvery: 90 * day,
+		UpdatedAt:   now.Add(-85 * day),
+	}
+
+	deadlines := secret.Deadlines()
+	if len(deadlines) != 2 || deadlines[0].Reason != models.DueRotation || deadlines[1].Reason != models.DueExpiry {
+		t.Fatalf("expected rotation then expiry, got %v", deadlines)
+	}
+
+	if !deadlines[0].At.Equal(now.Add(5 * day)) {
+		t.Errorf("expected rotation 90 days after the update, got %v", deadlines[0].At)
+	}
+
+	if due := secret.DueWithin(now, 7*day); len(due) != 1 || due[0].Reason != models.DueRotation {
+		t.Errorf("expected only the rotation within a week, got %v", due)
+	}
+
+	if due := secret.DueWithin(now, 14*day); len(due) != 2 {
+		t.Errorf("expected both within two weeks, got %v", due)
+	}
+
+	if due := secret.DueWithin(now, 0); len(due) != 0 {
+		t.Errorf("expected nothing due now, got %v", due)
+	}
+
+	if due := secret.DueWithin(now.Add(11*day), 0); len(due) != 2 || !due[1].Passed(now.Add(11*day)) {
+		t.Errorf("expected both to have passed, got %v", due)
+	}
+
+	if deadlines := (&models.Secret{UpdatedAt: now}).Deadlines(); len(deadlines) != 0 {
+		t.Errorf("expected no deadlines without expiry or rotation, got %v", deadlines)
+	}
+}
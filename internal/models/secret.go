@@ -4,25 +4,102 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Kinds of values with a special meaning.
+const (
+	// An SSH private key in the OpenSSH format, served by 'myst ssh-agent'
+	KindSSHKey string = "ssh-key"
 )
 
 type Secret struct {
 	ID             uuid.UUID `gorm:"type:uuid;primaryKey"`
 	Key            string    `gorm:"unique"`
 	EncryptedValue string    `gorm:"not null"`
-	Website        string
-	Notes          string
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
+
+	// What the value is, such as KindSSHKey, or empty for a password or any
+	// other value
+	Kind string
+
+	// Optional login name the value belongs to
+	Username string
+
+	Website string
+	Notes   string
+
+	// Optional folder used to group related secrets
+	Folder string
+
+	// Labels for finding and acting on secrets together, kept sorted
+	Tags []string `gorm:"serializer:json"`
+
+	// Names of the teammates the secret is shared with by default
+	Recipients []string `gorm:"serializer:json"`
+
+	// Files kept with the secret
+	Attachments []Attachment `gorm:"serializer:json"`
+
+	// When the value stops working, such as for an API token, if it does
+	ExpiresAt *time.Time
+
+	// How often the value should be replaced, counted from the last change
+	// of the value, or zero if it need not be
+	RotateEvery time.Duration
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	// When the value last changed, unlike UpdatedAt, which changes with any
+	// field and when the value is only encrypted again. Zero for secrets
+	// saved before it was tracked, see ValueChangedAt.
+	ValueUpdatedAt time.Time
+
+	// When the secret was moved to the trash, if it was
+	DeletedAt gorm.DeletedAt `gorm:"index"`
 }
 
 func (secret *Secret) OmitEncryptedValue() Secret {
 	return Secret{
-		ID:        secret.ID,
-		Key:       secret.Key,
-		Website:   secret.Website,
-		Notes:     secret.Notes,
-		CreatedAt: secret.CreatedAt,
-		UpdatedAt: secret.UpdatedAt,
+		ID:             secret.ID,
+		Key:            secret.Key,
+		Kind:           secret.Kind,
+		Username:       secret.Username,
+		Website:        secret.Website,
+		Notes:          secret.Notes,
+		Folder:         secret.Folder,
+		Tags:           secret.Tags,
+		Recipients:     secret.Recipients,
+		Attachments:    omitAttachmentKeys(secret.Attachments),
+		ExpiresAt:      secret.ExpiresAt,
+		RotateEvery:    secret.RotateEvery,
+		CreatedAt:      secret.CreatedAt,
+		UpdatedAt:      secret.UpdatedAt,
+		ValueUpdatedAt: secret.ValueUpdatedAt,
+		DeletedAt:      secret.DeletedAt,
+	}
+}
+
+// Returns when the value last changed, or when the secret was last updated
+// if it was saved before changes of the value were tracked.
+func (secret *Secret) ValueChangedAt() time.Time {
+	if secret.ValueUpdatedAt.IsZero() {
+		return secret.UpdatedAt
+	}
+
+	return secret.ValueUpdatedAt
+}
+
+func omitAttachmentKeys(attachments []Attachment) []Attachment {
+	if attachments == nil {
+		return nil
 	}
+
+	result := make([]Attachment, len(attachments))
+	for i, attachment := range attachments {
+		attachment.EncryptedKey = ""
+		result[i] = attachment
+	}
+
+	return result
 }
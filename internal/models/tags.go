@@ -0,0 +1,73 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Checks that the tag can be typed in a query and a comma-separated list.
+func ValidateTag(tag string) error {
+	if tag == "" {
+		return fmt.Errorf("tag cannot be empty")
+	}
+
+	if i := strings.IndexFunc(tag, func(r rune) bool {
+		return unicode.IsSpace(r) || strings.ContainsRune(`,:"()`, r)
+	}); i >= 0 {
+		return fmt.Errorf("tag '%s' cannot contain '%c'", tag, []rune(tag[i:])[0])
+	}
+
+	return nil
+}
+
+// Parses a comma-separated list of tags, ignoring blanks and duplicates.
+func ParseTags(input string) ([]string, error) {
+	var tags []string
+
+	for _, tag := range strings.Split(input, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+
+		if err := ValidateTag(tag); err != nil {
+			return nil, err
+		}
+
+		tags = append(tags, tag)
+	}
+
+	slices.Sort(tags)
+
+	return slices.Compact(tags), nil
+}
+
+// Reports whether the secret has the tag.
+func (secret *Secret) HasTag(tag string) bool {
+	_, found := slices.BinarySearch(secret.Tags, tag)
+	return found
+}
+
+// Adds the tag, keeping the tags sorted, and reports whether it was missing.
+func (secret *Secret) AddTag(tag string) bool {
+	i, found := slices.BinarySearch(secret.Tags, tag)
+	if found {
+		return false
+	}
+
+	secret.Tags = slices.Insert(secret.Tags, i, tag)
+	return true
+}
+
+// Removes the tag and reports whether the secret had it.
+func (secret *Secret) RemoveTag(tag string) bool {
+	i, found := slices.BinarySearch(secret.Tags, tag)
+	if !found {
+		return false
+	}
+
+	secret.Tags = slices.Delete(secret.Tags, i, i+1)
+	return true
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// A token giving a program access to secrets through the REST API.
+//
+// Only the hash of the token is stored, so the token itself is shown once
+// when it is created and cannot be recovered.
+type APIToken struct {
+	ID   uuid.UUID `gorm:"type:uuid;primaryKey"`
+	Name string    `gorm:"unique"`
+
+	// The SHA-256 of the token, in hex
+	Hash string `gorm:"uniqueIndex;not null"`
+
+	// Whether the token may only read secrets
+	ReadOnly bool
+
+	// The folders the token may access, including their subfolders, or all
+	// folders if empty
+	Folders []string `gorm:"serializer:json"`
+
+	// The token may only access secrets with at least one of these tags, or
+	// any secret if empty
+	Tags []string `gorm:"serializer:json"`
+
+	// When the token stops working, if it does
+	ExpiresAt *time.Time
+
+	CreatedAt time.Time
+}
+
+// Reports whether the token has expired.
+func (token *APIToken) Expired(now time.Time) bool {
+	return token.ExpiresAt != nil && !token.ExpiresAt.After(now)
+}
+
+// Reports whether the secret is within the folders and tags of the token.
+func (token *APIToken) Allows(secret *Secret) bool {
+	if len(token.Folders) > 0 && !inAnyFolder(secret.Folder, token.Folders) {
+		return false
+	}
+
+	if len(token.Tags) > 0 {
+		for _, tag := range token.Tags {
+			if secret.HasTag(tag) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	return true
+}
+
+// Reports whether the folder is one of the folders or inside one of them.
+func inAnyFolder(folder string, folders []string) bool {
+	for _, parent := range folders {
+		parent = strings.TrimSuffix(parent, "/")
+		if folder == parent || strings.HasPrefix(folder, parent+"/") {
+			return true
+		}
+	}
+
+	return false
+}
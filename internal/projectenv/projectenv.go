@@ -0,0 +1,246 @@
+package projectenv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/Isaac-Fate/myst/internal/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// Per-project environment variables, declared in a .myst.yml file in the
+// project directory or one of its parents:
+//
+//	vault: ~/work-vault        # optional, the vault of the entries below
+//	env:
+//	  DATABASE_URL: work/db-url
+//	  GITHUB_TOKEN:
+//	    key: github-token
+//	    vault: ~/myst          # optional, overrides the vault above
+//
+// Without a vault, values come from the default one. Relative vault paths
+// are relative to the directory of the file.
+
+// The name of the file declaring the environment of a project.
+const FileName string = ".myst.yml"
+
+// Output formats of the environment.
+const (
+	FormatBash string = "bash"
+	FormatZsh  string = "zsh"
+	FormatFish string = "fish"
+	FormatJSON string = "json"
+)
+
+// All output formats.
+var Formats = []string{FormatBash, FormatZsh, FormatFish, FormatJSON}
+
+// No .myst.yml was found.
+var ErrNotFound = errors.New("no " + FileName + " found in this directory or its parents")
+
+var namePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// The environment of a project.
+type Manifest struct {
+	// The path of the file.
+	Path string
+
+	// The variables, by name.
+	Variables []Variable
+}
+
+// An environment variable taking the value of a secret.
+type Variable struct {
+	Name string
+	Key  string
+
+	// The path of the vault holding the secret, or empty for the default
+	// vault.
+	Vault string
+}
+
+// A variable with its value.
+type Value struct {
+	Name  string
+	Value string
+}
+
+// Looks up the value of the secret with the key in the vault, reporting
+// whether it was found.
+type LookupFunc func(ctx context.Context, vault string, key string) (string, bool, error)
+
+type file struct {
+	Vault string           `yaml:"vault"`
+	Env   map[string]entry `yaml:"env"`
+}
+
+// An entry of env, either a key or a mapping with the key and vault.
+type entry struct {
+	Key   string `yaml:"key"`
+	Vault string `yaml:"vault"`
+}
+
+func (e *entry) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		return node.Decode(&e.Key)
+	}
+
+	type plain entry
+	return node.Decode((*plain)(e))
+}
+
+// Finds the .myst.yml in the directory or the closest of its parents and
+// loads it.
+func Find(dir string) (*Manifest, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		path := filepath.Join(dir, FileName)
+		if _, err := os.Stat(path); err == nil {
+			return Load(path)
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, ErrNotFound
+		}
+		dir = parent
+	}
+}
+
+// Loads the .myst.yml at path.
+func Load(path string) (*Manifest, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var f file
+	if err := yaml.Unmarshal(content, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	manifest := &Manifest{Path: path}
+
+	for name, entry := range f.Env {
+		if !namePattern.MatchString(name) {
+			return nil, fmt.Errorf("%s: invalid variable name '%s'", path, name)
+		}
+		if entry.Key == "" {
+			return nil, fmt.Errorf("%s: no key given for '%s'", path, name)
+		}
+
+		vault := entry.Vault
+		if vault == "" {
+			vault = f.Vault
+		}
+		if vault != "" {
+			if vault, err = resolveVault(dir, vault); err != nil {
+				return nil, err
+			}
+		}
+
+		manifest.Variables = append(manifest.Variables, Variable{Name: name, Key: entry.Key, Vault: vault})
+	}
+
+	slices.SortFunc(manifest.Variables, func(a, b Variable) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return manifest, nil
+}
+
+// Resolves a vault path relative to the directory of the file.
+func resolveVault(dir string, vault string) (string, error) {
+	if !strings.HasPrefix(vault, "~/") && !filepath.IsAbs(vault) {
+		vault = filepath.Join(dir, vault)
+	}
+
+	return utils.ResolvePath(vault)
+}
+
+// Looks up the values of the variables, returning the variables whose
+// secrets were not found separately.
+func (manifest *Manifest) Resolve(ctx context.Context, lookup LookupFunc) ([]Value, []Variable, error) {
+	var values []Value
+	var missing []Variable
+
+	for _, variable := range manifest.Variables {
+		value, found, err := lookup(ctx, variable.Vault, variable.Key)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to look up '%s' for %s: %w", variable.Key, variable.Name, err)
+		}
+
+		if !found {
+			missing = append(missing, variable)
+			continue
+		}
+
+		values = append(values, Value{Name: variable.Name, Value: value})
+	}
+
+	return values, missing, nil
+}
+
+// Writes the values in the format, as commands for the shell to evaluate or
+// as a JSON object.
+func Write(w io.Writer, format string, values []Value) error {
+	switch format {
+	case FormatBash, FormatZsh:
+		for _, value := range values {
+			if _, err := fmt.Fprintf(w, "export %s=%s\n", value.Name, quotePOSIX(value.Value)); err != nil {
+				return err
+			}
+		}
+
+	case FormatFish:
+		for _, value := range values {
+			if _, err := fmt.Fprintf(w, "set -gx %s %s;\n", value.Name, quoteFish(value.Value)); err != nil {
+				return err
+			}
+		}
+
+	case FormatJSON:
+		object := make(map[string]string, len(values))
+		for _, value := range values {
+			object[value.Name] = value.Value
+		}
+
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(object)
+
+	default:
+		return fmt.Errorf("unknown format '%s', use one of %s", format, strings.Join(Formats, ", "))
+	}
+
+	return nil
+}
+
+// Quotes a value for POSIX shells, where nothing is special within single
+// quotes.
+func quotePOSIX(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// Quotes a value for fish, where backslashes and single quotes are escaped
+// within single quotes.
+func quoteFish(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, "'", `\'`)
+	return "'" + value + "'"
+}
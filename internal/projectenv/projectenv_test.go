@@ -0,0 +1,205 @@
+package projectenv_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Isaac-Fate/myst/internal/projectenv"
+)
+
+func writeManifest(t *testing.T, dir string, content string) string {
+	t.Helper()
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, projectenv.FileName)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestFind(t *testing.T) {
+	root := t.TempDir()
+	path := writeManifest(t, root, "env:\n  API_KEY: api-key\n")
+
+	nested := filepath.Join(root, "src", "cmd")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	manifest, err := projectenv.Find(nested)
+	if err != nil {
+		t.Fatalf("failed to find manifest: %v", err)
+	}
+	if manifest.Path != path {
+		t.Errorf("path = %s, want %s", manifest.Path, path)
+	}
+
+	// The closest file wins
+	closer := writeManifest(t, filepath.Join(root, "src"), "env:\n  OTHER: other\n")
+	manifest, err = projectenv.Find(nested)
+	if err != nil {
+		t.Fatalf("failed to find manifest: %v", err)
+	}
+	if manifest.Path != closer {
+		t.Errorf("path = %s, want %s", manifest.Path, closer)
+	}
+}
+
+func TestFindNothing(t *testing.T) {
+	// Assumes no .myst.yml above the temporary directory
+	if _, err := projectenv.Find(t.TempDir()); !errors.Is(err, projectenv.ErrNotFound) {
+		t.Errorf("err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := writeManifest(t, dir, `
+vault: vaults/work
+env:
+  GITHUB_TOKEN:
+    key: github-token
+    vault: /srv/myst
+  DATABASE_URL: work/db-url
+  API_KEY:
+    key: api-key
+`)
+
+	manifest, err := projectenv.Load(path)
+	if err != nil {
+		t.Fatalf("failed to load: %v", err)
+	}
+
+	work := filepath.Join(dir, "vaults", "work")
+	want := []projectenv.Variable{
+		{Name: "API_KEY", Key: "api-key", Vault: work},
+		{Name: "DATABASE_URL", Key: "work/db-url", Vault: work},
+		{Name: "GITHUB_TOKEN", Key: "github-token", Vault: "/srv/myst"},
+	}
+	if !reflect.DeepEqual(manifest.Variables, want) {
+		t.Errorf("variables = %+v, want %+v", manifest.Variables, want)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		content string
+	}{
+		{"bad name", "env:\n  API-KEY: api-key\n"},
+		{"no key", "env:\n  API_KEY:\n    vault: /srv/myst\n"},
+		{"not YAML", "env: [\n"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			path := writeManifest(t, t.TempDir(), test.content)
+			if _, err := projectenv.Load(path); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	manifest := &projectenv.Manifest{Variables: []projectenv.Variable{
+		{Name: "API_KEY", Key: "api-key"},
+		{Name: "DB_PASSWORD", Key: "db-password", Vault: "/srv/myst"},
+		{Name: "GONE", Key: "gone"},
+	}}
+
+	secrets := map[string]string{"api-key": "k3y", "/srv/myst:db-password": "pa55"}
+	lookup := func(ctx context.Context, vault string, key string) (string, bool, error) {
+		if vault != "" {
+			key = vault + ":" + key
+		}
+		value, ok := secrets[key]
+		return value, ok, nil
+	}
+
+	values, missing, err := manifest.Resolve(context.Background(), lookup)
+	if err != nil {
+		t.Fatalf("failed to resolve: %v", err)
+	}
+
+	wantValues := []projectenv.Value{{Name: "API_KEY", Value: "k3y"}, {Name: "DB_PASSWORD", Value: "pa55"}}
+	if !reflect.DeepEqual(values, wantValues) {
+		t.Errorf("values = %+v, want %+v", values, wantValues)
+	}
+	if len(missing) != 1 || missing[0].Name != "GONE" {
+		t.Errorf("missing = %+v, want GONE", missing)
+	}
+}
+
+var tricky = []projectenv.Value{
+	{Name: "PLAIN", Value: "hunter2"},
+	{Name: "QUOTES", Value: `it's "quoted" \ $HOME`},
+	{Name: "LINES", Value: "first\nsecond"},
+}
+
+func TestWrite(t *testing.T) {
+	for _, test := range []struct {
+		format string
+		output string
+	}{
+		{projectenv.FormatBash, "export PLAIN='hunter2'\nexport QUOTES='it'\\''s \"quoted\" \\ $HOME'\nexport LINES='first\nsecond'\n"},
+		{projectenv.FormatFish, "set -gx PLAIN 'hunter2';\nset -gx QUOTES 'it\\'s \"quoted\" \\\\ $HOME';\nset -gx LINES 'first\nsecond';\n"},
+		{projectenv.FormatJSON, "{\n  \"LINES\": \"first\\nsecond\",\n  \"PLAIN\": \"hunter2\",\n  \"QUOTES\": \"it's \\\"quoted\\\" \\\\ $HOME\"\n}\n"},
+	} {
+		t.Run(test.format, func(t *testing.T) {
+			var buffer bytes.Buffer
+			if err := projectenv.Write(&buffer, test.format, tricky); err != nil {
+				t.Fatalf("failed to write: %v", err)
+			}
+			if buffer.String() != test.output {
+				t.Errorf("output = %q, want %q", buffer.String(), test.output)
+			}
+		})
+	}
+
+	if err := projectenv.Write(&bytes.Buffer{}, "powershell", tricky); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestWriteEvaluatedByShells(t *testing.T) {
+	for _, test := range []struct {
+		format string
+		shell  string
+	}{
+		{projectenv.FormatBash, "bash"},
+		{projectenv.FormatZsh, "zsh"},
+		{projectenv.FormatFish, "fish"},
+	} {
+		t.Run(test.shell, func(t *testing.T) {
+			if _, err := exec.LookPath(test.shell); err != nil {
+				t.Skipf("%s is not installed", test.shell)
+			}
+
+			var script bytes.Buffer
+			if err := projectenv.Write(&script, test.format, tricky); err != nil {
+				t.Fatal(err)
+			}
+			script.WriteString(`printf '%s|%s|%s' "$PLAIN" "$QUOTES" "$LINES"`)
+
+			output, err := exec.Command(test.shell, "-c", script.String()).Output()
+			if err != nil {
+				t.Fatalf("%s failed: %v", test.shell, err)
+			}
+
+			want := tricky[0].Value + "|" + tricky[1].Value + "|" + tricky[2].Value
+			if string(output) != want {
+				t.Errorf("output = %q, want %q", output, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package recovery
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	mycrypto "github.com/Isaac-Fate/myst/internal/crypto"
+	"github.com/Isaac-Fate/myst/internal/shamir"
+)
+
+// The master passphrase is never stored, so it cannot be split directly.
+// Instead, a random recovery key encrypts the passphrase, the encrypted
+// passphrase is kept in the configuration, and the recovery key is split
+// into Shamir shares handed out to trusted people.
+//
+// A share is printed as a line of text, easy to write down or to turn into
+// a QR code:
+//
+//	MYST1-ABCD-EFGH-...
+//
+// After the prefix comes the base32 encoding of
+//
+//	set ID (4 bytes) | threshold (1 byte) | x (1 byte) | y (32 bytes) | checksum (4 bytes)
+//
+// The set ID tells shares of different splits apart, and the checksum, the
+// first 4 bytes of the SHA-256 of everything before it, catches typos.
+
+const sharePrefix string = "MYST1"
+const recoveryKeyLength int = 32
+const setIdLength int = 4
+const checksumLength int = 4
+const groupLength int = 4
+
+// Returned when the shares do not recover the passphrase.
+var ErrRecoveryFailed = errors.New("the shares do not recover the passphrase")
+
+var shareEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// A share of the recovery key.
+type Share struct {
+	// Identifies the split the share belongs to
+	SetId [setIdLength]byte
+
+	// Number of shares needed to recover the key
+	Threshold int
+
+	shamir.Share
+}
+
+// Splits a new recovery key into shares.
+//
+// It returns the passphrase encrypted with the recovery key, which is to be
+// stored in the configuration, and the shares as text.
+func Split(passphrase string, n int, threshold int) (string, []string, error) {
+	// Generate the recovery key
+	recoveryKey := make([]byte, recoveryKeyLength)
+	if _, err := rand.Read(recoveryKey); err != nil {
+		return "", nil, err
+	}
+
+	wrappedPassphrase, err := Wrap(recoveryKey, passphrase)
+	if err != nil {
+		return "", nil, err
+	}
+
+	shares, err := shamir.Split(recoveryKey, n, threshold)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var setId [setIdLength]byte
+	if _, err := rand.Read(setId[:]); err != nil {
+		return "", nil, err
+	}
+
+	texts := make([]string, len(shares))
+	for i, share := range shares {
+		texts[i] = (&Share{SetId: setId, Threshold: threshold, Share: share}).String()
+	}
+
+	return wrappedPassphrase, texts, nil
+}
+
+// Encrypts the passphrase with the recovery key.
+func Wrap(recoveryKey []byte, passphrase string) (string, error) {
+	return mycrypto.Encrypt(hex.EncodeToString(recoveryKey), passphrase)
+}
+
+// Recovers the passphrase from the encrypted passphrase and enough shares.
+//
+// The recovery key is returned too, so that a new passphrase can be wrapped
+// with it and the existing shares stay valid.
+func Recover(wrappedPassphrase string, shares []*Share) (string, []byte, error) {
+	if len(shares) == 0 {
+		return "", nil, errors.New("no shares")
+	}
+
+	shamirShares := make([]shamir.Share, len(shares))
+
+	for i, share := range shares {
+		if share.SetId != shares[0].SetId {
+			return "", nil, errors.New("the shares belong to different splits")
+		}
+
+		shamirShares[i] = share.Share
+	}
+
+	if len(shares) < shares[0].Threshold {
+		return "", nil, errors.New("not enough shares")
+	}
+
+	recoveryKey, err := shamir.Combine(shamirShares)
+	if err != nil {
+		return "", nil, err
+	}
+
+	passphrase, err := mycrypto.Decrypt(hex.EncodeToString(recoveryKey), wrappedPassphrase)
+	if err != nil {
+		return "", nil, ErrRecoveryFailed
+	}
+
+	return passphrase, recoveryKey, nil
+}
+
+// Encodes the share as text.
+func (share *Share) String() string {
+	var payload bytes.Buffer
+	payload.Write(share.SetId[:])
+	payload.WriteByte(byte(share.Threshold))
+	payload.WriteByte(share.X)
+	payload.Write(share.Y)
+
+	checksum := sha256.Sum256(payload.Bytes())
+	payload.Write(checksum[:checksumLength])
+
+	encoded := shareEncoding.EncodeToString(payload.Bytes())
+
+	// Split into groups for reading and writing by hand
+	groups := []string{sharePrefix}
+	for len(encoded) > groupLength {
+		groups = append(groups, encoded[:groupLength])
+		encoded = encoded[groupLength:]
+	}
+	groups = append(groups, encoded)
+
+	return strings.Join(groups, "-")
+}
+
+// Parses a share from its text encoding.
+//
+// Case, spaces and dashes are ignored.
+func ParseShare(text string) (*Share, error) {
+	normalized := strings.ToUpper(text)
+	normalized = strings.NewReplacer("-", "", " ", "", "\t", "").Replace(normalized)
+
+	if !strings.HasPrefix(normalized, sharePrefix) {
+		return nil, errors.New("not a recovery share")
+	}
+
+	payload, err := shareEncoding.DecodeString(strings.TrimPrefix(normalized, sharePrefix))
+	if err != nil {
+		return nil, errors.New("invalid characters in the share")
+	}
+
+	if len(payload) != setIdLength+2+recoveryKeyLength+checksumLength {
+		return nil, errors.New("the share has the wrong length")
+	}
+
+	data := payload[:len(payload)-checksumLength]
+	checksum := sha256.Sum256(data)
+
+	if !bytes.Equal(checksum[:checksumLength], payload[len(data):]) {
+		return nil, errors.New("checksum mismatch, check the share for typos")
+	}
+
+	share := &Share{
+		Threshold: int(data[setIdLength]),
+		Share: shamir.Share{
+			X: data[setIdLength+1],
+			Y: bytes.Clone(data[setIdLength+2:]),
+		},
+	}
+	copy(share.SetId[:], data[:setIdLength])
+
+	return share, nil
+}
@@ -0,0 +1,133 @@
+package recovery_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Isaac-Fate/myst/internal/recovery"
+)
+
+const passphrase string = "hello, world"
+
+func TestSplitRecover(t *testing.T) {
+	wrappedPassphrase, texts, err := recovery.Split(passphrase, 5, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(texts) != 5 {
+		t.Fatalf("expected 5 shares, got %d", len(texts))
+	}
+
+	for _, text := range texts {
+		if !strings.HasPrefix(text, "MYST1-") {
+			t.Errorf("unexpected share %s", text)
+		}
+	}
+
+	// Parse 3 shares, one typed in lower case without dashes
+	var shares []*recovery.Share
+	for _, text := range []string{texts[4], strings.ToLower(strings.ReplaceAll(texts[0], "-", "")), texts[2]} {
+		share, err := recovery.ParseShare(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		shares = append(shares, share)
+	}
+
+	recovered, recoveryKey, err := recovery.Recover(wrappedPassphrase, shares)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if recovered != passphrase {
+		t.Errorf("expected %s, got %s", passphrase, recovered)
+	}
+
+	// The recovery key can wrap a new passphrase for the same shares
+	rewrapped, err := recovery.Wrap(recoveryKey, "new passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recovered, _, err = recovery.Recover(rewrapped, shares)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if recovered != "new passphrase" {
+		t.Errorf("expected the new passphrase, got %s", recovered)
+	}
+}
+
+func TestRecoverTooFewShares(t *testing.T) {
+	wrappedPassphrase, texts, err := recovery.Split(passphrase, 5, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var shares []*recovery.Share
+	for _, text := range texts[:2] {
+		share, err := recovery.ParseShare(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		shares = append(shares, share)
+	}
+
+	if _, _, err := recovery.Recover(wrappedPassphrase, shares); err == nil {
+		t.Error("expected an error with too few shares")
+	}
+}
+
+func TestRecoverMixedSplits(t *testing.T) {
+	wrappedPassphrase, texts, err := recovery.Split(passphrase, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, otherTexts, err := recovery.Split(passphrase, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := recovery.ParseShare(texts[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := recovery.ParseShare(otherTexts[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := recovery.Recover(wrappedPassphrase, []*recovery.Share{first, second}); err == nil {
+		t.Error("expected an error for shares of different splits")
+	}
+}
+
+func TestParseShareTypo(t *testing.T) {
+	_, texts, err := recovery.Split(passphrase, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Change one character of the share
+	text := []byte(texts[0])
+	if text[7] == 'A' {
+		text[7] = 'B'
+	} else {
+		text[7] = 'A'
+	}
+
+	if _, err := recovery.ParseShare(string(text)); err == nil {
+		t.Error("expected a typo to be detected")
+	}
+
+	if _, err := recovery.ParseShare("not a share"); err == nil {
+		t.Error("expected an error for garbage")
+	}
+
+}
@@ -7,15 +7,18 @@ import (
 
 // Adds a secret to the index.
 //
-// The secret is indexed with the fields name, website, and notes. The secret
-// ID is used as the document ID.
+// The secret is indexed with the fields key, username, website, notes, folder
+// and tag. The secret ID is used as the document ID.
 //
 // The function returns an error if the indexing fails.
 func AddSecret(index bleve.Index, secret *models.Secret) error {
-	return index.Index(secret.ID.String(), models.Secret{
-		Key:     secret.Key,
-		Website: secret.Website,
-		Notes:   secret.Notes,
+	return index.Index(secret.ID.String(), secretDocument{
+		Key:      secret.Key,
+		Username: secret.Username,
+		Website:  secret.Website,
+		Notes:    secret.Notes,
+		Folder:   secret.Folder,
+		Tags:     secret.Tags,
 	})
 }
 
@@ -0,0 +1,121 @@
+package search
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Matches are highlighted by looking for the text of the query terms in the
+// stored fields, ignoring case. Fuzzy terms are not highlighted, since the
+// text that matched them is not known.
+
+// The fields matches are highlighted in.
+var highlightFields = []string{"key", "username", "website", "notes"}
+
+// Fragments longer than this are cut around the first match.
+const fragmentLength int = 80
+
+// Text to highlight, in a single field or in any field if field is empty.
+type highlightTerm struct {
+	field string
+	text  []rune
+}
+
+// Marks the start and end of highlighted text in fragments.
+type Highlight struct {
+	Before string
+	After  string
+}
+
+// Returns the text to highlight for a term of the query.
+func highlightTermsOf(term token) []highlightTerm {
+	value := term.value
+
+	if !term.quoted && strings.Contains(value, "~") {
+		return nil
+	}
+
+	// Highlight the literal parts of wildcards
+	parts := []string{value}
+	if !term.quoted && isWildcard(value) {
+		parts = strings.FieldsFunc(value, func(r rune) bool { return r == '*' || r == '?' })
+	}
+
+	var terms []highlightTerm
+	for _, part := range parts {
+		if part != "" {
+			terms = append(terms, highlightTerm{field: term.field, text: []rune(strings.ToLower(part))})
+		}
+	}
+
+	return terms
+}
+
+// Highlights the terms in the text of the field.
+//
+// It returns false if no term appears in the text.
+func highlightField(field string, text string, terms []highlightTerm, highlight Highlight) (string, bool) {
+	runes := []rune(text)
+
+	lowered := make([]rune, len(runes))
+	for i, r := range runes {
+		lowered[i] = unicode.ToLower(r)
+	}
+
+	// Mark the runes that are part of a match
+	marked := make([]bool, len(runes))
+	first := -1
+
+	for _, term := range terms {
+		if term.field != "" && term.field != field {
+			continue
+		}
+
+		for i := 0; i+len(term.text) <= len(lowered); i++ {
+			if slices.Equal(lowered[i:i+len(term.text)], term.text) {
+				for j := i; j < i+len(term.text); j++ {
+					marked[j] = true
+				}
+
+				if first < 0 || i < first {
+					first = i
+				}
+			}
+		}
+	}
+
+	if first < 0 {
+		return "", false
+	}
+
+	// Cut long text around the first match
+	start, end := 0, len(runes)
+	if len(runes) > fragmentLength {
+		start = max(0, first-fragmentLength/4)
+		end = min(len(runes), start+fragmentLength)
+	}
+
+	var fragment strings.Builder
+	if start > 0 {
+		fragment.WriteString("…")
+	}
+
+	for i := start; i < end; i++ {
+		if marked[i] && (i == start || !marked[i-1]) {
+			fragment.WriteString(highlight.Before)
+		}
+
+		fragment.WriteRune(runes[i])
+
+		if marked[i] && (i == end-1 || !marked[i+1]) {
+			fragment.WriteString(highlight.After)
+		}
+	}
+
+	if end < len(runes) {
+		fragment.WriteString("…")
+	}
+
+	return fragment.String(), true
+}
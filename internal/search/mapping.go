@@ -0,0 +1,123 @@
+package search
+
+import (
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/custom"
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/standard"
+	"github.com/blevesearch/bleve/v2/analysis/token/lowercase"
+	"github.com/blevesearch/bleve/v2/analysis/token/ngram"
+	"github.com/blevesearch/bleve/v2/analysis/tokenizer/single"
+	"github.com/blevesearch/bleve/v2/analysis/tokenizer/unicode"
+	"github.com/blevesearch/bleve/v2/mapping"
+)
+
+// Every secret is indexed twice per field:
+//
+//   - key, username, website, folder and each tag as a single lowercase term,
+//     for exact, prefix, wildcard and fuzzy matching of the whole value, and
+//     notes as words
+//   - key_ngram, username_ngram, website_ngram, folder_ngram, tag_ngram and
+//     notes_ngram as every substring of 2 to 20 characters, so that "hub"
+//     finds "github-token"
+//
+// Bump mappingVersion whenever the mapping changes. An on-disk index with
+// another version is rebuilt when it is opened.
+const mappingVersion string = "4"
+
+// The internal key the mapping version is stored under.
+var mappingVersionKey = []byte("myst_mapping_version")
+
+const keywordAnalyzer string = "myst_keyword"
+const keywordNgramAnalyzer string = "myst_keyword_ngram"
+const textNgramAnalyzer string = "myst_text_ngram"
+const ngramFilter string = "myst_ngram"
+
+// Substrings outside these lengths are not indexed.
+const minGram int = 2
+const maxGram int = 20
+
+// Suffix of the fields holding the substrings of another field.
+const ngramSuffix string = "_ngram"
+
+// The fields a query can be restricted to.
+var fields = []string{"key", "username", "website", "notes", "folder", "tag"}
+
+// The fields indexed as a single term rather than as words.
+var keywordFields = map[string]bool{"key": true, "username": true, "website": true, "folder": true, "tag": true}
+
+// The document indexed for a secret.
+type secretDocument struct {
+	Key      string   `json:"key"`
+	Username string   `json:"username"`
+	Website  string   `json:"website"`
+	Notes    string   `json:"notes"`
+	Folder   string   `json:"folder"`
+	Tags     []string `json:"tag"`
+}
+
+// Creates the index mapping for secrets.
+func newIndexMapping() (mapping.IndexMapping, error) {
+	indexMapping := bleve.NewIndexMapping()
+
+	err := indexMapping.AddCustomAnalyzer(keywordAnalyzer, map[string]interface{}{
+		"type":          custom.Name,
+		"tokenizer":     single.Name,
+		"token_filters": []string{lowercase.Name},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = indexMapping.AddCustomTokenFilter(ngramFilter, map[string]interface{}{
+		"type": ngram.Name,
+		"min":  float64(minGram),
+		"max":  float64(maxGram),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = indexMapping.AddCustomAnalyzer(keywordNgramAnalyzer, map[string]interface{}{
+		"type":          custom.Name,
+		"tokenizer":     single.Name,
+		"token_filters": []string{lowercase.Name, ngramFilter},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = indexMapping.AddCustomAnalyzer(textNgramAnalyzer, map[string]interface{}{
+		"type":          custom.Name,
+		"tokenizer":     unicode.Name,
+		"token_filters": []string{lowercase.Name, ngramFilter},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	documentMapping := bleve.NewDocumentStaticMapping()
+
+	for _, field := range fields {
+		analyzer, ngramAnalyzer := keywordAnalyzer, keywordNgramAnalyzer
+		if !keywordFields[field] {
+			analyzer, ngramAnalyzer = standard.Name, textNgramAnalyzer
+		}
+
+		fieldMapping := bleve.NewTextFieldMapping()
+		fieldMapping.Analyzer = analyzer
+		fieldMapping.IncludeInAll = false
+
+		ngramMapping := bleve.NewTextFieldMapping()
+		ngramMapping.Name = field + ngramSuffix
+		ngramMapping.Analyzer = ngramAnalyzer
+		ngramMapping.Store = false
+		ngramMapping.IncludeInAll = false
+		ngramMapping.IncludeTermVectors = false
+
+		documentMapping.AddFieldMappingsAt(field, fieldMapping, ngramMapping)
+	}
+
+	indexMapping.DefaultMapping = documentMapping
+
+	return indexMapping, nil
+}
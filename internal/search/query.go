@@ -0,0 +1,456 @@
+package search
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+// Queries are made of terms combined with AND, OR and NOT:
+//
+//	git                          any field contains "git"
+//	key:git*                     the key starts with "git"
+//	website:github.com           the website is or contains "github.com"
+//	notes:"staging database"     the notes contain the phrase
+//	githbu~                      any field contains a word one typo away
+//	key:token~2                  the key is at most 2 typos away from "token"
+//	-notes:old, NOT notes:old    the notes do not contain "old"
+//	aws OR gcp                   either term matches
+//	(aws OR gcp) key:prod*       terms next to each other must all match
+//	tag:prod                     one of the tags is or contains "prod"
+//
+// The fields are key, username, website, notes, folder and tag. Matching
+// ignores case.
+
+// Returned for a query that cannot be parsed.
+var ErrInvalidQuery = errors.New("invalid query")
+
+// Bare terms at least this long also match words one typo away.
+const autoFuzzyLength int = 5
+
+// Parses a query into a bleve query. An empty query matches every secret.
+func ParseQuery(input string) (query.Query, error) {
+	parsed, _, err := parseQuery(input)
+	return parsed, err
+}
+
+// Parses a query and also returns the text to highlight in the results.
+func parseQuery(input string) (query.Query, []highlightTerm, error) {
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(tokens) == 0 {
+		return bleve.NewMatchAllQuery(), nil, nil
+	}
+
+	parser := &queryParser{tokens: tokens}
+
+	parsed, err := parser.parseOr()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !parser.done() {
+		return nil, nil, fmt.Errorf("%w: unexpected '%s'", ErrInvalidQuery, parser.peek().text)
+	}
+
+	return parsed, parser.highlights, nil
+}
+
+type tokenKind int
+
+const (
+	termToken tokenKind = iota
+	andToken
+	orToken
+	notToken
+	openToken
+	closeToken
+)
+
+type token struct {
+	kind tokenKind
+
+	// The text as written, for error messages
+	text string
+
+	// Only set for terms
+	field  string
+	value  string
+	quoted bool
+}
+
+// Splits the input into tokens.
+func tokenize(input string) ([]token, error) {
+	var tokens []token
+
+	for i := 0; i < len(input); {
+		r, size := utf8.DecodeRuneInString(input[i:])
+
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+		case r == '(':
+			tokens = append(tokens, token{kind: openToken, text: "("})
+			i += size
+		case r == ')':
+			tokens = append(tokens, token{kind: closeToken, text: ")"})
+			i += size
+		case r == '-' && i+1 < len(input) && !unicode.IsSpace(rune(input[i+1])):
+			tokens = append(tokens, token{kind: notToken, text: "-"})
+			i += size
+		default:
+			term, length, err := readTerm(input[i:])
+			if err != nil {
+				return nil, err
+			}
+
+			i += length
+
+			// Operators are only recognized in upper case, so that "or" can
+			// still be searched for
+			switch term.text {
+			case "AND", "&&":
+				term.kind = andToken
+			case "OR", "||", "|":
+				term.kind = orToken
+			case "NOT", "!":
+				term.kind = notToken
+			}
+
+			tokens = append(tokens, term)
+		}
+	}
+
+	return tokens, nil
+}
+
+// Reads a term, optionally prefixed by a field, from the start of the input.
+//
+// It returns the term and the number of bytes read.
+func readTerm(input string) (token, int, error) {
+	term := token{kind: termToken}
+
+	i := 0
+	var value strings.Builder
+
+	for i < len(input) {
+		r, size := utf8.DecodeRuneInString(input[i:])
+
+		if unicode.IsSpace(r) || r == '(' || r == ')' {
+			break
+		}
+
+		// A quoted phrase, possibly after a field
+		if r == '"' {
+			end := strings.IndexByte(input[i+1:], '"')
+			if end < 0 {
+				return token{}, 0, fmt.Errorf("%w: missing closing quote", ErrInvalidQuery)
+			}
+
+			value.WriteString(input[i+1 : i+1+end])
+			term.quoted = true
+			i += end + 2
+			break
+		}
+
+		// The first colon separates the field from the value
+		if r == ':' && term.field == "" && !term.quoted {
+			term.field = strings.ToLower(value.String())
+			value.Reset()
+			i += size
+			continue
+		}
+
+		value.WriteRune(r)
+		i += size
+	}
+
+	term.text = input[:i]
+	term.value = value.String()
+
+	if term.field != "" {
+		if !slices.Contains(fields, term.field) {
+			return token{}, 0, fmt.Errorf("%w: unknown field '%s', expected one of %s", ErrInvalidQuery, term.field, strings.Join(fields, ", "))
+		}
+
+		if term.value == "" {
+			return token{}, 0, fmt.Errorf("%w: no value for field '%s'", ErrInvalidQuery, term.field)
+		}
+	}
+
+	return term, i, nil
+}
+
+// A recursive descent parser over the tokens.
+//
+//	or    = and { "OR" and }
+//	and   = unary { [ "AND" ] unary }
+//	unary = ( "NOT" | "-" ) unary | "(" or ")" | term
+type queryParser struct {
+	tokens   []token
+	position int
+
+	// The text of the terms that are not negated
+	highlights []highlightTerm
+}
+
+func (parser *queryParser) done() bool {
+	return parser.position >= len(parser.tokens)
+}
+
+func (parser *queryParser) peek() token {
+	return parser.tokens[parser.position]
+}
+
+func (parser *queryParser) next() token {
+	token := parser.tokens[parser.position]
+	parser.position++
+	return token
+}
+
+func (parser *queryParser) parseOr() (query.Query, error) {
+	var queries []query.Query
+
+	for {
+		parsed, err := parser.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+
+		queries = append(queries, parsed)
+
+		if parser.done() || parser.peek().kind != orToken {
+			break
+		}
+		parser.next()
+	}
+
+	if len(queries) == 1 {
+		return queries[0], nil
+	}
+
+	return bleve.NewDisjunctionQuery(queries...), nil
+}
+
+func (parser *queryParser) parseAnd() (query.Query, error) {
+	var musts, mustNots []query.Query
+
+	for !parser.done() {
+		kind := parser.peek().kind
+
+		if kind == orToken || kind == closeToken {
+			break
+		}
+
+		if kind == andToken {
+			parser.next()
+			continue
+		}
+
+		highlightCount := len(parser.highlights)
+
+		parsed, negated, err := parser.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		if negated {
+			// Excluded terms never appear in the results
+			parser.highlights = parser.highlights[:highlightCount]
+			mustNots = append(mustNots, parsed)
+		} else {
+			musts = append(musts, parsed)
+		}
+	}
+
+	if len(musts) == 0 && len(mustNots) == 0 {
+		return nil, fmt.Errorf("%w: expected a term", ErrInvalidQuery)
+	}
+
+	if len(musts) == 1 && len(mustNots) == 0 {
+		return musts[0], nil
+	}
+
+	// Only excluding terms excludes them from every secret
+	if len(musts) == 0 {
+		musts = append(musts, bleve.NewMatchAllQuery())
+	}
+
+	booleanQuery := bleve.NewBooleanQuery()
+	booleanQuery.AddMust(musts...)
+	booleanQuery.AddMustNot(mustNots...)
+
+	return booleanQuery, nil
+}
+
+// Parses a unary expression and returns whether it is negated.
+func (parser *queryParser) parseUnary() (query.Query, bool, error) {
+	if parser.done() {
+		return nil, false, fmt.Errorf("%w: expected a term", ErrInvalidQuery)
+	}
+
+	token := parser.next()
+
+	switch token.kind {
+	case notToken:
+		parsed, negated, err := parser.parseUnary()
+		return parsed, !negated, err
+
+	case openToken:
+		parsed, err := parser.parseOr()
+		if err != nil {
+			return nil, false, err
+		}
+
+		if parser.done() || parser.next().kind != closeToken {
+			return nil, false, fmt.Errorf("%w: missing closing parenthesis", ErrInvalidQuery)
+		}
+
+		return parsed, false, nil
+
+	case termToken:
+		parser.highlights = append(parser.highlights, highlightTermsOf(token)...)
+		return termQuery(token), false, nil
+
+	default:
+		return nil, false, fmt.Errorf("%w: unexpected '%s'", ErrInvalidQuery, token.text)
+	}
+}
+
+// Creates the query for a single term.
+func termQuery(term token) query.Query {
+	value := term.value
+	fuzziness := 0
+
+	// A trailing ~ or ~N asks for fuzzy matching
+	if !term.quoted {
+		if i := strings.LastIndexByte(value, '~'); i > 0 {
+			if suffix := value[i+1:]; suffix == "" {
+				value, fuzziness = value[:i], 1
+			} else if n, err := strconv.Atoi(suffix); err == nil && n >= 0 {
+				// bleve does not support more than 2 edits
+				value, fuzziness = value[:i], min(n, 2)
+			}
+		}
+	}
+
+	searchFields := fields
+	if term.field != "" {
+		searchFields = []string{term.field}
+	}
+
+	var queries []query.Query
+	for _, field := range searchFields {
+		queries = append(queries, fieldQuery(field, value, term.quoted, fuzziness)...)
+	}
+
+	// Forgive a typo in longer bare terms
+	if term.field == "" && fuzziness == 0 && !term.quoted && !isWildcard(value) && utf8.RuneCountInString(value) >= autoFuzzyLength {
+		for _, field := range fields {
+			queries = append(queries, ngramQuery(field, value, 1, 0.5)...)
+		}
+	}
+
+	if len(queries) == 1 {
+		return queries[0]
+	}
+
+	return bleve.NewDisjunctionQuery(queries...)
+}
+
+// Creates the queries matching the value in a single field.
+func fieldQuery(field string, value string, quoted bool, fuzziness int) []query.Query {
+	lowered := strings.ToLower(value)
+
+	// Keywords are matched as a whole, notes word by word
+	isKeyword := keywordFields[field]
+
+	switch {
+	case quoted && isKeyword:
+		return []query.Query{newTermQuery(field, lowered, 1)}
+
+	case quoted:
+		phraseQuery := bleve.NewMatchPhraseQuery(value)
+		phraseQuery.SetField(field)
+		return []query.Query{phraseQuery}
+
+	case isWildcard(value):
+		wildcardQuery := bleve.NewWildcardQuery(lowered)
+		wildcardQuery.SetField(field)
+		return []query.Query{wildcardQuery}
+
+	case fuzziness > 0 && isKeyword:
+		fuzzyQuery := bleve.NewFuzzyQuery(lowered)
+		fuzzyQuery.SetField(field)
+		fuzzyQuery.SetFuzziness(fuzziness)
+		return append([]query.Query{fuzzyQuery}, ngramQuery(field, lowered, fuzziness, 1)...)
+
+	case fuzziness > 0:
+		matchQuery := bleve.NewMatchQuery(value)
+		matchQuery.SetField(field)
+		matchQuery.SetFuzziness(fuzziness)
+		return append([]query.Query{matchQuery}, ngramQuery(field, lowered, fuzziness, 1)...)
+
+	case isKeyword:
+		// Prefer exact matches, then prefixes, then substrings
+		prefixQuery := bleve.NewPrefixQuery(lowered)
+		prefixQuery.SetField(field)
+		prefixQuery.SetBoost(2)
+
+		queries := []query.Query{newTermQuery(field, lowered, 4), prefixQuery}
+		return append(queries, ngramQuery(field, lowered, 0, 1)...)
+
+	default:
+		// Prefer whole words over substrings
+		matchQuery := bleve.NewMatchQuery(value)
+		matchQuery.SetField(field)
+		matchQuery.SetOperator(query.MatchQueryOperatorAnd)
+		matchQuery.SetBoost(2)
+
+		return append([]query.Query{matchQuery}, ngramQuery(field, lowered, 0, 1)...)
+	}
+}
+
+// Creates a query matching the value as a substring of the field.
+//
+// Values of a length that is not indexed as a substring match nothing.
+func ngramQuery(field string, value string, fuzziness int, boost float64) []query.Query {
+	value = strings.ToLower(value)
+
+	length := utf8.RuneCountInString(value)
+	if length < minGram || length > maxGram || strings.ContainsFunc(value, unicode.IsSpace) {
+		return nil
+	}
+
+	if fuzziness == 0 {
+		return []query.Query{newTermQuery(field+ngramSuffix, value, boost)}
+	}
+
+	fuzzyQuery := bleve.NewFuzzyQuery(value)
+	fuzzyQuery.SetField(field + ngramSuffix)
+	fuzzyQuery.SetFuzziness(fuzziness)
+	fuzzyQuery.SetBoost(boost)
+
+	return []query.Query{fuzzyQuery}
+}
+
+func newTermQuery(field string, value string, boost float64) query.Query {
+	termQuery := bleve.NewTermQuery(value)
+	termQuery.SetField(field)
+	termQuery.SetBoost(boost)
+	return termQuery
+}
+
+func isWildcard(value string) bool {
+	return strings.ContainsAny(value, "*?")
+}
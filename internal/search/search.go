@@ -2,35 +2,68 @@ package search
 
 import (
 	"errors"
+	"os"
 
+	"github.com/Isaac-Fate/myst/internal/database"
 	"github.com/Isaac-Fate/myst/internal/models"
 	"github.com/blevesearch/bleve/v2"
 	"gorm.io/gorm"
 )
 
+// Opens the on-disk index at indexPath, creating it if necessary.
+//
+// An index created with an older mapping is deleted and created again, empty.
+// The caller is expected to index the secrets again when the index is empty.
 func OpenIndex(indexPath string) (bleve.Index, error) {
 	// Open the index
 	index, err := bleve.Open(indexPath)
 
 	if err == nil {
-		return index, nil
+		version, err := index.GetInternal(mappingVersionKey)
+		if err == nil && string(version) == mappingVersion {
+			return index, nil
+		}
+
+		// Delete the outdated index
+		index.Close()
+		if err := os.RemoveAll(indexPath); err != nil {
+			return nil, err
+		}
+	} else if !errors.Is(err, bleve.ErrorIndexPathDoesNotExist) {
+		return nil, err
 	}
 
 	// Create a new index
-	if errors.Is(err, bleve.ErrorIndexPathDoesNotExist) {
-		// Create a new index mapping
-		mapping := bleve.NewIndexMapping()
+	mapping, err := newIndexMapping()
+	if err != nil {
+		return nil, err
+	}
 
-		// Create a new index
-		index, err = bleve.New(indexPath, mapping)
-		if err != nil {
-			return nil, err
-		}
+	index, err = bleve.New(indexPath, mapping)
+	if err != nil {
+		return nil, err
+	}
 
-		return index, nil
+	if err := index.SetInternal(mappingVersionKey, []byte(mappingVersion)); err != nil {
+		index.Close()
+		return nil, err
 	}
 
-	return nil, err
+	return index, nil
+}
+
+// Opens an index that lives only in memory.
+//
+// The index is empty when it is opened and is discarded when it is closed.
+// It is used by the single-file backend, which rebuilds the index from the
+// decrypted vault every time the vault is unlocked.
+func OpenMemoryIndex() (bleve.Index, error) {
+	mapping, err := newIndexMapping()
+	if err != nil {
+		return nil, err
+	}
+
+	return bleve.NewMemOnly(mapping)
 }
 
 func FindSecrets(db *gorm.DB, index bleve.Index, query string) ([]models.Secret, error) {
@@ -40,22 +73,75 @@ func FindSecrets(db *gorm.DB, index bleve.Index, query string) ([]models.Secret,
 		return nil, err
 	}
 
-	// Find secrets in the database by IDs
-	var secrets []models.Secret
-	err = db.Where("id IN ?", secretIds).Find(&secrets).Error
+	// Find secrets in the database by IDs, keeping the order of the hits
+	return database.GetSecrets(db, secretIds)
+}
+
+// Returns all matching secrets when used as the limit of a search.
+const NoLimit int = 0
+
+// Options of a search.
+type Options struct {
+	// Maximum number of hits to return, or NoLimit
+	Limit int
+
+	// Number of hits to skip, for paging through the results
+	Offset int
+
+	// Highlights matches in the fragments of the hits, if set
+	Highlight *Highlight
+}
+
+// A secret matching a search.
+type Hit struct {
+	// The secret ID
+	Id string
+
+	Score float64
+
+	// The key, website and notes with the matches highlighted, for the
+	// fields with a match
+	Fragments map[string]string
+}
+
+// The results of a search.
+type Result struct {
+	// The hits within the limit and offset, best first
+	Hits []Hit
+
+	// The number of matching secrets, regardless of the limit and offset
+	Total uint64
+}
+
+// Searches the index, returning the best hits first.
+//
+// See ParseQuery for the query syntax.
+func Search(index bleve.Index, query string, options Options) (*Result, error) {
+	// Parse the query
+	searchQuery, highlightTerms, err := parseQuery(query)
 	if err != nil {
 		return nil, err
 	}
 
-	return secrets, nil
-}
+	// Without a limit, ask for every secret
+	size := options.Limit
+	if size <= NoLimit {
+		count, err := index.DocCount()
+		if err != nil {
+			return nil, err
+		}
 
-func FindSecretIds(index bleve.Index, query string) ([]string, error) {
-	// Create a match query
-	searchQuery := bleve.NewMatchQuery(query)
+		size = int(count)
+	}
 
-	// Create a search request based on the query
-	searchRequest := bleve.NewSearchRequest(searchQuery)
+	// Create a search request based on the query, sorting equal scores by key
+	// so that the order is stable
+	searchRequest := bleve.NewSearchRequestOptions(searchQuery, size, max(options.Offset, 0), false)
+	searchRequest.SortBy([]string{"-_score", "key"})
+
+	if options.Highlight != nil {
+		searchRequest.Fields = highlightFields
+	}
 
 	// Search the index
 	searchResult, err := index.Search(searchRequest)
@@ -63,12 +149,45 @@ func FindSecretIds(index bleve.Index, query string) ([]string, error) {
 		return nil, err
 	}
 
-	// Secret IDs
-	secretIds := make([]string, searchResult.Hits.Len())
+	result := &Result{
+		Hits:  make([]Hit, 0, searchResult.Hits.Len()),
+		Total: searchResult.Total,
+	}
+
+	for _, match := range searchResult.Hits {
+		hit := Hit{Id: match.ID, Score: match.Score}
+
+		if options.Highlight != nil {
+			hit.Fragments = make(map[string]string)
+
+			for _, field := range highlightFields {
+				text, _ := match.Fields[field].(string)
+
+				if fragment, ok := highlightField(field, text, highlightTerms, *options.Highlight); ok {
+					hit.Fragments[field] = fragment
+				}
+			}
+		}
+
+		result.Hits = append(result.Hits, hit)
+	}
+
+	return result, nil
+}
+
+// Finds the IDs of all secrets matching the query, best first.
+//
+// See ParseQuery for the query syntax.
+func FindSecretIds(index bleve.Index, query string) ([]string, error) {
+	result, err := Search(index, query, Options{Limit: NoLimit})
+	if err != nil {
+		return nil, err
+	}
 
 	// Collect each secret ID
-	for _, hit := range searchResult.Hits {
-		secretIds = append(secretIds, hit.ID)
+	secretIds := make([]string, len(result.Hits))
+	for i, hit := range result.Hits {
+		secretIds[i] = hit.Id
 	}
 
 	return secretIds, nil
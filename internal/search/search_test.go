@@ -2,89 +2,275 @@ package search_test
 
 import (
 	"errors"
-	"fmt"
-	"os"
+	"path/filepath"
+	"slices"
 	"testing"
 
 	"github.com/Isaac-Fate/myst/internal/models"
 	"github.com/Isaac-Fate/myst/internal/search"
 	"github.com/blevesearch/bleve/v2"
 	"github.com/google/uuid"
-	"github.com/joho/godotenv"
 )
 
+var testSecrets = []models.Secret{
+	{Key: "github-token", Website: "https://github.com", Notes: "personal access token"},
+	{Key: "gitlab-token", Website: "gitlab.com", Notes: "old token for the CI runner", Tags: []string{"ci", "legacy"}},
+	{Key: "deepseek-api-key", Website: "platform.deepseek.com", Notes: "for deepseek service"},
+	{Key: "gcp-service-account", Website: "console.cloud.google.com", Notes: "google cloud", Folder: "work"},
+	{Key: "aws-prod", Website: "aws.amazon.com", Notes: "production account", Folder: "work", Tags: []string{"aws", "prod"}},
+}
+
 func TestSearch(t *testing.T) {
+	index := createIndex(t)
+
+	cases := []struct {
+		query    string
+		expected []string
+	}{
+		// Partial matches anywhere
+		{"git", []string{"github-token", "gitlab-token"}},
+		{"hub", []string{"github-token"}},
+		{"GOOGLE", []string{"gcp-service-account"}},
+
+		// Field restrictions
+		{"key:git*", []string{"github-token", "gitlab-token"}},
+		{"key:token", []string{"github-token", "gitlab-token"}},
+		{"key:aws", []string{"aws-prod"}},
+		{"tag:legacy", []string{"gitlab-token"}},
+		{"tag:ci OR tag:prod", []string{"gitlab-token", "aws-prod"}},
+		{`tag:"pro"`, nil},
+		{`key:"aws"`, nil},
+		{`key:"aws-prod"`, []string{"aws-prod"}},
+		{"website:github.com", []string{"github-token"}},
+		{"folder:work", []string{"gcp-service-account", "aws-prod"}},
+		{`notes:"access token"`, []string{"github-token"}},
+
+		// Negation
+		{"token -notes:old", []string{"github-token"}},
+		{"key:git* NOT website:gitlab.com", []string{"github-token"}},
+		{"-folder:work -key:git*", []string{"deepseek-api-key"}},
+
+		// Boolean operators
+		{"aws OR deepseek", []string{"aws-prod", "deepseek-api-key"}},
+		{"folder:work AND production", []string{"aws-prod"}},
+		{"(aws OR gcp) account", []string{"aws-prod", "gcp-service-account"}},
+
+		// Typos
+		{"deepsek~", []string{"deepseek-api-key"}},
+		{"key:gitlab-tokn~", []string{"gitlab-token"}},
+		{"produktion", []string{"aws-prod"}},
+
+		// Everything
+		{"", []string{"github-token", "gitlab-token", "deepseek-api-key", "gcp-service-account", "aws-prod"}},
+	}
 
-	indexPath, err := getTestSeretIndexPath()
+	for _, c := range cases {
+		t.Run(c.query, func(t *testing.T) {
+			secretIds, err := search.FindSecretIds(index, c.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			keys := keysOf(secretIds)
+			slices.Sort(keys)
+
+			expected := slices.Clone(c.expected)
+			slices.Sort(expected)
+
+			if !slices.Equal(keys, expected) {
+				t.Errorf("expected %v, got %v", expected, keys)
+			}
+		})
+	}
+}
+
+func TestInvalidQuery(t *testing.T) {
+	for _, query := range []string{
+		"password:secret",
+		"key:",
+		`notes:"unterminated`,
+		"(aws OR gcp",
+		"aws OR",
+		"aws )",
+	} {
+		if _, err := search.ParseQuery(query); !errors.Is(err, search.ErrInvalidQuery) {
+			t.Errorf("%s: expected ErrInvalidQuery, got %v", query, err)
+		}
+	}
+}
+
+func TestReopenIndex(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "secret-index")
+
+	index, err := search.OpenIndex(indexPath)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
 
-	index, err := openIndex(indexPath)
+	secret := &models.Secret{ID: uuid.New(), Key: "kept"}
+	if err := search.AddSecret(index, secret); err != nil {
+		t.Fatal(err)
+	}
+	index.Close()
+
+	// The index is kept when it is opened again
+	index, err = search.OpenIndex(indexPath)
 	if err != nil {
-		t.Error(err)
-	}
-
-	secrets := []models.Secret{
-		{
-			ID:    uuid.New(),
-			Key:   "test-secret-1",
-			Notes: "github token",
-		},
-		{
-			ID:    uuid.New(),
-			Key:   "test-secret-2",
-			Notes: "for deepseek service",
-		},
-		{
-			ID:    uuid.New(),
-			Key:   "test-secret-3",
-			Notes: "google cloud",
-		},
-	}
-
-	for _, secret := range secrets {
-		err = index.Index(secret.ID.String(), &secret)
-		if err != nil {
-			t.Error(err)
-		}
+		t.Fatal(err)
 	}
 
-	secretIds, err := search.FindSecretIds(index, "github")
+	count, err := index.DocCount()
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("expected 1 document, got %d", count)
 	}
+	index.Close()
+}
 
-	fmt.Println(secretIds)
+func TestRebuildOutdatedIndex(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "secret-index")
 
+	// An index created with the default mapping
+	index, err := bleve.New(indexPath, bleve.NewIndexMapping())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := index.Index(uuid.NewString(), map[string]string{"Key": "outdated"}); err != nil {
+		t.Fatal(err)
+	}
+	index.Close()
+
+	index, err = search.OpenIndex(indexPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer index.Close()
+
+	count, err := index.DocCount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("expected the outdated index to be emptied, got %d documents", count)
+	}
 }
 
-func openIndex(indexPath string) (bleve.Index, error) {
-	index, err := bleve.Open(indexPath)
-	if err == nil {
-		return index, nil
+// Maps test secret IDs back to their keys.
+var testKeys = map[string]string{}
+
+// Creates an index of the test secrets in a temporary directory.
+func createIndex(t *testing.T) bleve.Index {
+	index, err := search.OpenIndex(filepath.Join(t.TempDir(), "secret-index"))
+	if err != nil {
+		t.Fatal(err)
 	}
+	t.Cleanup(func() { index.Close() })
 
-	// Try create a new index
+	for _, secret := range testSecrets {
+		secret.ID = uuid.New()
+		testKeys[secret.ID.String()] = secret.Key
 
-	// Create a new index mapping
-	mapping := bleve.NewIndexMapping()
+		if err := search.AddSecret(index, &secret); err != nil {
+			t.Fatal(err)
+		}
+	}
 
-	return bleve.New(indexPath, mapping)
+	return index
 }
 
-func getTestSeretIndexPath() (string, error) {
-	// Load .env file
-	err := godotenv.Load("../../.env")
+func keysOf(secretIds []string) []string {
+	keys := make([]string, len(secretIds))
+	for i, id := range secretIds {
+		keys[i] = testKeys[id]
+	}
+	return keys
+}
+
+func TestSearchOrderAndPaging(t *testing.T) {
+	index := createIndex(t)
+
+	// Exact key matches come before substrings
+	result, err := search.Search(index, "gitlab-token OR token", search.Options{Limit: search.NoLimit})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Total != 2 || len(result.Hits) != 2 {
+		t.Fatalf("expected 2 hits, got %d of %d", len(result.Hits), result.Total)
+	}
+
+	if keysOf([]string{result.Hits[0].Id})[0] != "gitlab-token" {
+		t.Errorf("expected gitlab-token first, got %v", keysOf([]string{result.Hits[0].Id, result.Hits[1].Id}))
+	}
+
+	if result.Hits[0].Score < result.Hits[1].Score {
+		t.Error("expected hits in score order")
+	}
+
+	// Pages cover every hit exactly once, and the total counts all of them
+	var paged []string
+	for offset := 0; offset < 5; offset += 2 {
+		result, err := search.Search(index, "", search.Options{Limit: 2, Offset: offset})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if result.Total != 5 {
+			t.Errorf("expected a total of 5, got %d", result.Total)
+		}
+
+		for _, hit := range result.Hits {
+			paged = append(paged, hit.Id)
+		}
+	}
+
+	all, err := search.FindSecretIds(index, "")
 	if err != nil {
-		return "", err
+		t.Fatal(err)
 	}
 
-	indexPath := os.Getenv("TEST_SECRET_INDEX_PATH")
+	if !slices.Equal(paged, all) {
+		t.Errorf("expected pages %v to equal %v", keysOf(paged), keysOf(all))
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	index := createIndex(t)
+
+	highlight := &search.Highlight{Before: "[", After: "]"}
 
-	if indexPath == "" {
-		return "", errors.New("TEST_SECRET_INDEX_PATH is not set")
+	cases := []struct {
+		query     string
+		fragments map[string]string
+	}{
+		{"hub", map[string]string{"key": "git[hub]-token", "website": "https://git[hub].com"}},
+		{"key:git* -notes:old", map[string]string{"key": "[git]hub-token"}},
+		{"ACCESS token", map[string]string{"key": "github-[token]", "notes": "personal [access] [token]"}},
 	}
 
-	return indexPath, nil
+	for _, c := range cases {
+		t.Run(c.query, func(t *testing.T) {
+			result, err := search.Search(index, c.query, search.Options{Limit: 1, Highlight: highlight})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(result.Hits) != 1 {
+				t.Fatalf("expected 1 hit, got %d", len(result.Hits))
+			}
+
+			fragments := result.Hits[0].Fragments
+			if len(fragments) != len(c.fragments) {
+				t.Errorf("expected fragments %v, got %v", c.fragments, fragments)
+			}
+
+			for field, expected := range c.fragments {
+				if fragments[field] != expected {
+					t.Errorf("%s: expected %q, got %q", field, expected, fragments[field])
+				}
+			}
+		})
+	}
 }
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// Slows down guessing tokens by locking out clients after too many failed
+// authentications.
+//
+// Clients are told apart by their address. Every local process shares the
+// loopback address, so a lockout affects all of them until it ends.
+type failureLimiter struct {
+	mutex sync.Mutex
+
+	// Failures allowed within a window before the client is locked out
+	maxFailures int
+	window      time.Duration
+	lockout     time.Duration
+
+	clients map[string]*failures
+}
+
+// The recent failed authentications of a client.
+type failures struct {
+	count       int
+	windowStart time.Time
+	lockedUntil time.Time
+}
+
+func newFailureLimiter(maxFailures int, window time.Duration, lockout time.Duration) *failureLimiter {
+	return &failureLimiter{
+		maxFailures: maxFailures,
+		window:      window,
+		lockout:     lockout,
+		clients:     make(map[string]*failures),
+	}
+}
+
+// Returns how long the client is still locked out, or zero if it is not.
+func (limiter *failureLimiter) lockedOut(client string, now time.Time) time.Duration {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	record, ok := limiter.clients[client]
+	if !ok || !now.Before(record.lockedUntil) {
+		return 0
+	}
+
+	return record.lockedUntil.Sub(now)
+}
+
+// Records a failed authentication, locking the client out if it failed too
+// often.
+func (limiter *failureLimiter) fail(client string, now time.Time) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	record, ok := limiter.clients[client]
+	if !ok || now.Sub(record.windowStart) > limiter.window {
+		record = &failures{windowStart: now}
+		limiter.clients[client] = record
+	}
+
+	record.count++
+	if record.count >= limiter.maxFailures {
+		record.lockedUntil = now.Add(limiter.lockout)
+		record.count = 0
+		record.windowStart = record.lockedUntil
+	}
+}
+
+// Forgets the failures of a client that authenticated.
+func (limiter *failureLimiter) succeed(client string) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	delete(limiter.clients, client)
+}
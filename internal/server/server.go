@@ -0,0 +1,568 @@
+package server
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Isaac-Fate/myst/internal/audit"
+	mycrypto "github.com/Isaac-Fate/myst/internal/crypto"
+	"github.com/Isaac-Fate/myst/internal/manager"
+	"github.com/Isaac-Fate/myst/internal/models"
+	"github.com/Isaac-Fate/myst/internal/search"
+	"github.com/Isaac-Fate/myst/internal/utils"
+	"github.com/google/uuid"
+)
+
+// A REST API for programs to read and change secrets without shelling out.
+//
+// Every request but the OpenAPI description carries an API token as
+// "Authorization: Bearer <token>". Tokens may be read-only, and limited to
+// folders and tags: secrets outside of a token's scope are reported as not
+// found, and their keys as ones the token cannot use, so a token cannot
+// even tell whether they exist.
+//
+// The API is described by openapi.json, served at /v1/openapi.json.
+
+//go:embed openapi.json
+var openAPI []byte
+
+// Defaults for locking out clients that fail to authenticate.
+const (
+	defaultMaxFailures   int           = 5
+	defaultFailureWindow time.Duration = time.Minute
+	defaultLockout       time.Duration = time.Minute
+)
+
+// The number of secrets listed when a request does not say.
+const defaultLimit int = 100
+
+// The largest request body accepted.
+const maxBodySize int64 = 1 << 20
+
+// Options of a server.
+type Options struct {
+	// Failed authentications a client may make within FailureWindow before
+	// it is locked out for Lockout. Zero values mean the defaults.
+	MaxFailures   int
+	FailureWindow time.Duration
+	Lockout       time.Duration
+}
+
+// Serves the REST API.
+type Server struct {
+	manager    *manager.SecretManager
+	passphrase string
+	limiter    *failureLimiter
+	mux        *http.ServeMux
+
+	// The manager and the audit log are not safe for concurrent use, so
+	// requests are handled one at a time
+	mutex sync.Mutex
+}
+
+// Creates a server giving access to the secrets of the manager, which are
+// encrypted and decrypted with the passphrase.
+func New(secretManager *manager.SecretManager, passphrase string, options Options) *Server {
+	if options.MaxFailures <= 0 {
+		options.MaxFailures = defaultMaxFailures
+	}
+	if options.FailureWindow <= 0 {
+		options.FailureWindow = defaultFailureWindow
+	}
+	if options.Lockout <= 0 {
+		options.Lockout = defaultLockout
+	}
+
+	server := &Server{
+		manager:    secretManager,
+		passphrase: passphrase,
+		limiter:    newFailureLimiter(options.MaxFailures, options.FailureWindow, options.Lockout),
+		mux:        http.NewServeMux(),
+	}
+
+	server.mux.HandleFunc("GET /v1/openapi.json", server.serveOpenAPI)
+	server.mux.HandleFunc("GET /v1/secrets", server.authenticated(server.listSecrets))
+	server.mux.HandleFunc("POST /v1/secrets", server.authenticated(server.createSecret))
+	server.mux.HandleFunc("GET /v1/secrets/{key...}", server.authenticated(server.getSecret))
+	server.mux.HandleFunc("PATCH /v1/secrets/{key...}", server.authenticated(server.updateSecret))
+	server.mux.HandleFunc("DELETE /v1/secrets/{key...}", server.authenticated(server.removeSecret))
+	server.mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, "not found")
+	})
+
+	return server
+}
+
+func (server *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	server.mux.ServeHTTP(w, r)
+}
+
+// Listens on a TCP address such as "127.0.0.1:7755", or on a Unix socket
+// given as "unix:/path/to/socket".
+//
+// A stale socket file is replaced, and a new socket is only accessible by
+// the current user.
+func Listen(address string) (net.Listener, error) {
+	path, isUnix := strings.CutPrefix(address, "unix:")
+	if !isUnix {
+		return net.Listen("tcp", address)
+	}
+
+	if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	return listener, nil
+}
+
+// A handler of a request made with a valid token.
+type tokenHandler func(w http.ResponseWriter, r *http.Request, token *models.APIToken)
+
+// Checks the token of a request before handling it.
+func (server *Server) authenticated(handler tokenHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		client := clientAddress(r)
+		now := time.Now()
+
+		if wait := server.limiter.lockedOut(client, now); wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			writeError(w, http.StatusTooManyRequests, "too many failed authentications, try again later")
+			return
+		}
+
+		raw, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !found || raw == "" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "missing bearer token")
+			return
+		}
+
+		server.mutex.Lock()
+		defer server.mutex.Unlock()
+
+		token, err := server.manager.AuthenticateToken(raw)
+		if errors.Is(err, models.ErrTokenNotFound) {
+			server.limiter.fail(client, now)
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "invalid or expired token")
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		server.limiter.succeed(client)
+		handler(w, r, token)
+	}
+}
+
+// Returns the host a request comes from, without the port.
+func clientAddress(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+func (server *Server) serveOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPI)
+}
+
+// A secret as returned by the API. The value is only included when a single
+// secret is requested.
+type secretResponse struct {
+	Key         string     `json:"key"`
+	Value       string     `json:"value,omitempty"`
+	Username    string     `json:"username,omitempty"`
+	Website     string     `json:"website,omitempty"`
+	Notes       string     `json:"notes,omitempty"`
+	Folder      string     `json:"folder,omitempty"`
+	Tags        []string   `json:"tags"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	RotateEvery string     `json:"rotate_every,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// The fields of a secret to create or change. Fields left out are not
+// changed.
+type secretRequest struct {
+	Key         *string    `json:"key"`
+	Value       *string    `json:"value"`
+	Username    *string    `json:"username"`
+	Website     *string    `json:"website"`
+	Notes       *string    `json:"notes"`
+	Folder      *string    `json:"folder"`
+	Tags        *[]string  `json:"tags"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	RotateEvery *string    `json:"rotate_every"`
+}
+
+// A page of secrets.
+type listResponse struct {
+	Secrets []secretResponse `json:"secrets"`
+
+	// The number of secrets the token may see matching the query,
+	// regardless of the limit and offset
+	Total int `json:"total"`
+}
+
+func (server *Server) listSecrets(w http.ResponseWriter, r *http.Request, token *models.APIToken) {
+	limit, offset, err := paging(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var secrets []models.Secret
+	if query := r.URL.Query().Get("query"); query != "" {
+		secrets, err = server.manager.FindSecrets(query)
+	} else {
+		secrets, err = server.manager.ListSecrets()
+		slices.SortFunc(secrets, func(a, b models.Secret) int {
+			return strings.Compare(a.Key, b.Key)
+		})
+	}
+
+	if errors.Is(err, search.ErrInvalidQuery) {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// Leave out what the token may not see before paging, so that the
+	// total does not reveal it either
+	secrets = slices.DeleteFunc(secrets, func(secret models.Secret) bool {
+		return !token.Allows(&secret)
+	})
+
+	response := listResponse{Secrets: []secretResponse{}, Total: len(secrets)}
+	for i := offset; i < len(secrets) && i < offset+limit; i++ {
+		response.Secrets = append(response.Secrets, toResponse(&secrets[i]))
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (server *Server) getSecret(w http.ResponseWriter, r *http.Request, token *models.APIToken) {
+	secret, ok := server.findSecret(w, r, token)
+	if !ok {
+		return
+	}
+
+	// Record the access before handing out the value
+	if err := server.manager.RecordAccess(audit.ActionReveal, secret, tokenDetails(token)); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	value, err := mycrypto.Decrypt(server.passphrase, secret.EncryptedValue)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "failed to decrypt secret value")
+		return
+	}
+
+	response := toResponse(secret)
+	response.Value = value
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (server *Server) createSecret(w http.ResponseWriter, r *http.Request, token *models.APIToken) {
+	if token.ReadOnly {
+		writeError(w, http.StatusForbidden, "the token is read-only")
+		return
+	}
+
+	var request secretRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+
+	if request.Key == nil || *request.Key == "" || request.Value == nil || *request.Value == "" {
+		writeError(w, http.StatusBadRequest, "key and value are required")
+		return
+	}
+
+	secret := &models.Secret{ID: uuid.New()}
+	if err := server.apply(&request, secret); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !token.Allows(secret) {
+		writeError(w, http.StatusForbidden, "the secret would be outside the folders and tags of the token")
+		return
+	}
+
+	if err := server.manager.AddSecret(secret); err != nil {
+		server.writeSaveError(w, err, token, secret.Key)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, toResponse(secret))
+}
+
+func (server *Server) updateSecret(w http.ResponseWriter, r *http.Request, token *models.APIToken) {
+	if token.ReadOnly {
+		writeError(w, http.StatusForbidden, "the token is read-only")
+		return
+	}
+
+	secret, ok := server.findSecret(w, r, token)
+	if !ok {
+		return
+	}
+
+	var request secretRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+
+	if request.Key != nil && *request.Key == "" {
+		writeError(w, http.StatusBadRequest, "key cannot be empty")
+		return
+	}
+	if request.Value != nil && *request.Value == "" {
+		writeError(w, http.StatusBadRequest, "value cannot be empty")
+		return
+	}
+
+	if err := server.apply(&request, secret); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !token.Allows(secret) {
+		writeError(w, http.StatusForbidden, "the secret would be outside the folders and tags of the token")
+		return
+	}
+
+	if err := server.manager.UpdateSecret(secret); err != nil {
+		server.writeSaveError(w, err, token, secret.Key)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, toResponse(secret))
+}
+
+func (server *Server) removeSecret(w http.ResponseWriter, r *http.Request, token *models.APIToken) {
+	if token.ReadOnly {
+		writeError(w, http.StatusForbidden, "the token is read-only")
+		return
+	}
+
+	secret, ok := server.findSecret(w, r, token)
+	if !ok {
+		return
+	}
+
+	if err := server.manager.RemoveSecret(secret); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Gets the secret with the key in the path, writing an error response if
+// there is none the token may see.
+func (server *Server) findSecret(w http.ResponseWriter, r *http.Request, token *models.APIToken) (*models.Secret, bool) {
+	key := r.PathValue("key")
+
+	secret, err := server.manager.GetSecretByKey(key, manager.MatchExactKey)
+	if errors.Is(err, models.ErrSecretNotFound) || (err == nil && !token.Allows(secret)) {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("secret with key '%s' not found", key))
+		return nil, false
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+
+	return secret, true
+}
+
+// Applies the fields of a request to a secret.
+func (server *Server) apply(request *secretRequest, secret *models.Secret) error {
+	if request.Value != nil {
+		encryptedValue, err := mycrypto.Encrypt(server.passphrase, *request.Value)
+		if err != nil {
+			return err
+		}
+		secret.EncryptedValue = encryptedValue
+	}
+
+	if request.Tags != nil {
+		for _, tag := range *request.Tags {
+			if err := models.ValidateTag(tag); err != nil {
+				return err
+			}
+		}
+
+		secret.Tags = nil
+		for _, tag := range *request.Tags {
+			secret.AddTag(tag)
+		}
+	}
+
+	if request.RotateEvery != nil {
+		period := time.Duration(0)
+		if *request.RotateEvery != "" {
+			var err error
+			period, err = utils.ParseDuration(*request.RotateEvery)
+			if err != nil || period < 0 {
+				return fmt.Errorf("invalid rotation period '%s'", *request.RotateEvery)
+			}
+		}
+		secret.RotateEvery = period
+	}
+
+	for _, field := range []struct {
+		value  *string
+		target *string
+	}{
+		{request.Key, &secret.Key},
+		{request.Username, &secret.Username},
+		{request.Website, &secret.Website},
+		{request.Notes, &secret.Notes},
+		{request.Folder, &secret.Folder},
+	} {
+		if field.value != nil {
+			*field.target = *field.value
+		}
+	}
+
+	if request.ExpiresAt != nil {
+		secret.ExpiresAt = request.ExpiresAt
+	}
+
+	return nil
+}
+
+// Converts a secret to its response, without the value.
+func toResponse(secret *models.Secret) secretResponse {
+	response := secretResponse{
+		Key:       secret.Key,
+		Username:  secret.Username,
+		Website:   secret.Website,
+		Notes:     secret.Notes,
+		Folder:    secret.Folder,
+		Tags:      secret.Tags,
+		ExpiresAt: secret.ExpiresAt,
+		CreatedAt: secret.CreatedAt,
+		UpdatedAt: secret.UpdatedAt,
+	}
+
+	if response.Tags == nil {
+		response.Tags = []string{}
+	}
+
+	if secret.RotateEvery > 0 {
+		response.RotateEvery = utils.FormatDuration(secret.RotateEvery)
+	}
+
+	return response
+}
+
+// Reads the limit and offset of a list request.
+func paging(r *http.Request) (int, int, error) {
+	limit, offset := defaultLimit, 0
+
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return 0, 0, fmt.Errorf("invalid limit '%s'", value)
+		}
+		limit = n
+	}
+
+	if value := r.URL.Query().Get("offset"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return 0, 0, fmt.Errorf("invalid offset '%s'", value)
+		}
+		offset = n
+	}
+
+	return limit, offset, nil
+}
+
+// Describes the token in the audit log.
+func tokenDetails(token *models.APIToken) string {
+	return fmt.Sprintf("api token '%s'", token.Name)
+}
+
+// The body of error responses.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errorResponse{Error: message})
+}
+
+// Writes the error of adding or updating a secret with the key. A key taken
+// by a secret outside the scope of the token is refused without saying by
+// what, so that the token cannot tell the secret exists.
+func (server *Server) writeSaveError(w http.ResponseWriter, err error, token *models.APIToken, key string) {
+	var clashing *models.Secret
+	var lookupErr error
+	switch {
+	case errors.Is(err, models.ErrDuplicateKey):
+		clashing, lookupErr = server.manager.GetSecretByKey(key, manager.MatchExactKey)
+	case errors.Is(err, models.ErrKeyInTrash):
+		clashing, lookupErr = server.manager.GetTrashedSecretByKey(key)
+	default:
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if lookupErr != nil || !token.Allows(clashing) {
+		writeError(w, http.StatusForbidden, "the token cannot use this key")
+		return
+	}
+
+	if errors.Is(err, models.ErrKeyInTrash) {
+		writeError(w, http.StatusConflict, "a secret with this key is in the trash")
+	} else {
+		writeError(w, http.StatusConflict, "a secret with this key already exists")
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
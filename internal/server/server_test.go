@@ -0,0 +1,285 @@
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Isaac-Fate/myst/internal/manager"
+	"github.com/Isaac-Fate/myst/internal/models"
+	"github.com/Isaac-Fate/myst/internal/server"
+)
+
+const testPassphrase string = "hello, world"
+
+// A server running on an ephemeral port.
+type harness struct {
+	t       *testing.T
+	manager *manager.SecretManager
+	client  *http.Client
+	baseURL string
+}
+
+func startServer(t *testing.T, address string, options server.Options) *harness {
+	secretManager, err := manager.NewFileSecretManager(filepath.Join(t.TempDir(), "vault.myst"), testPassphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { secretManager.Close() })
+
+	listener, err := server.Listen(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	httpServer := &http.Server{Handler: server.New(secretManager, testPassphrase, options)}
+	go httpServer.Serve(listener)
+	t.Cleanup(func() { httpServer.Close() })
+
+	h := &harness{t: t, manager: secretManager, client: http.DefaultClient, baseURL: "http://" + listener.Addr().String()}
+
+	if listener.Addr().Network() == "unix" {
+		h.baseURL = "http://myst"
+		h.client = &http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", listener.Addr().String())
+			},
+		}}
+	}
+
+	return h
+}
+
+// Creates a token and returns it.
+func (h *harness) token(token models.APIToken) string {
+	raw, err := h.manager.CreateToken(&token)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+
+	return raw
+}
+
+// Makes a request, decoding the JSON response into out if given.
+func (h *harness) do(method string, path string, token string, body any, out any) int {
+	var reader bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reader).Encode(body); err != nil {
+			h.t.Fatal(err)
+		}
+	}
+
+	request, err := http.NewRequest(method, h.baseURL+path, &reader)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	response, err := h.client.Do(request)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	if out != nil {
+		if err := json.NewDecoder(response.Body).Decode(out); err != nil {
+			h.t.Fatal(err)
+		}
+	}
+
+	return response.StatusCode
+}
+
+type secret struct {
+	Key    string   `json:"key"`
+	Value  string   `json:"value"`
+	Folder string   `json:"folder"`
+	Tags   []string `json:"tags"`
+}
+
+type secretList struct {
+	Secrets []secret `json:"secrets"`
+	Total   int      `json:"total"`
+}
+
+func TestCreateGetUpdateDelete(t *testing.T) {
+	h := startServer(t, "127.0.0.1:0", server.Options{})
+	token := h.token(models.APIToken{Name: "ci"})
+
+	body := map[string]any{"key": "github-token", "value": "ghp_123", "folder": "work", "tags": []string{"ci"}}
+	if status := h.do("POST", "/v1/secrets", token, body, nil); status != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", status)
+	}
+
+	if status := h.do("POST", "/v1/secrets", token, body, nil); status != http.StatusConflict {
+		t.Errorf("expected 409 for a duplicate key, got %d", status)
+	}
+
+	var got secret
+	if status := h.do("GET", "/v1/secrets/github-token", token, nil, &got); status != http.StatusOK || got.Value != "ghp_123" {
+		t.Errorf("expected the value, got %d, %+v", status, got)
+	}
+
+	update := map[string]any{"value": "ghp_456", "key": "work/github-token"}
+	if status := h.do("PATCH", "/v1/secrets/github-token", token, update, nil); status != http.StatusOK {
+		t.Fatalf("expected 200, got %d", status)
+	}
+
+	// Keys may contain slashes
+	if status := h.do("GET", "/v1/secrets/work/github-token", token, nil, &got); status != http.StatusOK || got.Value != "ghp_456" || got.Folder != "work" {
+		t.Errorf("expected the updated secret, got %d, %+v", status, got)
+	}
+
+	var list secretList
+	if status := h.do("GET", "/v1/secrets?query=github", token, nil, &list); status != http.StatusOK || list.Total != 1 || list.Secrets[0].Value != "" {
+		t.Errorf("expected one secret without its value, got %d, %+v", status, list)
+	}
+
+	if status := h.do("GET", "/v1/secrets?query=key:(", token, nil, nil); status != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid query, got %d", status)
+	}
+
+	if status := h.do("DELETE", "/v1/secrets/work/github-token", token, nil, nil); status != http.StatusNoContent {
+		t.Errorf("expected 204, got %d", status)
+	}
+
+	if status := h.do("GET", "/v1/secrets/work/github-token", token, nil, nil); status != http.StatusNotFound {
+		t.Errorf("expected 404 after deleting, got %d", status)
+	}
+
+	// Removed secrets go to the trash
+	if trash, err := h.manager.ListTrash(); err != nil || len(trash) != 1 {
+		t.Errorf("expected the secret in the trash, got %v, %v", trash, err)
+	}
+}
+
+func TestScopes(t *testing.T) {
+	h := startServer(t, "127.0.0.1:0", server.Options{})
+	admin := h.token(models.APIToken{Name: "admin"})
+
+	for _, body := range []map[string]any{
+		{"key": "prod-db", "value": "x", "folder": "prod"},
+		{"key": "prod-api", "value": "x", "folder": "prod/api"},
+		{"key": "dev-db", "value": "x", "folder": "dev", "tags": []string{"shared"}},
+	} {
+		if status := h.do("POST", "/v1/secrets", admin, body, nil); status != http.StatusCreated {
+			t.Fatalf("expected 201, got %d", status)
+		}
+	}
+
+	// Folders include their subfolders
+	prod := h.token(models.APIToken{Name: "prod", Folders: []string{"prod"}})
+
+	var list secretList
+	if h.do("GET", "/v1/secrets", prod, nil, &list); list.Total != 2 {
+		t.Errorf("expected the 2 prod secrets, got %+v", list)
+	}
+
+	if status := h.do("GET", "/v1/secrets/dev-db", prod, nil, nil); status != http.StatusNotFound {
+		t.Errorf("expected 404 outside the folder, got %d", status)
+	}
+
+	body := map[string]any{"key": "dev-token", "value": "x", "folder": "dev"}
+	if status := h.do("POST", "/v1/secrets", prod, body, nil); status != http.StatusForbidden {
+		t.Errorf("expected 403 creating outside the folder, got %d", status)
+	}
+
+	if status := h.do("PATCH", "/v1/secrets/prod-db", prod, map[string]any{"folder": "dev"}, nil); status != http.StatusForbidden {
+		t.Errorf("expected 403 moving out of the folder, got %d", status)
+	}
+
+	// Keys taken outside the folder are refused without telling by what,
+	// whether the secret is in the trash or not
+	if status := h.do("DELETE", "/v1/secrets/prod-api", admin, nil, nil); status != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", status)
+	}
+	if status := h.do("POST", "/v1/secrets", admin, map[string]any{"key": "old-dev", "value": "x", "folder": "dev"}, nil); status != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", status)
+	}
+	if status := h.do("DELETE", "/v1/secrets/old-dev", admin, nil, nil); status != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", status)
+	}
+
+	for _, test := range []struct {
+		key    string
+		status int
+	}{
+		{"dev-db", http.StatusForbidden},
+		{"old-dev", http.StatusForbidden},
+		{"prod-db", http.StatusConflict},
+		{"prod-api", http.StatusConflict},
+	} {
+		body := map[string]any{"key": test.key, "value": "x", "folder": "prod"}
+		if status := h.do("POST", "/v1/secrets", prod, body, nil); status != test.status {
+			t.Errorf("expected %d creating '%s', got %d", test.status, test.key, status)
+		}
+	}
+
+	if status := h.do("PATCH", "/v1/secrets/prod-db", prod, map[string]any{"key": "dev-db"}, nil); status != http.StatusForbidden {
+		t.Errorf("expected 403 renaming to a key outside the folder, got %d", status)
+	}
+
+	shared := h.token(models.APIToken{Name: "shared", Tags: []string{"shared"}, ReadOnly: true})
+
+	if h.do("GET", "/v1/secrets", shared, nil, &list); list.Total != 1 || list.Secrets[0].Key != "dev-db" {
+		t.Errorf("expected only the shared secret, got %+v", list)
+	}
+
+	if status := h.do("DELETE", "/v1/secrets/dev-db", shared, nil, nil); status != http.StatusForbidden {
+		t.Errorf("expected 403 for a read-only token, got %d", status)
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	h := startServer(t, "127.0.0.1:0", server.Options{MaxFailures: 3, Lockout: time.Hour})
+
+	expiresAt := time.Now().Add(-time.Minute)
+	expired := h.token(models.APIToken{Name: "old", ExpiresAt: &expiresAt})
+	valid := h.token(models.APIToken{Name: "valid"})
+
+	if status := h.do("GET", "/v1/secrets", "", nil, nil); status != http.StatusUnauthorized {
+		t.Errorf("expected 401 without a token, got %d", status)
+	}
+
+	if status := h.do("GET", "/v1/secrets", expired, nil, nil); status != http.StatusUnauthorized {
+		t.Errorf("expected 401 for an expired token, got %d", status)
+	}
+
+	if status := h.do("GET", "/v1/secrets", valid, nil, nil); status != http.StatusOK {
+		t.Errorf("expected 200 for a valid token, got %d", status)
+	}
+
+	// The OpenAPI description needs no token
+	var description map[string]any
+	if status := h.do("GET", "/v1/openapi.json", "", nil, &description); status != http.StatusOK || description["openapi"] == nil {
+		t.Errorf("expected the OpenAPI description, got %d", status)
+	}
+
+	// Too many failures lock the client out, even with a valid token
+	for i := 0; i < 3; i++ {
+		if status := h.do("GET", "/v1/secrets", "myst_guess", nil, nil); status != http.StatusUnauthorized {
+			t.Errorf("expected 401 for a wrong token, got %d", status)
+		}
+	}
+
+	if status := h.do("GET", "/v1/secrets", valid, nil, nil); status != http.StatusTooManyRequests {
+		t.Errorf("expected 429 after too many failures, got %d", status)
+	}
+}
+
+func TestUnixSocket(t *testing.T) {
+	h := startServer(t, "unix:"+filepath.Join(t.TempDir(), "myst.sock"), server.Options{})
+	token := h.token(models.APIToken{Name: "local"})
+
+	if status := h.do("GET", "/v1/secrets", token, nil, nil); status != http.StatusOK {
+		t.Errorf("expected 200 over the socket, got %d", status)
+	}
+}
@@ -0,0 +1,235 @@
+package session
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Isaac-Fate/myst/internal/models"
+)
+
+// Returned when the server rejects the API token.
+var ErrUnauthorized = errors.New("the server rejected the API token")
+
+// How many secrets are listed per request.
+const pageSize int = 100
+
+// How long a request to the server may take.
+const requestTimeout time.Duration = 30 * time.Second
+
+// A session on a running 'myst serve', reached through its REST API.
+type remoteSession struct {
+	client  *http.Client
+	baseURL string
+	token   string
+}
+
+// Creates a session on the server listening on the address, such as
+// "127.0.0.1:7755", "http://127.0.0.1:7755" or "unix:/path/to/socket",
+// authenticating with the API token.
+func Remote(address string, token string) Session {
+	session := &remoteSession{
+		client:  &http.Client{Timeout: requestTimeout},
+		baseURL: strings.TrimSuffix(address, "/"),
+		token:   token,
+	}
+
+	if path, isUnix := strings.CutPrefix(address, "unix:"); isUnix {
+		session.baseURL = "http://myst"
+		session.client.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", path)
+			},
+		}
+	} else if !strings.Contains(address, "://") {
+		session.baseURL = "http://" + session.baseURL
+	}
+
+	return session
+}
+
+// A secret as sent and returned by the API.
+type remoteSecret struct {
+	Key       string     `json:"key"`
+	Value     string     `json:"value,omitempty"`
+	Username  string     `json:"username,omitempty"`
+	Website   string     `json:"website,omitempty"`
+	Notes     string     `json:"notes,omitempty"`
+	Folder    string     `json:"folder,omitempty"`
+	Tags      []string   `json:"tags,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Converts a secret returned by the API, leaving out its value.
+func (secret *remoteSecret) toModel() models.Secret {
+	return models.Secret{
+		Key:       secret.Key,
+		Username:  secret.Username,
+		Website:   secret.Website,
+		Notes:     secret.Notes,
+		Folder:    secret.Folder,
+		Tags:      secret.Tags,
+		ExpiresAt: secret.ExpiresAt,
+	}
+}
+
+func (session *remoteSession) Find(ctx context.Context, query string) ([]models.Secret, error) {
+	var secrets []models.Secret
+
+	// Page through the results until all are in
+	for {
+		parameters := url.Values{}
+		parameters.Set("limit", strconv.Itoa(pageSize))
+		parameters.Set("offset", strconv.Itoa(len(secrets)))
+		if query != "" {
+			parameters.Set("query", query)
+		}
+
+		var page struct {
+			Secrets []remoteSecret `json:"secrets"`
+			Total   int            `json:"total"`
+		}
+		if err := session.do(ctx, http.MethodGet, "/v1/secrets?"+parameters.Encode(), nil, &page); err != nil {
+			return nil, err
+		}
+
+		for _, secret := range page.Secrets {
+			secrets = append(secrets, secret.toModel())
+		}
+
+		if len(page.Secrets) == 0 || len(secrets) >= page.Total {
+			return secrets, nil
+		}
+	}
+}
+
+func (session *remoteSession) Get(ctx context.Context, key string) (*models.Secret, string, error) {
+	var secret remoteSecret
+	if err := session.do(ctx, http.MethodGet, secretPath(key), nil, &secret); err != nil {
+		return nil, "", err
+	}
+
+	model := secret.toModel()
+	return &model, secret.Value, nil
+}
+
+func (session *remoteSession) Add(ctx context.Context, secret *models.Secret, value string) error {
+	body := remoteSecret{
+		Key:      secret.Key,
+		Value:    value,
+		Username: secret.Username,
+		Website:  secret.Website,
+		Notes:    secret.Notes,
+		Folder:   secret.Folder,
+		Tags:     secret.Tags,
+	}
+
+	return session.do(ctx, http.MethodPost, "/v1/secrets", body, nil)
+}
+
+func (session *remoteSession) Update(ctx context.Context, key string, secret *models.Secret, value string) error {
+	tags := secret.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	// Send every field, as fields left out are not changed
+	body := map[string]any{
+		"value":    value,
+		"username": secret.Username,
+		"website":  secret.Website,
+		"notes":    secret.Notes,
+		"folder":   secret.Folder,
+		"tags":     tags,
+	}
+
+	return session.do(ctx, http.MethodPatch, secretPath(key), body, nil)
+}
+
+func (session *remoteSession) Remove(ctx context.Context, key string) error {
+	return session.do(ctx, http.MethodDelete, secretPath(key), nil, nil)
+}
+
+func (session *remoteSession) Close() error {
+	session.client.CloseIdleConnections()
+	return nil
+}
+
+// Returns the path of the secret with the key. Keys may contain slashes,
+// which are kept.
+func secretPath(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+
+	return "/v1/secrets/" + strings.Join(segments, "/")
+}
+
+// Makes a request, encoding the body and decoding the response into out if
+// given. Error responses are turned into the errors of the models package
+// where there is one.
+func (session *remoteSession) do(ctx context.Context, method string, path string, body any, out any) error {
+	var reader io.Reader
+	if body != nil {
+		content, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(content)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, session.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Authorization", "Bearer "+session.token)
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err := session.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("failed to reach the server: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		var errorResponse struct {
+			Error string `json:"error"`
+		}
+		json.NewDecoder(response.Body).Decode(&errorResponse)
+
+		switch response.StatusCode {
+		case http.StatusUnauthorized:
+			return ErrUnauthorized
+		case http.StatusNotFound:
+			return models.ErrSecretNotFound
+		case http.StatusConflict:
+			if strings.Contains(errorResponse.Error, "trash") {
+				return models.ErrKeyInTrash
+			}
+			return models.ErrDuplicateKey
+		default:
+			return fmt.Errorf("server responded with %d: %s", response.StatusCode, errorResponse.Error)
+		}
+	}
+
+	if out != nil {
+		if err := json.NewDecoder(response.Body).Decode(out); err != nil {
+			return fmt.Errorf("failed to read the server response: %w", err)
+		}
+	}
+
+	return nil
+}
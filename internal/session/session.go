@@ -0,0 +1,163 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/Isaac-Fate/myst/internal/audit"
+	mycrypto "github.com/Isaac-Fate/myst/internal/crypto"
+	"github.com/Isaac-Fate/myst/internal/manager"
+	"github.com/Isaac-Fate/myst/internal/models"
+)
+
+// A session gives helpers, such as the git credential helper, access to an
+// unlocked vault: either one unlocked in the same process, or a running
+// 'myst serve' reached with an API token, so that the passphrase need not be
+// entered every time a helper runs.
+type Session interface {
+	// Finds the secrets matching the query, best first, without their
+	// values. An empty query lists every secret by key.
+	Find(ctx context.Context, query string) ([]models.Secret, error)
+
+	// Gets the secret with the key and its value, recording the access in
+	// the audit log.
+	Get(ctx context.Context, key string) (*models.Secret, string, error)
+
+	// Adds a secret with the value. Only the key, username, website, notes,
+	// folder and tags of the secret are used.
+	Add(ctx context.Context, secret *models.Secret, value string) error
+
+	// Replaces the value, username, website, notes, folder and tags of the
+	// secret with the key by those of the secret.
+	Update(ctx context.Context, key string, secret *models.Secret, value string) error
+
+	// Moves the secret with the key to the trash.
+	Remove(ctx context.Context, key string) error
+
+	Close() error
+}
+
+// A session on a secret manager unlocked in this process.
+type localSession struct {
+	manager    *manager.SecretManager
+	passphrase string
+
+	// Recorded in the audit log with every access
+	details string
+}
+
+// Creates a session on an unlocked secret manager, which is closed with the
+// session. Accesses are recorded in the audit log with the details, such as
+// the name of the helper.
+func Local(secretManager *manager.SecretManager, passphrase string, details string) Session {
+	return &localSession{manager: secretManager, passphrase: passphrase, details: details}
+}
+
+func (session *localSession) Find(ctx context.Context, query string) ([]models.Secret, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if query != "" {
+		return session.manager.FindSecrets(query)
+	}
+
+	secrets, err := session.manager.ListSecrets()
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(secrets, func(a, b models.Secret) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+
+	return secrets, nil
+}
+
+func (session *localSession) Get(ctx context.Context, key string) (*models.Secret, string, error) {
+	secret, err := session.get(ctx, key)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Record the access before handing out the value
+	if err := session.manager.RecordAccess(audit.ActionReveal, secret, session.details); err != nil {
+		return nil, "", err
+	}
+
+	value, err := mycrypto.Decrypt(session.passphrase, secret.EncryptedValue)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decrypt secret value: %w", err)
+	}
+
+	return secret, value, nil
+}
+
+func (session *localSession) Add(ctx context.Context, secret *models.Secret, value string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	encryptedValue, err := mycrypto.Encrypt(session.passphrase, value)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt secret value: %w", err)
+	}
+
+	return session.manager.AddSecret(&models.Secret{
+		Key:            secret.Key,
+		EncryptedValue: encryptedValue,
+		Username:       secret.Username,
+		Website:        secret.Website,
+		Notes:          secret.Notes,
+		Folder:         secret.Folder,
+		Tags:           secret.Tags,
+	})
+}
+
+func (session *localSession) Update(ctx context.Context, key string, secret *models.Secret, value string) error {
+	existing, err := session.get(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	// Leave an unchanged value as it is, so that the secret does not look
+	// rotated
+	if current, err := mycrypto.Decrypt(session.passphrase, existing.EncryptedValue); err != nil || current != value {
+		existing.EncryptedValue, err = mycrypto.Encrypt(session.passphrase, value)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt secret value: %w", err)
+		}
+	}
+
+	existing.Username = secret.Username
+	existing.Website = secret.Website
+	existing.Notes = secret.Notes
+	existing.Folder = secret.Folder
+	existing.Tags = secret.Tags
+
+	return session.manager.UpdateSecret(existing)
+}
+
+func (session *localSession) Remove(ctx context.Context, key string) error {
+	secret, err := session.get(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	return session.manager.RemoveSecret(secret)
+}
+
+func (session *localSession) Close() error {
+	return session.manager.Close()
+}
+
+// Gets the secret with exactly the key.
+func (session *localSession) get(ctx context.Context, key string) (*models.Secret, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return session.manager.GetSecretByKey(key, manager.MatchExactKey)
+}
@@ -0,0 +1,113 @@
+package session_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/Isaac-Fate/myst/internal/manager"
+	"github.com/Isaac-Fate/myst/internal/models"
+	"github.com/Isaac-Fate/myst/internal/server"
+	"github.com/Isaac-Fate/myst/internal/session"
+	"github.com/Isaac-Fate/myst/internal/session/sessiontest"
+)
+
+// Starts a server on a Unix socket and returns its address and a token.
+func startServer(t *testing.T, secretManager *manager.SecretManager) (string, string) {
+	address := "unix:" + filepath.Join(t.TempDir(), "myst.sock")
+
+	listener, err := server.Listen(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	httpServer := &http.Server{Handler: server.New(secretManager, sessiontest.Passphrase, server.Options{})}
+	go httpServer.Serve(listener)
+	t.Cleanup(func() { httpServer.Close() })
+
+	token, err := secretManager.CreateToken(&models.APIToken{Name: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return address, token
+}
+
+// Runs the test with a local session and with a session on a server.
+func forEachSession(t *testing.T, fn func(t *testing.T, s session.Session)) {
+	t.Run("local", func(t *testing.T) {
+		fn(t, sessiontest.Open(t, "test"))
+	})
+
+	t.Run("remote", func(t *testing.T) {
+		secretManager := sessiontest.NewSecretManager(t)
+		t.Cleanup(func() { secretManager.Close() })
+
+		address, token := startServer(t, secretManager)
+
+		s := session.Remote(address, token)
+		t.Cleanup(func() { s.Close() })
+
+		fn(t, s)
+	})
+}
+
+func TestSessions(t *testing.T) {
+	forEachSession(t, func(t *testing.T, s session.Session) {
+		ctx := context.Background()
+
+		secret := &models.Secret{Key: "git/alice@github.com", Username: "alice", Website: "https://github.com", Tags: []string{"git"}}
+		if err := s.Add(ctx, secret, "ghp_123"); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := s.Add(ctx, secret, "ghp_123"); !errors.Is(err, models.ErrDuplicateKey) {
+			t.Errorf("expected ErrDuplicateKey, got %v", err)
+		}
+
+		secrets, err := s.Find(ctx, "")
+		if err != nil || len(secrets) != 1 || secrets[0].Website != "https://github.com" || secrets[0].Username != "alice" {
+			t.Fatalf("expected the added secret, got %+v, %v", secrets, err)
+		}
+
+		secret.Username = "alice-work"
+		secret.Tags = nil
+		if err := s.Update(ctx, "git/alice@github.com", secret, "ghp_456"); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, value, err := s.Get(ctx, "git/alice@github.com"); err != nil || value != "ghp_456" {
+			t.Errorf("expected the new value, got %q, %v", value, err)
+		}
+
+		if secrets, err := s.Find(ctx, ""); err != nil || secrets[0].Username != "alice-work" || len(secrets[0].Tags) != 0 {
+			t.Errorf("expected the updated fields, got %+v, %v", secrets, err)
+		}
+
+		if err := s.Remove(ctx, "git/alice@github.com"); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, _, err := s.Get(ctx, "git/alice@github.com"); !errors.Is(err, models.ErrSecretNotFound) {
+			t.Errorf("expected ErrSecretNotFound after removing, got %v", err)
+		}
+
+		if err := s.Add(ctx, secret, "ghp_789"); !errors.Is(err, models.ErrKeyInTrash) {
+			t.Errorf("expected ErrKeyInTrash, got %v", err)
+		}
+	})
+}
+
+func TestRemoteUnauthorized(t *testing.T) {
+	secretManager := sessiontest.NewSecretManager(t)
+	t.Cleanup(func() { secretManager.Close() })
+
+	address, _ := startServer(t, secretManager)
+
+	s := session.Remote(address, "myst_wrong")
+	if _, err := s.Find(context.Background(), ""); !errors.Is(err, session.ErrUnauthorized) {
+		t.Errorf("expected ErrUnauthorized, got %v", err)
+	}
+}
@@ -0,0 +1,37 @@
+// Package sessiontest opens sessions on throwaway vaults for tests.
+package sessiontest
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/Isaac-Fate/myst/internal/manager"
+	"github.com/Isaac-Fate/myst/internal/session"
+)
+
+// The passphrase of the vaults.
+const Passphrase string = "hello, world"
+
+// Creates a secret manager on a vault file in a temporary directory. Closing
+// it is up to the test.
+func NewSecretManager(t *testing.T) *manager.SecretManager {
+	t.Helper()
+
+	secretManager, err := manager.NewFileSecretManager(filepath.Join(t.TempDir(), "vault.myst"), Passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return secretManager
+}
+
+// Opens a local session for the client on a new vault, closed when the test
+// ends.
+func Open(t *testing.T, client string) session.Session {
+	t.Helper()
+
+	s := session.Local(NewSecretManager(t), Passphrase, client)
+	t.Cleanup(func() { s.Close() })
+
+	return s
+}
@@ -0,0 +1,121 @@
+package shamir
+
+import (
+	"crypto/rand"
+	"errors"
+)
+
+// Shamir's secret sharing over GF(2^8).
+//
+// Every byte of the secret is the constant term of its own random polynomial
+// of degree threshold-1. A share holds the values of all these polynomials at
+// one non-zero point x. Any threshold shares determine the polynomials, and
+// thus the secret, by Lagrange interpolation at x = 0, while fewer shares
+// reveal nothing about it.
+
+// A single share of a secret.
+type Share struct {
+	// The point the polynomials were evaluated at, never 0
+	X byte
+
+	// One value per byte of the secret
+	Y []byte
+}
+
+// Splits the secret into n shares of which any threshold can reconstruct it.
+func Split(secret []byte, n int, threshold int) ([]Share, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("cannot split an empty secret")
+	}
+
+	if threshold < 2 {
+		return nil, errors.New("threshold must be at least 2")
+	}
+
+	if n < threshold {
+		return nil, errors.New("number of shares must be at least the threshold")
+	}
+
+	if n > 255 {
+		return nil, errors.New("number of shares must be at most 255")
+	}
+
+	shares := make([]Share, n)
+	for i := range shares {
+		shares[i] = Share{X: byte(i + 1), Y: make([]byte, len(secret))}
+	}
+
+	// Coefficients of the polynomial of the current byte, constant term first
+	coefficients := make([]byte, threshold)
+
+	for j, b := range secret {
+		coefficients[0] = b
+		if _, err := rand.Read(coefficients[1:]); err != nil {
+			return nil, err
+		}
+
+		for i := range shares {
+			shares[i].Y[j] = evaluate(coefficients, shares[i].X)
+		}
+	}
+
+	return shares, nil
+}
+
+// Reconstructs the secret from at least threshold shares.
+//
+// Combining fewer shares than the threshold, or shares of different secrets,
+// silently yields a wrong secret, so callers should verify the result.
+func Combine(shares []Share) ([]byte, error) {
+	if len(shares) < 2 {
+		return nil, errors.New("at least 2 shares are needed")
+	}
+
+	length := len(shares[0].Y)
+
+	for i, share := range shares {
+		if share.X == 0 {
+			return nil, errors.New("invalid share")
+		}
+
+		if len(share.Y) != length {
+			return nil, errors.New("shares have different lengths")
+		}
+
+		for _, other := range shares[:i] {
+			if other.X == share.X {
+				return nil, errors.New("duplicate share")
+			}
+		}
+	}
+
+	secret := make([]byte, length)
+
+	for i, share := range shares {
+		// Lagrange basis polynomial of this share evaluated at 0
+		basis := byte(1)
+		for j, other := range shares {
+			if i != j {
+				// x_j / (x_j - x_i), where subtraction is XOR
+				basis = mul(basis, div(other.X, other.X^share.X))
+			}
+		}
+
+		for k := range secret {
+			secret[k] ^= mul(share.Y[k], basis)
+		}
+	}
+
+	return secret, nil
+}
+
+// Evaluates the polynomial at x with Horner's method.
+func evaluate(coefficients []byte, x byte) byte {
+	result := byte(0)
+
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		result = mul(result, x) ^ coefficients[i]
+	}
+
+	return result
+}
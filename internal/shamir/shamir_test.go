@@ -0,0 +1,82 @@
+package shamir_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Isaac-Fate/myst/internal/shamir"
+)
+
+func TestSplitCombine(t *testing.T) {
+	secret := []byte("a 32 byte recovery key goes here")
+
+	shares, err := shamir.Split(secret, 5, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(shares) != 5 {
+		t.Fatalf("expected 5 shares, got %d", len(shares))
+	}
+
+	// Every combination of 3 shares recovers the secret
+	for i := 0; i < 5; i++ {
+		for j := i + 1; j < 5; j++ {
+			for k := j + 1; k < 5; k++ {
+				recovered, err := shamir.Combine([]shamir.Share{shares[i], shares[j], shares[k]})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !bytes.Equal(recovered, secret) {
+					t.Errorf("shares %d, %d, %d recovered %q", i, j, k, recovered)
+				}
+			}
+		}
+	}
+
+	// So do all 5
+	recovered, err := shamir.Combine(shares)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(recovered, secret) {
+		t.Errorf("all shares recovered %q", recovered)
+	}
+
+	// 2 shares do not
+	recovered, err = shamir.Combine(shares[:2])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Equal(recovered, secret) {
+		t.Error("2 shares recovered the secret with a threshold of 3")
+	}
+}
+
+func TestInvalidParameters(t *testing.T) {
+	secret := []byte("secret")
+
+	if _, err := shamir.Split(secret, 5, 1); err == nil {
+		t.Error("expected an error for a threshold of 1")
+	}
+
+	if _, err := shamir.Split(secret, 2, 3); err == nil {
+		t.Error("expected an error for fewer shares than the threshold")
+	}
+
+	if _, err := shamir.Split(nil, 5, 3); err == nil {
+		t.Error("expected an error for an empty secret")
+	}
+
+	shares, err := shamir.Split(secret, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := shamir.Combine([]shamir.Share{shares[0], shares[0]}); err == nil {
+		t.Error("expected an error for duplicate shares")
+	}
+}
@@ -0,0 +1,163 @@
+package sharing
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+
+	"filippo.io/age"
+	"github.com/Isaac-Fate/myst/internal/models"
+	"gopkg.in/yaml.v3"
+)
+
+// The teammates secrets can be shared with.
+//
+// It maps names to public keys and lists the teammates every secret in a
+// folder is shared with by default. It holds no secrets and is stored in
+// plain YAML.
+type AddressBook struct {
+	// Public keys by name
+	Recipients map[string]string `yaml:"recipients"`
+
+	// Recipient names by folder
+	Folders map[string][]string `yaml:"folders,omitempty"`
+}
+
+// Loads the address book, starting empty if it does not exist.
+func LoadAddressBook(path string) (*AddressBook, error) {
+	book := &AddressBook{}
+
+	content, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(content, book); err != nil {
+		return nil, err
+	}
+
+	if book.Recipients == nil {
+		book.Recipients = make(map[string]string)
+	}
+	if book.Folders == nil {
+		book.Folders = make(map[string][]string)
+	}
+
+	return book, nil
+}
+
+func (book *AddressBook) Save(path string) error {
+	content, err := yaml.Marshal(book)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, content, 0644)
+}
+
+// Adds or replaces a recipient after checking that the public key is valid.
+func (book *AddressBook) AddRecipient(name string, recipient string) error {
+	if name == "" {
+		return errors.New("recipient name cannot be empty")
+	}
+
+	if _, err := age.ParseX25519Recipient(recipient); err != nil {
+		return fmt.Errorf("invalid public key for '%s': %w", name, err)
+	}
+
+	book.Recipients[name] = recipient
+
+	return nil
+}
+
+// Removes a recipient and drops it from every folder.
+func (book *AddressBook) RemoveRecipient(name string) error {
+	if _, ok := book.Recipients[name]; !ok {
+		return fmt.Errorf("unknown recipient '%s'", name)
+	}
+
+	delete(book.Recipients, name)
+
+	for folder := range book.Folders {
+		book.UnassignFolder(folder, name)
+	}
+
+	return nil
+}
+
+// Shares every secret in the folder with the recipient by default.
+func (book *AddressBook) AssignFolder(folder string, name string) error {
+	if _, ok := book.Recipients[name]; !ok {
+		return fmt.Errorf("unknown recipient '%s'", name)
+	}
+
+	if !slices.Contains(book.Folders[folder], name) {
+		book.Folders[folder] = append(book.Folders[folder], name)
+	}
+
+	return nil
+}
+
+// Stops sharing the folder with the recipient by default.
+func (book *AddressBook) UnassignFolder(folder string, name string) {
+	names := slices.DeleteFunc(book.Folders[folder], func(n string) bool {
+		return n == name
+	})
+
+	if len(names) == 0 {
+		delete(book.Folders, folder)
+	} else {
+		book.Folders[folder] = names
+	}
+}
+
+// Returns the name of the recipient with the public key, reporting whether
+// there is one.
+func (book *AddressBook) NameOf(recipient string) (string, bool) {
+	for _, name := range slices.Sorted(maps.Keys(book.Recipients)) {
+		if book.Recipients[name] == recipient {
+			return name, true
+		}
+	}
+
+	return "", false
+}
+
+// Returns the public keys of the named recipients.
+func (book *AddressBook) Resolve(names []string) ([]age.Recipient, error) {
+	recipients := make([]age.Recipient, 0, len(names))
+
+	for _, name := range names {
+		publicKey, ok := book.Recipients[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown recipient '%s', add it with 'myst recipient add'", name)
+		}
+
+		recipient, err := age.ParseX25519Recipient(publicKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid public key for '%s': %w", name, err)
+		}
+
+		recipients = append(recipients, recipient)
+	}
+
+	return recipients, nil
+}
+
+// Returns the names of the recipients a secret is shared with by default,
+// which are its own recipients followed by those of its folder.
+func (book *AddressBook) RecipientsOf(secret *models.Secret) []string {
+	names := slices.Clone(secret.Recipients)
+
+	if secret.Folder != "" {
+		for _, name := range book.Folders[secret.Folder] {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+
+	return names
+}
@@ -0,0 +1,187 @@
+package sharing
+
+import (
+	"crypto/hmac"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+)
+
+// A bundle is a set of decrypted secrets encrypted to one or more recipients
+// with age and armored as text, so it can be pasted into a chat or an email:
+//
+//	-----BEGIN AGE ENCRYPTED FILE-----
+//	...
+//	-----END AGE ENCRYPTED FILE-----
+//
+// The encrypted payload is the JSON encoding of an envelope holding the
+// JSON encoding of Bundle and, for each recipient, a MAC proving that the
+// sender holds the private key of FromRecipient, see keys.go.
+
+const bundleVersion int = 2
+
+var (
+	// Returned when no recipient was given.
+	ErrNoRecipients = errors.New("no recipients to share with")
+
+	// Returned by Open when the bundle was not sent by the holder of the
+	// key it names as its sender.
+	ErrForgedSender = errors.New("the bundle is not authenticated by the key of its sender")
+)
+
+// A secret with its value in plaintext, as carried inside a bundle.
+type SharedSecret struct {
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+	Kind     string `json:"kind,omitempty"`
+	Username string `json:"username,omitempty"`
+	Website  string `json:"website,omitempty"`
+	Notes    string `json:"notes,omitempty"`
+	Folder   string `json:"folder,omitempty"`
+}
+
+// The decrypted content of a bundle.
+type Bundle struct {
+	Version int `json:"version"`
+
+	// The name and public key of the sender
+	From          string `json:"from"`
+	FromRecipient string `json:"from_recipient"`
+
+	CreatedAt time.Time      `json:"created_at"`
+	Secrets   []SharedSecret `json:"secrets"`
+
+	// The identity the bundle is sent by, which authenticates it when sealed
+	sender *Identity
+}
+
+// The encrypted payload of a bundle.
+type envelope struct {
+	// The JSON encoding of the Bundle
+	Bundle json.RawMessage `json:"bundle"`
+
+	// The MACs of Bundle by the public key of each recipient
+	MACs map[string][]byte `json:"macs"`
+}
+
+// Creates a bundle sent by the identity.
+func NewBundle(from *Identity, secrets []SharedSecret) *Bundle {
+	return &Bundle{
+		Version:       bundleVersion,
+		From:          from.Name,
+		FromRecipient: from.Recipient(),
+		CreatedAt:     time.Now(),
+		Secrets:       secrets,
+		sender:        from,
+	}
+}
+
+// Encrypts the bundle to the recipients, authenticated by the identity it
+// was created with, and writes it armored to dst.
+func Seal(dst io.Writer, bundle *Bundle, recipients ...age.Recipient) error {
+	if len(recipients) == 0 {
+		return ErrNoRecipients
+	}
+
+	if bundle.sender == nil {
+		return errors.New("the bundle has no sender to authenticate it")
+	}
+
+	encodedBundle, err := json.Marshal(bundle)
+	if err != nil {
+		return err
+	}
+
+	sealed := envelope{Bundle: encodedBundle, MACs: make(map[string][]byte, len(recipients))}
+	for _, recipient := range recipients {
+		x25519Recipient, ok := recipient.(*age.X25519Recipient)
+		if !ok {
+			return errors.New("bundles can only be shared with X25519 recipients")
+		}
+
+		publicKey := x25519Recipient.String()
+		if sealed.MACs[publicKey], err = bundle.sender.mac(publicKey, encodedBundle); err != nil {
+			return fmt.Errorf("failed to authenticate the bundle: %w", err)
+		}
+	}
+
+	payload, err := json.Marshal(sealed)
+	if err != nil {
+		return err
+	}
+
+	armorWriter := armor.NewWriter(dst)
+
+	encryptWriter, err := age.Encrypt(armorWriter, recipients...)
+	if err != nil {
+		return err
+	}
+
+	if _, err := encryptWriter.Write(payload); err != nil {
+		return err
+	}
+
+	// Close both writers to flush the last chunk and the armor footer
+	if err := encryptWriter.Close(); err != nil {
+		return err
+	}
+
+	return armorWriter.Close()
+}
+
+// Decrypts an armored bundle with the identity, checking that it was sent
+// by the holder of the private key of FromRecipient.
+//
+// Anyone can claim any name and key, so whether the key is that of a known
+// teammate must be checked in the address book, see AddressBook.NameOf.
+func Open(src io.Reader, identity *Identity) (*Bundle, error) {
+	decrypted, err := age.Decrypt(armor.NewReader(src), identity.identity)
+	if err != nil {
+		var noMatch *age.NoIdentityMatchError
+		if errors.As(err, &noMatch) {
+			return nil, errors.New("the bundle was not shared with your identity")
+		}
+
+		return nil, err
+	}
+
+	payload, err := io.ReadAll(decrypted)
+	if err != nil {
+		return nil, err
+	}
+
+	var sealed envelope
+	if err := json.Unmarshal(payload, &sealed); err != nil {
+		return nil, err
+	}
+
+	if len(sealed.Bundle) == 0 {
+		return nil, errors.New("unsupported bundle version, ask the sender to share it again with a newer myst")
+	}
+
+	var bundle Bundle
+	if err := json.Unmarshal(sealed.Bundle, &bundle); err != nil {
+		return nil, err
+	}
+
+	if bundle.Version != bundleVersion {
+		return nil, errors.New("unsupported bundle version")
+	}
+
+	// The MAC the sender computed for this identity must match the one this
+	// identity computes for the sender
+	expected, err := identity.mac(bundle.FromRecipient, sealed.Bundle)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrForgedSender, err)
+	}
+	if !hmac.Equal(sealed.MACs[identity.Recipient()], expected) {
+		return nil, ErrForgedSender
+	}
+
+	return &bundle, nil
+}
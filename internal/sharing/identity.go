@@ -0,0 +1,105 @@
+package sharing
+
+import (
+	"errors"
+	"os"
+
+	"filippo.io/age"
+	mycrypto "github.com/Isaac-Fate/myst/internal/crypto"
+	"github.com/Isaac-Fate/myst/internal/vaultfile"
+	"gopkg.in/yaml.v3"
+)
+
+// Returned when the identity file does not exist.
+var ErrNoIdentity = errors.New("no identity, run 'myst identity create' first")
+
+// A personal X25519 key pair.
+//
+// Teammates encrypt bundles to the public half, the recipient, and only the
+// private half can decrypt them. Keys use the age format, so bundles can
+// also be opened with the age command line tool.
+type Identity struct {
+	// The name teammates know this identity by
+	Name string
+
+	identity *age.X25519Identity
+}
+
+// The identity file. The private key is encrypted with the master passphrase.
+type identityFile struct {
+	Name                string `yaml:"name"`
+	Recipient           string `yaml:"recipient"`
+	EncryptedPrivateKey string `yaml:"encrypted_private_key"`
+}
+
+// Generates a new identity.
+func GenerateIdentity(name string) (*Identity, error) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Identity{Name: name, identity: identity}, nil
+}
+
+// Parses an identity from its private key, an AGE-SECRET-KEY-1... string.
+func ParseIdentity(name string, privateKey string) (*Identity, error) {
+	identity, err := age.ParseX25519Identity(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Identity{Name: name, identity: identity}, nil
+}
+
+// Returns the public key teammates share secrets with, an age1... string.
+func (identity *Identity) Recipient() string {
+	return identity.identity.Recipient().String()
+}
+
+// Returns the private key, an AGE-SECRET-KEY-1... string.
+func (identity *Identity) PrivateKey() string {
+	return identity.identity.String()
+}
+
+// Saves the identity with its private key encrypted by the passphrase.
+func SaveIdentity(path string, passphrase string, identity *Identity) error {
+	encryptedPrivateKey, err := mycrypto.Encrypt(passphrase, identity.PrivateKey())
+	if err != nil {
+		return err
+	}
+
+	content, err := yaml.Marshal(identityFile{
+		Name:                identity.Name,
+		Recipient:           identity.Recipient(),
+		EncryptedPrivateKey: encryptedPrivateKey,
+	})
+	if err != nil {
+		return err
+	}
+
+	return vaultfile.WriteFileAtomic(path, content, 0600)
+}
+
+// Loads the identity and decrypts its private key with the passphrase.
+func LoadIdentity(path string, passphrase string) (*Identity, error) {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNoIdentity
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var file identityFile
+	if err := yaml.Unmarshal(content, &file); err != nil {
+		return nil, err
+	}
+
+	privateKey, err := mycrypto.Decrypt(passphrase, file.EncryptedPrivateKey)
+	if err != nil {
+		return nil, errors.New("failed to decrypt the identity")
+	}
+
+	return ParseIdentity(file.Name, privateKey)
+}
@@ -0,0 +1,158 @@
+package sharing
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// Bundles are authenticated with the same X25519 keys they are encrypted
+// to: the sender and each recipient agree on a key, from the private key of
+// one and the public key of the other, and the sender adds a MAC for each
+// recipient. Only the sender and that recipient can compute it, so a valid
+// MAC proves the bundle comes from the holder of the sender's private key.
+//
+// age keeps the raw keys to itself, so they are decoded from their Bech32
+// encodings here.
+
+const (
+	recipientPrefix = "age"
+	identityPrefix  = "age-secret-key-"
+)
+
+// The info the MAC key is derived with, so it is used for nothing else.
+const macKeyInfo string = "myst bundle sender"
+
+// Returns the MAC of the payload only the sender and the recipient, given as
+// an age1... public key, can compute.
+func (identity *Identity) mac(recipient string, payload []byte) ([]byte, error) {
+	privateKey, err := decodeKey(identityPrefix, identity.PrivateKey())
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, err := decodeKey(recipientPrefix, recipient)
+	if err != nil {
+		return nil, err
+	}
+
+	private, err := ecdh.X25519().NewPrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	public, err := ecdh.X25519().NewPublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	shared, err := private.ECDH(public)
+	if err != nil {
+		return nil, err
+	}
+
+	// Derive the same key whichever side computes it
+	first, second := private.PublicKey().Bytes(), publicKey
+	if bytes.Compare(first, second) > 0 {
+		first, second = second, first
+	}
+	salt := append(bytes.Clone(first), second...)
+
+	macKey := make([]byte, sha256.Size)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared, salt, []byte(macKeyInfo)), macKey); err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write(payload)
+
+	return mac.Sum(nil), nil
+}
+
+// The characters of Bech32, by value.
+const bech32Charset string = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// Decodes a key encoded in Bech32 with the human-readable prefix, checking
+// its checksum.
+func decodeKey(prefix string, encoded string) ([]byte, error) {
+	encoded = strings.ToLower(encoded)
+
+	separator := strings.LastIndexByte(encoded, '1')
+	if separator < 0 || encoded[:separator] != prefix || len(encoded)-separator-1 < 6 {
+		return nil, fmt.Errorf("invalid %s key", prefix)
+	}
+
+	values := make([]byte, 0, len(encoded)-separator-1)
+	for _, c := range encoded[separator+1:] {
+		value := strings.IndexRune(bech32Charset, c)
+		if value < 0 {
+			return nil, fmt.Errorf("invalid %s key", prefix)
+		}
+		values = append(values, byte(value))
+	}
+
+	if bech32Polymod(append(bech32ExpandPrefix(prefix), values...)) != 1 {
+		return nil, fmt.Errorf("invalid checksum of %s key", prefix)
+	}
+
+	return convertBits(values[:len(values)-6])
+}
+
+func bech32Polymod(values []byte) uint32 {
+	generators := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+
+	checksum := uint32(1)
+	for _, value := range values {
+		top := checksum >> 25
+		checksum = (checksum&0x1ffffff)<<5 ^ uint32(value)
+		for i, generator := range generators {
+			if (top>>i)&1 == 1 {
+				checksum ^= generator
+			}
+		}
+	}
+
+	return checksum
+}
+
+func bech32ExpandPrefix(prefix string) []byte {
+	expanded := make([]byte, 0, 2*len(prefix)+1)
+	for i := range len(prefix) {
+		expanded = append(expanded, prefix[i]>>5)
+	}
+	expanded = append(expanded, 0)
+	for i := range len(prefix) {
+		expanded = append(expanded, prefix[i]&31)
+	}
+
+	return expanded
+}
+
+// Regroups 5-bit values into bytes, rejecting non-zero padding.
+func convertBits(values []byte) ([]byte, error) {
+	var result []byte
+	var accumulator uint32
+	var bits uint
+
+	for _, value := range values {
+		accumulator = accumulator<<5 | uint32(value)
+		bits += 5
+		for bits >= 8 {
+			bits -= 8
+			result = append(result, byte(accumulator>>bits))
+		}
+	}
+
+	if bits >= 5 || accumulator&(1<<bits-1) != 0 {
+		return nil, errors.New("invalid padding of key")
+	}
+
+	return result, nil
+}
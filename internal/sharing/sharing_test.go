@@ -0,0 +1,200 @@
+package sharing_test
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/Isaac-Fate/myst/internal/models"
+	"github.com/Isaac-Fate/myst/internal/sharing"
+)
+
+const passphrase string = "hello, world"
+
+func TestShareBundle(t *testing.T) {
+	alice, err := sharing.GenerateIdentity("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bob, err := sharing.GenerateIdentity("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Bob shares a secret with Alice
+	book, err := sharing.LoadAddressBook(filepath.Join(t.TempDir(), "recipients.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := book.AddRecipient("alice", alice.Recipient()); err != nil {
+		t.Fatal(err)
+	}
+
+	recipients, err := book.Resolve([]string{"alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bundle := sharing.NewBundle(bob, []sharing.SharedSecret{
+		{Key: "deploy-token", Value: "s3cr3t", Website: "ci.example.com"},
+	})
+
+	var sealed bytes.Buffer
+	if err := sharing.Seal(&sealed, bundle, recipients...); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(sealed.String(), "-----BEGIN AGE ENCRYPTED FILE-----") {
+		t.Errorf("expected an armored bundle, got %s", sealed.String())
+	}
+
+	if strings.Contains(sealed.String(), "s3cr3t") {
+		t.Error("bundle contains the plaintext value")
+	}
+
+	// Bob cannot open it
+	if _, err := sharing.Open(bytes.NewReader(sealed.Bytes()), bob); err == nil {
+		t.Error("expected the bundle to be unreadable without Alice's identity")
+	}
+
+	// Alice can
+	opened, err := sharing.Open(bytes.NewReader(sealed.Bytes()), alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if opened.From != "bob" || opened.FromRecipient != bob.Recipient() {
+		t.Errorf("unexpected sender %s %s", opened.From, opened.FromRecipient)
+	}
+
+	if len(opened.Secrets) != 1 || opened.Secrets[0].Value != "s3cr3t" {
+		t.Errorf("unexpected secrets %v", opened.Secrets)
+	}
+
+	// Bob is known by his key, whatever name he gives
+	if err := book.AddRecipient("bob", bob.Recipient()); err != nil {
+		t.Fatal(err)
+	}
+	if name, ok := book.NameOf(opened.FromRecipient); !ok || name != "bob" {
+		t.Errorf("expected bob in the address book, got %q, %v", name, ok)
+	}
+	if _, ok := book.NameOf(alice.Recipient() + "x"); ok {
+		t.Error("expected an unknown key not to be found")
+	}
+}
+
+func TestForgedSender(t *testing.T) {
+	alice, _ := sharing.GenerateIdentity("alice")
+	bob, _ := sharing.GenerateIdentity("bob")
+	mallory, _ := sharing.GenerateIdentity("mallory")
+
+	recipient, err := age.ParseX25519Recipient(alice.Recipient())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Mallory knows Alice's and Bob's public keys, and claims to be Bob
+	bundle := sharing.NewBundle(mallory, []sharing.SharedSecret{{Key: "deploy-token", Value: "evil"}})
+	bundle.From, bundle.FromRecipient = "bob", bob.Recipient()
+
+	var sealed bytes.Buffer
+	if err := sharing.Seal(&sealed, bundle, recipient); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := sharing.Open(bytes.NewReader(sealed.Bytes()), alice); !errors.Is(err, sharing.ErrForgedSender) {
+		t.Errorf("expected ErrForgedSender, got %v", err)
+	}
+
+	// A bundle shared with several recipients is authenticated for each
+	carol, _ := sharing.GenerateIdentity("carol")
+	carolRecipient, _ := age.ParseX25519Recipient(carol.Recipient())
+
+	sealed.Reset()
+	if err := sharing.Seal(&sealed, sharing.NewBundle(bob, nil), recipient, carolRecipient); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, identity := range []*sharing.Identity{alice, carol} {
+		if _, err := sharing.Open(bytes.NewReader(sealed.Bytes()), identity); err != nil {
+			t.Errorf("%s failed to open the bundle: %v", identity.Name, err)
+		}
+	}
+}
+
+func TestSaveLoadIdentity(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "identity.yml")
+
+	identity, err := sharing.GenerateIdentity("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sharing.SaveIdentity(path, passphrase, identity); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := sharing.LoadIdentity(path, passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if loaded.Name != "alice" || loaded.Recipient() != identity.Recipient() {
+		t.Errorf("unexpected identity %s %s", loaded.Name, loaded.Recipient())
+	}
+
+	if _, err := sharing.LoadIdentity(path, "wrong passphrase"); err == nil {
+		t.Error("expected an error with the wrong passphrase")
+	}
+}
+
+func TestRecipientsOf(t *testing.T) {
+	book, err := sharing.LoadAddressBook(filepath.Join(t.TempDir(), "recipients.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"alice", "bob", "carol"} {
+		identity, err := age.GenerateX25519Identity()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := book.AddRecipient(name, identity.Recipient().String()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := book.AssignFolder("ops", "bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := book.AssignFolder("ops", "carol"); err != nil {
+		t.Fatal(err)
+	}
+
+	secret := &models.Secret{Key: "db", Folder: "ops", Recipients: []string{"alice", "bob"}}
+
+	names := book.RecipientsOf(secret)
+	if strings.Join(names, ",") != "alice,bob,carol" {
+		t.Errorf("unexpected recipients %v", names)
+	}
+
+	// Removing a recipient drops it from the folders too
+	if err := book.RemoveRecipient("carol"); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(book.Folders["ops"], ",") != "bob" {
+		t.Errorf("unexpected folder recipients %v", book.Folders["ops"])
+	}
+
+	if err := book.AddRecipient("mallory", "not a key"); err == nil {
+		t.Error("expected an invalid public key to be rejected")
+	}
+}
@@ -0,0 +1,162 @@
+package sshagent
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// An SSH agent holding keys from the vault, so that they never sit
+// unencrypted in ~/.ssh. The keys are loaded when the agent starts; clients
+// may list them and sign with them, but not add or remove keys.
+
+// Returned when a client tries to add or remove keys.
+var ErrReadOnly = errors.New("agent: keys are managed by myst, see 'myst ssh-key'")
+
+// Returned when a use of a key was not confirmed.
+var ErrRefused = errors.New("agent: use of the key was refused")
+
+// A private key to serve, named after its secret.
+type Key struct {
+	Name       string
+	PrivateKey crypto.PrivateKey
+}
+
+// Options of an agent.
+type Options struct {
+	// Asks whether the key may be used to sign, if set. Confirmations are
+	// asked one at a time.
+	Confirm func(name string) bool
+
+	// Called after the key was used to sign, if set. The signature is not
+	// handed out if it fails.
+	Record func(name string) error
+}
+
+// An agent serving keys from the vault.
+type Agent struct {
+	keyring agent.ExtendedAgent
+	options Options
+
+	// The names of the keys by their marshaled public keys
+	names map[string]string
+
+	// Serializes confirmations and records
+	mutex sync.Mutex
+}
+
+// Creates an agent serving the keys.
+func New(keys []Key, options Options) (*Agent, error) {
+	keyring, ok := agent.NewKeyring().(agent.ExtendedAgent)
+	if !ok {
+		return nil, errors.New("agent: the keyring does not support signature flags")
+	}
+
+	sshAgent := &Agent{
+		keyring: keyring,
+		options: options,
+		names:   make(map[string]string, len(keys)),
+	}
+
+	for _, key := range keys {
+		if err := keyring.Add(agent.AddedKey{PrivateKey: key.PrivateKey, Comment: key.Name}); err != nil {
+			return nil, fmt.Errorf("failed to load key '%s': %w", key.Name, err)
+		}
+
+		signer, err := ssh.NewSignerFromKey(key.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load key '%s': %w", key.Name, err)
+		}
+
+		sshAgent.names[string(signer.PublicKey().Marshal())] = key.Name
+	}
+
+	return sshAgent, nil
+}
+
+// Serves clients connecting to the listener until it is closed.
+func (sshAgent *Agent) Serve(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		go func() {
+			defer conn.Close()
+			agent.ServeAgent(sshAgent, conn)
+		}()
+	}
+}
+
+func (sshAgent *Agent) List() ([]*agent.Key, error) {
+	return sshAgent.keyring.List()
+}
+
+func (sshAgent *Agent) Sign(key ssh.PublicKey, data []byte) (*ssh.Signature, error) {
+	return sshAgent.SignWithFlags(key, data, 0)
+}
+
+func (sshAgent *Agent) SignWithFlags(key ssh.PublicKey, data []byte, flags agent.SignatureFlags) (*ssh.Signature, error) {
+	name, ok := sshAgent.names[string(key.Marshal())]
+	if !ok {
+		return nil, errors.New("agent: key not found")
+	}
+
+	sshAgent.mutex.Lock()
+	defer sshAgent.mutex.Unlock()
+
+	if sshAgent.options.Confirm != nil && !sshAgent.options.Confirm(name) {
+		return nil, ErrRefused
+	}
+
+	signature, err := sshAgent.keyring.SignWithFlags(key, data, flags)
+	if err != nil {
+		return nil, err
+	}
+
+	if sshAgent.options.Record != nil {
+		if err := sshAgent.options.Record(name); err != nil {
+			return nil, err
+		}
+	}
+
+	return signature, nil
+}
+
+func (sshAgent *Agent) Add(key agent.AddedKey) error {
+	return ErrReadOnly
+}
+
+func (sshAgent *Agent) Remove(key ssh.PublicKey) error {
+	return ErrReadOnly
+}
+
+func (sshAgent *Agent) RemoveAll() error {
+	return ErrReadOnly
+}
+
+// Locks the agent with a passphrase, as 'ssh-add -x' does.
+func (sshAgent *Agent) Lock(passphrase []byte) error {
+	return sshAgent.keyring.Lock(passphrase)
+}
+
+func (sshAgent *Agent) Unlock(passphrase []byte) error {
+	return sshAgent.keyring.Unlock(passphrase)
+}
+
+func (sshAgent *Agent) Signers() ([]ssh.Signer, error) {
+	return sshAgent.keyring.Signers()
+}
+
+func (sshAgent *Agent) Extension(extensionType string, contents []byte) ([]byte, error) {
+	return nil, agent.ErrExtensionUnsupported
+}
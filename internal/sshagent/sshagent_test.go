@@ -0,0 +1,136 @@
+package sshagent_test
+
+import (
+	"errors"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/Isaac-Fate/myst/internal/sshagent"
+	"github.com/Isaac-Fate/myst/internal/sshkey"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// Starts an agent with an ed25519 and an RSA key, and returns a client
+// connected to it over a Unix socket.
+func startAgent(t *testing.T, options sshagent.Options) agent.ExtendedAgent {
+	var keys []sshagent.Key
+	for _, key := range []struct {
+		name    string
+		keyType string
+	}{
+		{"github-ssh", sshkey.TypeEd25519},
+		{"legacy-ssh", sshkey.TypeRSA},
+	} {
+		value, err := sshkey.Generate(key.keyType, 2048, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		privateKey, err := sshkey.ParsePrivateKey(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		keys = append(keys, sshagent.Key{Name: key.name, PrivateKey: privateKey})
+	}
+
+	sshAgent, err := sshagent.New(keys, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := net.Listen("unix", filepath.Join(t.TempDir(), "agent.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go sshAgent.Serve(listener)
+
+	conn, err := net.Dial("unix", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return agent.NewClient(conn)
+}
+
+func TestListAndSign(t *testing.T) {
+	var used []string
+	client := startAgent(t, sshagent.Options{
+		Record: func(name string) error {
+			used = append(used, name)
+			return nil
+		},
+	})
+
+	keys, err := client.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0].Comment != "github-ssh" || keys[1].Comment != "legacy-ssh" {
+		t.Fatalf("expected the two keys named after their secrets, got %v", keys)
+	}
+
+	data := []byte("session data")
+	for _, key := range keys {
+		signature, err := client.Sign(key, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := key.Verify(data, signature); err != nil {
+			t.Errorf("expected a valid signature by %s, got %v", key.Comment, err)
+		}
+	}
+
+	// RSA keys sign with SHA-2 when asked to
+	signature, err := client.SignWithFlags(keys[1], data, agent.SignatureFlagRsaSha256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signature.Format != ssh.KeyAlgoRSASHA256 {
+		t.Errorf("expected an %s signature, got %s", ssh.KeyAlgoRSASHA256, signature.Format)
+	}
+
+	if len(used) != 3 || used[0] != "github-ssh" {
+		t.Errorf("expected every use to be recorded, got %v", used)
+	}
+
+	// Keys are managed in the vault
+	if err := client.RemoveAll(); err == nil {
+		t.Error("expected removing keys to fail")
+	}
+
+	if keys, err := client.List(); err != nil || len(keys) != 2 {
+		t.Errorf("expected the keys to be kept, got %v, %v", keys, err)
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	recorded := false
+	client := startAgent(t, sshagent.Options{
+		Confirm: func(name string) bool { return name == "github-ssh" },
+		Record: func(name string) error {
+			recorded = true
+			return errors.New("the audit log is full")
+		},
+	})
+
+	keys, err := client.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := client.Sign(keys[1], []byte("data")); err == nil {
+		t.Error("expected signing to fail when refused")
+	}
+
+	// No signature is handed out if recording the use fails
+	if _, err := client.Sign(keys[0], []byte("data")); err == nil || !recorded {
+		t.Errorf("expected signing to fail when the use cannot be recorded, got %v", err)
+	}
+}
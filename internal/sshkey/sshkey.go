@@ -0,0 +1,139 @@
+package sshkey
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// SSH private keys are kept as the values of secrets, in the OpenSSH format
+// without a passphrase of their own: the vault encrypts them like any other
+// value.
+
+// Types of keys that can be generated.
+const (
+	TypeEd25519 string = "ed25519"
+	TypeRSA     string = "rsa"
+)
+
+// The size of generated RSA keys unless another is asked for.
+const DefaultRSABits int = 4096
+
+// The smallest RSA keys that can be generated.
+const minRSABits int = 2048
+
+// Returned when a key to import is protected by a passphrase and none was
+// given.
+var ErrPassphraseNeeded = errors.New("the key is protected by a passphrase")
+
+// Generates a private key of the type, returning it in the OpenSSH format.
+// The bits are only used for RSA keys, with zero meaning the default.
+func Generate(keyType string, bits int, comment string) (string, error) {
+	var key crypto.PrivateKey
+
+	switch keyType {
+	case TypeEd25519:
+		_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return "", err
+		}
+		key = privateKey
+	case TypeRSA:
+		if bits == 0 {
+			bits = DefaultRSABits
+		}
+		if bits < minRSABits {
+			return "", fmt.Errorf("RSA keys must have at least %d bits", minRSABits)
+		}
+
+		privateKey, err := rsa.GenerateKey(rand.Reader, bits)
+		if err != nil {
+			return "", err
+		}
+		key = privateKey
+	default:
+		return "", fmt.Errorf("unknown key type '%s', expected %s or %s", keyType, TypeEd25519, TypeRSA)
+	}
+
+	return marshal(key, comment)
+}
+
+// Reads a private key in any format ssh understands, decrypting it with the
+// passphrase if it has one, and returns it in the OpenSSH format without a
+// passphrase.
+//
+// It returns ErrPassphraseNeeded if the key has a passphrase and none was
+// given.
+func Import(content []byte, passphrase []byte, comment string) (string, error) {
+	var key any
+	var err error
+	if len(passphrase) == 0 {
+		key, err = ssh.ParseRawPrivateKey(content)
+	} else {
+		key, err = ssh.ParseRawPrivateKeyWithPassphrase(content, passphrase)
+	}
+
+	var missingError *ssh.PassphraseMissingError
+	if errors.As(err, &missingError) {
+		return "", ErrPassphraseNeeded
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read the private key: %w", err)
+	}
+
+	return marshal(key, comment)
+}
+
+// Encodes a private key in the OpenSSH format.
+func marshal(key crypto.PrivateKey, comment string) (string, error) {
+	block, err := ssh.MarshalPrivateKey(key, comment)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode the private key: %w", err)
+	}
+
+	return string(pem.EncodeToMemory(block)), nil
+}
+
+// Parses a private key kept as a value.
+func ParsePrivateKey(value string) (crypto.PrivateKey, error) {
+	key, err := ssh.ParseRawPrivateKey([]byte(value))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the private key: %w", err)
+	}
+
+	return key, nil
+}
+
+// Parses the public key of a private key kept as a value.
+func PublicKey(value string) (ssh.PublicKey, error) {
+	signer, err := ssh.ParsePrivateKey([]byte(value))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the private key: %w", err)
+	}
+
+	return signer.PublicKey(), nil
+}
+
+// Formats a public key for an authorized_keys file, followed by the comment.
+func AuthorizedKey(publicKey ssh.PublicKey, comment string) string {
+	line := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(publicKey)))
+	if comment != "" {
+		line += " " + comment
+	}
+
+	return line
+}
+
+// Describes a public key as ssh-keygen -l does, such as
+// "SHA256:... (ED25519)".
+func Describe(publicKey ssh.PublicKey) string {
+	keyType := strings.ToUpper(strings.TrimPrefix(publicKey.Type(), "ssh-"))
+	return fmt.Sprintf("%s (%s)", ssh.FingerprintSHA256(publicKey), keyType)
+}
@@ -0,0 +1,308 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Isaac-Fate/myst/internal/utils"
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+)
+
+var (
+	defaultStyle  = tcell.StyleDefault
+	titleStyle    = tcell.StyleDefault.Reverse(true)
+	selectedStyle = tcell.StyleDefault.Reverse(true)
+	labelStyle    = tcell.StyleDefault.Dim(true)
+	helpStyle     = tcell.StyleDefault.Dim(true)
+	errorStyle    = tcell.StyleDefault.Foreground(tcell.ColorRed)
+	focusStyle    = tcell.StyleDefault.Underline(true)
+)
+
+// Shown instead of hidden values.
+const maskedValue string = "••••••••"
+
+// Width of the labels in the detail pane.
+const labelWidth int = 10
+
+// Returns the number of rows of the secret list for a screen height.
+//
+// The title bar takes the first row, and the status and help lines the last
+// two.
+func listHeight(screenHeight int) int {
+	return max(screenHeight-3, 0)
+}
+
+func (app *App) draw() {
+	app.screen.Clear()
+
+	width, height := app.screen.Size()
+
+	if app.mode == lockedMode {
+		app.drawLocked(width, height)
+	} else {
+		app.drawTitle(width)
+		app.drawPanes(width, height)
+
+		help := browseHelp
+		if app.mode == formMode {
+			help = formHelp
+		}
+		drawText(app.screen, 0, height-1, width, helpStyle, help)
+	}
+
+	// The status line
+	style := defaultStyle
+	if app.statusIsError {
+		style = errorStyle
+	}
+	drawText(app.screen, 0, height-2, width, style, app.status)
+
+	app.screen.Show()
+}
+
+func (app *App) drawTitle(width int) {
+	for x := 0; x < width; x++ {
+		app.screen.SetContent(x, 0, ' ', nil, titleStyle)
+	}
+
+	title := " myst  Search: " + app.query
+	x := drawText(app.screen, 0, 0, width, titleStyle, title)
+
+	// The cursor of the search box
+	if app.mode == browseMode {
+		app.screen.ShowCursor(x, 0)
+	} else {
+		app.screen.HideCursor()
+	}
+
+	count := fmt.Sprintf("%d found ", app.total)
+	drawText(app.screen, width-runewidth.StringWidth(count), 0, width, titleStyle, count)
+}
+
+func (app *App) drawPanes(width int, height int) {
+	rows := listHeight(height)
+	listWidth := max(width*2/5, 20)
+
+	// Keep the selection visible
+	if app.selected < app.scroll {
+		app.scroll = app.selected
+	}
+	if app.selected >= app.scroll+rows {
+		app.scroll = app.selected - rows + 1
+	}
+
+	// The secret list
+	for row := 0; row < rows; row++ {
+		i := app.scroll + row
+		if i >= len(app.hits) {
+			break
+		}
+
+		secret := app.hits[i].Secret
+
+		style := defaultStyle
+		line := "  " + secret.Key
+		if i == app.selected {
+			style = selectedStyle
+			line = "▸ " + secret.Key
+		}
+		if secret.Folder != "" {
+			line += "  [" + secret.Folder + "]"
+		}
+
+		x := drawText(app.screen, 0, row+1, listWidth-1, style, line)
+		for ; i == app.selected && x < listWidth-1; x++ {
+			app.screen.SetContent(x, row+1, ' ', nil, style)
+		}
+	}
+
+	if len(app.hits) == 0 {
+		drawText(app.screen, 2, 1, listWidth-3, labelStyle, "No secrets found")
+	}
+
+	// The separator
+	for row := 1; row <= rows; row++ {
+		app.screen.SetContent(listWidth-1, row, '│', nil, labelStyle)
+	}
+
+	paneX := listWidth + 1
+	paneWidth := width - paneX
+
+	if app.mode == formMode {
+		app.drawForm(paneX, paneWidth, rows)
+	} else {
+		app.drawDetails(paneX, paneWidth, rows)
+	}
+}
+
+func (app *App) drawDetails(x int, width int, rows int) {
+	secret := app.selectedSecret()
+	if secret == nil {
+		return
+	}
+
+	value := maskedValue + "  (Enter to reveal)"
+	if app.revealedId == secret.ID.String() {
+		value = app.revealedValue
+	}
+
+	lines := [][2]string{
+		{"Key", secret.Key},
+		{"Value", value},
+		{"Username", secret.Username},
+		{"Website", secret.Website},
+		{"Folder", secret.Folder},
+		{"Tags", strings.Join(secret.Tags, ", ")},
+		{"Created", secret.CreatedAt.Local().Format("2006-01-02 15:04")},
+		{"Updated", secret.UpdatedAt.Local().Format("2006-01-02 15:04")},
+	}
+
+	if secret.ExpiresAt != nil {
+		lines = append(lines, [2]string{"Expires", secret.ExpiresAt.Local().Format("2006-01-02")})
+	}
+	if secret.RotateEvery > 0 {
+		lines = append(lines, [2]string{"Rotate", "every " + utils.FormatDuration(secret.RotateEvery)})
+	}
+
+	y := 1
+	for _, line := range lines {
+		drawText(app.screen, x, y, labelWidth, labelStyle, line[0])
+		drawText(app.screen, x+labelWidth, y, width-labelWidth, defaultStyle, line[1])
+		y++
+	}
+
+	if secret.Notes != "" {
+		y++
+		drawText(app.screen, x, y, width, labelStyle, "Notes")
+		y++
+
+		for _, line := range wrapText(secret.Notes, width) {
+			if y > rows {
+				break
+			}
+
+			drawText(app.screen, x, y, width, defaultStyle, line)
+			y++
+		}
+	}
+}
+
+func (app *App) drawForm(x int, width int, rows int) {
+	form := app.form
+
+	title := "New secret"
+	if form.secret != nil {
+		title = "Edit '" + form.secret.Key + "'"
+	}
+	drawText(app.screen, x, 1, width, defaultStyle.Bold(true), title)
+
+	for i, label := range fieldLabels {
+		y := 3 + i
+		if y > rows {
+			break
+		}
+
+		value := form.values[i]
+		if i == valueField {
+			if !form.showValue {
+				value = strings.Repeat("•", len([]rune(value)))
+			}
+			if value == "" && form.secret != nil && form.focus != i {
+				value = "(unchanged)"
+			}
+		}
+
+		style := defaultStyle
+		if i == form.focus {
+			style = focusStyle
+		}
+
+		drawText(app.screen, x, y, labelWidth, labelStyle, label)
+		end := drawText(app.screen, x+labelWidth, y, width-labelWidth, style, value)
+
+		if i == form.focus {
+			app.screen.ShowCursor(end, y)
+		}
+	}
+}
+
+func (app *App) drawLocked(width int, height int) {
+	lines := []string{
+		"myst is locked",
+		"",
+		"Passphrase: " + strings.Repeat("*", len([]rune(app.passphraseInput))),
+		"",
+		"Enter unlock  ^Q quit",
+	}
+
+	top := max((height-len(lines))/2, 0)
+	for i, line := range lines {
+		left := max((width-runewidth.StringWidth(line))/2, 0)
+		end := drawText(app.screen, left, top+i, width, defaultStyle, line)
+
+		if i == 2 {
+			app.screen.ShowCursor(end, top+i)
+		}
+	}
+}
+
+// Draws the text from x, cut to width cells, and returns the x after it.
+func drawText(screen tcell.Screen, x int, y int, width int, style tcell.Style, text string) int {
+	end := x + width
+
+	for _, r := range text {
+		w := runewidth.RuneWidth(r)
+		if x+w > end {
+			break
+		}
+
+		screen.SetContent(x, y, r, nil, style)
+		x += w
+	}
+
+	return x
+}
+
+// Splits the text into lines of at most width cells, between words where
+// possible.
+func wrapText(text string, width int) []string {
+	if width <= 0 {
+		return nil
+	}
+
+	var lines []string
+
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+
+		for _, word := range strings.Fields(paragraph) {
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+
+			if runewidth.StringWidth(candidate) <= width {
+				line = candidate
+				continue
+			}
+
+			if line != "" {
+				lines = append(lines, line)
+			}
+
+			// Break words longer than a line
+			for runewidth.StringWidth(word) > width {
+				head := runewidth.Truncate(word, width, "")
+				lines = append(lines, head)
+				word = word[len(head):]
+			}
+
+			line = word
+		}
+
+		lines = append(lines, line)
+	}
+
+	return lines
+}
@@ -0,0 +1,190 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	mycrypto "github.com/Isaac-Fate/myst/internal/crypto"
+	"github.com/Isaac-Fate/myst/internal/models"
+	"github.com/gdamore/tcell/v2"
+)
+
+// The shortcuts shown at the bottom of the screen while editing.
+const formHelp string = "Tab/↑↓ field  Enter save  Esc cancel  ^G generate value  ^R show value"
+
+// The fields of the form, in order.
+const (
+	keyField int = iota
+	valueField
+	usernameField
+	websiteField
+	notesField
+	folderField
+	tagsField
+)
+
+var fieldLabels = []string{"Key", "Value", "Username", "Website", "Notes", "Folder", "Tags"}
+
+// A form to edit or create a secret.
+type form struct {
+	// The secret being edited, or nil for a new secret
+	secret *models.Secret
+
+	values []string
+	focus  int
+
+	// Whether the value is shown in plaintext
+	showValue bool
+}
+
+// Opens the form for the secret, or for a new secret if it is nil.
+//
+// The value is only filled in if one is generated. Otherwise an empty value
+// leaves the value of an existing secret unchanged.
+func (app *App) openForm(secret *models.Secret, generate bool) {
+	form := &form{
+		values: make([]string, len(fieldLabels)),
+	}
+
+	if secret != nil {
+		edited := *secret
+		form.secret = &edited
+
+		form.values[keyField] = secret.Key
+		form.values[usernameField] = secret.Username
+		form.values[websiteField] = secret.Website
+		form.values[notesField] = secret.Notes
+		form.values[folderField] = secret.Folder
+		form.values[tagsField] = strings.Join(secret.Tags, ", ")
+		form.focus = valueField
+	}
+
+	if generate {
+		if err := form.generateValue(); err != nil {
+			app.setError(err)
+			return
+		}
+	}
+
+	app.form = form
+	app.mode = formMode
+	app.hideValue()
+	app.setStatus("")
+}
+
+func (form *form) generateValue() error {
+	value, err := mycrypto.GeneratePassword(generatedLength)
+	if err != nil {
+		return err
+	}
+
+	form.values[valueField] = value
+	form.focus = valueField
+	form.showValue = true
+
+	return nil
+}
+
+func (app *App) handleFormKey(event *tcell.EventKey) {
+	form := app.form
+
+	switch event.Key() {
+	case tcell.KeyEscape:
+		app.closeForm()
+
+	case tcell.KeyTab, tcell.KeyDown:
+		form.focus = (form.focus + 1) % len(form.values)
+	case tcell.KeyBacktab, tcell.KeyUp:
+		form.focus = (form.focus + len(form.values) - 1) % len(form.values)
+
+	case tcell.KeyEnter:
+		app.saveForm()
+
+	case tcell.KeyCtrlG:
+		if err := form.generateValue(); err != nil {
+			app.setError(err)
+		}
+	case tcell.KeyCtrlR:
+		form.showValue = !form.showValue
+
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		form.values[form.focus] = dropLastRune(form.values[form.focus])
+	case tcell.KeyRune:
+		form.values[form.focus] += string(event.Rune())
+	}
+}
+
+func (app *App) closeForm() {
+	app.form = nil
+	app.mode = browseMode
+	app.setStatus("")
+}
+
+// Adds or updates the secret in the form.
+func (app *App) saveForm() {
+	form := app.form
+
+	if form.values[keyField] == "" {
+		app.setError(errors.New("key cannot be empty"))
+		return
+	}
+
+	isNew := form.secret == nil
+	if isNew && form.values[valueField] == "" {
+		app.setError(errors.New("value cannot be empty"))
+		return
+	}
+
+	tags, err := models.ParseTags(form.values[tagsField])
+	if err != nil {
+		app.setError(err)
+		return
+	}
+
+	secret := form.secret
+	if isNew {
+		secret = &models.Secret{}
+	}
+
+	secret.Key = form.values[keyField]
+	secret.Username = form.values[usernameField]
+	secret.Website = form.values[websiteField]
+	secret.Notes = form.values[notesField]
+	secret.Folder = form.values[folderField]
+	secret.Tags = tags
+
+	if value := form.values[valueField]; value != "" {
+		encryptedValue, err := mycrypto.Encrypt(app.options.Passphrase, value)
+		if err != nil {
+			app.setError(err)
+			return
+		}
+
+		secret.EncryptedValue = encryptedValue
+	}
+
+	if isNew {
+		err = app.options.Manager.AddSecret(secret)
+	} else {
+		err = app.options.Manager.UpdateSecret(secret)
+	}
+
+	if errors.Is(err, models.ErrDuplicateKey) {
+		app.setError(fmt.Errorf("a secret with key '%s' already exists", secret.Key))
+		return
+	}
+	if errors.Is(err, models.ErrKeyInTrash) {
+		app.setError(fmt.Errorf("a secret with key '%s' is in the trash", secret.Key))
+		return
+	}
+	if err != nil {
+		app.setError(fmt.Errorf("failed to save secret: %w", err))
+		return
+	}
+
+	app.closeForm()
+	app.search()
+	app.selectSecret(secret.ID.String())
+	app.setStatus(fmt.Sprintf("Secret '%s' saved", secret.Key))
+}
@@ -0,0 +1,126 @@
+package tui
+
+import (
+	"github.com/gdamore/tcell/v2"
+)
+
+// The shortcuts shown at the bottom of the screen in browse mode.
+const browseHelp string = "↑↓ move  Enter reveal  ^Y copy  ^U copy user  ^E edit  ^N new  ^G generate  ^D delete  ^L lock  ^Q quit"
+
+// Handles a key press and reports whether to quit.
+func (app *App) handleKey(event *tcell.EventKey) bool {
+	// Quitting works everywhere
+	if event.Key() == tcell.KeyCtrlC || event.Key() == tcell.KeyCtrlQ {
+		return true
+	}
+
+	switch app.mode {
+	case browseMode:
+		return app.handleBrowseKey(event)
+	case formMode:
+		app.handleFormKey(event)
+	case deleteMode:
+		app.handleDeleteKey(event)
+	case lockedMode:
+		app.handleLockedKey(event)
+	}
+
+	return false
+}
+
+func (app *App) handleBrowseKey(event *tcell.EventKey) bool {
+	_, height := app.screen.Size()
+	page := max(listHeight(height)-1, 1)
+
+	switch event.Key() {
+	case tcell.KeyEscape:
+		// Clear the search first, then quit
+		if app.query == "" {
+			return true
+		}
+
+		app.editQuery("")
+
+	case tcell.KeyUp:
+		app.moveSelection(-1)
+	case tcell.KeyDown:
+		app.moveSelection(1)
+	case tcell.KeyPgUp:
+		app.moveSelection(-page)
+	case tcell.KeyPgDn:
+		app.moveSelection(page)
+	case tcell.KeyHome:
+		app.moveSelection(-len(app.hits))
+	case tcell.KeyEnd:
+		app.moveSelection(len(app.hits))
+
+	case tcell.KeyEnter, tcell.KeyCtrlR:
+		app.toggleValue()
+	case tcell.KeyCtrlY:
+		app.copyValue()
+	case tcell.KeyCtrlU:
+		app.copyUsername()
+	case tcell.KeyCtrlE:
+		if secret := app.selectedSecret(); secret != nil {
+			app.openForm(secret, false)
+		}
+	case tcell.KeyCtrlN:
+		app.openForm(nil, false)
+	case tcell.KeyCtrlG:
+		// Edit the selected secret with a new value, or create one
+		app.openForm(app.selectedSecret(), true)
+	case tcell.KeyCtrlD:
+		if secret := app.selectedSecret(); secret != nil {
+			app.mode = deleteMode
+			app.setStatus("Remove '" + secret.Key + "'? (y/N)")
+		}
+	case tcell.KeyCtrlL:
+		app.lock()
+
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		app.editQuery(dropLastRune(app.query))
+	case tcell.KeyRune:
+		app.editQuery(app.query + string(event.Rune()))
+	}
+
+	return false
+}
+
+// Changes the search box and searches again.
+func (app *App) editQuery(query string) {
+	app.query = query
+	app.selected = 0
+	app.hideValue()
+	app.search()
+}
+
+func (app *App) handleDeleteKey(event *tcell.EventKey) {
+	app.mode = browseMode
+
+	if event.Key() == tcell.KeyRune && (event.Rune() == 'y' || event.Rune() == 'Y') {
+		app.removeSelected()
+		return
+	}
+
+	app.setStatus("")
+}
+
+func (app *App) handleLockedKey(event *tcell.EventKey) {
+	switch event.Key() {
+	case tcell.KeyEnter:
+		app.unlock()
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		app.passphraseInput = dropLastRune(app.passphraseInput)
+	case tcell.KeyRune:
+		app.passphraseInput += string(event.Rune())
+	}
+}
+
+func dropLastRune(text string) string {
+	runes := []rune(text)
+	if len(runes) == 0 {
+		return text
+	}
+
+	return string(runes[:len(runes)-1])
+}
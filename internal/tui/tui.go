@@ -0,0 +1,421 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Isaac-Fate/myst/internal/audit"
+	mycrypto "github.com/Isaac-Fate/myst/internal/crypto"
+	"github.com/Isaac-Fate/myst/internal/manager"
+	"github.com/Isaac-Fate/myst/internal/models"
+	"github.com/Isaac-Fate/myst/internal/search"
+	"github.com/gdamore/tcell/v2"
+)
+
+// A full-screen terminal UI over a secret manager.
+//
+// The screen is split into a list of the secrets matching the search box and
+// a pane with the details of the selected secret. Typing edits the search,
+// and control keys act on the selected secret. All data goes through the
+// secret manager, so changes are indexed and audited as everywhere else.
+//
+// The UI draws on any tcell.Screen, so tests drive it headlessly with a
+// tcell.SimulationScreen.
+
+// Length of generated values.
+const generatedLength int = 20
+
+// Configures the UI.
+type Options struct {
+	Manager *manager.SecretManager
+
+	// Encrypts and decrypts the values
+	Passphrase string
+
+	// Reports whether the passphrase unlocks the UI after it locked
+	VerifyPassphrase func(passphrase string) bool
+
+	// Locks the UI after this long without a key press, or never if zero
+	AutoLock time.Duration
+
+	// Copies text to the clipboard
+	Clipboard func(text string) error
+
+	// Reads the clipboard, so that a copied value is cleared only while the
+	// clipboard still holds it. Without it, copied values are never cleared.
+	ReadClipboard func() (string, error)
+
+	// Clears a copied value from the clipboard after this long, and anyway
+	// when the UI locks or exits. If zero, only when it locks or exits.
+	ClearClipboardAfter time.Duration
+}
+
+type mode int
+
+const (
+	browseMode mode = iota
+	formMode
+	deleteMode
+	lockedMode
+)
+
+// Posted to the event loop when the auto-lock timer fires.
+//
+// Only the request of the latest timer counts, earlier timers may still fire
+// after they were replaced.
+type lockRequest struct {
+	generation int
+}
+
+// Posted to the event loop when a copied value is due to be cleared from the
+// clipboard. Only the request for the latest copy counts.
+type clearClipboardRequest struct {
+	generation int
+}
+
+// The state of the UI.
+type App struct {
+	screen  tcell.Screen
+	options Options
+
+	mode mode
+
+	// The search box and its results
+	query    string
+	hits     []manager.SearchHit
+	total    uint64
+	selected int
+	scroll   int
+
+	// The decrypted value shown in the detail pane
+	revealedId    string
+	revealedValue string
+
+	// The secret being edited or created
+	form *form
+
+	// Typed while locked
+	passphraseInput string
+
+	status        string
+	statusIsError bool
+
+	lastActivity   time.Time
+	lockTimer      *time.Timer
+	lockGeneration int
+
+	// The value last copied to the clipboard, until it is cleared
+	copiedValue         string
+	clipboardTimer      *time.Timer
+	clipboardGeneration int
+}
+
+// Creates a UI drawing on the screen, which must already be initialized.
+func New(screen tcell.Screen, options Options) *App {
+	return &App{
+		screen:  screen,
+		options: options,
+	}
+}
+
+// Runs the UI until the user quits.
+func (app *App) Run() error {
+	app.search()
+
+	app.lastActivity = time.Now()
+	app.startLockTimer(app.options.AutoLock)
+	defer app.stopLockTimer()
+	defer app.clearClipboard()
+
+	for {
+		app.draw()
+
+		switch event := app.screen.PollEvent().(type) {
+		case nil:
+			// The screen was finalized
+			return nil
+
+		case *tcell.EventResize:
+			app.screen.Sync()
+
+		case *tcell.EventInterrupt:
+			switch request := event.Data().(type) {
+			case lockRequest:
+				if request.generation == app.lockGeneration {
+					app.checkAutoLock()
+				}
+			case clearClipboardRequest:
+				if request.generation == app.clipboardGeneration {
+					app.clearClipboard()
+				}
+			}
+
+		case *tcell.EventKey:
+			app.lastActivity = time.Now()
+
+			if quit := app.handleKey(event); quit {
+				return nil
+			}
+		}
+	}
+}
+
+// Arms the auto-lock timer, if auto-lock is enabled.
+func (app *App) startLockTimer(after time.Duration) {
+	if app.options.AutoLock <= 0 {
+		return
+	}
+
+	app.stopLockTimer()
+	app.lockGeneration++
+
+	request := lockRequest{generation: app.lockGeneration}
+	app.lockTimer = time.AfterFunc(after, func() {
+		app.screen.PostEvent(tcell.NewEventInterrupt(request))
+	})
+}
+
+func (app *App) stopLockTimer() {
+	if app.lockTimer != nil {
+		app.lockTimer.Stop()
+	}
+}
+
+// Locks the UI if it has been idle long enough, or waits for the rest.
+func (app *App) checkAutoLock() {
+	if app.mode == lockedMode {
+		return
+	}
+
+	idle := time.Since(app.lastActivity)
+	if idle >= app.options.AutoLock {
+		app.lock()
+		return
+	}
+
+	app.startLockTimer(app.options.AutoLock - idle)
+}
+
+// Hides everything until the passphrase is entered again.
+func (app *App) lock() {
+	app.mode = lockedMode
+	app.form = nil
+	app.passphraseInput = ""
+	app.hideValue()
+	app.clearClipboard()
+	app.setStatus("")
+}
+
+func (app *App) unlock() {
+	if !app.options.VerifyPassphrase(app.passphraseInput) {
+		app.passphraseInput = ""
+		app.setError(errors.New("wrong passphrase"))
+		return
+	}
+
+	app.mode = browseMode
+	app.passphraseInput = ""
+	app.setStatus("")
+
+	app.lastActivity = time.Now()
+	app.startLockTimer(app.options.AutoLock)
+}
+
+// Runs the search box query and keeps the selection within the results.
+func (app *App) search() {
+	result, err := app.options.Manager.SearchSecrets(app.query, search.Options{Limit: search.NoLimit})
+	if err != nil {
+		// Keep the previous results while the query is being typed
+		app.setError(err)
+		return
+	}
+
+	app.hits = result.Hits
+	app.total = result.Total
+	app.setStatus("")
+
+	app.selected = min(app.selected, max(len(app.hits)-1, 0))
+}
+
+// Returns the selected secret, or nil if there are no results.
+func (app *App) selectedSecret() *models.Secret {
+	if app.selected >= len(app.hits) {
+		return nil
+	}
+
+	return &app.hits[app.selected].Secret
+}
+
+// Selects the secret with the ID, if it is among the results.
+func (app *App) selectSecret(id string) {
+	for i, hit := range app.hits {
+		if hit.Secret.ID.String() == id {
+			app.selected = i
+			return
+		}
+	}
+}
+
+func (app *App) moveSelection(delta int) {
+	if len(app.hits) == 0 {
+		return
+	}
+
+	app.selected = max(0, min(len(app.hits)-1, app.selected+delta))
+	app.hideValue()
+}
+
+// Decrypts the value of the secret, recording the access first.
+func (app *App) accessValue(secret *models.Secret, action audit.Action) (string, error) {
+	if err := app.options.Manager.RecordAccess(action, secret, ""); err != nil {
+		return "", err
+	}
+
+	value, err := mycrypto.Decrypt(app.options.Passphrase, secret.EncryptedValue)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt secret value: %w", err)
+	}
+
+	return value, nil
+}
+
+// Shows or hides the value of the selected secret.
+func (app *App) toggleValue() {
+	secret := app.selectedSecret()
+	if secret == nil {
+		return
+	}
+
+	if app.revealedId == secret.ID.String() {
+		app.hideValue()
+		return
+	}
+
+	value, err := app.accessValue(secret, audit.ActionReveal)
+	if err != nil {
+		app.setError(err)
+		return
+	}
+
+	app.revealedId = secret.ID.String()
+	app.revealedValue = value
+}
+
+func (app *App) hideValue() {
+	app.revealedId = ""
+	app.revealedValue = ""
+}
+
+func (app *App) copyValue() {
+	secret := app.selectedSecret()
+	if secret == nil {
+		return
+	}
+
+	value, err := app.accessValue(secret, audit.ActionCopy)
+	if err != nil {
+		app.setError(err)
+		return
+	}
+
+	if err := app.options.Clipboard(value); err != nil {
+		app.setError(fmt.Errorf("failed to copy to clipboard: %w", err))
+		return
+	}
+
+	app.copiedValue = value
+	app.startClipboardTimer()
+
+	if app.options.ClearClipboardAfter > 0 {
+		app.setStatus(fmt.Sprintf("Value of '%s' copied to clipboard, cleared in %s", secret.Key, app.options.ClearClipboardAfter))
+	} else {
+		app.setStatus(fmt.Sprintf("Value of '%s' copied to clipboard", secret.Key))
+	}
+}
+
+// Arms the timer clearing the value just copied, if there is a timeout.
+func (app *App) startClipboardTimer() {
+	if app.clipboardTimer != nil {
+		app.clipboardTimer.Stop()
+	}
+	app.clipboardGeneration++
+
+	if app.options.ClearClipboardAfter <= 0 {
+		return
+	}
+
+	request := clearClipboardRequest{generation: app.clipboardGeneration}
+	app.clipboardTimer = time.AfterFunc(app.options.ClearClipboardAfter, func() {
+		app.screen.PostEvent(tcell.NewEventInterrupt(request))
+	})
+}
+
+// Clears the copied value from the clipboard, unless something else was
+// copied since.
+func (app *App) clearClipboard() {
+	if app.clipboardTimer != nil {
+		app.clipboardTimer.Stop()
+	}
+
+	value := app.copiedValue
+	app.copiedValue = ""
+
+	if value == "" || app.options.ReadClipboard == nil {
+		return
+	}
+
+	if current, err := app.options.ReadClipboard(); err != nil || current != value {
+		return
+	}
+
+	if err := app.options.Clipboard(""); err != nil {
+		app.setError(fmt.Errorf("failed to clear the clipboard: %w", err))
+	}
+}
+
+func (app *App) copyUsername() {
+	secret := app.selectedSecret()
+	if secret == nil {
+		return
+	}
+
+	if secret.Username == "" {
+		app.setError(fmt.Errorf("'%s' has no username", secret.Key))
+		return
+	}
+
+	if err := app.options.Clipboard(secret.Username); err != nil {
+		app.setError(fmt.Errorf("failed to copy to clipboard: %w", err))
+		return
+	}
+
+	app.setStatus(fmt.Sprintf("Username of '%s' copied to clipboard", secret.Key))
+}
+
+func (app *App) removeSelected() {
+	secret := app.selectedSecret()
+	if secret == nil {
+		return
+	}
+
+	if err := app.options.Manager.RemoveSecret(secret); err != nil {
+		app.setError(fmt.Errorf("failed to remove secret: %w", err))
+		return
+	}
+
+	key := secret.Key
+	app.hideValue()
+	app.search()
+	app.setStatus(fmt.Sprintf("Secret '%s' moved to the trash", key))
+}
+
+func (app *App) setStatus(status string) {
+	app.status = status
+	app.statusIsError = false
+}
+
+func (app *App) setError(err error) {
+	app.status = err.Error()
+	app.statusIsError = true
+}
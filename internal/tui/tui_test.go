@@ -0,0 +1,408 @@
+package tui_test
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	mycrypto "github.com/Isaac-Fate/myst/internal/crypto"
+	"github.com/Isaac-Fate/myst/internal/manager"
+	"github.com/Isaac-Fate/myst/internal/models"
+	"github.com/Isaac-Fate/myst/internal/tui"
+	"github.com/gdamore/tcell/v2"
+)
+
+const testPassphrase string = "test passphrase"
+
+// A simulated screen keeping a copy of what it last showed.
+//
+// The cells of the simulated screen are only safe to read on the goroutine
+// drawing them, so they are copied there.
+type snapshotScreen struct {
+	tcell.SimulationScreen
+
+	mu   sync.Mutex
+	text string
+}
+
+func (screen *snapshotScreen) Show() {
+	screen.SimulationScreen.Show()
+
+	cells, width, _ := screen.GetContents()
+
+	var text strings.Builder
+	for i, cell := range cells {
+		if i > 0 && i%width == 0 {
+			text.WriteByte('\n')
+		}
+		if len(cell.Runes) > 0 {
+			text.WriteRune(cell.Runes[0])
+		}
+	}
+
+	screen.mu.Lock()
+	screen.text = text.String()
+	screen.mu.Unlock()
+}
+
+// A UI running on a simulated screen.
+type harness struct {
+	t       *testing.T
+	screen  *snapshotScreen
+	manager *manager.SecretManager
+	done    chan error
+
+	mu        sync.Mutex
+	clipboard string
+}
+
+func start(t *testing.T, autoLock time.Duration) *harness {
+	t.Helper()
+
+	return startClearing(t, autoLock, 0)
+}
+
+// Starts a UI clearing copied values from the clipboard after clearAfter.
+func startClearing(t *testing.T, autoLock time.Duration, clearAfter time.Duration) *harness {
+	t.Helper()
+
+	secretManager, err := manager.NewFileSecretManager(filepath.Join(t.TempDir(), "vault.myst"), testPassphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { secretManager.Close() })
+
+	for _, secret := range []struct{ key, value, username, website string }{
+		{"github-token", "ghp_secret", "octocat", "github.com"},
+		{"aws-key", "AKIA_secret", "admin", "aws.amazon.com"},
+	} {
+		encryptedValue, err := mycrypto.Encrypt(testPassphrase, secret.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = secretManager.AddSecret(&models.Secret{
+			Key:            secret.key,
+			EncryptedValue: encryptedValue,
+			Username:       secret.username,
+			Website:        secret.website,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	screen := &snapshotScreen{SimulationScreen: tcell.NewSimulationScreen("UTF-8")}
+	if err := screen.Init(); err != nil {
+		t.Fatal(err)
+	}
+	screen.SetSize(100, 30)
+
+	h := &harness{
+		t:       t,
+		screen:  screen,
+		manager: secretManager,
+		done:    make(chan error, 1),
+	}
+
+	app := tui.New(screen, tui.Options{
+		Manager:    secretManager,
+		Passphrase: testPassphrase,
+		VerifyPassphrase: func(passphrase string) bool {
+			return passphrase == testPassphrase
+		},
+		AutoLock: autoLock,
+		Clipboard: func(text string) error {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+
+			h.clipboard = text
+			return nil
+		},
+		ReadClipboard: func() (string, error) {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+
+			return h.clipboard, nil
+		},
+		ClearClipboardAfter: clearAfter,
+	})
+
+	go func() { h.done <- app.Run() }()
+
+	t.Cleanup(func() {
+		h.key(tcell.KeyCtrlQ)
+		<-h.done
+		screen.Fini()
+	})
+
+	h.waitFor("github-token")
+	return h
+}
+
+func (h *harness) key(key tcell.Key) {
+	h.screen.InjectKey(key, 0, tcell.ModNone)
+}
+
+func (h *harness) typeText(text string) {
+	for _, r := range text {
+		h.screen.InjectKey(tcell.KeyRune, r, tcell.ModNone)
+	}
+}
+
+// Returns the text on the screen, one line per row.
+func (h *harness) text() string {
+	h.screen.mu.Lock()
+	defer h.screen.mu.Unlock()
+
+	return h.screen.text
+}
+
+// Waits until the screen shows the text.
+func (h *harness) waitFor(text string) {
+	h.t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(h.text(), text) {
+		if time.Now().After(deadline) {
+			h.t.Fatalf("timed out waiting for %q, screen:\n%s", text, h.text())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// Waits until the screen no longer shows the text.
+func (h *harness) waitForNo(text string) {
+	h.t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for strings.Contains(h.text(), text) {
+		if time.Now().After(deadline) {
+			h.t.Fatalf("timed out waiting for %q to disappear, screen:\n%s", text, h.text())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func (h *harness) setClipboard(text string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.clipboard = text
+}
+
+func (h *harness) waitForClipboard(text string) {
+	h.t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		h.mu.Lock()
+		clipboard := h.clipboard
+		h.mu.Unlock()
+
+		if clipboard == text {
+			return
+		}
+		if time.Now().After(deadline) {
+			h.t.Fatalf("expected %q in the clipboard, got %q", text, clipboard)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSearchAndReveal(t *testing.T) {
+	h := start(t, 0)
+	h.waitFor("aws-key")
+	h.waitFor("2 found")
+
+	h.typeText("github")
+	h.waitFor("1 found")
+	h.waitForNo("aws-key")
+
+	// Values are hidden until revealed
+	h.waitFor("octocat")
+	if strings.Contains(h.text(), "ghp_secret") {
+		t.Fatal("expected the value to be hidden")
+	}
+
+	h.key(tcell.KeyEnter)
+	h.waitFor("ghp_secret")
+
+	h.key(tcell.KeyEnter)
+	h.waitForNo("ghp_secret")
+
+	// Escape clears the search
+	h.key(tcell.KeyEscape)
+	h.waitFor("aws-key")
+}
+
+func TestCopy(t *testing.T) {
+	h := start(t, 0)
+
+	h.typeText("aws")
+	h.waitFor("1 found")
+
+	h.key(tcell.KeyCtrlY)
+	h.waitForClipboard("AKIA_secret")
+	h.waitFor("Value of 'aws-key' copied")
+
+	h.key(tcell.KeyCtrlU)
+	h.waitForClipboard("admin")
+	h.waitFor("Username of 'aws-key' copied")
+}
+
+func TestClearClipboard(t *testing.T) {
+	h := startClearing(t, 0, 200*time.Millisecond)
+
+	h.typeText("aws")
+	h.waitFor("1 found")
+
+	h.key(tcell.KeyCtrlY)
+	h.waitForClipboard("AKIA_secret")
+	h.waitForClipboard("")
+
+	// Something else copied since is left alone
+	h.key(tcell.KeyCtrlY)
+	h.waitForClipboard("AKIA_secret")
+	h.setClipboard("copied elsewhere")
+
+	time.Sleep(400 * time.Millisecond)
+	h.waitForClipboard("copied elsewhere")
+}
+
+func TestClearClipboardOnLock(t *testing.T) {
+	h := start(t, 300*time.Millisecond)
+
+	h.key(tcell.KeyCtrlY)
+	h.waitForClipboard("AKIA_secret")
+
+	h.waitFor("myst is locked")
+	h.waitForClipboard("")
+}
+
+func TestEditAndCreate(t *testing.T) {
+	h := start(t, 0)
+
+	// Edit the username and leave the value as it is
+	h.typeText("github")
+	h.waitFor("1 found")
+
+	h.key(tcell.KeyCtrlE)
+	h.waitFor("Edit 'github-token'")
+
+	h.key(tcell.KeyTab)
+	h.typeText("-bot")
+	h.key(tcell.KeyEnter)
+	h.waitFor("Secret 'github-token' saved")
+	h.waitFor("octocat-bot")
+
+	h.key(tcell.KeyEnter)
+	h.waitFor("ghp_secret")
+
+	// Create a secret with a generated value
+	h.key(tcell.KeyEscape)
+	h.waitFor("2 found")
+
+	h.key(tcell.KeyCtrlN)
+	h.waitFor("New secret")
+
+	h.typeText("db-password")
+	h.key(tcell.KeyCtrlG)
+	h.key(tcell.KeyEnter)
+	h.waitFor("Secret 'db-password' saved")
+	h.waitFor("3 found")
+
+	secrets, err := h.manager.ListSecrets()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, secret := range secrets {
+		if secret.Key != "db-password" {
+			continue
+		}
+
+		value, err := mycrypto.Decrypt(testPassphrase, secret.EncryptedValue)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(value) != 20 {
+			t.Errorf("expected a generated value of 20 characters, got %q", value)
+		}
+	}
+
+	// Keys must stay unique
+	h.key(tcell.KeyCtrlN)
+	h.waitFor("New secret")
+
+	h.typeText("aws-key")
+	h.key(tcell.KeyTab)
+	h.typeText("value")
+	h.key(tcell.KeyEnter)
+	h.waitFor("a secret with key 'aws-key' already exists")
+}
+
+func TestDelete(t *testing.T) {
+	h := start(t, 0)
+
+	h.typeText("aws")
+	h.waitFor("1 found")
+
+	// Anything but y cancels
+	h.key(tcell.KeyCtrlD)
+	h.waitFor("Remove 'aws-key'? (y/N)")
+	h.typeText("n")
+	h.waitForNo("Remove 'aws-key'?")
+
+	h.key(tcell.KeyCtrlD)
+	h.waitFor("Remove 'aws-key'? (y/N)")
+	h.typeText("y")
+	h.waitFor("Secret 'aws-key' moved to the trash")
+	h.waitFor("0 found")
+}
+
+func TestAutoLock(t *testing.T) {
+	h := start(t, 200*time.Millisecond)
+
+	// Locking hides everything
+	h.waitFor("myst is locked")
+	h.waitForNo("github-token")
+
+	h.typeText("wrong")
+	h.key(tcell.KeyEnter)
+	h.waitFor("wrong passphrase")
+
+	h.typeText(testPassphrase)
+	h.key(tcell.KeyEnter)
+	h.waitFor("github-token")
+
+	// And it locks again after the next idle period
+	h.waitFor("myst is locked")
+}
+
+func TestQuit(t *testing.T) {
+	h := start(t, 0)
+
+	h.key(tcell.KeyCtrlY)
+	h.waitForClipboard("AKIA_secret")
+
+	// Escape quits once the search is empty
+	h.key(tcell.KeyEscape)
+
+	select {
+	case err := <-h.done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the UI to quit")
+	}
+
+	// Quitting clears the copied value
+	h.waitForClipboard("")
+
+	// Let the cleanup find the UI finished
+	h.done <- nil
+}
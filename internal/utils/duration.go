@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Parses a duration, also accepting days such as "30d".
+func ParseDuration(value string) (time.Duration, error) {
+	if days, found := strings.CutSuffix(value, "d"); found {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid duration '%s'", value)
+		}
+
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	return time.ParseDuration(value)
+}
+
+// Formats a duration the way ParseDuration reads it, in days if it is a
+// whole number of days.
+func FormatDuration(duration time.Duration) string {
+	day := 24 * time.Hour
+	if duration > 0 && duration%day == 0 {
+		return fmt.Sprintf("%dd", duration/day)
+	}
+
+	return duration.String()
+}
+
+// Formats how long before or after now a time is, such as "3 days ago" or
+// "in 2 hours".
+func FormatRelative(t time.Time, now time.Time) string {
+	age := now.Sub(t)
+	if age < 0 {
+		return "in " + formatSpan(-age)
+	}
+
+	if age < time.Minute {
+		return "just now"
+	}
+
+	return formatSpan(age) + " ago"
+}
+
+// Formats a positive span of time in its largest whole unit.
+func formatSpan(span time.Duration) string {
+	switch {
+	case span < time.Minute:
+		return "less than a minute"
+	case span < time.Hour:
+		return pluralize(int(span/time.Minute), "minute")
+	case span < 24*time.Hour:
+		return pluralize(int(span/time.Hour), "hour")
+	default:
+		return pluralize(int(span/(24*time.Hour)), "day")
+	}
+}
+
+// Formats a count of a unit, such as "1 day" or "2 days".
+func pluralize(count int, unit string) string {
+	if count == 1 {
+		return "1 " + unit
+	}
+
+	return fmt.Sprintf("%d %ss", count, unit)
+}
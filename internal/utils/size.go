@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"GiB", 1 << 30},
+	{"MiB", 1 << 20},
+	{"KiB", 1 << 10},
+	{"GB", 1000 * 1000 * 1000},
+	{"MB", 1000 * 1000},
+	{"KB", 1000},
+	{"B", 1},
+}
+
+// Parses a size in bytes such as "512", "64KiB", "10MB" or "1GiB".
+func ParseSize(value string) (int64, error) {
+	number, multiplier := strings.TrimSpace(value), int64(1)
+	for _, unit := range sizeUnits {
+		if n, found := strings.CutSuffix(number, unit.suffix); found {
+			number, multiplier = strings.TrimSpace(n), unit.bytes
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(number, 10, 64)
+	if err != nil || n < 0 || n > (1<<62)/multiplier {
+		return 0, fmt.Errorf("invalid size '%s'", value)
+	}
+
+	return n * multiplier, nil
+}
+
+// Formats a size in bytes in its largest binary unit, such as "1.5 MiB".
+func FormatSize(size int64) string {
+	for _, unit := range sizeUnits[:3] {
+		if size >= unit.bytes {
+			return strings.TrimSuffix(fmt.Sprintf("%.1f", float64(size)/float64(unit.bytes)), ".0") + " " + unit.suffix
+		}
+	}
+
+	return fmt.Sprintf("%d B", size)
+}
@@ -0,0 +1,148 @@
+package vaultfile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	mycrypto "github.com/Isaac-Fate/myst/internal/crypto"
+	"github.com/Isaac-Fate/myst/internal/models"
+)
+
+// A vault file stores the whole secret store in a single file.
+//
+// The file is a small JSON envelope:
+//
+//	{"format": "myst-vault", "version": 1, "data": "<encrypted payload>"}
+//
+// The payload is the JSON encoding of Vault encrypted with the master
+// passphrase by crypto.Encrypt. Only the envelope is readable without the
+// passphrase, which keeps the file easy to recognize when it is synced or
+// backed up.
+
+const format string = "myst-vault"
+const version int = 1
+
+// Returned when the file is not a vault file or the passphrase is wrong.
+var ErrInvalidVault = errors.New("invalid vault file or wrong passphrase")
+
+// The decrypted content of a vault file.
+type Vault struct {
+	Secrets []models.Secret   `json:"secrets"`
+	Tokens  []models.APIToken `json:"tokens,omitempty"`
+}
+
+type envelope struct {
+	Format  string `json:"format"`
+	Version int    `json:"version"`
+	Data    string `json:"data"`
+}
+
+// Reads and decrypts the vault file at path.
+//
+// If the file does not exist, the returned error wraps os.ErrNotExist so that
+// callers can start with an empty vault.
+func Read(path string, passphrase string) (*Vault, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse the envelope
+	var env envelope
+	if err := json.Unmarshal(content, &env); err != nil || env.Format != format {
+		return nil, ErrInvalidVault
+	}
+
+	if env.Version != version {
+		return nil, fmt.Errorf("unsupported vault file version %d", env.Version)
+	}
+
+	// Decrypt the payload
+	payload, err := mycrypto.Decrypt(passphrase, env.Data)
+	if err != nil {
+		return nil, ErrInvalidVault
+	}
+
+	var vault Vault
+	if err := json.Unmarshal([]byte(payload), &vault); err != nil {
+		return nil, ErrInvalidVault
+	}
+
+	return &vault, nil
+}
+
+// Encrypts the vault and writes it to path atomically.
+//
+// The content is first written to a temporary file in the same directory,
+// synced to disk and then renamed over the old file. A crash in the middle of
+// writing therefore leaves either the old or the new vault, never a partial
+// one.
+func Write(path string, passphrase string, vault *Vault) error {
+	// Encode and encrypt the payload
+	payload, err := json.Marshal(vault)
+	if err != nil {
+		return err
+	}
+
+	data, err := mycrypto.Encrypt(passphrase, string(payload))
+	if err != nil {
+		return err
+	}
+
+	content, err := json.MarshalIndent(envelope{
+		Format:  format,
+		Version: version,
+		Data:    data,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return WriteFileAtomic(path, content, 0600)
+}
+
+// Writes content to path via a temporary file and a rename.
+func WriteFileAtomic(path string, content []byte, perm os.FileMode) error {
+	// Create the temporary file next to the target so that the rename does
+	// not cross file systems
+	tempFile, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tempPath := tempFile.Name()
+
+	// Remove the temporary file if anything goes wrong
+	succeeded := false
+	defer func() {
+		if !succeeded {
+			tempFile.Close()
+			os.Remove(tempPath)
+		}
+	}()
+
+	if _, err := tempFile.Write(content); err != nil {
+		return err
+	}
+
+	if err := tempFile.Chmod(perm); err != nil {
+		return err
+	}
+
+	if err := tempFile.Sync(); err != nil {
+		return err
+	}
+
+	if err := tempFile.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tempPath, path); err != nil {
+		return err
+	}
+
+	succeeded = true
+	return nil
+}
@@ -0,0 +1,92 @@
+package vaultfile_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Isaac-Fate/myst/internal/models"
+	"github.com/Isaac-Fate/myst/internal/vaultfile"
+	"github.com/google/uuid"
+)
+
+const passphrase string = "hello, world"
+
+func TestWriteRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vault.myst")
+
+	vault := vaultfile.Vault{
+		Secrets: []models.Secret{
+			{
+				ID:             uuid.New(),
+				Key:            "github-token",
+				EncryptedValue: "xxx",
+				Website:        "github.com",
+			},
+		},
+	}
+
+	if err := vaultfile.Write(path, passphrase, &vault); err != nil {
+		t.Fatal(err)
+	}
+
+	// Metadata must not be readable without the passphrase
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(content), "github") {
+		t.Error("vault file contains plaintext metadata")
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected permissions 0600, got %o", info.Mode().Perm())
+	}
+
+	recovered, err := vaultfile.Read(path, passphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(recovered.Secrets) != 1 || recovered.Secrets[0].Key != "github-token" {
+		t.Errorf("unexpected secrets %v", recovered.Secrets)
+	}
+
+	// No temporary files are left behind
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 {
+		t.Errorf("expected only the vault file, found %d entries", len(entries))
+	}
+}
+
+func TestReadWrongPassphrase(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vault.myst")
+
+	if err := vaultfile.Write(path, passphrase, &vaultfile.Vault{}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := vaultfile.Read(path, "wrong passphrase")
+	if !errors.Is(err, vaultfile.ErrInvalidVault) {
+		t.Errorf("expected ErrInvalidVault, got %v", err)
+	}
+}
+
+func TestReadMissingFile(t *testing.T) {
+	_, err := vaultfile.Read(filepath.Join(t.TempDir(), "missing.myst"), passphrase)
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected os.ErrNotExist, got %v", err)
+	}
+}
@@ -0,0 +1,29 @@
+/*
+Package vault reads and writes MyST secrets from other Go programs.
+
+A vault is either a MyST data directory, such as the one the myst command
+keeps in ~/myst, or a single encrypted vault file. Open it with an
+[Unlocker] that supplies the master passphrase:
+
+	v, err := vault.Open(vault.DefaultPath(), vault.Passphrase(os.Getenv("MYST_PASSPHRASE")))
+	if err != nil {
+		return err
+	}
+	defer v.Close()
+
+	secret, err := v.Get(ctx, "github-token")
+
+Errors can be told apart with [errors.Is] against the sentinel errors of
+this package, such as [ErrNotFound] and [ErrWrongPassphrase].
+
+# Compatibility
+
+This package follows semantic versioning. Within a major version of the
+module, exported names are neither removed nor changed in a way that breaks
+callers, and the meaning of the sentinel errors stays the same. New fields,
+methods and errors may be added in minor versions.
+
+The packages under internal/ carry no such promise; this package is the
+only supported way to use MyST as a library.
+*/
+package vault
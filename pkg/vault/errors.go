@@ -0,0 +1,71 @@
+package vault
+
+import (
+	"errors"
+
+	"github.com/Isaac-Fate/myst/internal/models"
+	"github.com/Isaac-Fate/myst/internal/search"
+	"github.com/Isaac-Fate/myst/internal/vaultfile"
+)
+
+var (
+	// Returned when no secret has the key.
+	ErrNotFound = errors.New("vault: secret not found")
+
+	// Returned when the key is held by a secret in the trash. The key stays
+	// taken until the secret is purged with 'myst trash purge'.
+	ErrInTrash = errors.New("vault: secret with this key is in the trash")
+
+	// Returned when the passphrase does not unlock the vault.
+	ErrWrongPassphrase = errors.New("vault: wrong passphrase")
+
+	// Returned when a data directory has not been set up with the myst
+	// command yet.
+	ErrNotInitialized = errors.New("vault: data directory is not initialized")
+
+	// Returned when a search query cannot be parsed.
+	ErrInvalidQuery = errors.New("vault: invalid query")
+
+	// Returned when a secret is missing its key or value, or has an
+	// invalid tag.
+	ErrInvalidSecret = errors.New("vault: invalid secret")
+
+	// Returned when the vault is used after it was closed.
+	ErrClosed = errors.New("vault: vault is closed")
+)
+
+// Translates the errors of the internal packages into the errors of this
+// package, keeping the original message.
+func translate(err error) error {
+	var sentinel error
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, models.ErrSecretNotFound):
+		sentinel = ErrNotFound
+	case errors.Is(err, models.ErrKeyInTrash):
+		sentinel = ErrInTrash
+	case errors.Is(err, search.ErrInvalidQuery):
+		sentinel = ErrInvalidQuery
+	case errors.Is(err, vaultfile.ErrInvalidVault):
+		sentinel = ErrWrongPassphrase
+	default:
+		return err
+	}
+
+	return &wrappedError{sentinel: sentinel, err: err}
+}
+
+// An internal error matching one of the sentinel errors.
+type wrappedError struct {
+	sentinel error
+	err      error
+}
+
+func (e *wrappedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *wrappedError) Unwrap() []error {
+	return []error{e.sentinel, e.err}
+}
@@ -0,0 +1,108 @@
+package vault_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/Isaac-Fate/myst/pkg/vault"
+)
+
+func Example() {
+	dir, err := os.MkdirTemp("", "myst-example")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+
+	// A vault file is created if it does not exist
+	v, err := vault.Open(filepath.Join(dir, "vault.myst"), vault.Passphrase("hello, world"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer v.Close()
+
+	err = v.Put(ctx, vault.Secret{Key: "github-token", Value: "ghp_123", Folder: "work", Tags: []string{"ci"}})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	secret, err := v.Get(ctx, "github-token")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println(secret.Key, secret.Value, secret.Folder)
+	// Output: github-token ghp_123 work
+}
+
+func ExampleVault_Search() {
+	dir, err := os.MkdirTemp("", "myst-example")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+
+	v, err := vault.Open(filepath.Join(dir, "vault.myst"), vault.Passphrase("hello, world"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer v.Close()
+
+	for _, secret := range []vault.Secret{
+		{Key: "prod-db", Value: "x", Folder: "prod"},
+		{Key: "dev-db", Value: "x", Folder: "dev"},
+		{Key: "prod-api", Value: "x", Folder: "prod"},
+	} {
+		if err := v.Put(ctx, secret); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Search results leave out the values
+	secrets, err := v.Search(ctx, "folder:prod db")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for _, secret := range secrets {
+		fmt.Println(secret.Key)
+	}
+	// Output: prod-db
+}
+
+func ExampleVault_Delete() {
+	dir, err := os.MkdirTemp("", "myst-example")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+
+	v, err := vault.Open(filepath.Join(dir, "vault.myst"), vault.Passphrase("hello, world"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer v.Close()
+
+	if err := v.Put(ctx, vault.Secret{Key: "old-token", Value: "x"}); err != nil {
+		log.Fatal(err)
+	}
+
+	if err := v.Delete(ctx, "old-token"); err != nil {
+		log.Fatal(err)
+	}
+
+	// Typed errors can be checked with errors.Is
+	_, err = v.Get(ctx, "old-token")
+	fmt.Println(errors.Is(err, vault.ErrNotFound))
+	// Output: true
+}
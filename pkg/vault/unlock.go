@@ -0,0 +1,39 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+// Supplies the master passphrase when a vault is opened.
+type Unlocker interface {
+	Passphrase(ctx context.Context) (string, error)
+}
+
+// Adapts a function to an Unlocker, such as one that prompts the user.
+type UnlockerFunc func(ctx context.Context) (string, error)
+
+func (fn UnlockerFunc) Passphrase(ctx context.Context) (string, error) {
+	return fn(ctx)
+}
+
+// Returns an Unlocker that always supplies the given passphrase.
+func Passphrase(passphrase string) Unlocker {
+	return UnlockerFunc(func(ctx context.Context) (string, error) {
+		return passphrase, nil
+	})
+}
+
+// Returns an Unlocker that reads the passphrase from an environment
+// variable, failing if it is unset or empty.
+func PassphraseFromEnv(name string) Unlocker {
+	return UnlockerFunc(func(ctx context.Context) (string, error) {
+		passphrase := os.Getenv(name)
+		if passphrase == "" {
+			return "", fmt.Errorf("vault: environment variable %s is not set", name)
+		}
+
+		return passphrase, nil
+	})
+}
@@ -0,0 +1,356 @@
+package vault
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Isaac-Fate/myst/internal/audit"
+	"github.com/Isaac-Fate/myst/internal/config"
+	mycrypto "github.com/Isaac-Fate/myst/internal/crypto"
+	"github.com/Isaac-Fate/myst/internal/manager"
+	"github.com/Isaac-Fate/myst/internal/models"
+	"github.com/Isaac-Fate/myst/internal/vaultfile"
+)
+
+// Details recorded in the audit log for the accesses made through this
+// package, to tell them apart from those made with the myst command.
+const auditDetails string = "pkg/vault"
+
+// A secret and its details.
+type Secret struct {
+	Key string
+
+	// The secret value. It is only filled in by Get, and required by Put.
+	Value string
+
+	Username string
+	Website  string
+	Notes    string
+	Folder   string
+
+	// Labels for finding secrets together, kept sorted
+	Tags []string
+
+	// When the value stops working, if it does
+	ExpiresAt *time.Time
+
+	// How often the value should be replaced, or zero if it need not be
+	RotateEvery time.Duration
+
+	// Set by the vault
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// An open vault. It is safe for concurrent use.
+//
+// Operations are local and quick, so a context is only checked before an
+// operation starts; one that is already done fails with its error.
+type Vault struct {
+	mu         sync.Mutex
+	manager    *manager.SecretManager
+	passphrase string
+}
+
+// Returns the path of the data directory the myst command uses.
+func DefaultPath() string {
+	dataDir, err := config.DefaultDataDir()
+	if err != nil {
+		return ""
+	}
+
+	return dataDir
+}
+
+// Opens the vault at path, a MyST data directory or a vault file.
+//
+// A data directory must have been set up with the myst command, while a
+// vault file is created if it does not exist.
+func Open(path string, unlocker Unlocker) (*Vault, error) {
+	return OpenContext(context.Background(), path, unlocker)
+}
+
+// Opens the vault at path like Open, passing the context to the unlocker.
+func OpenContext(ctx context.Context, path string, unlocker Unlocker) (*Vault, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	passphrase, err := unlocker.Passphrase(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	var secretManager *manager.SecretManager
+	if err == nil && info.IsDir() {
+		secretManager, err = openDataDir(path, passphrase)
+	} else {
+		secretManager, err = manager.NewFileSecretManager(path, passphrase)
+	}
+	if err != nil {
+		return nil, translate(err)
+	}
+
+	return &Vault{manager: secretManager, passphrase: passphrase}, nil
+}
+
+// Opens the secret manager of a data directory with its configured backend.
+func openDataDir(dir string, passphrase string) (*manager.SecretManager, error) {
+	var cfg config.Config
+	err := config.LoadConfigFile(filepath.Join(dir, config.ConfigFileName), &cfg)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotInitialized, dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !cfg.VerifyPassphrase(passphrase) {
+		return nil, ErrWrongPassphrase
+	}
+
+	var secretManager *manager.SecretManager
+	switch backend := cfg.StorageBackend(); backend {
+	case config.SQLiteBackend:
+		secretManager, err = manager.NewSecretManager(
+			filepath.Join(dir, config.SecretStoreFileName),
+			filepath.Join(dir, config.SecretIndexDirName),
+		)
+	case config.FileBackend:
+		secretManager, err = manager.NewFileSecretManager(filepath.Join(dir, config.VaultFileName), passphrase)
+	default:
+		err = fmt.Errorf("unknown storage backend '%s'", backend)
+	}
+
+	// Finish a change of passphrase the myst command was interrupted in,
+	// now that it is known which passphrase the vault is encrypted with
+	if cfg.ChangingPassphrase() {
+		opens := err == nil
+		if opens {
+			opens, err = secretManager.OpensWith(passphrase)
+		}
+		if err != nil && !errors.Is(err, vaultfile.ErrInvalidVault) {
+			return nil, err
+		}
+
+		if finishErr := cfg.FinishPassphraseChange(dir, passphrase, opens); finishErr != nil {
+			return nil, finishErr
+		}
+		if !opens {
+			if secretManager != nil {
+				secretManager.Close()
+			}
+			return nil, ErrWrongPassphrase
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	auditKey, err := cfg.AuditKey(dir, passphrase)
+	if err != nil {
+		secretManager.Close()
+		return nil, err
+	}
+
+	secretManager.SetAuditLog(audit.Open(filepath.Join(dir, config.AuditLogFileName), auditKey))
+	return secretManager, nil
+}
+
+// Closes the vault. Using it afterwards fails with ErrClosed.
+func (vault *Vault) Close() error {
+	vault.mu.Lock()
+	defer vault.mu.Unlock()
+
+	if vault.manager == nil {
+		return nil
+	}
+
+	err := vault.manager.Close()
+	vault.manager = nil
+
+	return err
+}
+
+// Locks the vault for an operation, checking it is still open and the
+// context is not done. The caller must unlock the vault afterwards.
+func (vault *Vault) begin(ctx context.Context) error {
+	vault.mu.Lock()
+
+	if vault.manager == nil {
+		vault.mu.Unlock()
+		return ErrClosed
+	}
+
+	if err := ctx.Err(); err != nil {
+		vault.mu.Unlock()
+		return err
+	}
+
+	return nil
+}
+
+// Gets the secret with the key, including its value.
+//
+// Reading a value is recorded in the audit log of a data directory.
+func (vault *Vault) Get(ctx context.Context, key string) (*Secret, error) {
+	if err := vault.begin(ctx); err != nil {
+		return nil, err
+	}
+	defer vault.mu.Unlock()
+
+	secret, err := vault.manager.GetSecretByKey(key, manager.MatchExactKey)
+	if err != nil {
+		return nil, translate(err)
+	}
+
+	// Record the access before handing out the value
+	if err := vault.manager.RecordAccess(audit.ActionReveal, secret, auditDetails); err != nil {
+		return nil, err
+	}
+
+	value, err := mycrypto.Decrypt(vault.passphrase, secret.EncryptedValue)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt secret value: %w", err)
+	}
+
+	result := fromModel(secret)
+	result.Value = value
+
+	return &result, nil
+}
+
+// Stores the secret under its key, replacing the value and details of the
+// secret with the key if there is one.
+func (vault *Vault) Put(ctx context.Context, secret Secret) error {
+	if secret.Key == "" {
+		return fmt.Errorf("%w: key cannot be empty", ErrInvalidSecret)
+	}
+	if secret.Value == "" {
+		return fmt.Errorf("%w: value cannot be empty", ErrInvalidSecret)
+	}
+	for _, tag := range secret.Tags {
+		if err := models.ValidateTag(tag); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidSecret, err)
+		}
+	}
+
+	if err := vault.begin(ctx); err != nil {
+		return err
+	}
+	defer vault.mu.Unlock()
+
+	existing, err := vault.manager.GetSecretByKey(secret.Key, manager.MatchExactKey)
+	if err != nil && !errors.Is(err, models.ErrSecretNotFound) {
+		return translate(err)
+	}
+
+	model := existing
+	if model == nil {
+		model = &models.Secret{Key: secret.Key}
+	}
+
+	// Leave an unchanged value as it is, so that the secret does not look
+	// rotated
+	if current, err := mycrypto.Decrypt(vault.passphrase, model.EncryptedValue); err != nil || current != secret.Value {
+		model.EncryptedValue, err = mycrypto.Encrypt(vault.passphrase, secret.Value)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt secret value: %w", err)
+		}
+	}
+	model.Username = secret.Username
+	model.Website = secret.Website
+	model.Notes = secret.Notes
+	model.Folder = secret.Folder
+	model.ExpiresAt = secret.ExpiresAt
+	model.RotateEvery = secret.RotateEvery
+
+	model.Tags = nil
+	for _, tag := range secret.Tags {
+		model.AddTag(tag)
+	}
+
+	if existing == nil {
+		err = vault.manager.AddSecret(model)
+	} else {
+		err = vault.manager.UpdateSecret(model)
+	}
+
+	return translate(err)
+}
+
+// Finds the secrets matching the query, best first, without their values.
+//
+// The query uses the same syntax as 'myst find', such as
+// "folder:work tag:ci". An empty query lists every secret by key.
+func (vault *Vault) Search(ctx context.Context, query string) ([]Secret, error) {
+	if err := vault.begin(ctx); err != nil {
+		return nil, err
+	}
+	defer vault.mu.Unlock()
+
+	var secrets []models.Secret
+	var err error
+	if strings.TrimSpace(query) == "" {
+		secrets, err = vault.manager.ListSecrets()
+		slices.SortFunc(secrets, func(a, b models.Secret) int {
+			return strings.Compare(a.Key, b.Key)
+		})
+	} else {
+		secrets, err = vault.manager.FindSecrets(query)
+	}
+	if err != nil {
+		return nil, translate(err)
+	}
+
+	results := make([]Secret, len(secrets))
+	for i := range secrets {
+		results[i] = fromModel(&secrets[i])
+	}
+
+	return results, nil
+}
+
+// Moves the secret with the key to the trash, from where the myst command
+// can restore it.
+func (vault *Vault) Delete(ctx context.Context, key string) error {
+	if err := vault.begin(ctx); err != nil {
+		return err
+	}
+	defer vault.mu.Unlock()
+
+	secret, err := vault.manager.GetSecretByKey(key, manager.MatchExactKey)
+	if err != nil {
+		return translate(err)
+	}
+
+	return translate(vault.manager.RemoveSecret(secret))
+}
+
+// Copies the details of a secret, leaving out its value.
+func fromModel(secret *models.Secret) Secret {
+	return Secret{
+		Key:         secret.Key,
+		Username:    secret.Username,
+		Website:     secret.Website,
+		Notes:       secret.Notes,
+		Folder:      secret.Folder,
+		Tags:        slices.Clone(secret.Tags),
+		ExpiresAt:   secret.ExpiresAt,
+		RotateEvery: secret.RotateEvery,
+		CreatedAt:   secret.CreatedAt,
+		UpdatedAt:   secret.UpdatedAt,
+	}
+}
@@ -0,0 +1,281 @@
+package vault_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Isaac-Fate/myst/internal/audit"
+	"github.com/Isaac-Fate/myst/internal/config"
+	mycrypto "github.com/Isaac-Fate/myst/internal/crypto"
+	"github.com/Isaac-Fate/myst/internal/manager"
+	"github.com/Isaac-Fate/myst/pkg/vault"
+	"gopkg.in/yaml.v3"
+)
+
+const testPassphrase string = "hello, world"
+
+// Sets up a data directory like the myst command does.
+func createDataDir(t *testing.T, backend string) string {
+	dir := t.TempDir()
+
+	content, err := yaml.Marshal(&config.Config{
+		DigestedPassphrase: mycrypto.DigestPassphrase(testPassphrase),
+		Backend:            backend,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, config.ConfigFileName), content, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return dir
+}
+
+func openVault(t *testing.T, path string) *vault.Vault {
+	v, err := vault.Open(path, vault.Passphrase(testPassphrase))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { v.Close() })
+
+	return v
+}
+
+func TestDataDirOnEachBackend(t *testing.T) {
+	for _, backend := range []string{config.SQLiteBackend, config.FileBackend} {
+		t.Run(backend, func(t *testing.T) {
+			dir := createDataDir(t, backend)
+			ctx := context.Background()
+
+			v := openVault(t, dir)
+
+			if err := v.Put(ctx, vault.Secret{Key: "api-key", Value: "abc", Tags: []string{"prod", "ci"}}); err != nil {
+				t.Fatal(err)
+			}
+
+			created, err := v.Get(ctx, "api-key")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if created.Value != "abc" || len(created.Tags) != 2 || created.Tags[0] != "ci" {
+				t.Errorf("expected the stored secret with sorted tags, got %+v", created)
+			}
+
+			// Putting an existing key replaces the secret
+			if err := v.Put(ctx, vault.Secret{Key: "api-key", Value: "def", Notes: "rotated"}); err != nil {
+				t.Fatal(err)
+			}
+
+			updated, err := v.Get(ctx, "api-key")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if updated.Value != "def" || updated.Notes != "rotated" || len(updated.Tags) != 0 || !updated.CreatedAt.Equal(created.CreatedAt) {
+				t.Errorf("expected the replaced secret, got %+v", updated)
+			}
+
+			if err := v.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			// Reads and changes are in the audit log of the data directory,
+			// chained with the key of its configuration
+			var cfg config.Config
+			if err := config.LoadConfigFile(filepath.Join(dir, config.ConfigFileName), &cfg); err != nil {
+				t.Fatal(err)
+			}
+			auditKey, err := cfg.AuditKey(dir, testPassphrase)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			auditLog := audit.Open(filepath.Join(dir, config.AuditLogFileName), auditKey)
+			if _, err := auditLog.Verify(); err != nil {
+				t.Fatal(err)
+			}
+
+			events, err := auditLog.Events()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			reveals := 0
+			for _, event := range events {
+				if event.Action == audit.ActionReveal {
+					reveals++
+				}
+			}
+			if len(events) != 4 || reveals != 2 {
+				t.Errorf("expected 2 changes and 2 reveals in the audit log, got %+v", events)
+			}
+
+			// The secrets are there when the directory is opened again
+			v = openVault(t, dir)
+			if secrets, err := v.Search(ctx, ""); err != nil || len(secrets) != 1 || secrets[0].Value != "" {
+				t.Errorf("expected the secret without its value, got %+v, %v", secrets, err)
+			}
+		})
+	}
+}
+
+func TestErrors(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "vault.myst")
+
+	v := openVault(t, path)
+
+	if _, err := v.Get(ctx, "missing"); !errors.Is(err, vault.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	if err := v.Delete(ctx, "missing"); !errors.Is(err, vault.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	if err := v.Put(ctx, vault.Secret{Key: "empty"}); !errors.Is(err, vault.ErrInvalidSecret) {
+		t.Errorf("expected ErrInvalidSecret for an empty value, got %v", err)
+	}
+
+	if err := v.Put(ctx, vault.Secret{Key: "bad-tag", Value: "x", Tags: []string{"two words"}}); !errors.Is(err, vault.ErrInvalidSecret) {
+		t.Errorf("expected ErrInvalidSecret for an invalid tag, got %v", err)
+	}
+
+	if _, err := v.Search(ctx, "key:("); !errors.Is(err, vault.ErrInvalidQuery) {
+		t.Errorf("expected ErrInvalidQuery, got %v", err)
+	}
+
+	// The key of a secret in the trash stays taken
+	if err := v.Put(ctx, vault.Secret{Key: "old", Value: "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Delete(ctx, "old"); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.Put(ctx, vault.Secret{Key: "old", Value: "y"}); !errors.Is(err, vault.ErrInTrash) {
+		t.Errorf("expected ErrInTrash, got %v", err)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := v.Get(canceled, "old"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the context error, got %v", err)
+	}
+
+	v.Close()
+	if _, err := v.Search(ctx, ""); !errors.Is(err, vault.ErrClosed) {
+		t.Errorf("expected ErrClosed, got %v", err)
+	}
+
+	// A wrong passphrase is rejected for vault files and data directories
+	if _, err := vault.Open(path, vault.Passphrase("wrong")); !errors.Is(err, vault.ErrWrongPassphrase) {
+		t.Errorf("expected ErrWrongPassphrase for a vault file, got %v", err)
+	}
+
+	dir := createDataDir(t, config.SQLiteBackend)
+	if _, err := vault.Open(dir, vault.Passphrase("wrong")); !errors.Is(err, vault.ErrWrongPassphrase) {
+		t.Errorf("expected ErrWrongPassphrase for a data directory, got %v", err)
+	}
+
+	if _, err := vault.Open(t.TempDir(), vault.Passphrase(testPassphrase)); !errors.Is(err, vault.ErrNotInitialized) {
+		t.Errorf("expected ErrNotInitialized, got %v", err)
+	}
+
+	t.Setenv("MYST_TEST_PASSPHRASE", "")
+	if _, err := vault.Open(path, vault.PassphraseFromEnv("MYST_TEST_PASSPHRASE")); err == nil {
+		t.Error("expected an error for an unset passphrase variable")
+	}
+}
+
+func TestInterruptedPassphraseChange(t *testing.T) {
+	const newPassphrase = "goodbye, world"
+	ctx := context.Background()
+
+	// Re-encrypts the secrets of a data directory as the myst command does
+	rekey := func(t *testing.T, dir string, backend string) {
+		var secretManager *manager.SecretManager
+		var err error
+		if backend == config.FileBackend {
+			secretManager, err = manager.NewFileSecretManager(filepath.Join(dir, config.VaultFileName), testPassphrase)
+		} else {
+			secretManager, err = manager.NewSecretManager(filepath.Join(dir, config.SecretStoreFileName), filepath.Join(dir, config.SecretIndexDirName))
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer secretManager.Close()
+
+		if err := secretManager.ChangePassphrase(testPassphrase, newPassphrase); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, backend := range []string{config.SQLiteBackend, config.FileBackend} {
+		for _, rekeyed := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/rekeyed=%v", backend, rekeyed), func(t *testing.T) {
+				dir := createDataDir(t, backend)
+
+				v := openVault(t, dir)
+				if err := v.Put(ctx, vault.Secret{Key: "api-key", Value: "abc"}); err != nil {
+					t.Fatal(err)
+				}
+				v.Close()
+
+				// The change was interrupted before or after the secrets
+				// were re-encrypted
+				var cfg config.Config
+				if err := config.LoadConfigFile(filepath.Join(dir, config.ConfigFileName), &cfg); err != nil {
+					t.Fatal(err)
+				}
+				auditKey, err := cfg.AuditKey(dir, testPassphrase)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := cfg.BeginPassphraseChange(newPassphrase, "", auditKey); err != nil {
+					t.Fatal(err)
+				}
+				if err := config.SaveFile(filepath.Join(dir, config.ConfigFileName), &cfg); err != nil {
+					t.Fatal(err)
+				}
+
+				if rekeyed {
+					rekey(t, dir, backend)
+				}
+
+				// The passphrase the vault is not encrypted with is rejected,
+				// which finishes the change either way
+				current, other := testPassphrase, newPassphrase
+				if rekeyed {
+					current, other = newPassphrase, testPassphrase
+				}
+
+				if _, err := vault.Open(dir, vault.Passphrase(other)); !errors.Is(err, vault.ErrWrongPassphrase) {
+					t.Fatalf("expected ErrWrongPassphrase for the other passphrase, got %v", err)
+				}
+
+				cfg = config.Config{}
+				if err := config.LoadConfigFile(filepath.Join(dir, config.ConfigFileName), &cfg); err != nil {
+					t.Fatal(err)
+				}
+				if cfg.ChangingPassphrase() || !mycrypto.VerifyPassphrase(current, cfg.DigestedPassphrase) {
+					t.Errorf("expected the change to be finished with the passphrase of the vault, got %+v", cfg)
+				}
+
+				v, err = vault.Open(dir, vault.Passphrase(current))
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer v.Close()
+
+				if secret, err := v.Get(ctx, "api-key"); err != nil || secret.Value != "abc" {
+					t.Errorf("expected the secret, got %+v, %v", secret, err)
+				}
+			})
+		}
+	}
+}